@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// Listing limits for the deepseek_allowed_paths tool
+const (
+	defaultAllowedPathsDepth   = 1
+	defaultAllowedPathsEntries = 50
+)
+
+// allowedPathProblems describes each allowed file path that does not exist or is not a directory
+func allowedPathProblems(paths []string) []string {
+	var problems []string
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			problems = append(problems, fmt.Sprintf("%s does not exist", root))
+		case err != nil:
+			problems = append(problems, fmt.Sprintf("%s is not accessible (%v)", root, err))
+		case !info.IsDir():
+			problems = append(problems, fmt.Sprintf("%s is not a directory", root))
+		}
+	}
+	return problems
+}
+
+// checkAllowedFilePaths warns about allowed file paths that are not directories, which usually
+// means a typo. With StrictAllowedPaths they are an error instead.
+func (c *Config) checkAllowedFilePaths(logger Logger) error {
+	if c.DisableFileAccess {
+		return nil
+	}
+	problems := allowedPathProblems(c.AllowedFilePaths)
+	if len(problems) == 0 {
+		return nil
+	}
+	if c.StrictAllowedPaths {
+		return fmt.Errorf("invalid DEEPSEEK_ALLOWED_FILE_PATHS: %s", strings.Join(problems, "; "))
+	}
+	for _, problem := range problems {
+		logger.Warn("DEEPSEEK_ALLOWED_FILE_PATHS entry %s; check it for a typo, as files meant to be allowed under it will be rejected", problem)
+	}
+	return nil
+}
+
+// handleDeepseekAllowedPaths handles requests to the deepseek_allowed_paths tool
+func (s *DeepseekServer) handleDeepseekAllowedPaths(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.logger.Info("Listing allowed file roots")
+
+	depth := req.GetInt("depth", defaultAllowedPathsDepth)
+	if depth < 1 || depth > maxTreeDepth {
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid depth: %d. It must be between 1 and %d", depth, maxTreeDepth)), nil
+	}
+	maxEntries := req.GetInt("max_entries", defaultAllowedPathsEntries)
+	if maxEntries < 1 || maxEntries > maxTreeEntries {
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid max_entries: %d. It must be between 1 and %d", maxEntries, maxTreeEntries)), nil
+	}
+
+	var formattedContent strings.Builder
+	formattedContent.WriteString("# Allowed File Roots\n\n")
+
+	if s.config().DisableFileAccess {
+		formattedContent.WriteString("*File access is disabled on this server, so no files can be attached.*\n")
+		return mcp.NewToolResultText(formattedContent.String()), nil
+	}
+	if len(s.config().AllowedFilePaths) == 0 {
+		formattedContent.WriteString("*No allowed roots are configured, so files can be attached from any path.*\n")
+		return mcp.NewToolResultText(formattedContent.String()), nil
+	}
+
+	formattedContent.WriteString("Files can be attached from these directories and their subdirectories. ")
+	formattedContent.WriteString("Listings respect .gitignore and only show files of allowed types.\n\n")
+
+	for _, root := range s.config().AllowedFilePaths {
+		formattedContent.WriteString(fmt.Sprintf("## %s\n\n", root))
+
+		info, err := os.Stat(root)
+		switch {
+		case err != nil:
+			formattedContent.WriteString(fmt.Sprintf("**Status:** not accessible (%v)\n\n", err))
+			continue
+		case !info.IsDir():
+			formattedContent.WriteString("**Status:** not a directory\n\n")
+			continue
+		}
+		if _, err := os.ReadDir(root); err != nil {
+			formattedContent.WriteString(fmt.Sprintf("**Status:** not readable (%v)\n\n", err))
+			continue
+		}
+		formattedContent.WriteString("**Status:** readable\n\n")
+
+		tree, err := buildDirectoryTree(root, depth, maxEntries, s.config())
+		if err != nil {
+			s.logger.Warn("Failed to list allowed root %s: %v", root, err)
+			continue
+		}
+		formattedContent.WriteString("```\n" + tree + "```\n\n")
+	}
+
+	return mcp.NewToolResultText(formattedContent.String()), nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckAllowedFilePaths(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("notes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing")
+
+	tests := []struct {
+		name     string
+		cfg      Config
+		wantErr  string
+		wantWarn []string
+	}{
+		{name: "existing directory", cfg: Config{AllowedFilePaths: []string{dir}}},
+		{name: "missing root", cfg: Config{AllowedFilePaths: []string{dir, missing}}, wantWarn: []string{missing + " does not exist"}},
+		{name: "file root", cfg: Config{AllowedFilePaths: []string{file}}, wantWarn: []string{file + " is not a directory"}},
+		{name: "every bad root", cfg: Config{AllowedFilePaths: []string{missing, file}},
+			wantWarn: []string{missing + " does not exist", file + " is not a directory"}},
+		{name: "strict", cfg: Config{AllowedFilePaths: []string{dir, missing, file}, StrictAllowedPaths: true},
+			wantErr: missing + " does not exist; " + file + " is not a directory"},
+		{name: "file access disabled", cfg: Config{AllowedFilePaths: []string{missing}, StrictAllowedPaths: true, DisableFileAccess: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+			err := tt.cfg.checkAllowedFilePaths(logger)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("checkAllowedFilePaths() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkAllowedFilePaths() error = %v", err)
+			}
+			if len(logger.messages) != len(tt.wantWarn) {
+				t.Errorf("logged %q, want %d warnings", logger.messages, len(tt.wantWarn))
+			}
+			for _, warning := range tt.wantWarn {
+				if !logger.contains(warning) {
+					t.Errorf("no warning containing %q in %q", warning, logger.messages)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// anonymizedLabelRe matches a file label in a response, followed by any further dotted
+// segments, e.g. "file2.go" or "file2.go.Close"
+var anonymizedLabelRe = regexp.MustCompile(`\bfile\d+(?:\.[A-Za-z0-9_]+)*\b`)
+
+// pathAnonymizer replaces file paths in the prompt with opaque labels such as "file1.go",
+// keeping the mapping locally so that labels in the response can be turned back into paths.
+// Only the file extension is kept, as it tells the model the language of the file.
+type pathAnonymizer struct {
+	labels map[string]string // Path -> label
+	paths  map[string]string // Label -> path
+	order  []string          // Labels in the order they were assigned
+}
+
+// newPathAnonymizer creates an anonymizer without any labels
+func newPathAnonymizer() *pathAnonymizer {
+	return &pathAnonymizer{labels: make(map[string]string), paths: make(map[string]string)}
+}
+
+// Label returns the label of a path, assigning the next free one on first use
+func (a *pathAnonymizer) Label(path string) string {
+	if label, ok := a.labels[path]; ok {
+		return label
+	}
+	label := fmt.Sprintf("file%d%s", len(a.order)+1, strings.ToLower(filepath.Ext(path)))
+	a.labels[path], a.paths[label] = label, path
+	a.order = append(a.order, label)
+	return label
+}
+
+// Restore replaces the labels in text with the paths they stand for. A nil anonymizer
+// returns text unchanged.
+func (a *pathAnonymizer) Restore(text string) string {
+	if a == nil || len(a.paths) == 0 {
+		return text
+	}
+	return anonymizedLabelRe.ReplaceAllStringFunc(text, func(match string) string {
+		// Drop trailing segments until a label matches, e.g. a method called on the file name
+		for candidate := match; ; {
+			if path, ok := a.paths[candidate]; ok {
+				return path + match[len(candidate):]
+			}
+			dot := strings.LastIndexByte(candidate, '.')
+			if dot < 0 {
+				return match
+			}
+			candidate = candidate[:dot]
+		}
+	})
+}
+
+// Mapping returns the label of each path, for logging
+func (a *pathAnonymizer) Mapping() string {
+	entries := make([]string, 0, len(a.order))
+	for _, label := range a.order {
+		entries = append(entries, label+" = "+a.paths[label])
+	}
+	return strings.Join(entries, ", ")
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestPathAnonymizerRoundTrip(t *testing.T) {
+	a := newPathAnonymizer()
+	paths := []string{"/srv/acme-payments/api/server.go", "/srv/acme-payments/api/Handler.GO", "/srv/acme-payments/Makefile"}
+	for i, want := range []string{"file1.go", "file2.go", "file3"} {
+		if got := a.Label(paths[i]); got != want {
+			t.Errorf("Label(%s) = %q, want %q", paths[i], got, want)
+		}
+	}
+	if got := a.Label(paths[0]); got != "file1.go" {
+		t.Errorf("second Label(%s) = %q, want file1.go", paths[0], got)
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"label", "See file1.go.", "See /srv/acme-payments/api/server.go."},
+		{"label without extension", "Edit file3 first", "Edit /srv/acme-payments/Makefile first"},
+		{"line citation", "file2.go:42 panics", "/srv/acme-payments/api/Handler.GO:42 panics"},
+		{"trailing segment", "file1.go.Close is never called", "/srv/acme-payments/api/server.go.Close is never called"},
+		{"unknown label", "file9.go and profile1.go", "file9.go and profile1.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := a.Restore(tt.text); got != tt.want {
+				t.Errorf("Restore(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+	if got := (*pathAnonymizer)(nil).Restore("file1.go"); got != "file1.go" {
+		t.Errorf("nil Restore() = %q, want the text unchanged", got)
+	}
+}
+
+func TestAskAnonymizePaths(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "acme-payments")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "server.go")
+	if err := os.WriteFile(path, []byte("package server\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+		return textResponse("The bug is in file1.go:1."), nil
+	}}
+	s := newTestServer(t, client, map[string]string{"DEEPSEEK_ALLOWED_FILE_PATHS": dir})
+	result := callTool(t, s.handleAskDeepseek, map[string]any{"query": "review", "file_paths": []any{path}, "anonymize_paths": true})
+	if result.IsError {
+		t.Fatalf("request failed: %s", resultText(result))
+	}
+	for _, message := range client.requests[0].Messages {
+		if strings.Contains(message.Content, "acme-payments") {
+			t.Errorf("prompt reveals the path:\n%s", message.Content)
+		}
+	}
+	if text := resultText(result); !strings.Contains(text, path+":1") {
+		t.Errorf("response = %q, want the label restored to %s", text, path)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// balanceCheckInterval is how long a balance fetched for the minimum balance guard is reused
+const balanceCheckInterval = 5 * time.Minute
+
+// errInsufficientBalance is returned without calling the API while the account balance is below
+// DEEPSEEK_MIN_BALANCE. Its message must not look like a timeout or network error, so it is not retried.
+var errInsufficientBalance = errors.New("insufficient balance")
+
+// balanceGuard caches the account balance so that completion requests can be refused while it
+// is below the configured minimum, without fetching the balance for every request
+type balanceGuard struct {
+	mu  sync.Mutex
+	now func() time.Time
+
+	checkedAt  time.Time // When the balance was last fetched, zero if never
+	refreshing bool      // A balance fetch is in flight
+	summary    string    // Highest balance and its currency, e.g. "0.42 USD"
+	available  bool      // Whether the account can make API calls
+	balance    float64   // Highest total balance over all currencies
+	low        bool      // The balance was below the minimum at the last check
+}
+
+// newBalanceGuard creates a balance guard that fetches the balance on first use
+func newBalanceGuard() *balanceGuard {
+	return &balanceGuard{now: time.Now}
+}
+
+// checkBalance returns an error wrapping errInsufficientBalance if the account balance is below
+// DEEPSEEK_MIN_BALANCE. The balance is fetched at most every balanceCheckInterval, by one caller
+// at a time and without holding the guard's lock, so that other requests use the previous result
+// meanwhile; if it cannot be fetched, the result of the previous check stands, so an unreachable
+// balance endpoint does not block requests. A minimum of zero disables the guard.
+func (s *DeepseekServer) checkBalance(ctx context.Context) error {
+	cfg := s.configFrom(ctx)
+	minimum := cfg.MinBalance
+	if minimum <= 0 || s.balance == nil {
+		return nil
+	}
+
+	g := s.balance
+	if g.startRefresh() {
+		timeoutCtx, cancel := context.WithTimeout(ctx, s.attemptTimeout(ctx, cfg.HTTPTimeout))
+		response, err := s.client.GetBalance(timeoutCtx)
+		cancel()
+		if err != nil {
+			s.logger.Warn("Failed to check the balance against DEEPSEEK_MIN_BALANCE, keeping the previous result: %v", err)
+		}
+		g.mu.Lock()
+		g.refreshing = false
+		if err == nil {
+			g.record(response, minimum, s.logger)
+		}
+		g.mu.Unlock()
+	}
+
+	g.mu.Lock()
+	low, available, summary := g.low, g.available, g.summary
+	g.mu.Unlock()
+	if !low {
+		return nil
+	}
+	if !available {
+		return fmt.Errorf("%w: the DeepSeek account is not available for API calls; top it up at https://platform.deepseek.com", errInsufficientBalance)
+	}
+	return fmt.Errorf("%w: balance of %s is below the minimum of %.2f set by DEEPSEEK_MIN_BALANCE; top it up at https://platform.deepseek.com",
+		errInsufficientBalance, summary, minimum)
+}
+
+// startRefresh reports whether the balance is due to be fetched and no other fetch is in flight,
+// in which case the caller must fetch it and clear g.refreshing when done
+func (g *balanceGuard) startRefresh() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.refreshing || !g.checkedAt.IsZero() && g.now().Sub(g.checkedAt) < balanceCheckInterval {
+		return false
+	}
+	g.refreshing = true
+	g.checkedAt = g.now()
+	return true
+}
+
+// refreshBalanceGuard updates the minimum balance guard from a balance fetched by
+// deepseek_balance, so that a top-up is noticed without waiting for the next check
+func (s *DeepseekServer) refreshBalanceGuard(response *deepseek.BalanceResponse) {
+	minimum := s.config().MinBalance
+	if minimum <= 0 || s.balance == nil {
+		return
+	}
+	s.balance.mu.Lock()
+	defer s.balance.mu.Unlock()
+	s.balance.checkedAt = s.balance.now()
+	s.balance.record(response, minimum, s.logger)
+}
+
+// record updates the guard from a balance response, logging when the balance falls below the
+// minimum or recovers. Must be called with g.mu held.
+func (g *balanceGuard) record(response *deepseek.BalanceResponse, minimum float64, logger Logger) {
+	g.available = response.IsAvailable
+	g.balance, g.summary = highestBalance(response.BalanceInfos)
+
+	wasLow := g.low
+	g.low = !g.available || g.balance < minimum
+	switch {
+	case g.low && !wasLow:
+		logger.Warn("INSUFFICIENT BALANCE: %s is below DEEPSEEK_MIN_BALANCE (%.2f) or the account is unavailable; completion requests are refused until it is topped up",
+			g.summary, minimum)
+	case !g.low && wasLow:
+		logger.Info("Balance of %s is above DEEPSEEK_MIN_BALANCE again; completion requests are allowed", g.summary)
+	}
+}
+
+// highestBalance returns the highest total balance of the account and its summary with the
+// currency. Accounts normally hold a single currency; with several, the guard trips only when
+// all of them are low. Balances that do not parse are ignored.
+func highestBalance(infos []deepseek.BalanceInfo) (float64, string) {
+	highest, summary, found := 0.0, "0", false
+	for _, info := range infos {
+		total, err := strconv.ParseFloat(strings.TrimSpace(info.TotalBalance), 64)
+		if err != nil {
+			continue
+		}
+		if !found || total > highest {
+			found = true
+			highest = total
+			summary = strings.TrimSpace(info.TotalBalance + " " + info.Currency)
+		}
+	}
+	return highest, summary
+}
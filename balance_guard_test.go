@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// balanceResponse returns an available account holding balances "amount currency"
+func balanceResponse(balances ...string) *deepseek.BalanceResponse {
+	response := &deepseek.BalanceResponse{IsAvailable: true}
+	for _, balance := range balances {
+		amount, currency, _ := strings.Cut(balance, " ")
+		response.BalanceInfos = append(response.BalanceInfos, deepseek.BalanceInfo{TotalBalance: amount, Currency: currency})
+	}
+	return response
+}
+
+func TestCheckBalance(t *testing.T) {
+	unavailable := balanceResponse("50.00 USD")
+	unavailable.IsAvailable = false
+
+	tests := []struct {
+		name      string
+		minimum   string
+		balance   *deepseek.BalanceResponse
+		err       error
+		wantCalls int
+		wantErr   string
+	}{
+		{name: "above the threshold", minimum: "1", balance: balanceResponse("12.50 USD"), wantCalls: 1},
+		{name: "at the threshold", minimum: "1", balance: balanceResponse("1.00 USD"), wantCalls: 1},
+		{name: "below the threshold", minimum: "1", balance: balanceResponse("0.42 USD"), wantCalls: 1,
+			wantErr: "balance of 0.42 USD is below the minimum of 1.00"},
+		{name: "one currency above the threshold", minimum: "5", balance: balanceResponse("0.10 USD", "30.00 CNY"), wantCalls: 1},
+		{name: "account unavailable", minimum: "1", balance: unavailable, wantCalls: 1, wantErr: "not available for API calls"},
+		{name: "balance unknown", minimum: "1", err: errors.New("connection refused"), wantCalls: 1},
+		{name: "guard disabled", minimum: "0", balance: balanceResponse("0.00 USD"), wantCalls: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{balance: tt.balance, balanceErr: tt.err}
+			s := newTestServer(t, client, map[string]string{"DEEPSEEK_MIN_BALANCE": tt.minimum})
+			ctx := context.Background()
+			for range 3 {
+				err := s.checkBalance(ctx)
+				if tt.wantErr == "" && err != nil {
+					t.Fatalf("checkBalance() error = %v", err)
+				}
+				if tt.wantErr != "" && (!errors.Is(err, errInsufficientBalance) || !strings.Contains(err.Error(), tt.wantErr)) {
+					t.Fatalf("checkBalance() error = %v, want insufficient balance containing %q", err, tt.wantErr)
+				}
+			}
+			if client.balanceCalls != tt.wantCalls {
+				t.Errorf("balance fetched %d times, want %d", client.balanceCalls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCheckBalanceRefreshesAfterInterval(t *testing.T) {
+	client := &mockDeepseekClient{balance: balanceResponse("0.42 USD")}
+	s := newTestServer(t, client, map[string]string{"DEEPSEEK_MIN_BALANCE": "1"})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.balance.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	result := callTool(t, s.handleAskDeepseek, map[string]any{"query": "q"})
+	if !result.IsError || !strings.Contains(resultText(result), "insufficient balance") || client.calls() != 0 {
+		t.Fatalf("result = %q after %d requests, want a refusal without calling the API", resultText(result), client.calls())
+	}
+
+	client.balance = balanceResponse("20.00 USD")
+	now = now.Add(balanceCheckInterval - time.Second)
+	if err := s.checkBalance(ctx); err == nil {
+		t.Error("checkBalance() refetched the balance before the interval passed")
+	}
+	now = now.Add(time.Second)
+	if err := s.checkBalance(ctx); err != nil {
+		t.Errorf("checkBalance() error = %v after the balance was topped up", err)
+	}
+	if client.balanceCalls != 2 {
+		t.Errorf("balance fetched %d times, want 2", client.balanceCalls)
+	}
+}
+
+// blockingBalanceClient is a DeepseekAPI whose balance requests block until release is closed
+type blockingBalanceClient struct {
+	mockDeepseekClient
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingBalanceClient) GetBalance(ctx context.Context) (*deepseek.BalanceResponse, error) {
+	if c.calls.Add(1) == 1 {
+		close(c.started)
+	}
+	<-c.release
+	return balanceResponse("0.42 USD"), nil
+}
+
+func TestCheckBalanceSingleRefresh(t *testing.T) {
+	client := &blockingBalanceClient{started: make(chan struct{}), release: make(chan struct{})}
+	s := newTestServer(t, client, map[string]string{"DEEPSEEK_MIN_BALANCE": "1"})
+	ctx := context.Background()
+
+	refreshed := make(chan error)
+	go func() { refreshed <- s.checkBalance(ctx) }()
+	<-client.started
+
+	// Other callers use the previous result, none yet, while the balance is being fetched
+	checked := make(chan error)
+	go func() {
+		for range 3 {
+			if err := s.checkBalance(ctx); err != nil {
+				checked <- err
+				return
+			}
+		}
+		checked <- nil
+	}()
+	select {
+	case err := <-checked:
+		if err != nil {
+			t.Errorf("checkBalance() during the refresh error = %v, want the previous result", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("checkBalance() blocked while another caller fetched the balance")
+	}
+
+	close(client.release)
+	if err := <-refreshed; !errors.Is(err, errInsufficientBalance) {
+		t.Errorf("checkBalance() after the refresh error = %v, want insufficient balance", err)
+	}
+	if err := s.checkBalance(ctx); !errors.Is(err, errInsufficientBalance) {
+		t.Errorf("checkBalance() error = %v, want the refreshed result", err)
+	}
+	if calls := client.calls.Load(); calls != 1 {
+		t.Errorf("balance fetched %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// minBalanceLogInterval is the shortest accepted DEEPSEEK_BALANCE_LOG_INTERVAL, so that the
+// balance is polled in the background rather than as often as requests are made
+const minBalanceLogInterval = time.Minute
+
+// logBalancePeriodically logs the account balance every interval until ctx is done, so that
+// spending shows up in the logs without checking it by hand. The first failure to fetch the
+// balance is logged as a warning and later ones at debug level, until a fetch succeeds again.
+func (s *DeepseekServer) logBalancePeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failing := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, s.config().HTTPTimeout)
+		response, err := s.client.GetBalance(timeoutCtx)
+		cancel()
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return
+		case err != nil && failing:
+			s.logger.Debug("Failed to fetch the balance for periodic logging: %v", err)
+			continue
+		case err != nil:
+			s.logger.Warn("Failed to fetch the balance for periodic logging, further failures are logged at debug level: %v", err)
+			failing = true
+			continue
+		}
+		failing = false
+		s.logger.Info("Balance: %s", formatBalanceSummary(response))
+		s.refreshBalanceGuard(response)
+	}
+}
+
+// formatBalanceSummary formats a balance response on one line, e.g.
+// "12.34 USD (granted 0.00, topped up 12.34), account available"
+func formatBalanceSummary(response *deepseek.BalanceResponse) string {
+	var parts []string
+	for _, balance := range response.BalanceInfos {
+		parts = append(parts, fmt.Sprintf("%s %s (granted %s, topped up %s)",
+			balance.TotalBalance, balance.Currency, balance.GrantedBalance, balance.ToppedUpBalance))
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "no balance details")
+	}
+	status := "account available"
+	if !response.IsAvailable {
+		status = "account unavailable"
+	}
+	return strings.Join(parts, "; ") + ", " + status
+}
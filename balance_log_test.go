@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// scriptedBalanceClient answers balance requests with errs in turn, succeeding where the
+// error is nil and once the script runs out, and records when each request was made
+type scriptedBalanceClient struct {
+	mockDeepseekClient
+	errs  []error
+	mu    sync.Mutex
+	times []time.Time
+}
+
+func (c *scriptedBalanceClient) GetBalance(ctx context.Context) (*deepseek.BalanceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := len(c.times)
+	c.times = append(c.times, time.Now())
+	if n < len(c.errs) && c.errs[n] != nil {
+		return nil, c.errs[n]
+	}
+	return balanceResponse("12.34 USD"), nil
+}
+
+// fetches returns the number of balance requests made so far
+func (c *scriptedBalanceClient) fetches() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.times)
+}
+
+func TestLogBalancePeriodically(t *testing.T) {
+	failure := errors.New("connection refused")
+	tests := []struct {
+		name string
+		errs []error
+		want []string // Level and start of each message, in order
+	}{
+		{"balance logged at every tick", nil, []string{"INFO Balance: 12.34 USD", "INFO Balance: 12.34 USD", "INFO Balance: 12.34 USD"}},
+		{"repeated failures logged quietly", []error{failure, failure, failure},
+			[]string{"WARN Failed to fetch the balance", "DEBUG Failed to fetch the balance", "DEBUG Failed to fetch the balance"}},
+		{"warning again after a recovery", []error{failure, nil, failure},
+			[]string{"WARN Failed to fetch the balance", "INFO Balance: 12.34 USD", "WARN Failed to fetch the balance"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const interval = 20 * time.Millisecond
+			client := &scriptedBalanceClient{errs: tt.errs}
+			logger := &recordingLogger{}
+			s := newTestServer(t, client, nil)
+			s.logger = logger
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			start := time.Now()
+			go func() {
+				s.logBalancePeriodically(ctx, interval)
+				close(done)
+			}()
+			deadline := time.Now().Add(5 * time.Second)
+			for client.fetches() < len(tt.want) && time.Now().Before(deadline) {
+				time.Sleep(interval / 4)
+			}
+			cancel()
+			<-done
+
+			client.mu.Lock()
+			times := append([]time.Time{}, client.times...)
+			client.mu.Unlock()
+			if len(times) < len(tt.want) {
+				t.Fatalf("balance fetched %d times, want at least %d", len(times), len(tt.want))
+			}
+			if elapsed := times[len(tt.want)-1].Sub(start); elapsed < time.Duration(len(tt.want))*interval {
+				t.Errorf("fetch %d after %v, want no sooner than one interval per fetch", len(tt.want), elapsed)
+			}
+			logger.mu.Lock()
+			messages := append([]string{}, logger.messages...)
+			logger.mu.Unlock()
+			for i, want := range tt.want {
+				if i >= len(messages) || !strings.HasPrefix(messages[i], want) {
+					t.Fatalf("logged %q, want messages starting with %q", messages, tt.want)
+				}
+			}
+
+			fetched := client.fetches()
+			time.Sleep(3 * interval)
+			if client.fetches() != fetched {
+				t.Errorf("balance fetched %d more times after the context was done", client.fetches()-fetched)
+			}
+		})
+	}
+}
+
+func TestBalanceLogIntervalConfig(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr string
+	}{
+		{"", 0, ""},
+		{"0", 0, ""},
+		{"15m", 15 * time.Minute, ""},
+		{"30s", 0, "must be 0 or at least 1m0s"},
+		{"-1m", 0, "must not be negative"},
+		{"hourly", 0, "invalid DEEPSEEK_BALANCE_LOG_INTERVAL"},
+	}
+	for _, tt := range tests {
+		t.Setenv("DEEPSEEK_API_KEY", "test-key")
+		t.Setenv("DEEPSEEK_BALANCE_LOG_INTERVAL", tt.value)
+		cfg, err := NewConfig()
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("DEEPSEEK_BALANCE_LOG_INTERVAL=%q: error = %v, want one containing %q", tt.value, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("DEEPSEEK_BALANCE_LOG_INTERVAL=%q: error = %v", tt.value, err)
+		} else if cfg.BalanceLogInterval != tt.want {
+			t.Errorf("DEEPSEEK_BALANCE_LOG_INTERVAL=%q: interval = %v, want %v", tt.value, cfg.BalanceLogInterval, tt.want)
+		}
+	}
+}
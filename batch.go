@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/cohesion-org/deepseek-go"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultBatchConcurrency bounds the queries of one batch that run at once when
+// DEEPSEEK_MAX_CONCURRENT_REQUESTS does not set a limit
+const defaultBatchConcurrency = 4
+
+// batchQuery is a single query submitted to the deepseek_batch tool
+type batchQuery struct {
+	Query        string `json:"query"`
+	Model        string `json:"model,omitempty"`
+	SystemPrompt string `json:"systemPrompt,omitempty"`
+}
+
+// batchUsage summarizes token usage and its cost in USD for a batch item or the whole batch.
+// The cost is left out for models without known pricing.
+type batchUsage struct {
+	PromptTokens     int      `json:"prompt_tokens"`
+	CompletionTokens int      `json:"completion_tokens"`
+	TotalTokens      int      `json:"total_tokens"`
+	Cost             *float64 `json:"cost_usd,omitempty"`
+}
+
+// batchItemResult is the result of a single batch query, aligned by index with the input
+type batchItemResult struct {
+	Index    int         `json:"index"`
+	Success  bool        `json:"success"`
+	Model    string      `json:"model,omitempty"`
+	Response string      `json:"response,omitempty"`
+	Error    string      `json:"error,omitempty"`
+	Code     ErrorCode   `json:"error_code,omitempty"`
+	Usage    *batchUsage `json:"usage,omitempty"`
+}
+
+// batchResult is the full response of the deepseek_batch tool
+type batchResult struct {
+	Results []batchItemResult `json:"results"`
+	Summary struct {
+		Total          int        `json:"total"`
+		Succeeded      int        `json:"succeeded"`
+		Failed         int        `json:"failed"`
+		Usage          batchUsage `json:"usage"`
+		UnpricedModels []string   `json:"unpriced_models,omitempty"` // Models whose usage is not in the cost
+	} `json:"summary"`
+}
+
+// handleDeepseekBatch handles requests to the deepseek_batch tool
+func (s *DeepseekServer) handleDeepseekBatch(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.logger.Info("Handling deepseek_batch request")
+
+	var args struct {
+		Queries []batchQuery `json:"queries"`
+	}
+	if err := req.BindArguments(&args); err != nil {
+		s.logger.Error("Invalid deepseek_batch arguments: %v", err)
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+	if len(args.Queries) == 0 {
+		return toolError(ErrorCodeInvalidArgument, "Missing required 'queries' parameter: provide at least one query"), nil
+	}
+	// Read the configuration once, so that a reload cannot change it between the queries of a batch
+	cfg := s.config()
+	ctx = withRequestConfig(ctx, cfg)
+	if cfg.MaxBatchSize > 0 && len(args.Queries) > cfg.MaxBatchSize {
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Too many queries: %d (maximum batch size is %d)", len(args.Queries), cfg.MaxBatchSize)), nil
+	}
+
+	s.logger.Info("Executing batch of %d queries", len(args.Queries))
+	results := make([]batchItemResult, len(args.Queries))
+	sem := make(chan struct{}, batchConcurrency(cfg))
+	var wg sync.WaitGroup
+	for i, query := range args.Queries {
+		wg.Add(1)
+		go func(i int, query batchQuery) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i] = batchItemResult{Index: i, Error: fmt.Sprintf("batch cancelled: %v", ctx.Err()), Code: apiErrorCode(ctx.Err())}
+				return
+			}
+			results[i] = s.runBatchQuery(ctx, i, query)
+		}(i, query)
+	}
+	wg.Wait()
+
+	var result batchResult
+	result.Results = results
+	result.Summary.Total = len(results)
+	var cost float64
+	priced, unpriced := false, make(map[string]bool)
+	for _, item := range results {
+		if !item.Success {
+			result.Summary.Failed++
+			continue
+		}
+		result.Summary.Succeeded++
+		if item.Usage == nil {
+			continue
+		}
+		result.Summary.Usage.PromptTokens += item.Usage.PromptTokens
+		result.Summary.Usage.CompletionTokens += item.Usage.CompletionTokens
+		result.Summary.Usage.TotalTokens += item.Usage.TotalTokens
+		if item.Usage.Cost != nil {
+			cost += *item.Usage.Cost
+			priced = true
+		} else if !unpriced[item.Model] {
+			unpriced[item.Model] = true
+			result.Summary.UnpricedModels = append(result.Summary.UnpricedModels, item.Model)
+		}
+	}
+	if priced {
+		result.Summary.Usage.Cost = &cost
+	}
+	s.logger.Info("Batch finished: %d succeeded, %d failed, %d total tokens, $%.4f",
+		result.Summary.Succeeded, result.Summary.Failed, result.Summary.Usage.TotalTokens, cost)
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		s.logger.Error("Failed to marshal batch results: %v", err)
+		return toolError(ErrorCodeInternal, fmt.Sprintf("Failed to marshal batch results: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// batchConcurrency returns how many queries of one batch may run at once: the
+// configured request limit, or defaultBatchConcurrency when limiting is disabled
+func batchConcurrency(cfg *Config) int {
+	if cfg.MaxConcurrentRequests > 0 {
+		return cfg.MaxConcurrentRequests
+	}
+	return defaultBatchConcurrency
+}
+
+// runBatchQuery executes a single batch query, recording any failure in the result
+func (s *DeepseekServer) runBatchQuery(ctx context.Context, index int, query batchQuery) batchItemResult {
+	result := batchItemResult{Index: index}
+	if query.Query == "" {
+		result.Error = "missing required 'query' field"
+		result.Code = ErrorCodeInvalidArgument
+		return result
+	}
+
+	cfg := s.configFrom(ctx)
+	modelName := cfg.DeepseekModel
+	if query.Model != "" {
+		if err := s.ValidateModelID(query.Model); err != nil {
+			result.Error = fmt.Sprintf("invalid model specified: %v", err)
+			result.Code = ErrorCodeInvalidModel
+			return result
+		}
+		modelName = query.Model
+	}
+	result.Model = modelName
+
+	systemPrompt := cfg.DeepseekSystemPrompt
+	if query.SystemPrompt != "" {
+		systemPrompt = query.SystemPrompt
+	}
+
+	requestPayload := &deepseek.ChatCompletionRequest{
+		Model: modelName,
+		Messages: []deepseek.ChatCompletionMessage{
+			{Role: deepseek.ChatMessageRoleSystem, Content: systemPrompt},
+			{Role: deepseek.ChatMessageRoleUser, Content: query.Query},
+		},
+		Temperature: s.requestTemperature(cfg, modelName),
+	}
+
+	response, err := s.createChatCompletion(ctx, requestPayload)
+	if err != nil {
+		s.logger.Error("Batch query %d failed: %v", index, err)
+		result.Error = fmt.Sprintf("error from DeepSeek API: %v", err)
+		result.Code = apiErrorCode(err)
+		return result
+	}
+
+	if len(response.Choices) > 0 {
+		result.Response = response.Choices[0].Message.Content
+	}
+	if result.Response == "" {
+		result.Error = "the model returned an empty response"
+		result.Code = ErrorCodeInvalidResponse
+		return result
+	}
+	result.Success = true
+	result.Usage = &batchUsage{
+		PromptTokens:     response.Usage.PromptTokens,
+		CompletionTokens: response.Usage.CompletionTokens,
+		TotalTokens:      response.Usage.TotalTokens,
+	}
+	if cost, ok := cfg.responseCost(modelName, response.Usage); ok {
+		result.Usage.Cost = &cost
+	}
+	return result
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestBatchConcurrencyBound(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantMax int64
+	}{
+		{"default without a request limit", nil, defaultBatchConcurrency},
+		{"configured request limit", map[string]string{"DEEPSEEK_MAX_CONCURRENT_REQUESTS": "2"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var running, peak atomic.Int64
+			client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+				n := running.Add(1)
+				defer running.Add(-1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				return textResponse("ok"), nil
+			}}
+			s := newTestServer(t, client, tt.env)
+
+			queries := make([]any, 10)
+			for i := range queries {
+				queries[i] = map[string]any{"query": "q"}
+			}
+			result := callTool(t, s.handleDeepseekBatch, map[string]any{"queries": queries})
+			if result.IsError {
+				t.Fatalf("batch failed: %s", resultText(result))
+			}
+			if client.calls() != len(queries) {
+				t.Errorf("calls = %d, want %d", client.calls(), len(queries))
+			}
+			if got := peak.Load(); got > tt.wantMax {
+				t.Errorf("peak concurrent queries = %d, want at most %d", got, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestBatchSummaryCost(t *testing.T) {
+	client := &mockDeepseekClient{respond: func(request *deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+		response := textResponse("ok")
+		response.Usage = deepseek.Usage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500}
+		return response, nil
+	}}
+	s := newTestServer(t, client, map[string]string{"DEEPSEEK_PRICING": "deepseek-chat=1/2"})
+	delete(s.config().ModelPricing, "deepseek-reasoner")
+
+	result := callTool(t, s.handleDeepseekBatch, map[string]any{"queries": []any{
+		map[string]any{"query": "a", "model": "deepseek-chat"},
+		map[string]any{"query": "b", "model": "deepseek-chat"},
+		map[string]any{"query": "c", "model": "deepseek-reasoner"},
+	}})
+	var batch batchResult
+	if err := json.Unmarshal([]byte(resultText(result)), &batch); err != nil {
+		t.Fatalf("result %q is not a batch result: %v", resultText(result), err)
+	}
+	// 1000 prompt tokens at $1 and 500 completion tokens at $2 per million, for each priced query
+	const wantItemCost = 0.002
+	if cost := batch.Results[0].Usage.Cost; cost == nil || math.Abs(*cost-wantItemCost) > 1e-9 {
+		t.Errorf("item cost = %v, want %v", cost, wantItemCost)
+	}
+	if cost := batch.Results[2].Usage.Cost; cost != nil {
+		t.Errorf("cost of the unpriced item = %v, want none", *cost)
+	}
+	summary := batch.Summary
+	if summary.Usage.Cost == nil || math.Abs(*summary.Usage.Cost-2*wantItemCost) > 1e-9 {
+		t.Errorf("summary cost = %v, want %v", summary.Usage.Cost, 2*wantItemCost)
+	}
+	if summary.Usage.TotalTokens != 4500 || !reflect.DeepEqual(summary.UnpricedModels, []string{"deepseek-reasoner"}) {
+		t.Errorf("summary = %+v, want 4500 tokens and deepseek-reasoner unpriced", summary)
+	}
+}
+
+func TestBatchReadsConfigOnce(t *testing.T) {
+	var s *DeepseekServer
+	client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+		// Reload the configuration while the batch is running
+		reloaded := *s.config()
+		reloaded.DeepseekSystemPrompt = "reloaded prompt"
+		s.configPtr.Store(&reloaded)
+		return textResponse("ok"), nil
+	}}
+	s = newTestServer(t, client, map[string]string{
+		"DEEPSEEK_SYSTEM_PROMPT":           "original prompt",
+		"DEEPSEEK_MAX_CONCURRENT_REQUESTS": "1",
+	})
+
+	callTool(t, s.handleDeepseekBatch, map[string]any{"queries": []any{
+		map[string]any{"query": "a"}, map[string]any{"query": "b"}, map[string]any{"query": "c"},
+	}})
+	if client.calls() != 3 {
+		t.Fatalf("calls = %d, want 3", client.calls())
+	}
+	for i, request := range client.requests {
+		if got := request.Messages[0].Content; !strings.Contains(got, "original prompt") {
+			t.Errorf("query %d system prompt = %q, want the configuration the batch started with", i, got)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// Headers attributing API requests to an organization and project, as used by OpenAI-compatible APIs
+const (
+	organizationHeader = "OpenAI-Organization"
+	projectHeader      = "OpenAI-Project"
+)
+
+// billingIDPattern matches the accepted organization and project identifiers
+var billingIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// validateBillingID checks that an organization or project identifier is safe to send as a header
+func validateBillingID(id string) error {
+	if id != "" && !billingIDPattern.MatchString(id) {
+		return fmt.Errorf("%q must be 1 to 64 letters, digits, dots, underscores or hyphens, starting with a letter or digit", id)
+	}
+	return nil
+}
+
+// billingHeaders returns the headers attributing API requests to the configured organization
+// and project, or nil if neither is set
+func billingHeaders(organization, project string) http.Header {
+	if organization == "" && project == "" {
+		return nil
+	}
+	headers := make(http.Header)
+	if organization != "" {
+		headers.Set(organizationHeader, organization)
+	}
+	if project != "" {
+		headers.Set(projectHeader, project)
+	}
+	return headers
+}
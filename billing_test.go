@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateBillingID(t *testing.T) {
+	tests := []struct {
+		id      string
+		wantErr bool
+	}{
+		{"", false},
+		{"org-AbC123", false},
+		{"proj_1.2", false},
+		{strings.Repeat("a", 64), false},
+		{strings.Repeat("a", 65), true},
+		{"-leading-hyphen", true},
+		{"has space", true},
+		{"line\nbreak", true},
+		{"org:1", true},
+	}
+	for _, tt := range tests {
+		if err := validateBillingID(tt.id); (err != nil) != tt.wantErr {
+			t.Errorf("validateBillingID(%q) error = %v, wantErr %v", tt.id, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBillingHeadersSent(t *testing.T) {
+	tests := []struct {
+		name             string
+		env              map[string]string
+		wantOrganization string
+		wantProject      string
+		wantErr          string
+	}{
+		{name: "none"},
+		{name: "organization", env: map[string]string{"DEEPSEEK_ORGANIZATION": "org-1"}, wantOrganization: "org-1"},
+		{name: "project", env: map[string]string{"DEEPSEEK_PROJECT": "proj-2"}, wantProject: "proj-2"},
+		{name: "both trimmed", env: map[string]string{"DEEPSEEK_ORGANIZATION": " org-1 ", "DEEPSEEK_PROJECT": "proj-2"},
+			wantOrganization: "org-1", wantProject: "proj-2"},
+		{name: "invalid organization", env: map[string]string{"DEEPSEEK_ORGANIZATION": "org 1"}, wantErr: "invalid DEEPSEEK_ORGANIZATION"},
+		{name: "invalid project", env: map[string]string{"DEEPSEEK_PROJECT": "proj\r\nX-Injected: 1"}, wantErr: "invalid DEEPSEEK_PROJECT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEEPSEEK_API_KEY", "test-key")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			cfg, err := NewConfig()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewConfig() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewConfig() error = %v", err)
+			}
+
+			var got http.Header
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Clone()
+			}))
+			defer server.Close()
+			client := newAPIHTTPClient(time.Second, billingHeaders(cfg.Organization, cfg.Project))
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if organization := got.Get(organizationHeader); organization != tt.wantOrganization {
+				t.Errorf("%s = %q, want %q", organizationHeader, organization, tt.wantOrganization)
+			}
+			if project := got.Get(projectHeader); project != tt.wantProject {
+				t.Errorf("%s = %q, want %q", projectHeader, project, tt.wantProject)
+			}
+		})
+	}
+}
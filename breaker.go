@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// Circuit breaker states
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// errServiceUnavailable is returned without calling the API while the circuit breaker is open.
+// Its message must not look like a timeout or network error, so it is not retried.
+var errServiceUnavailable = errors.New("DeepSeek API service unavailable")
+
+// circuitBreaker stops calls to a failing API. After threshold consecutive failures it opens
+// and rejects calls for the cooldown period, then lets a single probe call through (half-open).
+// A successful probe closes the breaker; a failed one opens it again.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	logger    Logger
+	now       func() time.Time
+
+	state    string
+	failures int       // Consecutive failures
+	openedAt time.Time // When the breaker last opened
+	probing  bool      // A half-open probe call is in flight
+}
+
+// newCircuitBreaker creates a closed circuit breaker. A threshold of zero or less disables it.
+func newCircuitBreaker(threshold int, cooldown time.Duration, logger Logger) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		logger:    logger,
+		now:       time.Now,
+		state:     CircuitClosed,
+	}
+}
+
+// Allow reports whether a call may be made, returning errServiceUnavailable if not.
+// Every allowed call must be followed by a call to Done with its result.
+func (b *circuitBreaker) Allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		remaining := b.cooldown - b.now().Sub(b.openedAt)
+		if remaining > 0 {
+			return fmt.Errorf("%w: %d consecutive failures, not retrying for another %s",
+				errServiceUnavailable, b.failures, remaining.Round(time.Second))
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		b.logger.Info("Circuit breaker half-open: probing whether the DeepSeek API has recovered")
+		return nil
+	case CircuitHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%w: waiting for a probe request to check whether it has recovered", errServiceUnavailable)
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Done records the result of a call allowed by Allow
+func (b *circuitBreaker) Done(err error) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasProbe := b.state == CircuitHalfOpen
+	b.probing = false
+	if errors.Is(err, context.Canceled) {
+		return // Says nothing about the API; a cancelled probe is simply retried by the next call
+	}
+
+	if !isOutageError(err) {
+		if b.state != CircuitClosed {
+			b.logger.Info("Circuit breaker closed: the DeepSeek API has recovered")
+		}
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if wasProbe || b.failures >= b.threshold {
+		if b.state != CircuitOpen {
+			b.logger.Warn("Circuit breaker open after %d consecutive failures; failing fast for %s (last error: %v)",
+				b.failures, b.cooldown, err)
+		}
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// State returns the breaker state and the number of consecutive failures
+func (b *circuitBreaker) State() (string, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		return CircuitHalfOpen, b.failures
+	}
+	return b.state, b.failures
+}
+
+// isOutageError reports whether an error suggests that the API is unavailable, as opposed to
+// a problem with the request itself
+func isOutageError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *deepseek.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500
+	}
+	return IsRetryableError(err)
+}
+
+// breakerClient wraps a DeepseekAPI client with a circuit breaker
+type breakerClient struct {
+	client  DeepseekAPI
+	breaker *circuitBreaker
+}
+
+func (c *breakerClient) CreateChatCompletion(ctx context.Context, req *deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	response, err := c.client.CreateChatCompletion(ctx, req)
+	c.breaker.Done(err)
+	return response, err
+}
+
+func (c *breakerClient) ListAllModels(ctx context.Context) (*deepseek.APIModels, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	models, err := c.client.ListAllModels(ctx)
+	c.breaker.Done(err)
+	return models, err
+}
+
+func (c *breakerClient) GetBalance(ctx context.Context) (*deepseek.BalanceResponse, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	balance, err := c.client.GetBalance(ctx)
+	c.breaker.Done(err)
+	return balance, err
+}
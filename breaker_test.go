@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestIsOutageError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"server error", &deepseek.APIError{StatusCode: 503}, true},
+		{"wrapped server error", fmt.Errorf("call: %w", &deepseek.APIError{StatusCode: 500}), true},
+		{"bad request", &deepseek.APIError{StatusCode: 400}, false},
+		{"rate limited", &deepseek.APIError{StatusCode: 429}, false},
+		{"timeout", errors.New("context deadline exceeded"), true},
+		{"network", errors.New("connection refused"), true},
+		{"other", errors.New("invalid model"), false},
+	}
+	for _, tt := range tests {
+		if got := isOutageError(tt.err); got != tt.want {
+			t.Errorf("isOutageError(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// breakerStep advances the clock, asks the breaker for a call and, unless the call is left
+// pending or rejected, reports result to it
+type breakerStep struct {
+	advance      time.Duration
+	wantRejected bool
+	pending      bool
+	result       error
+	wantState    string
+	wantFailures int
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	outage := &deepseek.APIError{StatusCode: 502}
+	tests := []struct {
+		name      string
+		threshold int
+		steps     []breakerStep
+	}{
+		{
+			name:      "opens after threshold failures",
+			threshold: 2,
+			steps: []breakerStep{
+				{result: outage, wantState: CircuitClosed, wantFailures: 1},
+				{result: outage, wantState: CircuitOpen, wantFailures: 2},
+				{advance: 30 * time.Second, wantRejected: true, wantState: CircuitOpen, wantFailures: 2},
+			},
+		},
+		{
+			name:      "success resets failures",
+			threshold: 2,
+			steps: []breakerStep{
+				{result: outage, wantState: CircuitClosed, wantFailures: 1},
+				{wantState: CircuitClosed},
+				{result: outage, wantState: CircuitClosed, wantFailures: 1},
+			},
+		},
+		{
+			name:      "request errors are not failures",
+			threshold: 1,
+			steps: []breakerStep{
+				{result: &deepseek.APIError{StatusCode: 400}, wantState: CircuitClosed},
+				{result: errors.New("invalid model"), wantState: CircuitClosed},
+			},
+		},
+		{
+			name:      "successful probe closes",
+			threshold: 1,
+			steps: []breakerStep{
+				{result: outage, wantState: CircuitOpen, wantFailures: 1},
+				{advance: time.Minute, wantState: CircuitClosed},
+			},
+		},
+		{
+			name:      "failed probe reopens",
+			threshold: 1,
+			steps: []breakerStep{
+				{result: outage, wantState: CircuitOpen, wantFailures: 1},
+				{advance: time.Minute, result: outage, wantState: CircuitOpen, wantFailures: 2},
+				{advance: 30 * time.Second, wantRejected: true, wantState: CircuitOpen, wantFailures: 2},
+			},
+		},
+		{
+			name:      "single probe at a time",
+			threshold: 1,
+			steps: []breakerStep{
+				{result: outage, wantState: CircuitOpen, wantFailures: 1},
+				{advance: time.Minute, pending: true, wantState: CircuitHalfOpen, wantFailures: 1},
+				{wantRejected: true, wantState: CircuitHalfOpen, wantFailures: 1},
+			},
+		},
+		{
+			name:      "cancelled probe is retried",
+			threshold: 1,
+			steps: []breakerStep{
+				{result: outage, wantState: CircuitOpen, wantFailures: 1},
+				{advance: time.Minute, result: context.Canceled, wantState: CircuitHalfOpen, wantFailures: 1},
+				{wantState: CircuitClosed},
+			},
+		},
+		{
+			name:      "disabled",
+			threshold: 0,
+			steps: []breakerStep{
+				{result: outage, wantState: CircuitClosed},
+				{result: outage, wantState: CircuitClosed},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(0, 0)
+			b := newCircuitBreaker(tt.threshold, time.Minute, NewLogger("error"))
+			b.now = func() time.Time { return now }
+			for i, step := range tt.steps {
+				now = now.Add(step.advance)
+				err := b.Allow()
+				if rejected := err != nil; rejected != step.wantRejected {
+					t.Fatalf("step %d: Allow() = %v, want rejected %v", i, err, step.wantRejected)
+				}
+				if err != nil && !errors.Is(err, errServiceUnavailable) {
+					t.Errorf("step %d: Allow() = %v, want errServiceUnavailable", i, err)
+				}
+				if err == nil && !step.pending {
+					b.Done(step.result)
+				}
+				if state, failures := b.State(); state != step.wantState || failures != step.wantFailures {
+					t.Errorf("step %d: State() = %s, %d; want %s, %d", i, state, failures, step.wantState, step.wantFailures)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerClientFailsFast(t *testing.T) {
+	client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+		return nil, &deepseek.APIError{StatusCode: 503}
+	}}
+	wrapped := &breakerClient{client: client, breaker: newCircuitBreaker(2, time.Minute, NewLogger("error"))}
+	for i := 0; i < 4; i++ {
+		_, err := wrapped.CreateChatCompletion(context.Background(), &deepseek.ChatCompletionRequest{})
+		if err == nil {
+			t.Fatalf("call %d succeeded", i)
+		}
+	}
+	if client.calls() != 2 {
+		t.Errorf("got %d API calls, want 2 before the breaker opened", client.calls())
+	}
+	if _, err := wrapped.GetBalance(context.Background()); !errors.Is(err, errServiceUnavailable) {
+		t.Errorf("GetBalance() error = %v, want errServiceUnavailable", err)
+	}
+}
+
+func TestDeepseekHealth(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold string
+		failures  int
+		want      []string
+	}{
+		{
+			name:      "disabled",
+			threshold: "0",
+			want:      []string{"**Circuit Breaker:** disabled", "**Empty Responses:** 0 (0 recovered by retry)"},
+		},
+		{
+			name:      "open",
+			threshold: "1",
+			failures:  1,
+			want:      []string{"**Circuit Breaker:** open", "**Consecutive Failures:** 1 (opens at 1, cooldown 1m0s)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, &mockDeepseekClient{}, map[string]string{
+				"DEEPSEEK_BREAKER_THRESHOLD": tt.threshold,
+				"DEEPSEEK_BREAKER_COOLDOWN":  "1m",
+			})
+			s.breaker = newCircuitBreaker(s.config().BreakerThreshold, s.config().BreakerCooldown, NewLogger("error"))
+			for i := 0; i < tt.failures; i++ {
+				if err := s.breaker.Allow(); err != nil {
+					t.Fatal(err)
+				}
+				s.breaker.Done(&deepseek.APIError{StatusCode: 500})
+			}
+			text := resultText(callTool(t, s.handleDeepseekHealth, nil))
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("health %q does not contain %q", text, want)
+				}
+			}
+		})
+	}
+}
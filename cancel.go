@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// errCancelledByRequest is the cancellation cause of requests cancelled with deepseek_cancel
+var errCancelledByRequest = errors.New("cancelled by request")
+
+// activeRequests tracks the cancel functions of in-flight deepseek_ask calls by request ID
+type activeRequests struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelCauseFunc
+}
+
+// newActiveRequests creates an empty registry of in-flight requests
+func newActiveRequests() *activeRequests {
+	return &activeRequests{cancels: make(map[string]context.CancelCauseFunc)}
+}
+
+// Register derives a cancellable context for the request with the given ID.
+// The returned function removes the request from the registry and must be called when it completes.
+func (a *activeRequests) Register(ctx context.Context, id string) (context.Context, func(), error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, exists := a.cancels[id]; exists {
+		return nil, nil, fmt.Errorf("request %q is already in progress", id)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	a.cancels[id] = cancel
+
+	done := func() {
+		a.mu.Lock()
+		delete(a.cancels, id)
+		a.mu.Unlock()
+		cancel(nil)
+	}
+	return ctx, done, nil
+}
+
+// Cancel cancels the in-flight request with the given ID, reporting whether it was found
+func (a *activeRequests) Cancel(id string) bool {
+	a.mu.Lock()
+	cancel, ok := a.cancels[id]
+	a.mu.Unlock()
+
+	if ok {
+		cancel(errCancelledByRequest)
+	}
+	return ok
+}
+
+// IDs returns the sorted IDs of the in-flight requests
+func (a *activeRequests) IDs() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ids := make([]string, 0, len(a.cancels))
+	for id := range a.cancels {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// newRequestID generates a random ID for a request that did not specify one
+func newRequestID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate request ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// isCancelledByRequest reports whether ctx was cancelled with deepseek_cancel
+func isCancelledByRequest(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errCancelledByRequest)
+}
+
+// handleDeepseekCancel handles requests to the deepseek_cancel tool
+func (s *DeepseekServer) handleDeepseekCancel(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	requestID, err := req.RequireString("request_id")
+	if err != nil {
+		s.logger.Error("Missing required 'request_id' parameter: %v", err)
+		return toolError(ErrorCodeInvalidArgument, "Missing required 'request_id' parameter: "+err.Error()), nil
+	}
+
+	if !s.active.Cancel(requestID) {
+		s.logger.Warn("Cancel requested for unknown request: %s", requestID)
+		msg := fmt.Sprintf("No in-flight request with ID %q. It may have already completed.", requestID)
+		if ids := s.active.IDs(); len(ids) > 0 {
+			msg += fmt.Sprintf(" In-flight requests: %s", strings.Join(ids, ", "))
+		}
+		return toolError(ErrorCodeNotFound, msg), nil
+	}
+
+	s.logger.Info("Cancelled request %s", requestID)
+	return mcp.NewToolResultText(fmt.Sprintf("Request %s has been cancelled.", requestID)), nil
+}
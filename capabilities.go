@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Model capabilities that requests may depend on
+const (
+	CapabilityJSONMode        = "json_mode"
+	CapabilityJSONSchema      = "json_schema"
+	CapabilityFunctionCalling = "function_calling"
+	CapabilityVision          = "vision"
+	CapabilityReasoning       = "reasoning"
+)
+
+// knownCapabilities lists the capability names accepted in DEEPSEEK_MODEL_CAPABILITIES
+var knownCapabilities = []string{CapabilityJSONMode, CapabilityJSONSchema, CapabilityFunctionCalling, CapabilityVision, CapabilityReasoning}
+
+// defaultModelCapabilities returns the built-in capabilities of the known DeepSeek models.
+// The API does not report capabilities, so they can be overridden in the config.
+func defaultModelCapabilities() map[string][]string {
+	return map[string][]string{
+		"deepseek-chat":     {CapabilityJSONMode, CapabilityFunctionCalling},
+		"deepseek-reasoner": {CapabilityJSONMode, CapabilityReasoning},
+	}
+}
+
+// parseModelCapabilities parses capability overrides in the form
+// "model=cap1|cap2;model2=cap3". An empty capability list marks a model as supporting none.
+func parseModelCapabilities(value string) (map[string][]string, error) {
+	capabilities := make(map[string][]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		model, caps, ok := strings.Cut(entry, "=")
+		model = strings.TrimSpace(model)
+		if !ok || model == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected model=capability|capability", entry)
+		}
+
+		list := []string{}
+		for _, capability := range strings.Split(caps, "|") {
+			capability = strings.TrimSpace(capability)
+			if capability == "" {
+				continue
+			}
+			if !isKnownCapability(capability) {
+				return nil, fmt.Errorf("unknown capability %q for model %s. Known capabilities are: %s",
+					capability, model, strings.Join(knownCapabilities, ", "))
+			}
+			list = append(list, capability)
+		}
+		capabilities[model] = list
+	}
+	return capabilities, nil
+}
+
+// formatModelCapabilities formats capabilities in the form accepted by parseModelCapabilities
+func formatModelCapabilities(capabilities map[string][]string) string {
+	models := make([]string, 0, len(capabilities))
+	for model := range capabilities {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	entries := make([]string, 0, len(models))
+	for _, model := range models {
+		entries = append(entries, model+"="+strings.Join(capabilities[model], "|"))
+	}
+	return strings.Join(entries, ";")
+}
+
+// isKnownCapability reports whether name is a known capability
+func isKnownCapability(name string) bool {
+	for _, capability := range knownCapabilities {
+		if capability == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ModelCapabilities returns the capabilities of a model and whether they are known
+func (c *Config) ModelCapabilities(modelID string) ([]string, bool) {
+	capabilities, ok := c.ModelCapabilityTable[modelID]
+	return capabilities, ok
+}
+
+// checkModelCapability verifies that a model supports a capability a request depends on.
+// Models with unknown capabilities are allowed, with known reported as false so callers can warn.
+func (c *Config) checkModelCapability(modelID, capability string) (known bool, err error) {
+	capabilities, ok := c.ModelCapabilities(modelID)
+	if !ok {
+		return false, nil
+	}
+	for _, supported := range capabilities {
+		if supported == capability {
+			return true, nil
+		}
+	}
+	return true, fmt.Errorf("model %s does not support %s", modelID, capability)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseModelCapabilities(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string][]string
+		wantErr string
+	}{
+		{
+			name:  "several models",
+			value: "deepseek-chat=json_mode|function_calling; custom = vision | reasoning ",
+			want: map[string][]string{
+				"deepseek-chat": {CapabilityJSONMode, CapabilityFunctionCalling},
+				"custom":        {CapabilityVision, CapabilityReasoning},
+			},
+		},
+		{
+			name:  "empty list supports nothing",
+			value: "plain=",
+			want:  map[string][]string{"plain": {}},
+		},
+		{
+			name:  "empty entries skipped",
+			value: ";;deepseek-chat=json_schema;",
+			want:  map[string][]string{"deepseek-chat": {CapabilityJSONSchema}},
+		},
+		{name: "missing separator", value: "deepseek-chat", wantErr: "invalid entry"},
+		{name: "missing model", value: "=json_mode", wantErr: "invalid entry"},
+		{name: "unknown capability", value: "deepseek-chat=telepathy", wantErr: `unknown capability "telepathy"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseModelCapabilities(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseModelCapabilities(%q) error = %v, want %q", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseModelCapabilities(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatModelCapabilitiesRoundTrip(t *testing.T) {
+	capabilities := defaultModelCapabilities()
+	capabilities["plain"] = []string{}
+	formatted := formatModelCapabilities(capabilities)
+	if want := "deepseek-chat=json_mode|function_calling;deepseek-reasoner=json_mode|reasoning;plain="; formatted != want {
+		t.Errorf("formatModelCapabilities() = %q, want %q", formatted, want)
+	}
+	parsed, err := parseModelCapabilities(formatted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, capabilities) {
+		t.Errorf("round trip = %v, want %v", parsed, capabilities)
+	}
+}
+
+func TestCheckModelCapability(t *testing.T) {
+	cfg := newTestConfig(t, map[string]string{
+		"DEEPSEEK_MODEL_CAPABILITIES": "deepseek-chat=json_schema",
+	})
+	tests := []struct {
+		model      string
+		capability string
+		wantKnown  bool
+		wantErr    bool
+	}{
+		{"deepseek-chat", CapabilityJSONSchema, true, false},
+		{"deepseek-chat", CapabilityJSONMode, true, true},
+		{"deepseek-reasoner", CapabilityReasoning, true, false},
+		{"deepseek-reasoner", CapabilityFunctionCalling, true, true},
+		{"unlisted-model", CapabilityVision, false, false},
+	}
+	for _, tt := range tests {
+		known, err := cfg.checkModelCapability(tt.model, tt.capability)
+		if known != tt.wantKnown || (err != nil) != tt.wantErr {
+			t.Errorf("checkModelCapability(%q, %q) = %v, %v; want known=%v err=%v",
+				tt.model, tt.capability, known, err, tt.wantKnown, tt.wantErr)
+		}
+	}
+}
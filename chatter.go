@@ -0,0 +1,84 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Limits of what trimChatter considers removing
+const (
+	maxChatterChars        = 200 // Longest paragraph considered chatter
+	maxPostambleParagraphs = 2   // Most closing paragraphs removed
+)
+
+// Conversational openings and closings removed by trim_chatter. An opening is only removed when
+// it is a bare interjection such as "Sure!" or introduces the answer with a trailing colon,
+// such as "Sure! Here's the refactored function:", so that an opening carrying content is kept.
+var (
+	interjectionPattern = regexp.MustCompile(`(?i)^(sure|certainly|of course|absolutely|okay|ok|great question|good question)[!.,]?$`)
+	introductionPattern = regexp.MustCompile(`(?i)^(sure|certainly|of course|absolutely|okay|ok|great question|good question|happy to help|i'd be happy to|here's|here is|here are|below is|below are)\b.*:$`)
+	postamblePattern    = regexp.MustCompile(`(?i)(let me know if|feel free to|hope (this|that|it) helps|happy coding|if you have any (other |more |further )?questions|don't hesitate to)`)
+)
+
+// trimChatter removes conversational preamble such as "Sure! Here's the code:" and postamble
+// such as "Let me know if you have questions!" from a response. It only removes short
+// single-line paragraphs outside code blocks at the very start or end of the response, and
+// never returns an empty response, so the substantive answer is kept intact.
+func trimChatter(content string) string {
+	paragraphs := splitParagraphs(content)
+	if len(paragraphs) < 2 {
+		return content
+	}
+
+	start, end := 0, len(paragraphs)
+	if isChatter(paragraphs[0]) && (interjectionPattern.MatchString(paragraphs[0]) || introductionPattern.MatchString(paragraphs[0])) {
+		start++
+	}
+	for removed := 0; removed < maxPostambleParagraphs && end-1 > start; removed++ {
+		last := paragraphs[end-1]
+		if !isChatter(last) || !postamblePattern.MatchString(last) {
+			break
+		}
+		end--
+	}
+	if start == 0 && end == len(paragraphs) {
+		return content
+	}
+	return strings.Join(paragraphs[start:end], "\n\n")
+}
+
+// isChatter reports whether a paragraph is short enough to be considered for removal
+func isChatter(paragraph string) bool {
+	return len([]rune(paragraph)) <= maxChatterChars && !strings.Contains(paragraph, "\n") && !strings.HasPrefix(paragraph, "```")
+}
+
+// splitParagraphs splits markdown into paragraphs separated by blank lines, keeping fenced
+// code blocks whole even when they contain blank lines
+func splitParagraphs(content string) []string {
+	var paragraphs []string
+	var current []string
+	fence := ""
+	flush := func() {
+		if len(current) > 0 {
+			paragraphs = append(paragraphs, strings.Join(current, "\n"))
+			current = nil
+		}
+	}
+	for _, line := range strings.Split(strings.TrimSpace(content), "\n") {
+		if marker := fenceMarkerOf(line); marker != "" {
+			switch fence {
+			case "":
+				fence = marker
+			case marker:
+				fence = ""
+			}
+		}
+		if fence == "" && strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+	return paragraphs
+}
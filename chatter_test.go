@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestTrimChatter(t *testing.T) {
+	code := "```go\nfunc f() {}\n\nfunc g() {}\n```"
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"interjection", "Sure!\n\n" + code, code},
+		{"introduction", "Sure! Here's the refactored function:\n\n" + code, code},
+		{"postamble", code + "\n\nLet me know if you need anything else.", code},
+		{"preamble and two closings", "Certainly.\n\nThe fix is below.\n\n" + code + "\n\nHope this helps!\n\nHappy coding!", "The fix is below.\n\n" + code},
+		{"at most two closings", "Answer.\n\nFeel free to ask.\n\nHope this helps!\n\nHappy coding!", "Answer.\n\nFeel free to ask."},
+		{"opening with content kept", "Sure, the bug is in the loop bound.\n\n" + code, "Sure, the bug is in the loop bound.\n\n" + code},
+		{"long closing kept", code + "\n\nLet me know if " + strings.Repeat("x", maxChatterChars), code + "\n\nLet me know if " + strings.Repeat("x", maxChatterChars)},
+		{"single paragraph kept", "Sure!", "Sure!"},
+		{"never empties the response", "Sure!\n\nHope this helps!", "Hope this helps!"},
+		{"no chatter unchanged", "First.\n\n\nSecond.", "First.\n\n\nSecond."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimChatter(tt.content); got != tt.want {
+				t.Errorf("trimChatter(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitParagraphs(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"a\nb\n\n\nc", []string{"a\nb", "c"}},
+		{"intro\n\n```\nx\n\ny\n```\n\nend", []string{"intro", "```\nx\n\ny\n```", "end"}},
+		{"~~~\n```\n\n~~~", []string{"~~~\n```\n\n~~~"}},
+		{"  \n\n", nil},
+	}
+	for _, tt := range tests {
+		if got := splitParagraphs(tt.content); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitParagraphs(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestAskTrimChatter(t *testing.T) {
+	tests := []struct {
+		name string
+		trim bool
+		want string
+	}{
+		{"off", false, "Sure!\n\nThe answer is 4."},
+		{"on", true, "The answer is 4."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+				return textResponse("Sure!\n\nThe answer is 4."), nil
+			}}
+			s := newTestServer(t, client, nil)
+			text := resultText(callTool(t, s.handleAskDeepseek, map[string]any{"query": "2+2?", "trim_chatter": tt.trim}))
+			if !strings.HasPrefix(text, tt.want) {
+				t.Errorf("result %q does not start with %q", text, tt.want)
+			}
+		})
+	}
+}
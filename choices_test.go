@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestFormatChoices(t *testing.T) {
+	choices := []deepseek.Choice{
+		{Message: deepseek.Message{Content: "first"}},
+		{Message: deepseek.Message{Content: ""}},
+		{Message: deepseek.Message{Content: "third"}},
+	}
+	want := "## Choice 1\n\nfirst\n\n## Choice 2\n\n*Empty response*\n\n## Choice 3\n\nthird"
+	if got := formatChoices(choices); got != want {
+		t.Errorf("formatChoices() = %q, want %q", got, want)
+	}
+}
+
+func TestAskMultipleChoices(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       map[string]any
+		wantText   []string
+		wantAbsent []string
+	}{
+		{
+			name:       "first choice by default",
+			args:       map[string]any{"query": "q", "n": float64(2)},
+			wantText:   []string{"answer one"},
+			wantAbsent: []string{"answer two", "## Choice"},
+		},
+		{
+			name:     "all choices",
+			args:     map[string]any{"query": "q", "n": float64(2), "return_all_choices": true},
+			wantText: []string{"## Choice 1\n\nanswer one", "## Choice 2\n\nanswer two"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+				response := textResponse("answer one")
+				response.Choices = append(response.Choices, deepseek.Choice{Message: deepseek.Message{Content: "answer two"}})
+				return response, nil
+			}}
+			s := newTestServer(t, client, nil)
+			text := resultText(callTool(t, s.handleAskDeepseek, tt.args))
+			for _, want := range tt.wantText {
+				if !strings.Contains(text, want) {
+					t.Errorf("result %q does not contain %q", text, want)
+				}
+			}
+			for _, absent := range tt.wantAbsent {
+				if strings.Contains(text, absent) {
+					t.Errorf("result %q contains %q", text, absent)
+				}
+			}
+			if n := client.bodyFields[0]["n"]; n != 2 {
+				t.Errorf("n body field = %v, want 2", n)
+			}
+		})
+	}
+}
+
+func TestAskChoiceCountOnlyOnPrimaryRequest(t *testing.T) {
+	// The first answer is empty, so the request is retried; the retry reads a single choice
+	calls := 0
+	client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+		calls++
+		if calls == 1 {
+			return textResponse(""), nil
+		}
+		return textResponse("recovered"), nil
+	}}
+	s := newTestServer(t, client, map[string]string{"DEEPSEEK_RETRY_ON_EMPTY": "true"})
+	text := resultText(callTool(t, s.handleAskDeepseek, map[string]any{"query": "q", "n": float64(3), "seed": float64(5)}))
+	if !strings.Contains(text, "recovered") {
+		t.Fatalf("result %q does not contain the retried answer", text)
+	}
+	if len(client.bodyFields) != 2 {
+		t.Fatalf("requests = %d, want 2", len(client.bodyFields))
+	}
+	if n := client.bodyFields[0]["n"]; n != 3 {
+		t.Errorf("primary request n = %v, want 3", n)
+	}
+	if n, ok := client.bodyFields[1]["n"]; ok {
+		t.Errorf("retry request n = %v, want none", n)
+	}
+	if seed := client.bodyFields[1]["seed"]; seed != int64(5) {
+		t.Errorf("retry request seed = %v, want 5", seed)
+	}
+}
+
+func TestAskRejectsInvalidChoiceCount(t *testing.T) {
+	s := newTestServer(t, &mockDeepseekClient{}, nil)
+	for _, n := range []float64{0, 100} {
+		result := callTool(t, s.handleAskDeepseek, map[string]any{"query": "q", "n": n})
+		if !result.IsError {
+			t.Errorf("n=%v: result is not an error", n)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// citationInstruction is appended to the system prompt when include_citations is set
+const citationInstruction = "The reference files are shown with line numbers. " +
+	"Ground your answer in them and end it with a section headed exactly \"## Citations\" that lists, " +
+	"one per line, each file and line range you relied on in the form \"- path:start-end - short description\". " +
+	"Only cite files that were provided and keep the citations out of the main answer."
+
+var (
+	citationsHeadingRe = regexp.MustCompile(`(?im)^#{1,6}\s*citations\s*:?\s*$`)
+	citationRefRe      = regexp.MustCompile(`([A-Za-z0-9_./\\-]+\.[A-Za-z0-9]+|[A-Za-z0-9_-]+):(\d+)(?:\s*-\s*(\d+))?`)
+)
+
+// citedFile describes a file provided to the model that citations may refer to
+type citedFile struct {
+	Path  string
+	Lines int
+}
+
+// addLineNumbers prefixes each line of content with its line number so the model can cite lines
+func addLineNumbers(content []byte) []byte {
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	width := len(strconv.Itoa(len(lines)))
+
+	var sb strings.Builder
+	sb.Grow(len(content) + len(lines)*(width+2))
+	for i, line := range lines {
+		sb.WriteString(fmt.Sprintf("%*d| %s\n", width, i+1, line))
+	}
+	return []byte(sb.String())
+}
+
+// countLines returns the number of lines in content
+func countLines(content []byte) int {
+	if len(content) == 0 {
+		return 0
+	}
+	return strings.Count(strings.TrimSuffix(string(content), "\n"), "\n") + 1
+}
+
+// verifyCitations checks the citations section of a response against the files that were
+// provided, annotating citations of unknown files or out-of-range lines. Responses without
+// a citations section get a note appended instead.
+func verifyCitations(response string, files []citedFile) (string, int) {
+	loc := citationsHeadingRe.FindStringIndex(response)
+	if loc == nil {
+		return response + "\n\n## Citations\n\n*The model did not provide citations.*", 0
+	}
+
+	body, section := response[:loc[1]], response[loc[1]:]
+	lines := strings.Split(section, "\n")
+	flagged := 0
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			break // Next section
+		}
+		for _, match := range citationRefRe.FindAllStringSubmatch(line, -1) {
+			if problem := checkCitation(match, files); problem != "" {
+				lines[i] += fmt.Sprintf(" ⚠️ *Unverified: %s*", problem)
+				flagged++
+				break
+			}
+		}
+	}
+	return body + strings.Join(lines, "\n"), flagged
+}
+
+// checkCitation returns a description of what is wrong with a citation, or "" if it matches a provided file
+func checkCitation(match []string, files []citedFile) string {
+	cited := filepath.ToSlash(match[1])
+	start, _ := strconv.Atoi(match[2])
+	end := start
+	if match[3] != "" {
+		end, _ = strconv.Atoi(match[3])
+	}
+
+	for _, file := range files {
+		path := filepath.ToSlash(file.Path)
+		if cited != path && !strings.HasSuffix(path, "/"+cited) && cited != filepath.Base(file.Path) {
+			continue
+		}
+		if start < 1 || end < start || end > file.Lines {
+			return fmt.Sprintf("lines %d-%d are outside %s (%d lines)", start, end, cited, file.Lines)
+		}
+		return ""
+	}
+	return fmt.Sprintf("%s was not among the provided files", cited)
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change of a show_diff diff
+const diffContextLines = 3
+
+// minBlockSimilarity is the share of lines a response code block must have in common with a
+// query code block to be diffed against it as a revision of that code
+const minBlockSimilarity = 0.4
+
+// maxDiffCells bounds the size of the table used to diff two code blocks, so that very long
+// blocks are reported as too large instead of using a lot of memory
+const maxDiffCells = 4_000_000
+
+// codeBlock is a fenced code block of a markdown document
+type codeBlock struct {
+	Language string
+	Lines    []string
+}
+
+// extractCodeBlocks returns the fenced code blocks of a markdown document in order. An
+// unterminated block runs to the end of the document.
+func extractCodeBlocks(md string) []codeBlock {
+	var blocks []codeBlock
+	var current *codeBlock
+	fenceMarker := ""
+	for _, line := range strings.Split(md, "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if marker := fenceMarkerOf(trimmed); marker != "" {
+			if current == nil {
+				current = &codeBlock{Language: strings.TrimSpace(strings.TrimLeft(trimmed, marker[:1]))}
+				fenceMarker = marker
+				continue
+			}
+			if strings.HasPrefix(trimmed, fenceMarker) && strings.Trim(trimmed, fenceMarker[:1]) == "" {
+				blocks = append(blocks, *current)
+				current = nil
+				continue
+			}
+		}
+		if current != nil {
+			current.Lines = append(current.Lines, line)
+		}
+	}
+	if current != nil {
+		blocks = append(blocks, *current)
+	}
+	return blocks
+}
+
+// blockSimilarity returns the share of the non-blank lines of two code blocks they have in
+// common, ignoring indentation, from 0 for nothing in common to 1 for the same lines
+func blockSimilarity(a, b []string) float64 {
+	counts := make(map[string]int)
+	total := 0
+	for _, line := range a {
+		if line = strings.TrimSpace(line); line != "" {
+			counts[line]++
+			total++
+		}
+	}
+	common := 0
+	for _, line := range b {
+		if line = strings.TrimSpace(line); line != "" {
+			total++
+			if counts[line] > 0 {
+				counts[line]--
+				common++
+			}
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return 2 * float64(common) / float64(total)
+}
+
+// codeRevision pairs a code block of the query with the response block that revises it
+type codeRevision struct {
+	Before, After       codeBlock
+	BeforeIdx, AfterIdx int // 1-based positions of the blocks in the query and the response
+}
+
+// pairCodeBlocks matches each response code block with the most similar query block it revises.
+// Each query block is matched at most once, and blocks that are unchanged or too different to
+// be a revision are left out.
+func pairCodeBlocks(before, after []codeBlock) []codeRevision {
+	var revisions []codeRevision
+	used := make([]bool, len(before))
+	for j, candidate := range after {
+		best, bestScore := -1, minBlockSimilarity
+		for i, original := range before {
+			if used[i] {
+				continue
+			}
+			if score := blockSimilarity(original.Lines, candidate.Lines); score >= bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			continue
+		}
+		used[best] = true
+		if strings.Join(before[best].Lines, "\n") == strings.Join(candidate.Lines, "\n") {
+			continue
+		}
+		revisions = append(revisions, codeRevision{Before: before[best], After: candidate, BeforeIdx: best + 1, AfterIdx: j + 1})
+	}
+	return revisions
+}
+
+// diffOp is one line of a line diff: ' ' for a kept line, '-' for a removed one, '+' for an added one
+type diffOp struct {
+	kind  byte
+	text  string
+	aLine int // 0-based line of a before the op
+	bLine int // 0-based line of b before the op
+}
+
+// diffLines computes a minimal line diff of a and b from their longest common subsequence,
+// returning false if the blocks are too large to diff
+func diffLines(a, b []string) ([]diffOp, bool) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	n, m := len(midA), len(midB)
+	if (n+1)*(m+1) > maxDiffCells {
+		return nil, false
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of midA[i:] and midB[j:]
+	lcs := make([][]int32, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	ai, bi := 0, 0
+	for ; ai < prefix; ai, bi = ai+1, bi+1 {
+		ops = append(ops, diffOp{kind: ' ', text: a[ai], aLine: ai, bLine: bi})
+	}
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && midA[i] == midB[j]:
+			ops = append(ops, diffOp{kind: ' ', text: midA[i], aLine: ai, bLine: bi})
+			i, j, ai, bi = i+1, j+1, ai+1, bi+1
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{kind: '-', text: midA[i], aLine: ai, bLine: bi})
+			i, ai = i+1, ai+1
+		default:
+			ops = append(ops, diffOp{kind: '+', text: midB[j], aLine: ai, bLine: bi})
+			j, bi = j+1, bi+1
+		}
+	}
+	for k := 0; k < suffix; k, ai, bi = k+1, ai+1, bi+1 {
+		ops = append(ops, diffOp{kind: ' ', text: a[ai], aLine: ai, bLine: bi})
+	}
+	return ops, true
+}
+
+// unifiedDiff formats a line diff of a and b in the unified format, with diffContextLines of
+// context around each change. It returns "" if the blocks are equal and false if they are too
+// large to diff.
+func unifiedDiff(fromName, toName string, a, b []string) (string, bool) {
+	ops, ok := diffLines(a, b)
+	if !ok {
+		return "", false
+	}
+	var sb strings.Builder
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		start, end := max(0, i-diffContextLines), i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			} else if j-end > 2*diffContextLines {
+				break
+			}
+		}
+		stop := min(len(ops), end+diffContextLines+1)
+		writeHunk(&sb, ops[start:stop])
+		i = stop
+	}
+	if sb.Len() == 0 {
+		return "", true
+	}
+	return fmt.Sprintf("--- %s\n+++ %s\n%s", fromName, toName, sb.String()), true
+}
+
+// writeHunk writes a hunk of diff ops with its @@ header
+func writeHunk(sb *strings.Builder, ops []diffOp) {
+	aCount, bCount := 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			aCount++
+		}
+		if op.kind != '-' {
+			bCount++
+		}
+	}
+	// An empty range starts at the line before it, as in diff -u
+	aStart, bStart := ops[0].aLine+1, ops[0].bLine+1
+	if aCount == 0 {
+		aStart--
+	}
+	if bCount == 0 {
+		bStart--
+	}
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+	for _, op := range ops {
+		sb.WriteByte(op.kind)
+		sb.WriteString(op.text)
+		sb.WriteByte('\n')
+	}
+}
+
+// formatCodeDiffs formats unified diffs between the code blocks of the query and the revised
+// blocks of the response, for show_diff. Without comparable blocks it returns a note saying so.
+func formatCodeDiffs(query, response string) (string, int) {
+	before := extractCodeBlocks(query)
+	if len(before) == 0 {
+		return "\n\n---\n*show_diff: the query has no fenced code block to compare the response with.*", 0
+	}
+	revisions := pairCodeBlocks(before, extractCodeBlocks(response))
+	if len(revisions) == 0 {
+		return "\n\n---\n*show_diff: the response has no revised version of a code block in the query.*", 0
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n---\n## Suggested Changes\n")
+	diffs := 0
+	for _, revision := range revisions {
+		diff, ok := unifiedDiff(fmt.Sprintf("query block %d", revision.BeforeIdx), fmt.Sprintf("response block %d", revision.AfterIdx),
+			revision.Before.Lines, revision.After.Lines)
+		switch {
+		case !ok:
+			sb.WriteString(fmt.Sprintf("\n*Query block %d and response block %d are too large to diff.*\n", revision.BeforeIdx, revision.AfterIdx))
+		case diff != "":
+			sb.WriteString(fmt.Sprintf("\n```diff\n%s```\n", diff))
+			diffs++
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n"), diffs
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractCodeBlocks(t *testing.T) {
+	md := "Fix this:\n```go\nfunc f() {\n}\n```\nand\n~~~~\n```\nnested\n~~~~\n```python\nprint(1)"
+	blocks := extractCodeBlocks(md)
+	want := []codeBlock{
+		{Language: "go", Lines: []string{"func f() {", "}"}},
+		{Language: "", Lines: []string{"```", "nested"}},
+		{Language: "python", Lines: []string{"print(1)"}},
+	}
+	if len(blocks) != len(want) {
+		t.Fatalf("extractCodeBlocks() = %+v, want %+v", blocks, want)
+	}
+	for i := range want {
+		if blocks[i].Language != want[i].Language || strings.Join(blocks[i].Lines, "\n") != strings.Join(want[i].Lines, "\n") {
+			t.Errorf("block %d = %+v, want %+v", i+1, blocks[i], want[i])
+		}
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(s string) []string {
+		if s == "" {
+			return nil
+		}
+		return strings.Split(s, "\n")
+	}
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"equal", "a\nb", "a\nb", ""},
+		{"changed line", "a\nb\nc", "a\nB\nc", "--- before\n+++ after\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+		{"insertion at the start", "a\nb", "x\na\nb", "--- before\n+++ after\n@@ -1,2 +1,3 @@\n+x\n a\n b\n"},
+		{"empty before", "", "x", "--- before\n+++ after\n@@ -0,0 +1,1 @@\n+x\n"},
+		{"deletion at the end", "a\nb\nc", "a\nb", "--- before\n+++ after\n@@ -1,3 +1,2 @@\n a\n b\n-c\n"},
+		{"distant changes in separate hunks", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12", "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve",
+			"--- before\n+++ after\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := unifiedDiff("before", "after", lines(tt.a), lines(tt.b))
+			if !ok || got != tt.want {
+				t.Errorf("unifiedDiff() = %q, %v, want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatCodeDiffs(t *testing.T) {
+	query := "Why does this panic?\n```go\nfunc first(s []int) int {\n\treturn s[0]\n}\n```"
+	tests := []struct {
+		name      string
+		query     string
+		response  string
+		wantDiffs int
+		want      string
+	}{
+		{"revised block", query, "Check the length:\n```go\nfunc first(s []int) int {\n\tif len(s) == 0 {\n\t\treturn 0\n\t}\n\treturn s[0]\n}\n```",
+			1, "```diff\n--- query block 1\n+++ response block 1\n@@ -1,3 +1,6 @@\n func first(s []int) int {\n+\tif len(s) == 0 {\n+\t\treturn 0\n+\t}\n \treturn s[0]\n }\n```"},
+		{"no code in the query", "Why does it panic?", "```go\nfunc first() {}\n```", 0, "the query has no fenced code block"},
+		{"unrelated response code", query, "Run:\n```sh\ngo test ./...\n```", 0, "the response has no revised version"},
+		{"unchanged code", query, "It is fine:\n```go\nfunc first(s []int) int {\n\treturn s[0]\n}\n```", 0, "the response has no revised version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, diffs := formatCodeDiffs(tt.query, tt.response)
+			if diffs != tt.wantDiffs || !strings.Contains(got, tt.want) {
+				t.Errorf("formatCodeDiffs() = %q, %d, want %d diffs and %q", got, diffs, tt.wantDiffs, tt.want)
+			}
+		})
+	}
+}
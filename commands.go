@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// commandContext is the output of a command run for the context of a request
+type commandContext struct {
+	Command   string
+	Stdout    string
+	Stderr    string
+	ExitCode  int
+	Truncated bool // Output beyond the configured limit was dropped
+	TimedOut  bool
+}
+
+// cappedBuffer keeps the first limit bytes written to it and drops the rest
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining < len(p) {
+		b.truncated = true
+		b.buf.Write(p[:max(remaining, 0)])
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// parseAllowedCommand splits a command into its program and arguments and checks it against
+// the allowlist of command prefixes. A prefix matches whole words, so "go test" allows
+// "go test ./..." but not "go tester". Arguments after the prefix may only be operands such as
+// package patterns or paths: a flag, that is any argument starting with "-", must be part of
+// the allowlisted prefix itself, so "go test" does not allow "go test -exec /bin/sh ./..." and
+// "go test -race" must be listed to allow "go test -race ./...". Commands are never run through
+// a shell, so shell syntax such as pipes or redirections is passed to the program as literal
+// arguments.
+func parseAllowedCommand(command string, allowlist []string) ([]string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	flagDenied := ""
+	for _, prefix := range allowlist {
+		prefixArgs := strings.Fields(prefix)
+		if len(prefixArgs) == 0 || len(prefixArgs) > len(args) {
+			continue
+		}
+		matched := true
+		for i, arg := range prefixArgs {
+			if args[i] != arg {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		if flag := firstFlag(args[len(prefixArgs):]); flag != "" {
+			flagDenied = flag
+			continue
+		}
+		return args, nil
+	}
+	if flagDenied != "" {
+		return nil, fmt.Errorf("command is not allowed: %q. The flag %q is not part of an allowed command prefix; flags must be listed in DEEPSEEK_COMMAND_ALLOWLIST", command, flagDenied)
+	}
+	return nil, fmt.Errorf("command is not allowed: %q. Allowed command prefixes are: %s", command, strings.Join(allowlist, ", "))
+}
+
+// firstFlag returns the first argument that is a flag, or "" if all are operands
+func firstFlag(args []string) string {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			return arg
+		}
+	}
+	return ""
+}
+
+// validateCommands checks that command context is enabled and that every command is allowed,
+// before any of them is run
+func validateCommands(commands []string, cfg *Config) ([][]string, error) {
+	if !cfg.AllowCommandContext {
+		return nil, fmt.Errorf("command context is disabled on this server; set DEEPSEEK_ALLOW_COMMAND_CONTEXT=true to enable it")
+	}
+	if len(cfg.CommandAllowlist) == 0 {
+		return nil, fmt.Errorf("no commands are allowed; set DEEPSEEK_COMMAND_ALLOWLIST to the permitted command prefixes")
+	}
+	parsed := make([][]string, 0, len(commands))
+	for _, command := range commands {
+		args, err := parseAllowedCommand(command, cfg.CommandAllowlist)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkCommandOperands(args, cfg); err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, args)
+	}
+	return parsed, nil
+}
+
+// checkCommandOperands rejects a command with an operand that names a path outside the allowed
+// roots, as validateFile does for file_paths. Each operand is resolved against the working
+// directory, as the command itself would resolve it, so that "cat ../../etc/passwd" is caught
+// as well as "cat /etc/passwd". An operand that is not a path, such as a package pattern or a
+// branch name, resolves inside the working directory and passes.
+func checkCommandOperands(args []string, cfg *Config) error {
+	if len(cfg.AllowedFilePaths) == 0 {
+		return nil
+	}
+	for _, arg := range args[1:] {
+		path := arg
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cfg.CommandWorkDir, path)
+		}
+		if !isPathAllowed(existingAncestor(path), cfg.AllowedFilePaths) {
+			return fmt.Errorf("%w: command operand %q resolves to %s. Allowed roots are: %s",
+				errPathNotAllowed, arg, filepath.Clean(path), strings.Join(cfg.AllowedFilePaths, ", "))
+		}
+	}
+	return nil
+}
+
+// existingAncestor returns path, or its nearest parent directory if path does not exist, so that
+// the root check of an operand naming a file yet to be created resolves symlinks where it can
+func existingAncestor(path string) string {
+	path = filepath.Clean(path)
+	for {
+		if _, err := os.Lstat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// runCommand runs a validated command directly, without a shell, in the configured working
+// directory and bounded by the configured timeout and output limit. A failing command is not
+// an error; its exit code and output are what the model needs to see. An error is only
+// returned when the command cannot be started.
+func runCommand(ctx context.Context, args []string, cfg *Config) (*commandContext, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.CommandTimeout)
+	defer cancel()
+
+	stdout := &cappedBuffer{limit: cfg.CommandMaxOutput}
+	stderr := &cappedBuffer{limit: cfg.CommandMaxOutput}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = cfg.CommandWorkDir
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	cmd.WaitDelay = time.Second // Do not wait for children holding the output pipes open
+
+	result := &commandContext{Command: strings.Join(args, " ")}
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		result.TimedOut = true
+		result.ExitCode = -1
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		return nil, fmt.Errorf("failed to run %q: %w", result.Command, err)
+	}
+	result.Stdout, result.Stderr = stdout.buf.String(), stderr.buf.String()
+	result.Truncated = stdout.truncated || stderr.truncated
+	return result, nil
+}
+
+// formatCommandContext formats command outputs for inclusion in the query
+func formatCommandContext(results []*commandContext, timeout time.Duration) string {
+	var sb strings.Builder
+	sb.WriteString("\n\n# Command Output\n")
+	for _, result := range results {
+		status := fmt.Sprintf("exit code %d", result.ExitCode)
+		if result.TimedOut {
+			status = fmt.Sprintf("timed out after %v", timeout)
+		}
+		sb.WriteString(fmt.Sprintf("\n## `%s` (%s)\n", result.Command, status))
+		if result.Stdout == "" && result.Stderr == "" {
+			sb.WriteString("\n*No output.*\n")
+		}
+		if result.Stdout != "" {
+			sb.WriteString("\n### stdout\n\n```text\n" + strings.TrimRight(result.Stdout, "\n") + "\n```\n")
+		}
+		if result.Stderr != "" {
+			sb.WriteString("\n### stderr\n\n```text\n" + strings.TrimRight(result.Stderr, "\n") + "\n```\n")
+		}
+		if result.Truncated {
+			sb.WriteString("\n*Output was truncated.*\n")
+		}
+	}
+	return sb.String()
+}
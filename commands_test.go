@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAllowedCommand(t *testing.T) {
+	allowlist := []string{"go vet", "go test", "go test -race", "npm test"}
+	tests := []struct {
+		name    string
+		command string
+		want    []string
+		wantErr string
+	}{
+		{"exact prefix", "go vet", []string{"go", "vet"}, ""},
+		{"operands", "go test ./... ./cmd", []string{"go", "test", "./...", "./cmd"}, ""},
+		{"allowlisted flag", "go test -race ./...", []string{"go", "test", "-race", "./..."}, ""},
+		{"extra whitespace", "  go   vet  ./...  ", []string{"go", "vet", "./..."}, ""},
+		{"shell syntax is literal", "go vet ./... | sh", []string{"go", "vet", "./...", "|", "sh"}, ""},
+		{"partial word", "go tester", nil, "Allowed command prefixes"},
+		{"other program", "rm -rf /", nil, "Allowed command prefixes"},
+		{"prefix longer than command", "go", nil, "Allowed command prefixes"},
+		{"flag after prefix", "go test -exec /bin/sh ./...", nil, `flag "-exec"`},
+		{"flag with value", "go vet -vettool=/tmp/tool ./...", nil, `flag "-vettool=/tmp/tool"`},
+		{"flag after operand", "go test ./... -run X", nil, `flag "-run"`},
+		{"flag beyond allowlisted flag", "go test -race -exec sh", nil, `flag "-exec"`},
+		{"double dash", "npm test -- --inspect", nil, `flag "--"`},
+		{"empty", "   ", nil, "empty command"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseAllowedCommand(tt.command, allowlist)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseAllowedCommand(%q) error = %v, want one containing %q", tt.command, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseAllowedCommand(%q) error = %v", tt.command, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseAllowedCommand(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateCommands(t *testing.T) {
+	root := t.TempDir()
+	workDir := filepath.Join(root, "project")
+	if err := os.MkdirAll(filepath.Join(workDir, "pkg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(workDir, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	sandboxed := Config{AllowCommandContext: true, CommandAllowlist: []string{"cat", "go test"}, AllowedFilePaths: []string{root}, CommandWorkDir: workDir}
+
+	tests := []struct {
+		name     string
+		cfg      Config
+		commands []string
+		wantErr  string
+	}{
+		{"disabled", Config{CommandAllowlist: []string{"go vet"}}, []string{"go vet"}, "disabled"},
+		{"empty allowlist", Config{AllowCommandContext: true}, []string{"go vet"}, "no commands are allowed"},
+		{"one denied", Config{AllowCommandContext: true, CommandAllowlist: []string{"go vet"}}, []string{"go vet", "go run ."}, "not allowed"},
+		{"all allowed", Config{AllowCommandContext: true, CommandAllowlist: []string{"go vet"}}, []string{"go vet", "go vet ./..."}, ""},
+		{"operands within the roots", sandboxed, []string{"go test ./...", "cat pkg/main.go ../README.md", "cat " + filepath.Join(root, "notes.txt")}, ""},
+		{"relative operand outside the roots", sandboxed, []string{"cat ../../etc/passwd"}, "file path is not allowed"},
+		{"absolute operand outside the roots", sandboxed, []string{"cat /etc/passwd"}, "file path is not allowed"},
+		{"operand through a symlink", sandboxed, []string{"cat escape/secret.txt"}, "file path is not allowed"},
+		{"one operand outside the roots", sandboxed, []string{"go test ./...", "go test ./pkg " + outside}, "file path is not allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := validateCommands(tt.commands, &tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("validateCommands() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || len(parsed) != len(tt.commands) {
+				t.Fatalf("validateCommands() = %v, %v", parsed, err)
+			}
+		})
+	}
+}
+
+func TestRunCommandUsesWorkDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "marker.txt"), []byte("here"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{CommandTimeout: 5 * time.Second, CommandMaxOutput: 1024, CommandWorkDir: dir}
+	result, err := runCommand(context.Background(), []string{"ls"}, cfg)
+	if err != nil {
+		t.Skipf("ls is not available: %v", err)
+	}
+	if result.ExitCode != 0 || !strings.Contains(result.Stdout, "marker.txt") {
+		t.Errorf("runCommand(ls) = %+v, want the listing of the working directory", result)
+	}
+}
+
+func TestCommandWorkDirConfig(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{"defaults to the first allowed path", map[string]string{"DEEPSEEK_ALLOWED_FILE_PATHS": dir + "," + os.TempDir()}, dir, false},
+		{"explicit", map[string]string{"DEEPSEEK_ALLOWED_FILE_PATHS": os.TempDir(), "DEEPSEEK_COMMAND_WORKDIR": dir}, dir, false},
+		{"outside the allowed paths", map[string]string{"DEEPSEEK_ALLOWED_FILE_PATHS": filepath.Join(dir, "sub"), "DEEPSEEK_COMMAND_WORKDIR": dir}, "", true},
+		{"missing", map[string]string{"DEEPSEEK_COMMAND_WORKDIR": filepath.Join(dir, "missing")}, "", true},
+		{"not a directory", map[string]string{"DEEPSEEK_COMMAND_WORKDIR": file}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEEPSEEK_API_KEY", "test-key")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			cfg, err := NewConfig()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("NewConfig() succeeded, want an invalid DEEPSEEK_COMMAND_WORKDIR error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.CommandWorkDir != tt.want {
+				t.Errorf("CommandWorkDir = %q, want %q", cfg.CommandWorkDir, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"strings"
+)
+
+// commentSyntax describes the comments and string literals of a language for strip_comments
+type commentSyntax struct {
+	line         []string // Line comment markers
+	blockStart   string   // Block comment start marker, empty if the language has none
+	blockEnd     string   // Block comment end marker
+	quotes       string   // Characters that delimit string literals
+	rawQuotes    string   // Quote characters whose literals have no escape sequences
+	tripleQuotes bool     // Whether tripled quote characters delimit multi-line strings
+	lineAtWord   bool     // Line comments only start at the beginning of a word
+}
+
+var (
+	cStyleComments = commentSyntax{line: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: `"'`}
+	hashComments   = commentSyntax{line: []string{"#"}, quotes: `"'`, lineAtWord: true}
+)
+
+// commentSyntaxes maps languages returned by getLanguageFromPath to their comment syntax
+var commentSyntaxes = map[string]commentSyntax{
+	"c":          cStyleComments,
+	"cpp":        cStyleComments,
+	"csharp":     cStyleComments,
+	"java":       cStyleComments,
+	"javascript": {line: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: "\"'`"},
+	"typescript": {line: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: "\"'`"},
+	"kotlin":     {line: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: `"'`, tripleQuotes: true},
+	"scala":      {line: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: `"'`, tripleQuotes: true},
+	"swift":      {line: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: `"`, tripleQuotes: true},
+	"dart":       {line: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: `"'`, tripleQuotes: true},
+	"groovy":     {line: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: `"'`, tripleQuotes: true},
+	"go":         {line: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: "\"'`", rawQuotes: "`"},
+	"rust":       {line: []string{"//"}, blockStart: "/*", blockEnd: "*/", quotes: `"`},
+	"php":        {line: []string{"//", "#"}, blockStart: "/*", blockEnd: "*/", quotes: `"'`},
+	"css":        {blockStart: "/*", blockEnd: "*/", quotes: `"'`},
+	"fsharp":     {line: []string{"//"}, blockStart: "(*", blockEnd: "*)", quotes: `"`},
+	"python":     {line: []string{"#"}, quotes: `"'`, tripleQuotes: true, lineAtWord: true},
+	"ruby":       hashComments,
+	"bash":       hashComments,
+	"perl":       hashComments,
+	"r":          hashComments,
+	"yaml":       hashComments,
+	"elixir":     {line: []string{"#"}, quotes: `"`, tripleQuotes: true, lineAtWord: true},
+	"julia":      {line: []string{"#"}, blockStart: "#=", blockEnd: "=#", quotes: `"`, tripleQuotes: true, lineAtWord: true},
+	"powershell": {line: []string{"#"}, blockStart: "<#", blockEnd: "#>", quotes: `"'`, lineAtWord: true},
+	"sql":        {line: []string{"--"}, blockStart: "/*", blockEnd: "*/", quotes: `"'`},
+	"lua":        {line: []string{"--"}, blockStart: "--[[", blockEnd: "]]", quotes: `"'`},
+	"haskell":    {line: []string{"--"}, blockStart: "{-", blockEnd: "-}", quotes: `"`},
+	"html":       {blockStart: "<!--", blockEnd: "-->"},
+	"xml":        {blockStart: "<!--", blockEnd: "-->"},
+	"markdown":   {blockStart: "<!--", blockEnd: "-->"},
+	"erlang":     {line: []string{"%"}, quotes: `"`},
+	"matlab":     {line: []string{"%"}, blockStart: "%{", blockEnd: "%}", quotes: `"`},
+	"clojure":    {line: []string{";"}, quotes: `"`},
+	"vbnet":      {line: []string{"'"}, quotes: `"`, rawQuotes: `"`},
+}
+
+// stripComments removes comments from content according to the comment syntax of language,
+// preserving string literals, and collapses runs of blank lines. Content in languages without
+// known comment syntax is only trimmed of blank line runs. A leading shebang line is kept.
+func stripComments(content, language string) string {
+	syntax, ok := commentSyntaxes[language]
+	if !ok {
+		return collapseBlankLines(content)
+	}
+
+	var shebang string
+	if strings.HasPrefix(content, "#!") {
+		if end := strings.IndexByte(content, '\n'); end >= 0 {
+			shebang, content = content[:end+1], content[end+1:]
+		} else {
+			return content
+		}
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(content))
+	for i := 0; i < len(content); {
+		rest := content[i:]
+
+		// Block comments are checked before line comments, since markers like "--[[" and "#="
+		// start with a line comment marker
+		if syntax.blockStart != "" && strings.HasPrefix(rest, syntax.blockStart) {
+			end := strings.Index(rest[len(syntax.blockStart):], syntax.blockEnd)
+			if end < 0 {
+				break // Unterminated block comment runs to the end of the file
+			}
+			comment := rest[:len(syntax.blockStart)+end+len(syntax.blockEnd)]
+			if strings.Contains(comment, "\n") {
+				sb.WriteByte('\n')
+			} else {
+				sb.WriteByte(' ')
+			}
+			i += len(comment)
+			continue
+		}
+
+		if lineCommentAt(content, i, syntax) {
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				break
+			}
+			i += end // Keep the newline
+			continue
+		}
+
+		if c := content[i]; strings.IndexByte(syntax.quotes, c) >= 0 {
+			literal := stringLiteralAt(rest, c, syntax)
+			sb.WriteString(literal)
+			i += len(literal)
+			continue
+		}
+
+		sb.WriteByte(content[i])
+		i++
+	}
+	return shebang + collapseBlankLines(sb.String())
+}
+
+// lineCommentAt reports whether a line comment starts at position i of content
+func lineCommentAt(content string, i int, syntax commentSyntax) bool {
+	for _, marker := range syntax.line {
+		if !strings.HasPrefix(content[i:], marker) {
+			continue
+		}
+		if syntax.lineAtWord && i > 0 && !strings.ContainsRune(" \t\n;", rune(content[i-1])) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// stringLiteralAt returns the string literal starting with quote at the beginning of s.
+// An unterminated literal extends to the end of the line, or of s for multi-line literals.
+func stringLiteralAt(s string, quote byte, syntax commentSyntax) string {
+	if syntax.tripleQuotes {
+		triple := strings.Repeat(string(quote), 3)
+		if strings.HasPrefix(s, triple) {
+			if end := strings.Index(s[3:], triple); end >= 0 {
+				return s[:3+end+3]
+			}
+			return s
+		}
+	}
+
+	raw := strings.IndexByte(syntax.rawQuotes, quote) >= 0
+	for j := 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			if !raw {
+				j++
+			}
+		case quote:
+			return s[:j+1]
+		case '\n':
+			if !raw {
+				return s[:j]
+			}
+		}
+	}
+	return s
+}
+
+// collapseBlankLines trims trailing whitespace from each line and replaces runs of
+// blank lines with a single blank line
+func collapseBlankLines(content string) string {
+	lines := strings.Split(content, "\n")
+	out := lines[:0]
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			if blank || len(out) == 0 {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		out = append(out, line)
+	}
+	return strings.TrimRight(strings.Join(out, "\n"), "\n") + "\n"
+}
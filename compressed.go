@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// isGzipPath reports whether a path names a gzip-compressed file
+func isGzipPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".gz")
+}
+
+// uncompressedPath returns the name of a compressed file without its compression extension,
+// e.g. app.log for app.log.gz, so that its type and language come from the inner name
+func uncompressedPath(path string) string {
+	if !isGzipPath(path) {
+		return path
+	}
+	return path[:len(path)-len(".gz")]
+}
+
+// decompressGzip decompresses gzip content, failing once more than limit bytes would be
+// produced so that a small decompression bomb cannot exhaust memory
+func decompressGzip(content []byte, limit int64) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress gzip content: %w", err)
+	}
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress gzip content: %w", err)
+	}
+	if int64(len(decompressed)) > limit {
+		return nil, fmt.Errorf("%w: decompressed content exceeds %s", errFileTooLarge, humanReadableSize(limit))
+	}
+	return decompressed, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// gzipBytes compresses content with gzip
+func gzipBytes(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUncompressedPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		wantGzip bool
+		want     string
+	}{
+		{"logs/app.log.gz", true, "logs/app.log"},
+		{"main.go.GZ", true, "main.go"},
+		{"archive.tgz", false, "archive.tgz"},
+		{"main.go", false, "main.go"},
+	}
+	for _, tt := range tests {
+		if got := isGzipPath(tt.path); got != tt.wantGzip {
+			t.Errorf("isGzipPath(%q) = %v, want %v", tt.path, got, tt.wantGzip)
+		}
+		if got := uncompressedPath(tt.path); got != tt.want {
+			t.Errorf("uncompressedPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestDecompressGzip(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   []byte
+		limit     int64
+		want      string
+		wantErr   string
+		wantLarge bool
+	}{
+		{name: "within the limit", content: gzipBytes(t, "hello"), limit: 5, want: "hello"},
+		{name: "over the limit", content: gzipBytes(t, strings.Repeat("a", 1000)), limit: 999, wantErr: "exceeds", wantLarge: true},
+		{name: "not gzip", content: []byte("plain text"), limit: 100, wantErr: "failed to decompress"},
+		{name: "truncated", content: gzipBytes(t, "hello world")[:15], limit: 100, wantErr: "failed to decompress"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decompressGzip(tt.content, tt.limit)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("decompressGzip() error = %v, want %q", err, tt.wantErr)
+				}
+				if errors.Is(err, errFileTooLarge) != tt.wantLarge {
+					t.Errorf("decompressGzip() error %v is errFileTooLarge = %v, want %v", err, !tt.wantLarge, tt.wantLarge)
+				}
+				return
+			}
+			if err != nil || string(got) != tt.want {
+				t.Errorf("decompressGzip() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAskIncludesGzipFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go.gz")
+	if err := os.WriteFile(path, gzipBytes(t, "package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client := &mockDeepseekClient{}
+	s := newTestServer(t, client, map[string]string{"DEEPSEEK_ALLOWED_FILE_PATHS": dir})
+	callTool(t, s.handleAskDeepseek, map[string]any{"query": "review", "file_paths": []any{path}})
+	if client.calls() != 1 {
+		t.Fatalf("got %d requests, want 1", client.calls())
+	}
+	messages := client.requests[0].Messages
+	if prompt := messages[len(messages)-1].Content; !strings.Contains(prompt, "```go\npackage main") {
+		t.Errorf("prompt %q does not contain the decompressed Go file", prompt)
+	}
+}
+
+func TestReadGzipFileExcerpt(t *testing.T) {
+	var numbered strings.Builder
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&numbered, "line %04d\n", i)
+	}
+	dir := t.TempDir()
+
+	tests := []struct {
+		name           string
+		content        string
+		truncate       string
+		wantErr        string
+		wantHead       string
+		wantTail       string
+		wantCompressed bool // Whether the compressed file itself is over the maximum size
+	}{
+		{name: "compressed file over the maximum size", content: numbered.String()[:10000], truncate: "true",
+			wantHead: "line 0000\n", wantTail: "line 0999\n", wantCompressed: true},
+		{name: "decompressed content over the maximum size", content: strings.Repeat("a\n", 4000) + "end\n", truncate: "true",
+			wantHead: "a\n", wantTail: "end\n"},
+		{name: "not truncated", content: strings.Repeat("a\n", 5000), truncate: "false", wantErr: "exceeds"},
+		{name: "decompression cap", content: numbered.String(), truncate: "true", wantErr: "too large to excerpt", wantCompressed: true},
+		{name: "decompression cap with a small file", content: strings.Repeat("a\n", 6000), truncate: "true", wantErr: "too large to excerpt"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("app%d.txt.gz", i))
+			compressed := gzipBytes(t, tt.content)
+			if err := os.WriteFile(path, compressed, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cfg := newTestConfig(t, map[string]string{
+				"DEEPSEEK_ALLOWED_FILE_PATHS":       dir,
+				"DEEPSEEK_MAX_FILE_SIZE":            "1000",
+				"DEEPSEEK_TRUNCATE_LARGE_FILES":     tt.truncate,
+				"DEEPSEEK_LARGE_FILE_EXCERPT_BYTES": "100",
+			})
+			if got := len(compressed) > 1000; got != tt.wantCompressed {
+				t.Fatalf("compressed size %d over the maximum = %v, want %v", len(compressed), got, tt.wantCompressed)
+			}
+
+			result := readValidatedFile(path, cfg, nil)
+			if tt.wantErr != "" {
+				if result.Err == nil || !strings.Contains(result.Err.Error(), tt.wantErr) {
+					t.Fatalf("readValidatedFile() error = %v, want %q", result.Err, tt.wantErr)
+				}
+				return
+			}
+			if result.Err != nil {
+				t.Fatalf("readValidatedFile() error = %v", result.Err)
+			}
+			content := string(result.Content)
+			if !strings.HasPrefix(content, tt.wantHead) || !strings.HasSuffix(content, tt.wantTail) || !strings.Contains(content, "bytes omitted") {
+				t.Errorf("content = %q, want an excerpt from %q to %q", content, tt.wantHead, tt.wantTail)
+			}
+			if !utf8.Valid(result.Content) || len(content) > 200 {
+				t.Errorf("content of %d bytes is not a decompressed excerpt", len(content))
+			}
+			if marker := fmt.Sprintf("... %d bytes omitted ...\n", result.Omitted); int64(len(content)-len(marker))+result.Omitted != int64(len(tt.content)) {
+				t.Errorf("omitted = %d, want the %d bytes left out of %d", result.Omitted, len(tt.content)-len(content)+len(marker), len(tt.content))
+			}
+		})
+	}
+}
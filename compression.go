@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// Context compression modes for files that do not fit in the total file size budget
+const (
+	ContextCompressionNone      = "none"
+	ContextCompressionTruncate  = "truncate"
+	ContextCompressionSummarize = "summarize"
+)
+
+// maxCachedSummaries bounds the number of file summaries kept in memory
+const maxCachedSummaries = 256
+
+// summarizeSystemPrompt instructs the summary model how to condense a file
+const summarizeSystemPrompt = "You condense source files for another model that will answer questions about them. " +
+	"Summarize the file below: its purpose, the main types, functions and their signatures, important constants, " +
+	"and any non-obvious behavior. Be concise and factual, and do not speculate about code you cannot see."
+
+// isValidContextCompression reports whether mode is a supported context compression mode
+func isValidContextCompression(mode string) bool {
+	switch mode {
+	case ContextCompressionNone, ContextCompressionTruncate, ContextCompressionSummarize:
+		return true
+	}
+	return false
+}
+
+// truncateFileContent cuts content to at most maxBytes, at a line boundary where possible,
+// and marks where it was cut
+func truncateFileContent(content []byte, maxBytes int64) []byte {
+	if int64(len(content)) <= maxBytes {
+		return content
+	}
+	cut := content[:maxBytes]
+	if i := bytes.LastIndexByte(cut, '\n'); i > 0 {
+		cut = cut[:i+1]
+	}
+	marker := fmt.Sprintf("\n... [truncated: %d of %d bytes shown]\n", len(cut), len(content))
+	return append(append([]byte{}, cut...), marker...)
+}
+
+// summaryCache keeps file summaries keyed by the hash of the summary model and file content,
+// evicting the least recently used summary when it holds maxCachedSummaries
+type summaryCache struct {
+	mu      sync.Mutex
+	max     int
+	entries map[string]*list.Element
+	lru     *list.List // Front is the most recently used
+}
+
+// summaryCacheEntry is a cached summary in the LRU list
+type summaryCacheEntry struct {
+	key     string
+	summary string
+}
+
+// newSummaryCache creates an empty summary cache
+func newSummaryCache() *summaryCache {
+	return &summaryCache{max: maxCachedSummaries, entries: make(map[string]*list.Element), lru: list.New()}
+}
+
+// summaryKey returns the cache key for a file summarized with a model
+func summaryKey(model string, content []byte) string {
+	h := sha256.New()
+	h.Write([]byte(model))
+	h.Write([]byte{0})
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the cached summary for key, marking it as recently used
+func (c *summaryCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*summaryCacheEntry).summary, true
+}
+
+// Put stores a summary, evicting the least recently used summaries when the cache is full
+func (c *summaryCache) Put(key, summary string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*summaryCacheEntry).summary = summary
+		c.lru.MoveToFront(elem)
+		return
+	}
+	for c.lru.Len() >= c.max {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*summaryCacheEntry).key)
+	}
+	c.entries[key] = c.lru.PushFront(&summaryCacheEntry{key: key, summary: summary})
+}
+
+// summarizeFile condenses a file with the configured summary model, reusing cached summaries
+func (s *DeepseekServer) summarizeFile(ctx context.Context, path string, content []byte) (string, error) {
+	model := s.configFrom(ctx).SummaryModel
+	key := summaryKey(model, content)
+	if summary, ok := s.summaries.Get(key); ok {
+		s.logger.Debug("Using cached summary of %s", path)
+		return summary, nil
+	}
+
+	// The summary is a request of its own: the user, seed, n and response format of the
+	// request being answered must not be sent with it
+	s.logger.Info("Summarizing %s (%s) with %s", path, humanReadableSize(int64(len(content))), model)
+	response, err := s.createChatCompletion(withoutRequestBodyFields(ctx), &deepseek.ChatCompletionRequest{
+		Model: model,
+		Messages: []deepseek.ChatCompletionMessage{
+			{Role: deepseek.ChatMessageRoleSystem, Content: summarizeSystemPrompt},
+			{Role: deepseek.ChatMessageRoleUser, Content: fmt.Sprintf("File: %s\n\n%s", path, content)},
+		},
+		Temperature: 0,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize %s: %w", path, err)
+	}
+	if len(response.Choices) == 0 || response.Choices[0].Message.Content == "" {
+		return "", fmt.Errorf("failed to summarize %s: the model returned an empty summary", path)
+	}
+
+	summary := response.Choices[0].Message.Content
+	s.summaries.Put(key, summary)
+	return summary, nil
+}
+
+// formatSummarizedFile marks a file summary so the model knows it is not the original content
+func formatSummarizedFile(summary string, originalSize int) []byte {
+	return []byte(fmt.Sprintf("[Summarized: this file (%s) exceeded the context budget, so this is a condensed summary, not the original content.]\n\n%s",
+		humanReadableSize(int64(originalSize)), summary))
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTruncateFileContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		maxBytes int64
+		want     string
+	}{
+		{"fits", "a\nb\n", 10, "a\nb\n"},
+		{"cut at line", "line one\nline two\n", 12, "line one\n\n... [truncated: 9 of 18 bytes shown]\n"},
+		{"no line break", "abcdefghij", 4, "abcd\n... [truncated: 4 of 10 bytes shown]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(truncateFileContent([]byte(tt.content), tt.maxBytes)); got != tt.want {
+				t.Errorf("truncateFileContent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummaryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newSummaryCache()
+	c.max = 2
+	c.Put("a", "summary a")
+	c.Put("b", "summary b")
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("Get(a) missed before eviction")
+	}
+	c.Put("c", "summary c") // b is the least recently used
+
+	tests := []struct {
+		key    string
+		wantOK bool
+	}{
+		{"a", true},
+		{"b", false},
+		{"c", true},
+	}
+	for _, tt := range tests {
+		if _, ok := c.Get(tt.key); ok != tt.wantOK {
+			t.Errorf("Get(%s) ok = %v, want %v", tt.key, ok, tt.wantOK)
+		}
+	}
+
+	c.Put("a", "new summary a")
+	if got, _ := c.Get("a"); got != "new summary a" || c.lru.Len() != 2 {
+		t.Errorf("Put of an existing key: Get = %q with %d entries, want the new summary and 2 entries", got, c.lru.Len())
+	}
+}
+
+func TestSummarizeFileSendsNoRequestBodyFields(t *testing.T) {
+	client := &mockDeepseekClient{}
+	s := newTestServer(t, client, nil)
+
+	ctx := withRequestBodyField(context.Background(), "n", 3)
+	ctx = withRequestBodyField(ctx, "seed", int64(7))
+	ctx = withRequestBodyField(ctx, "user", "alice")
+	summary, err := s.summarizeFile(ctx, "main.go", []byte("package main"))
+	if err != nil || summary != "ok" {
+		t.Fatalf("summarizeFile() = %q, %v", summary, err)
+	}
+	if len(client.bodyFields[0]) != 0 {
+		t.Errorf("summary request carried body fields %v, want none", client.bodyFields[0])
+	}
+
+	// A second summary of the same content is served from the cache
+	if _, err := s.summarizeFile(ctx, "copy.go", []byte("package main")); err != nil {
+		t.Fatal(err)
+	}
+	if client.calls() != 1 {
+		t.Errorf("summary requests = %d, want 1", client.calls())
+	}
+}
+
+func TestFormatSummarizedFile(t *testing.T) {
+	got := string(formatSummarizedFile("short", 2048))
+	if !strings.HasPrefix(got, "[Summarized:") || !strings.HasSuffix(got, "short") || !strings.Contains(got, "2.0 KB") {
+		t.Errorf("formatSummarizedFile() = %q", got)
+	}
+}
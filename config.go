@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
@@ -12,25 +13,122 @@ import (
 // Config holds the configuration for the DeepseekMCP server
 type Config struct {
 	// API configuration
-	DeepseekAPIKey       string
-	DeepseekModel        string
-	DeepseekSystemPrompt string
-	MaxFileSize          int64
-	AllowedFileTypes     []string
-	DeepseekTemperature  float32
-	HTTPTimeout          time.Duration
-	MaxRetries           int
-	InitialBackoff       time.Duration
-	MaxBackoff           time.Duration
-	AllowedFilePaths     []string // New field for allowed file paths
-	LogLevel             string   // New field for log level
+	DeepseekAPIKey              string
+	DeepseekModel               string
+	ModelFallback               string // Model used when DeepseekModel is not served by the API
+	SummaryModel                string // Model used to summarize files for context_compression=summarize
+	StrictModelValidation       bool   // Fail at startup instead of falling back when DeepseekModel is unavailable
+	DeepseekSystemPrompt        string
+	SystemPromptPrefix          string // Prepended to every system prompt, regardless of the request
+	SystemPromptSuffix          string // Appended to every system prompt, regardless of the request
+	MaxFileSize                 int64
+	TruncateLargeFiles          bool  // Include the head and tail of files over MaxFileSize instead of skipping them
+	LargeFileExcerptBytes       int64 // Size of the head and tail kept of a large file, 0 for MaxFileSize
+	MaxTotalFileSize            int64 // Maximum combined size of files included in one request (0 disables)
+	FileCacheMaxBytes           int64 // Maximum total size of cached file contents (0 disables the cache)
+	AllowedFileTypes            []string
+	MimeDetection               string // How file types are detected: extension, content or both
+	FileOrder                   string // Default order of attached files in the prompt
+	DeepseekTemperature         float32
+	TemperatureSet              bool // The temperature was configured rather than defaulted
+	NoteIgnoredTemperature      bool // Append a note to deepseek_ask responses from models that ignore the temperature
+	HTTPTimeout                 time.Duration
+	ConnectTimeout              time.Duration            // Bounds establishing the connection and TLS handshake
+	ResponseTimeout             time.Duration            // Bounds each chat completion attempt, including reading the response
+	ToolTimeouts                map[string]time.Duration // Timeouts of each API call attempt by tool name, overriding HTTPTimeout and ResponseTimeout
+	MaxRetries                  int
+	RetryOnEmpty                bool // Retry once when the model returns an empty response
+	InitialBackoff              time.Duration
+	MaxBackoff                  time.Duration
+	MaxRetryWait                time.Duration           // Upper bound on waits requested by Retry-After headers
+	BreakerThreshold            int                     // Consecutive API failures that open the circuit breaker (0 disables it)
+	BreakerCooldown             time.Duration           // How long the open circuit breaker fails fast before probing
+	BalanceLogInterval          time.Duration           // How often the balance is logged in the background, 0 to disable
+	AllowedFilePaths            []string                // New field for allowed file paths
+	StrictAllowedPaths          bool                    // Fail at startup instead of warning when an allowed path is not a directory
+	WritableFilePaths           []string                // Roots deepseek_ask may write responses to with output_file (empty disables writing)
+	EnabledTools                []string                // Tools to register (empty registers all tools)
+	DisabledTools               []string                // Tools never registered, applied after EnabledTools
+	DisableFileAccess           bool                    // Reject all file access regardless of allowed paths
+	AllowPerRequestTypeOverride bool                    // Let deepseek_ask requests allow extra file types for that call only
+	AllowReloadTool             bool                    // Registers the deepseek_reload tool
+	AllowCommandContext         bool                    // Lets deepseek_ask include the output of allowlisted commands
+	CommandAllowlist            []string                // Command prefixes deepseek_ask may run, e.g. "go vet"
+	CommandTimeout              time.Duration           // Time allowed for each command
+	CommandMaxOutput            int                     // Maximum bytes kept of each of a command's stdout and stderr
+	CommandWorkDir              string                  // Directory commands run in
+	LogLevel                    string                  // New field for log level
+	LogMaxFieldChars            int                     // Maximum characters of query and response content written to the log (0 disables truncation)
+	FileTemplate                string                  // Optional text/template used to render included files
+	MaxResponseChars            int                     // Split responses longer than this into parts (0 disables)
+	Presets                     map[string]Preset       // Named system prompt presets
+	MaxFileReadConcurrency      int                     // Maximum number of files read in parallel
+	MaxOpenFiles                int                     // Maximum number of files held open at once across all requests, 0 for no limit
+	DefaultUser                 string                  // End-user identifier sent with requests for abuse monitoring
+	MaxConcurrentRequests       int                     // Maximum number of concurrent API requests (0 disables)
+	QueueDepth                  int                     // Maximum number of requests waiting for a free request slot (0 for no limit)
+	MaxQueueWait                time.Duration           // Longest wait for a free request slot (0 for no limit)
+	MaxBatchSize                int                     // Maximum number of queries in one deepseek_batch call
+	UsageLedgerPath             string                  // JSON Lines file persisting token usage (empty keeps it in memory)
+	Organization                string                  // Organization sent with API requests and recorded in the usage ledger
+	Project                     string                  // Project sent with API requests and recorded in the usage ledger
+	ModelCapabilityTable        map[string][]string     // Supported features per model ID
+	ModelRecommendations        map[string][]string     // Use cases each model is recommended for, by model ID
+	TranscodeFiles              bool                    // Convert non-UTF-8 file contents to UTF-8 before inclusion
+	LanguageOverrides           map[string]string       // Languages of included files by lower-case extension or file name
+	UseEditorConfig             bool                    // Take file languages from language properties in .editorconfig files
+	ExtractNotebookCells        bool                    // Include only the code and markdown cells of Jupyter notebooks
+	MaxQueryChars               int                     // Maximum length of the deepseek_ask query in characters (0 disables the check)
+	MaxFilePaths                int                     // Maximum number of deepseek_ask file_paths, before and after expansion (0 disables the check)
+	MaxHistoryTokens            int                     // Maximum estimated tokens of a deepseek_ask history_file (0 disables the check)
+	MaxContinuations            int                     // Maximum number of times a truncated response is continued
+	MaxChoices                  int                     // Maximum value of the deepseek_ask "n" parameter
+	MaxSelfConsistency          int                     // Maximum value of the deepseek_ask "self_consistency" parameter
+	ModelPricing                map[string]ModelPricing // Price per million tokens per model ID
+	CostWarningThreshold        float64                 // Estimated USD cost above which deepseek_ask requires confirm_cost (0 disables)
+	ReasoningTokenReserve       int                     // Completion tokens reserved for hidden reasoning in cost estimates for reasoning models
+	EscalationModels            []string                // Models tried in order when deepseek_ask allows escalation and the response is a refusal or too short
+	EscalationMinChars          int                     // Responses shorter than this are escalated
+	MaxEscalations              int                     // Maximum number of escalated requests per deepseek_ask call
+	EscalationMaxCost           float64                 // Maximum USD cost of a request including its escalations (0 disables the bound)
+	RefusalPatterns             []refusalPattern        // Patterns flagging responses as refusals; they also trigger escalation
+	OfflineMode                 bool                    // Serve canned responses without calling the API, for development only
+	StructuredResults           bool                    // Add structured metadata blocks to deepseek_ask and deepseek_models results
+	MinBalance                  float64                 // Balance below which completion requests are refused, 0 to disable
+	MitigatePromptInjection     bool                    // Delimit included files as untrusted data and log injection-like phrases in them
+	RedactionRulesPath          string                  // JSON file with rules redacting file content before it is sent
+	RedactionRules              []redactionRule         // Loaded from RedactionRulesPath
+	OfflineFixturesPath         string                  // JSON file with the canned responses of offline mode
+	OfflineFixtures             *offlineFixtures        // Loaded from OfflineFixturesPath, nil for the built-in responses
 }
 
 // NewConfig creates a new configuration instance from environment variables
 func NewConfig() (*Config, error) {
-	// Read API key (required)
+	// Read offline mode switch (optional, defaults to false). For development only: the API is
+	// never called and canned responses are returned instead.
+	offlineMode := false
+	if offlineStr := os.Getenv("DEEPSEEK_OFFLINE"); offlineStr != "" {
+		var err error
+		offlineMode, err = strconv.ParseBool(offlineStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_OFFLINE: %w", err)
+		}
+	}
+
+	// Read offline fixtures (optional, defaults to built-in canned responses)
+	offlineFixturesPath := os.Getenv("DEEPSEEK_OFFLINE_FIXTURES_FILE")
+	var offlineFixtures *offlineFixtures
+	if offlineMode && offlineFixturesPath != "" {
+		var err error
+		offlineFixtures, err = loadOfflineFixtures(offlineFixturesPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_OFFLINE_FIXTURES_FILE: %w", err)
+		}
+	}
+
+	// Read API key (required unless in offline mode)
 	apiKey := os.Getenv("DEEPSEEK_API_KEY")
-	if apiKey == "" {
+	if apiKey == "" && !offlineMode {
 		return nil, errors.New("DEEPSEEK_API_KEY environment variable is required")
 	}
 
@@ -40,6 +138,25 @@ func NewConfig() (*Config, error) {
 		model = "deepseek-reasoner"
 	}
 
+	// Read model fallback (optional, defaults to the first available model)
+	modelFallback := os.Getenv("DEEPSEEK_MODEL_FALLBACK")
+
+	// Read strict model validation (optional, defaults to false)
+	strictModelValidation := false
+	if strictStr := os.Getenv("DEEPSEEK_STRICT_MODEL_VALIDATION"); strictStr != "" {
+		var err error
+		strictModelValidation, err = strconv.ParseBool(strictStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_STRICT_MODEL_VALIDATION: %w", err)
+		}
+	}
+
+	// Read summary model (optional, defaults to deepseek-chat)
+	summaryModel := os.Getenv("DEEPSEEK_SUMMARY_MODEL")
+	if summaryModel == "" {
+		summaryModel = "deepseek-chat"
+	}
+
 	// Read system prompt (optional)
 	systemPrompt := os.Getenv("DEEPSEEK_SYSTEM_PROMPT")
 	if systemPrompt == "" {
@@ -52,14 +169,15 @@ func NewConfig() (*Config, error) {
 			}
 			systemPrompt = string(data)
 		} else {
-			// Default system prompt for code review
-			systemPrompt = "You are a helpful AI assistant that specializes in code review and software engineering. " +
-				"Provide thorough and insightful analysis with specific, actionable feedback. " +
-				"Focus on issues like bugs, security vulnerabilities, performance problems, and code quality. " +
-				"Include examples and explanations in your reviews."
+			// Default system prompt of the code mode
+			systemPrompt = promptModeProfiles[PromptModeCode]
 		}
 	}
 
+	// Read system prompt guardrails (optional, wrapped around every system prompt)
+	systemPromptPrefix := os.Getenv("DEEPSEEK_SYSTEM_PROMPT_PREFIX")
+	systemPromptSuffix := os.Getenv("DEEPSEEK_SYSTEM_PROMPT_SUFFIX")
+
 	// Read max file size (optional, defaults to 10MB)
 	maxFileSizeStr := os.Getenv("DEEPSEEK_MAX_FILE_SIZE")
 	var maxFileSize int64 = 10 * 1024 * 1024 // 10MB default
@@ -71,6 +189,51 @@ func NewConfig() (*Config, error) {
 		}
 	}
 
+	// Read large file truncation switch (optional, defaults to false, which skips large files)
+	truncateLargeFiles := false
+	if truncateStr := os.Getenv("DEEPSEEK_TRUNCATE_LARGE_FILES"); truncateStr != "" {
+		var err error
+		truncateLargeFiles, err = strconv.ParseBool(truncateStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_TRUNCATE_LARGE_FILES: %w", err)
+		}
+	}
+
+	// Read large file excerpt size (optional, defaults to 0, which uses the max file size)
+	var largeFileExcerptBytes int64
+	if excerptStr := os.Getenv("DEEPSEEK_LARGE_FILE_EXCERPT_BYTES"); excerptStr != "" {
+		var err error
+		largeFileExcerptBytes, err = strconv.ParseInt(excerptStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_LARGE_FILE_EXCERPT_BYTES: %w", err)
+		}
+		if largeFileExcerptBytes < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_LARGE_FILE_EXCERPT_BYTES: must not be negative")
+		}
+	}
+
+	// Read file cache size (optional, defaults to 64MB, 0 disables the cache)
+	fileCacheMaxBytesStr := os.Getenv("DEEPSEEK_FILE_CACHE_MAX_BYTES")
+	var fileCacheMaxBytes int64 = 64 * 1024 * 1024
+	if fileCacheMaxBytesStr != "" {
+		var err error
+		fileCacheMaxBytes, err = strconv.ParseInt(fileCacheMaxBytesStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_FILE_CACHE_MAX_BYTES: %w", err)
+		}
+	}
+
+	// Read max total file size (optional, defaults to 0 meaning no limit)
+	maxTotalFileSizeStr := os.Getenv("DEEPSEEK_MAX_TOTAL_FILE_SIZE")
+	var maxTotalFileSize int64
+	if maxTotalFileSizeStr != "" {
+		var err error
+		maxTotalFileSize, err = strconv.ParseInt(maxTotalFileSizeStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_TOTAL_FILE_SIZE: %w", err)
+		}
+	}
+
 	// Read allowed file types (optional, defaults to common code file types)
 	allowedFileTypesStr := os.Getenv("DEEPSEEK_ALLOWED_FILE_TYPES")
 	var allowedFileTypes []string
@@ -80,15 +243,34 @@ func NewConfig() (*Config, error) {
 			"text/plain", "text/x-go", "text/x-python", "text/javascript",
 			"text/markdown", "text/x-java", "text/x-c", "text/x-c++",
 			"text/csv", "application/json", "text/x-yaml", "text/x-toml",
-			"text/html", "text/css", "application/xml",
+			"text/html", "text/css", "application/xml", "text/x-sql",
 		}
 	} else {
 		allowedFileTypes = strings.Split(allowedFileTypesStr, ",")
 	}
 
+	// Read MIME detection mode (optional, defaults to extension-only)
+	mimeDetection := strings.ToLower(os.Getenv("DEEPSEEK_MIME_DETECTION"))
+	if mimeDetection == "" {
+		mimeDetection = MimeDetectionExtension
+	}
+	if !isValidMimeDetection(mimeDetection) {
+		return nil, fmt.Errorf("invalid DEEPSEEK_MIME_DETECTION %q: must be one of extension, content, both", mimeDetection)
+	}
+
+	// Read file ordering (optional, defaults to as-given)
+	fileOrder := strings.ToLower(os.Getenv("DEEPSEEK_FILE_ORDER"))
+	if fileOrder == "" {
+		fileOrder = FileOrderAsGiven
+	}
+	if !isValidFileOrder(fileOrder) {
+		return nil, fmt.Errorf("invalid DEEPSEEK_FILE_ORDER %q: must be one of as-given, alphabetical, size-asc, size-desc", fileOrder)
+	}
+
 	// Read temperature (optional, defaults to 0.4)
 	tempStr := os.Getenv("DEEPSEEK_TEMPERATURE")
 	var temperature float32 = 0.4
+	temperatureSet := tempStr != ""
 	if tempStr != "" {
 		tempFloat, err := strconv.ParseFloat(tempStr, 32)
 		if err != nil {
@@ -97,6 +279,16 @@ func NewConfig() (*Config, error) {
 		temperature = float32(tempFloat)
 	}
 
+	// Read ignored temperature note switch (optional, defaults to false)
+	noteIgnoredTemperature := false
+	if noteStr := os.Getenv("DEEPSEEK_NOTE_IGNORED_TEMPERATURE"); noteStr != "" {
+		var err error
+		noteIgnoredTemperature, err = strconv.ParseBool(noteStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_NOTE_IGNORED_TEMPERATURE: %w", err)
+		}
+	}
+
 	// Read HTTP timeout (optional, defaults to 120 seconds)
 	timeoutStr := os.Getenv("DEEPSEEK_TIMEOUT")
 	timeout := 270 * time.Second // Default timeout
@@ -114,6 +306,32 @@ func NewConfig() (*Config, error) {
 		}
 	}
 
+	// Read connect timeout (optional, defaults to 10 seconds)
+	connectTimeout := 10 * time.Second
+	if connectTimeoutStr := os.Getenv("DEEPSEEK_CONNECT_TIMEOUT"); connectTimeoutStr != "" {
+		var err error
+		connectTimeout, err = parseTimeout(connectTimeoutStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_CONNECT_TIMEOUT value %q: %w", connectTimeoutStr, err)
+		}
+	}
+
+	// Read response timeout (optional, defaults to the HTTP timeout)
+	responseTimeout := timeout
+	if responseTimeoutStr := os.Getenv("DEEPSEEK_RESPONSE_TIMEOUT"); responseTimeoutStr != "" {
+		var err error
+		responseTimeout, err = parseTimeout(responseTimeoutStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_RESPONSE_TIMEOUT value %q: %w", responseTimeoutStr, err)
+		}
+	}
+
+	// Read per-tool timeouts (optional, defaults to none)
+	toolTimeouts, err := parseToolTimeouts(os.Getenv("DEEPSEEK_TOOL_TIMEOUTS"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DEEPSEEK_TOOL_TIMEOUTS: %w", err)
+	}
+
 	// Read max retries (optional, defaults to 2)
 	maxRetriesStr := os.Getenv("DEEPSEEK_MAX_RETRIES")
 	maxRetries := 2
@@ -125,6 +343,16 @@ func NewConfig() (*Config, error) {
 		}
 	}
 
+	// Read retry on empty response switch (optional, defaults to false)
+	retryOnEmpty := false
+	if retryOnEmptyStr := os.Getenv("DEEPSEEK_RETRY_ON_EMPTY"); retryOnEmptyStr != "" {
+		var err error
+		retryOnEmpty, err = strconv.ParseBool(retryOnEmptyStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_RETRY_ON_EMPTY: %w", err)
+		}
+	}
+
 	// Read initial backoff (optional, defaults to 1 second)
 	initialBackoffStr := os.Getenv("DEEPSEEK_INITIAL_BACKOFF")
 	initialBackoff := 1 * time.Second
@@ -147,7 +375,60 @@ func NewConfig() (*Config, error) {
 		}
 	}
 
-	// Read allowed file paths (optional, defaults to current working directory)
+	// Read maximum Retry-After wait (optional, defaults to 60 seconds)
+	maxRetryWait := 60 * time.Second
+	if maxRetryWaitStr := os.Getenv("DEEPSEEK_MAX_RETRY_WAIT"); maxRetryWaitStr != "" {
+		var err error
+		maxRetryWait, err = parseTimeout(maxRetryWaitStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_RETRY_WAIT: %w", err)
+		}
+	}
+
+	// Read circuit breaker threshold (optional, defaults to 5)
+	breakerThreshold := 5
+	if breakerThresholdStr := os.Getenv("DEEPSEEK_BREAKER_THRESHOLD"); breakerThresholdStr != "" {
+		var err error
+		breakerThreshold, err = strconv.Atoi(breakerThresholdStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_BREAKER_THRESHOLD: %w", err)
+		}
+		if breakerThreshold < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_BREAKER_THRESHOLD: must not be negative")
+		}
+	}
+
+	// Read circuit breaker cooldown (optional, defaults to 30 seconds)
+	breakerCooldown := 30 * time.Second
+	if breakerCooldownStr := os.Getenv("DEEPSEEK_BREAKER_COOLDOWN"); breakerCooldownStr != "" {
+		var err error
+		breakerCooldown, err = time.ParseDuration(breakerCooldownStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_BREAKER_COOLDOWN: %w", err)
+		}
+		if breakerCooldown < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_BREAKER_COOLDOWN: must not be negative")
+		}
+	}
+
+	// Read balance log interval (optional, defaults to 0 meaning disabled)
+	var balanceLogInterval time.Duration
+	if balanceLogIntervalStr := os.Getenv("DEEPSEEK_BALANCE_LOG_INTERVAL"); balanceLogIntervalStr != "" {
+		var err error
+		balanceLogInterval, err = time.ParseDuration(balanceLogIntervalStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_BALANCE_LOG_INTERVAL: %w", err)
+		}
+		if balanceLogInterval < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_BALANCE_LOG_INTERVAL: must not be negative")
+		}
+		if balanceLogInterval > 0 && balanceLogInterval < minBalanceLogInterval {
+			return nil, fmt.Errorf("invalid DEEPSEEK_BALANCE_LOG_INTERVAL: must be 0 or at least %v", minBalanceLogInterval)
+		}
+	}
+
+	// Read allowed file paths (optional, defaults to current working directory). ~ and
+	// environment variables are expanded.
 	allowedFilePathsStr := os.Getenv("DEEPSEEK_ALLOWED_FILE_PATHS")
 	var allowedFilePaths []string
 	if allowedFilePathsStr == "" {
@@ -157,7 +438,123 @@ func NewConfig() (*Config, error) {
 		}
 		allowedFilePaths = []string{wd}
 	} else {
-		allowedFilePaths = strings.Split(allowedFilePathsStr, ",")
+		var err error
+		allowedFilePaths, err = expandPaths(strings.Split(allowedFilePathsStr, ","))
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_ALLOWED_FILE_PATHS: %w", err)
+		}
+	}
+
+	// Read strict allowed paths validation (optional, defaults to false, which only warns)
+	strictAllowedPaths := false
+	if strictStr := os.Getenv("DEEPSEEK_STRICT_ALLOWED_PATHS"); strictStr != "" {
+		var err error
+		strictAllowedPaths, err = strconv.ParseBool(strictStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_STRICT_ALLOWED_PATHS: %w", err)
+		}
+	}
+
+	// Read writable file paths (optional, defaults to none, which disables output_file). ~ and
+	// environment variables are expanded.
+	var writableFilePaths []string
+	if writableStr := os.Getenv("DEEPSEEK_WRITABLE_FILE_PATHS"); writableStr != "" {
+		var err error
+		writableFilePaths, err = expandPaths(splitList(writableStr))
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_WRITABLE_FILE_PATHS: %w", err)
+		}
+	}
+
+	// Read enabled and disabled tools (optional, defaults to all tools enabled)
+	enabledTools := splitList(os.Getenv("DEEPSEEK_ENABLED_TOOLS"))
+	disabledTools := splitList(os.Getenv("DEEPSEEK_DISABLED_TOOLS"))
+
+	// Read file access switch (optional, defaults to false)
+	disableFileAccess := false
+	if disableStr := os.Getenv("DEEPSEEK_DISABLE_FILE_ACCESS"); disableStr != "" {
+		var err error
+		disableFileAccess, err = strconv.ParseBool(disableStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_DISABLE_FILE_ACCESS: %w", err)
+		}
+	}
+
+	// Read per-request file type override switch (optional, defaults to false)
+	allowPerRequestTypeOverride := false
+	if overrideStr := os.Getenv("DEEPSEEK_ALLOW_PER_REQUEST_TYPE_OVERRIDE"); overrideStr != "" {
+		var err error
+		allowPerRequestTypeOverride, err = strconv.ParseBool(overrideStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_ALLOW_PER_REQUEST_TYPE_OVERRIDE: %w", err)
+		}
+	}
+
+	// Read reload tool switch (optional, defaults to false)
+	allowReloadTool := false
+	if reloadStr := os.Getenv("DEEPSEEK_ALLOW_RELOAD_TOOL"); reloadStr != "" {
+		var err error
+		allowReloadTool, err = strconv.ParseBool(reloadStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_ALLOW_RELOAD_TOOL: %w", err)
+		}
+	}
+
+	// Read command context switch (optional, defaults to false)
+	allowCommandContext := false
+	if commandStr := os.Getenv("DEEPSEEK_ALLOW_COMMAND_CONTEXT"); commandStr != "" {
+		var err error
+		allowCommandContext, err = strconv.ParseBool(commandStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_ALLOW_COMMAND_CONTEXT: %w", err)
+		}
+	}
+
+	// Read command allowlist (optional, defaults to none)
+	commandAllowlist := splitList(os.Getenv("DEEPSEEK_COMMAND_ALLOWLIST"))
+
+	// Read command timeout (optional, defaults to 30 seconds)
+	commandTimeout := 30 * time.Second
+	if commandTimeoutStr := os.Getenv("DEEPSEEK_COMMAND_TIMEOUT"); commandTimeoutStr != "" {
+		var err error
+		commandTimeout, err = parseTimeout(commandTimeoutStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_COMMAND_TIMEOUT: %w", err)
+		}
+	}
+
+	// Read command output limit (optional, defaults to 64KB)
+	commandMaxOutput := 64 * 1024
+	if commandMaxOutputStr := os.Getenv("DEEPSEEK_COMMAND_MAX_OUTPUT"); commandMaxOutputStr != "" {
+		var err error
+		commandMaxOutput, err = strconv.Atoi(commandMaxOutputStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_COMMAND_MAX_OUTPUT: %w", err)
+		}
+		if commandMaxOutput <= 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_COMMAND_MAX_OUTPUT: must be positive")
+		}
+	}
+
+	// Read command working directory (optional, defaults to the first allowed file path). ~ and
+	// environment variables are expanded.
+	commandWorkDir := allowedFilePaths[0]
+	if workDirStr := os.Getenv("DEEPSEEK_COMMAND_WORKDIR"); workDirStr != "" {
+		expanded, err := expandPath(workDirStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_COMMAND_WORKDIR: %w", err)
+		}
+		info, err := os.Stat(expanded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_COMMAND_WORKDIR: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("invalid DEEPSEEK_COMMAND_WORKDIR: %s is not a directory", expanded)
+		}
+		if !isPathAllowed(expanded, allowedFilePaths) {
+			return nil, fmt.Errorf("invalid DEEPSEEK_COMMAND_WORKDIR: %s is not within DEEPSEEK_ALLOWED_FILE_PATHS", expanded)
+		}
+		commandWorkDir = expanded
 	}
 
 	// Read log level (optional, defaults to "info")
@@ -166,18 +563,671 @@ func NewConfig() (*Config, error) {
 		logLevel = "info"
 	}
 
+	// Read max logged field length (optional, defaults to 200)
+	logMaxFieldChars := 200
+	if logMaxFieldCharsStr := os.Getenv("DEEPSEEK_LOG_MAX_FIELD_CHARS"); logMaxFieldCharsStr != "" {
+		var err error
+		logMaxFieldChars, err = strconv.Atoi(logMaxFieldCharsStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_LOG_MAX_FIELD_CHARS: %w", err)
+		}
+	}
+
+	// Read file template (optional, defaults to the built-in markdown layout)
+	fileTemplate := os.Getenv("DEEPSEEK_FILE_TEMPLATE")
+	if fileTemplate != "" {
+		if _, err := parseFileTemplate(fileTemplate); err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_FILE_TEMPLATE: %w", err)
+		}
+	}
+
+	// Read max response chars (optional, defaults to 0 meaning responses are never split)
+	maxResponseCharsStr := os.Getenv("DEEPSEEK_MAX_RESPONSE_CHARS")
+	maxResponseChars := 0
+	if maxResponseCharsStr != "" {
+		var err error
+		maxResponseChars, err = strconv.Atoi(maxResponseCharsStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_RESPONSE_CHARS: %w", err)
+		}
+		if maxResponseChars < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_RESPONSE_CHARS: must not be negative")
+		}
+	}
+
+	// Read max file read concurrency (optional, defaults to 8)
+	maxFileReadConcurrencyStr := os.Getenv("DEEPSEEK_MAX_FILE_READ_CONCURRENCY")
+	maxFileReadConcurrency := 8
+	if maxFileReadConcurrencyStr != "" {
+		var err error
+		maxFileReadConcurrency, err = strconv.Atoi(maxFileReadConcurrencyStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_FILE_READ_CONCURRENCY: %w", err)
+		}
+		if maxFileReadConcurrency < 1 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_FILE_READ_CONCURRENCY: must be at least 1")
+		}
+	}
+
+	// Read max open files (optional, defaults to 64)
+	maxOpenFiles := 64
+	if maxOpenFilesStr := os.Getenv("DEEPSEEK_MAX_OPEN_FILES"); maxOpenFilesStr != "" {
+		var err error
+		maxOpenFiles, err = strconv.Atoi(maxOpenFilesStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_OPEN_FILES: %w", err)
+		}
+		if maxOpenFiles < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_OPEN_FILES: must not be negative")
+		}
+	}
+
+	// Read default user (optional, sent as the "user" field for abuse monitoring)
+	defaultUser, err := sanitizeUserID(os.Getenv("DEEPSEEK_DEFAULT_USER"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DEEPSEEK_DEFAULT_USER: %w", err)
+	}
+
+	// Read max concurrent requests (optional, defaults to 0, which disables limiting)
+	maxConcurrentRequestsStr := os.Getenv("DEEPSEEK_MAX_CONCURRENT_REQUESTS")
+	maxConcurrentRequests := 0
+	if maxConcurrentRequestsStr != "" {
+		maxConcurrentRequests, err = strconv.Atoi(maxConcurrentRequestsStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_CONCURRENT_REQUESTS: %w", err)
+		}
+	}
+
+	// Read request queue depth (optional, defaults to 32)
+	queueDepth := 32
+	if queueDepthStr := os.Getenv("DEEPSEEK_QUEUE_DEPTH"); queueDepthStr != "" {
+		queueDepth, err = strconv.Atoi(queueDepthStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_QUEUE_DEPTH: %w", err)
+		}
+		if queueDepth < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_QUEUE_DEPTH: must not be negative")
+		}
+	}
+
+	// Read max queue wait (optional, defaults to 2 minutes, 0 waits as long as the request lasts)
+	maxQueueWait := 2 * time.Minute
+	if maxQueueWaitStr := os.Getenv("DEEPSEEK_MAX_QUEUE_WAIT"); isZeroTimeout(maxQueueWaitStr) {
+		maxQueueWait = 0
+	} else if maxQueueWaitStr != "" {
+		maxQueueWait, err = parseTimeout(maxQueueWaitStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_QUEUE_WAIT value %q: %w", maxQueueWaitStr, err)
+		}
+	}
+
+	// Read max batch size (optional, defaults to 10)
+	maxBatchSizeStr := os.Getenv("DEEPSEEK_MAX_BATCH_SIZE")
+	maxBatchSize := 10
+	if maxBatchSizeStr != "" {
+		maxBatchSize, err = strconv.Atoi(maxBatchSizeStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_BATCH_SIZE: %w", err)
+		}
+	}
+
+	// Read max query chars (optional, defaults to 200000)
+	maxQueryCharsStr := os.Getenv("DEEPSEEK_MAX_QUERY_CHARS")
+	maxQueryChars := 200000
+	if maxQueryCharsStr != "" {
+		maxQueryChars, err = strconv.Atoi(maxQueryCharsStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_QUERY_CHARS: %w", err)
+		}
+		if maxQueryChars < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_QUERY_CHARS: must not be negative")
+		}
+	}
+
+	// Read max file paths (optional, defaults to 200)
+	maxFilePathsStr := os.Getenv("DEEPSEEK_MAX_FILE_PATHS")
+	maxFilePaths := 200
+	if maxFilePathsStr != "" {
+		maxFilePaths, err = strconv.Atoi(maxFilePathsStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_FILE_PATHS: %w", err)
+		}
+		if maxFilePaths < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_FILE_PATHS: must not be negative")
+		}
+	}
+
+	// Read max history tokens (optional, defaults to 32000)
+	maxHistoryTokensStr := os.Getenv("DEEPSEEK_MAX_HISTORY_TOKENS")
+	maxHistoryTokens := 32000
+	if maxHistoryTokensStr != "" {
+		maxHistoryTokens, err = strconv.Atoi(maxHistoryTokensStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_HISTORY_TOKENS: %w", err)
+		}
+		if maxHistoryTokens < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_HISTORY_TOKENS: must not be negative")
+		}
+	}
+
+	// Read max continuations (optional, defaults to 3)
+	maxContinuationsStr := os.Getenv("DEEPSEEK_MAX_CONTINUATIONS")
+	maxContinuations := 3
+	if maxContinuationsStr != "" {
+		maxContinuations, err = strconv.Atoi(maxContinuationsStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_CONTINUATIONS: %w", err)
+		}
+		if maxContinuations < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_CONTINUATIONS: must not be negative")
+		}
+	}
+
+	// Read max choices (optional, defaults to 4)
+	maxChoicesStr := os.Getenv("DEEPSEEK_MAX_CHOICES")
+	maxChoices := 4
+	if maxChoicesStr != "" {
+		maxChoices, err = strconv.Atoi(maxChoicesStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_CHOICES: %w", err)
+		}
+		if maxChoices < 1 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_CHOICES: must be at least 1")
+		}
+	}
+
+	// Read max self-consistency samples (optional, defaults to 5)
+	maxSelfConsistency := 5
+	if maxSelfConsistencyStr := os.Getenv("DEEPSEEK_MAX_SELF_CONSISTENCY"); maxSelfConsistencyStr != "" {
+		maxSelfConsistency, err = strconv.Atoi(maxSelfConsistencyStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_SELF_CONSISTENCY: %w", err)
+		}
+		if maxSelfConsistency < 1 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_SELF_CONSISTENCY: must be at least 1")
+		}
+	}
+
+	// Read model pricing overrides (optional, merged over the built-in table)
+	modelPricing := defaultModelPricing()
+	if pricingStr := os.Getenv("DEEPSEEK_PRICING"); pricingStr != "" {
+		overrides, err := parseModelPricing(pricingStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_PRICING: %w", err)
+		}
+		for model, pricing := range overrides {
+			modelPricing[model] = pricing
+		}
+	}
+
+	// Read cost warning threshold (optional, defaults to 0 meaning disabled)
+	costWarningThreshold := 0.0
+	if thresholdStr := os.Getenv("DEEPSEEK_COST_WARNING_THRESHOLD"); thresholdStr != "" {
+		costWarningThreshold, err = strconv.ParseFloat(thresholdStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_COST_WARNING_THRESHOLD: %w", err)
+		}
+		if costWarningThreshold < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_COST_WARNING_THRESHOLD: must not be negative")
+		}
+	}
+
+	// Read escalation models (optional, defaults to none, which disables escalation)
+	escalationModels := splitList(os.Getenv("DEEPSEEK_ESCALATION_MODELS"))
+
+	// Read escalation length threshold (optional, defaults to 80 characters)
+	escalationMinChars := 80
+	if minCharsStr := os.Getenv("DEEPSEEK_ESCALATION_MIN_CHARS"); minCharsStr != "" {
+		escalationMinChars, err = strconv.Atoi(minCharsStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_ESCALATION_MIN_CHARS: %w", err)
+		}
+		if escalationMinChars < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_ESCALATION_MIN_CHARS: must not be negative")
+		}
+	}
+
+	// Read maximum escalations (optional, defaults to 2)
+	maxEscalations := 2
+	if maxEscalationsStr := os.Getenv("DEEPSEEK_MAX_ESCALATIONS"); maxEscalationsStr != "" {
+		maxEscalations, err = strconv.Atoi(maxEscalationsStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_ESCALATIONS: %w", err)
+		}
+		if maxEscalations < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MAX_ESCALATIONS: must not be negative")
+		}
+	}
+
+	// Read escalation cost bound (optional, defaults to 0.10 USD)
+	escalationMaxCost := 0.10
+	if maxCostStr := os.Getenv("DEEPSEEK_ESCALATION_MAX_COST"); maxCostStr != "" {
+		escalationMaxCost, err = strconv.ParseFloat(maxCostStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_ESCALATION_MAX_COST: %w", err)
+		}
+		if escalationMaxCost < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_ESCALATION_MAX_COST: must not be negative")
+		}
+	}
+
+	// Read reasoning token reserve (optional, defaults to 8192)
+	reasoningTokenReserve := 8192
+	if reserveStr := os.Getenv("DEEPSEEK_REASONING_TOKEN_RESERVE"); reserveStr != "" {
+		reasoningTokenReserve, err = strconv.Atoi(reserveStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_REASONING_TOKEN_RESERVE: %w", err)
+		}
+		if reasoningTokenReserve < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_REASONING_TOKEN_RESERVE: must not be negative")
+		}
+	}
+
+	// Read file transcoding switch (optional, defaults to false)
+	transcodeFiles := false
+	if transcodeStr := os.Getenv("DEEPSEEK_TRANSCODE_FILES"); transcodeStr != "" {
+		var err error
+		transcodeFiles, err = strconv.ParseBool(transcodeStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_TRANSCODE_FILES: %w", err)
+		}
+	}
+
+	// Read language overrides (optional, defaults to none)
+	languageOverrides, err := parseLanguageOverrides(os.Getenv("DEEPSEEK_LANGUAGE_OVERRIDES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DEEPSEEK_LANGUAGE_OVERRIDES: %w", err)
+	}
+
+	// Read .editorconfig language switch (optional, defaults to false)
+	useEditorConfig := false
+	if editorConfigStr := os.Getenv("DEEPSEEK_USE_EDITORCONFIG"); editorConfigStr != "" {
+		useEditorConfig, err = strconv.ParseBool(editorConfigStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_USE_EDITORCONFIG: %w", err)
+		}
+	}
+
+	// Read notebook cell extraction switch (optional, defaults to false)
+	extractNotebookCells := false
+	if extractStr := os.Getenv("DEEPSEEK_EXTRACT_NOTEBOOK_CELLS"); extractStr != "" {
+		var err error
+		extractNotebookCells, err = strconv.ParseBool(extractStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_EXTRACT_NOTEBOOK_CELLS: %w", err)
+		}
+	}
+
+	// Read usage ledger path (optional, usage is kept in memory if unset)
+	usageLedgerPath := os.Getenv("DEEPSEEK_USAGE_LEDGER")
+
+	// Read billing attribution (optional, defaults to none)
+	organization := strings.TrimSpace(os.Getenv("DEEPSEEK_ORGANIZATION"))
+	if err := validateBillingID(organization); err != nil {
+		return nil, fmt.Errorf("invalid DEEPSEEK_ORGANIZATION: %w", err)
+	}
+	project := strings.TrimSpace(os.Getenv("DEEPSEEK_PROJECT"))
+	if err := validateBillingID(project); err != nil {
+		return nil, fmt.Errorf("invalid DEEPSEEK_PROJECT: %w", err)
+	}
+
+	// Read model capability overrides (optional, merged over the built-in table)
+	modelCapabilities := defaultModelCapabilities()
+	if capsStr := os.Getenv("DEEPSEEK_MODEL_CAPABILITIES"); capsStr != "" {
+		overrides, err := parseModelCapabilities(capsStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MODEL_CAPABILITIES: %w", err)
+		}
+		for model, capabilities := range overrides {
+			modelCapabilities[model] = capabilities
+		}
+	}
+
+	// Read model recommendations (optional, defaults to none)
+	var modelRecommendations map[string][]string
+	if recommendationsStr := os.Getenv("DEEPSEEK_MODEL_RECOMMENDATIONS"); recommendationsStr != "" {
+		modelRecommendations, err = parseModelRecommendations(recommendationsStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MODEL_RECOMMENDATIONS: %w", err)
+		}
+	}
+
+	// Read system prompt presets (built-in presets, optionally extended from a JSON file)
+	presets := defaultPresets()
+	if presetsPath := os.Getenv("DEEPSEEK_PRESETS_FILE"); presetsPath != "" {
+		filePresets, err := loadPresetsFile(presetsPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_PRESETS_FILE: %w", err)
+		}
+		for name, preset := range filePresets {
+			presets[name] = preset
+		}
+	}
+
+	// Read refusal patterns (built-in patterns, optionally extended from a file)
+	refusalPatterns := defaultRefusalPatterns()
+	refusalPatternsPath := os.Getenv("DEEPSEEK_REFUSAL_PATTERNS_FILE")
+	if refusalPatternsPath != "" {
+		filePatterns, err := loadRefusalPatterns(refusalPatternsPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_REFUSAL_PATTERNS_FILE: %w", err)
+		}
+		refusalPatterns = append(refusalPatterns, filePatterns...)
+	}
+
+	// Read structured results switch (optional, defaults to false, which returns text only)
+	structuredResults := false
+	if structuredStr := os.Getenv("DEEPSEEK_STRUCTURED_RESULTS"); structuredStr != "" {
+		structuredResults, err = strconv.ParseBool(structuredStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_STRUCTURED_RESULTS: %w", err)
+		}
+	}
+
+	// Read minimum balance (optional, defaults to 0 meaning disabled)
+	minBalance := 0.0
+	if minBalanceStr := os.Getenv("DEEPSEEK_MIN_BALANCE"); minBalanceStr != "" {
+		minBalance, err = strconv.ParseFloat(minBalanceStr, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MIN_BALANCE: %w", err)
+		}
+		if minBalance < 0 {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MIN_BALANCE: must not be negative")
+		}
+	}
+
+	// Read prompt injection mitigation switch (optional, defaults to false)
+	mitigatePromptInjection := false
+	if mitigateStr := os.Getenv("DEEPSEEK_MITIGATE_PROMPT_INJECTION"); mitigateStr != "" {
+		mitigatePromptInjection, err = strconv.ParseBool(mitigateStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_MITIGATE_PROMPT_INJECTION: %w", err)
+		}
+	}
+
+	// Read content redaction rules (optional, defaults to none)
+	redactionRulesPath := os.Getenv("DEEPSEEK_REDACTION_RULES_FILE")
+	var redactionRules []redactionRule
+	if redactionRulesPath != "" {
+		redactionRules, err = loadRedactionRules(redactionRulesPath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DEEPSEEK_REDACTION_RULES_FILE: %w", err)
+		}
+	}
+
 	return &Config{
-		DeepseekAPIKey:       apiKey,
-		DeepseekModel:        model,
-		DeepseekSystemPrompt: systemPrompt,
-		MaxFileSize:          maxFileSize,
-		AllowedFileTypes:     allowedFileTypes,
-		DeepseekTemperature:  temperature,
-		HTTPTimeout:          timeout,
-		MaxRetries:           maxRetries,
-		InitialBackoff:       initialBackoff,
-		MaxBackoff:           maxBackoff,
-		AllowedFilePaths:     allowedFilePaths,
-		LogLevel:             logLevel,
+		DeepseekAPIKey:              apiKey,
+		DeepseekModel:               model,
+		ModelFallback:               modelFallback,
+		SummaryModel:                summaryModel,
+		StrictModelValidation:       strictModelValidation,
+		DeepseekSystemPrompt:        systemPrompt,
+		SystemPromptPrefix:          systemPromptPrefix,
+		SystemPromptSuffix:          systemPromptSuffix,
+		MaxFileSize:                 maxFileSize,
+		TruncateLargeFiles:          truncateLargeFiles,
+		LargeFileExcerptBytes:       largeFileExcerptBytes,
+		MaxTotalFileSize:            maxTotalFileSize,
+		FileCacheMaxBytes:           fileCacheMaxBytes,
+		AllowedFileTypes:            allowedFileTypes,
+		MimeDetection:               mimeDetection,
+		FileOrder:                   fileOrder,
+		DeepseekTemperature:         temperature,
+		TemperatureSet:              temperatureSet,
+		NoteIgnoredTemperature:      noteIgnoredTemperature,
+		HTTPTimeout:                 timeout,
+		ConnectTimeout:              connectTimeout,
+		ResponseTimeout:             responseTimeout,
+		ToolTimeouts:                toolTimeouts,
+		MaxRetries:                  maxRetries,
+		RetryOnEmpty:                retryOnEmpty,
+		InitialBackoff:              initialBackoff,
+		MaxRetryWait:                maxRetryWait,
+		MaxBackoff:                  maxBackoff,
+		BreakerThreshold:            breakerThreshold,
+		BreakerCooldown:             breakerCooldown,
+		BalanceLogInterval:          balanceLogInterval,
+		AllowedFilePaths:            allowedFilePaths,
+		StrictAllowedPaths:          strictAllowedPaths,
+		WritableFilePaths:           writableFilePaths,
+		EnabledTools:                enabledTools,
+		DisabledTools:               disabledTools,
+		DisableFileAccess:           disableFileAccess,
+		AllowPerRequestTypeOverride: allowPerRequestTypeOverride,
+		AllowReloadTool:             allowReloadTool,
+		AllowCommandContext:         allowCommandContext,
+		CommandAllowlist:            commandAllowlist,
+		CommandTimeout:              commandTimeout,
+		CommandMaxOutput:            commandMaxOutput,
+		CommandWorkDir:              commandWorkDir,
+		LogLevel:                    logLevel,
+		LogMaxFieldChars:            logMaxFieldChars,
+		FileTemplate:                fileTemplate,
+		MaxResponseChars:            maxResponseChars,
+		Presets:                     presets,
+		MaxFileReadConcurrency:      maxFileReadConcurrency,
+		MaxOpenFiles:                maxOpenFiles,
+		DefaultUser:                 defaultUser,
+		MaxConcurrentRequests:       maxConcurrentRequests,
+		QueueDepth:                  queueDepth,
+		MaxQueueWait:                maxQueueWait,
+		MaxBatchSize:                maxBatchSize,
+		UsageLedgerPath:             usageLedgerPath,
+		Organization:                organization,
+		Project:                     project,
+		ModelCapabilityTable:        modelCapabilities,
+		ModelRecommendations:        modelRecommendations,
+		TranscodeFiles:              transcodeFiles,
+		LanguageOverrides:           languageOverrides,
+		UseEditorConfig:             useEditorConfig,
+		ExtractNotebookCells:        extractNotebookCells,
+		MaxQueryChars:               maxQueryChars,
+		MaxFilePaths:                maxFilePaths,
+		MaxHistoryTokens:            maxHistoryTokens,
+		MaxContinuations:            maxContinuations,
+		MaxChoices:                  maxChoices,
+		MaxSelfConsistency:          maxSelfConsistency,
+		ModelPricing:                modelPricing,
+		CostWarningThreshold:        costWarningThreshold,
+		ReasoningTokenReserve:       reasoningTokenReserve,
+		EscalationModels:            escalationModels,
+		EscalationMinChars:          escalationMinChars,
+		MaxEscalations:              maxEscalations,
+		OfflineMode:                 offlineMode,
+		StructuredResults:           structuredResults,
+		MinBalance:                  minBalance,
+		MitigatePromptInjection:     mitigatePromptInjection,
+		RedactionRulesPath:          redactionRulesPath,
+		RedactionRules:              redactionRules,
+		OfflineFixturesPath:         offlineFixturesPath,
+		OfflineFixtures:             offlineFixtures,
+		EscalationMaxCost:           escalationMaxCost,
+		RefusalPatterns:             refusalPatterns,
 	}, nil
 }
+
+// parseTimeout parses a timeout given in whole seconds or as a duration string, e.g. "90" or "1m30s"
+func parseTimeout(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0, fmt.Errorf("must be positive")
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if timeout <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return timeout, nil
+}
+
+// isZeroTimeout reports whether a timeout value is zero, given as "0" or as a zero duration
+// such as "0s", the form in which a zero timeout is written by WriteDotenv
+func isZeroTimeout(value string) bool {
+	if value == "0" {
+		return true
+	}
+	timeout, err := time.ParseDuration(value)
+	return err == nil && timeout == 0
+}
+
+// splitList splits a comma-separated list, trimming whitespace and dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// IsToolEnabled reports whether a tool should be registered according to the
+// EnabledTools and DisabledTools settings
+func (c *Config) IsToolEnabled(name string) bool {
+	for _, disabled := range c.DisabledTools {
+		if disabled == name {
+			return false
+		}
+	}
+	if len(c.EnabledTools) == 0 {
+		return true
+	}
+	for _, enabled := range c.EnabledTools {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+// maskSecret masks a secret value, keeping only the last four characters visible
+func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) <= 8 {
+		return "****"
+	}
+	return "****" + secret[len(secret)-4:]
+}
+
+// dotenvValue formats a value for a dotenv file, quoting it when necessary
+func dotenvValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\n\"'#=$\\") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// dotenvEntry is one setting of the configuration, keyed by its environment variable name
+type dotenvEntry struct {
+	key   string
+	value string
+}
+
+// dotenvEntries returns the settings of the configuration with secrets masked
+func (c *Config) dotenvEntries() []dotenvEntry {
+	return []dotenvEntry{
+		{"DEEPSEEK_API_KEY", maskSecret(c.DeepseekAPIKey)},
+		{"DEEPSEEK_MODEL", c.DeepseekModel},
+		{"DEEPSEEK_MODEL_FALLBACK", c.ModelFallback},
+		{"DEEPSEEK_SUMMARY_MODEL", c.SummaryModel},
+		{"DEEPSEEK_STRICT_MODEL_VALIDATION", strconv.FormatBool(c.StrictModelValidation)},
+		{"DEEPSEEK_SYSTEM_PROMPT", c.DeepseekSystemPrompt},
+		{"DEEPSEEK_SYSTEM_PROMPT_PREFIX", c.SystemPromptPrefix},
+		{"DEEPSEEK_SYSTEM_PROMPT_SUFFIX", c.SystemPromptSuffix},
+		{"DEEPSEEK_MAX_FILE_SIZE", strconv.FormatInt(c.MaxFileSize, 10)},
+		{"DEEPSEEK_TRUNCATE_LARGE_FILES", strconv.FormatBool(c.TruncateLargeFiles)},
+		{"DEEPSEEK_LARGE_FILE_EXCERPT_BYTES", strconv.FormatInt(c.LargeFileExcerptBytes, 10)},
+		{"DEEPSEEK_MAX_TOTAL_FILE_SIZE", strconv.FormatInt(c.MaxTotalFileSize, 10)},
+		{"DEEPSEEK_FILE_CACHE_MAX_BYTES", strconv.FormatInt(c.FileCacheMaxBytes, 10)},
+		{"DEEPSEEK_ALLOWED_FILE_TYPES", strings.Join(c.AllowedFileTypes, ",")},
+		{"DEEPSEEK_MIME_DETECTION", c.MimeDetection},
+		{"DEEPSEEK_FILE_ORDER", c.FileOrder},
+		{"DEEPSEEK_TEMPERATURE", strconv.FormatFloat(float64(c.DeepseekTemperature), 'g', -1, 32)},
+		{"DEEPSEEK_NOTE_IGNORED_TEMPERATURE", strconv.FormatBool(c.NoteIgnoredTemperature)},
+		{"DEEPSEEK_TIMEOUT", c.HTTPTimeout.String()},
+		{"DEEPSEEK_CONNECT_TIMEOUT", c.ConnectTimeout.String()},
+		{"DEEPSEEK_RESPONSE_TIMEOUT", c.ResponseTimeout.String()},
+		{"DEEPSEEK_TOOL_TIMEOUTS", formatToolTimeouts(c.ToolTimeouts)},
+		{"DEEPSEEK_MAX_RETRIES", strconv.Itoa(c.MaxRetries)},
+		{"DEEPSEEK_RETRY_ON_EMPTY", strconv.FormatBool(c.RetryOnEmpty)},
+		{"DEEPSEEK_INITIAL_BACKOFF", c.InitialBackoff.String()},
+		{"DEEPSEEK_MAX_BACKOFF", c.MaxBackoff.String()},
+		{"DEEPSEEK_MAX_RETRY_WAIT", c.MaxRetryWait.String()},
+		{"DEEPSEEK_BREAKER_THRESHOLD", strconv.Itoa(c.BreakerThreshold)},
+		{"DEEPSEEK_BREAKER_COOLDOWN", c.BreakerCooldown.String()},
+		{"DEEPSEEK_BALANCE_LOG_INTERVAL", c.BalanceLogInterval.String()},
+		{"DEEPSEEK_ALLOWED_FILE_PATHS", strings.Join(c.AllowedFilePaths, ",")},
+		{"DEEPSEEK_STRICT_ALLOWED_PATHS", strconv.FormatBool(c.StrictAllowedPaths)},
+		{"DEEPSEEK_WRITABLE_FILE_PATHS", strings.Join(c.WritableFilePaths, ",")},
+		{"DEEPSEEK_ENABLED_TOOLS", strings.Join(c.EnabledTools, ",")},
+		{"DEEPSEEK_DISABLED_TOOLS", strings.Join(c.DisabledTools, ",")},
+		{"DEEPSEEK_DISABLE_FILE_ACCESS", strconv.FormatBool(c.DisableFileAccess)},
+		{"DEEPSEEK_ALLOW_PER_REQUEST_TYPE_OVERRIDE", strconv.FormatBool(c.AllowPerRequestTypeOverride)},
+		{"DEEPSEEK_ALLOW_RELOAD_TOOL", strconv.FormatBool(c.AllowReloadTool)},
+		{"DEEPSEEK_ALLOW_COMMAND_CONTEXT", strconv.FormatBool(c.AllowCommandContext)},
+		{"DEEPSEEK_COMMAND_ALLOWLIST", strings.Join(c.CommandAllowlist, ",")},
+		{"DEEPSEEK_COMMAND_TIMEOUT", c.CommandTimeout.String()},
+		{"DEEPSEEK_COMMAND_MAX_OUTPUT", strconv.Itoa(c.CommandMaxOutput)},
+		{"DEEPSEEK_COMMAND_WORKDIR", c.CommandWorkDir},
+		{"DEEPSEEK_LOG_LEVEL", c.LogLevel},
+		{"DEEPSEEK_LOG_MAX_FIELD_CHARS", strconv.Itoa(c.LogMaxFieldChars)},
+		{"DEEPSEEK_FILE_TEMPLATE", c.FileTemplate},
+		{"DEEPSEEK_MAX_RESPONSE_CHARS", strconv.Itoa(c.MaxResponseChars)},
+		{"DEEPSEEK_MAX_FILE_READ_CONCURRENCY", strconv.Itoa(c.MaxFileReadConcurrency)},
+		{"DEEPSEEK_MAX_OPEN_FILES", strconv.Itoa(c.MaxOpenFiles)},
+		{"DEEPSEEK_DEFAULT_USER", c.DefaultUser},
+		{"DEEPSEEK_MAX_CONCURRENT_REQUESTS", strconv.Itoa(c.MaxConcurrentRequests)},
+		{"DEEPSEEK_QUEUE_DEPTH", strconv.Itoa(c.QueueDepth)},
+		{"DEEPSEEK_MAX_QUEUE_WAIT", c.MaxQueueWait.String()},
+		{"DEEPSEEK_MAX_BATCH_SIZE", strconv.Itoa(c.MaxBatchSize)},
+		{"DEEPSEEK_USAGE_LEDGER", c.UsageLedgerPath},
+		{"DEEPSEEK_ORGANIZATION", c.Organization},
+		{"DEEPSEEK_PROJECT", c.Project},
+		{"DEEPSEEK_MODEL_CAPABILITIES", formatModelCapabilities(c.ModelCapabilityTable)},
+		{"DEEPSEEK_MODEL_RECOMMENDATIONS", formatModelRecommendations(c.ModelRecommendations)},
+		{"DEEPSEEK_TRANSCODE_FILES", strconv.FormatBool(c.TranscodeFiles)},
+		{"DEEPSEEK_LANGUAGE_OVERRIDES", formatLanguageOverrides(c.LanguageOverrides)},
+		{"DEEPSEEK_USE_EDITORCONFIG", strconv.FormatBool(c.UseEditorConfig)},
+		{"DEEPSEEK_EXTRACT_NOTEBOOK_CELLS", strconv.FormatBool(c.ExtractNotebookCells)},
+		{"DEEPSEEK_MAX_QUERY_CHARS", strconv.Itoa(c.MaxQueryChars)},
+		{"DEEPSEEK_MAX_FILE_PATHS", strconv.Itoa(c.MaxFilePaths)},
+		{"DEEPSEEK_MAX_HISTORY_TOKENS", strconv.Itoa(c.MaxHistoryTokens)},
+		{"DEEPSEEK_MAX_CONTINUATIONS", strconv.Itoa(c.MaxContinuations)},
+		{"DEEPSEEK_MAX_CHOICES", strconv.Itoa(c.MaxChoices)},
+		{"DEEPSEEK_MAX_SELF_CONSISTENCY", strconv.Itoa(c.MaxSelfConsistency)},
+		{"DEEPSEEK_PRICING", formatModelPricing(c.ModelPricing)},
+		{"DEEPSEEK_COST_WARNING_THRESHOLD", strconv.FormatFloat(c.CostWarningThreshold, 'g', -1, 64)},
+		{"DEEPSEEK_REASONING_TOKEN_RESERVE", strconv.Itoa(c.ReasoningTokenReserve)},
+		{"DEEPSEEK_ESCALATION_MODELS", strings.Join(c.EscalationModels, ",")},
+		{"DEEPSEEK_ESCALATION_MIN_CHARS", strconv.Itoa(c.EscalationMinChars)},
+		{"DEEPSEEK_MAX_ESCALATIONS", strconv.Itoa(c.MaxEscalations)},
+		{"DEEPSEEK_ESCALATION_MAX_COST", strconv.FormatFloat(c.EscalationMaxCost, 'g', -1, 64)},
+		{"DEEPSEEK_OFFLINE", strconv.FormatBool(c.OfflineMode)},
+		{"DEEPSEEK_STRUCTURED_RESULTS", strconv.FormatBool(c.StructuredResults)},
+		{"DEEPSEEK_MIN_BALANCE", strconv.FormatFloat(c.MinBalance, 'g', -1, 64)},
+		{"DEEPSEEK_MITIGATE_PROMPT_INJECTION", strconv.FormatBool(c.MitigatePromptInjection)},
+		{"DEEPSEEK_REDACTION_RULES_FILE", c.RedactionRulesPath},
+		{"DEEPSEEK_OFFLINE_FIXTURES_FILE", c.OfflineFixturesPath},
+	}
+}
+
+// WriteDotenv writes the effective configuration in dotenv format with secrets masked.
+// Keys use the environment variable names so the output can be used to reproduce the setup.
+func (c *Config) WriteDotenv(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "# Effective DeepseekMCP configuration (secrets masked)"); err != nil {
+		return err
+	}
+	for _, entry := range c.dotenvEntries() {
+		if _, err := fmt.Fprintf(w, "%s=%s\n", entry.key, dotenvValue(entry.value)); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "# %d system prompt preset(s) loaded: %s\n", len(c.Presets), strings.Join(presetNames(c.Presets), ", "))
+	return err
+}
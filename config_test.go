@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+func TestIsZeroTimeout(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"0", true},
+		{"0s", true},
+		{"0m0s", true},
+		{"", false},
+		{"5", false},
+		{"2m0s", false},
+		{"soon", false},
+	}
+	for _, tt := range tests {
+		if got := isZeroTimeout(tt.value); got != tt.want {
+			t.Errorf("isZeroTimeout(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWriteDotenvRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"defaults", nil},
+		{"zero queue wait", map[string]string{"DEEPSEEK_MAX_QUEUE_WAIT": "0"}},
+		{"custom values", map[string]string{
+			"DEEPSEEK_MAX_QUEUE_WAIT":          "45s",
+			"DEEPSEEK_MAX_CONCURRENT_REQUESTS": "3",
+			"DEEPSEEK_SYSTEM_PROMPT":           "Review this code.\nBe brief.",
+			"DEEPSEEK_TOOL_TIMEOUTS":           "deepseek_ask=10m",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, tt.env)
+			var dumped bytes.Buffer
+			if err := cfg.WriteDotenv(&dumped); err != nil {
+				t.Fatalf("WriteDotenv() error = %v", err)
+			}
+
+			env, err := godotenv.Unmarshal(dumped.String())
+			if err != nil {
+				t.Fatalf("dumped configuration is not valid dotenv: %v\n%s", err, dumped.String())
+			}
+			delete(env, "DEEPSEEK_API_KEY") // Masked in the dump
+			loaded := newTestConfig(t, env)
+
+			want, got := cfg.dotenvEntries(), loaded.dotenvEntries()
+			if len(got) != len(want) {
+				t.Fatalf("loaded %d settings, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Errorf("%s = %q after loading the dump, want %q", want[i].key, got[i].value, want[i].value)
+				}
+			}
+		})
+	}
+	if cfg := newTestConfig(t, map[string]string{"DEEPSEEK_MAX_QUEUE_WAIT": "0s"}); cfg.MaxQueueWait != time.Duration(0) {
+		t.Errorf("MaxQueueWait = %v, want 0", cfg.MaxQueueWait)
+	}
+}
+
+func TestLogStartupSummaryFileCache(t *testing.T) {
+	tests := []struct {
+		cacheBytes string
+		want       string
+	}{
+		{"0", "file_cache=disabled "},
+		{"2097152", "file_cache=2.0 MB "},
+	}
+	for _, tt := range tests {
+		cfg := newTestConfig(t, map[string]string{"DEEPSEEK_FILE_CACHE_MAX_BYTES": tt.cacheBytes})
+		logger := &recordingLogger{}
+		logStartupSummary(logger, cfg, "stdio")
+		if !logger.contains(tt.want) {
+			t.Errorf("DEEPSEEK_FILE_CACHE_MAX_BYTES=%s: summary %v does not contain %q", tt.cacheBytes, logger.messages, tt.want)
+		}
+	}
+}
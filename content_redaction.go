@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// defaultRedactionReplacement replaces matches of a redaction rule without a replacement
+const defaultRedactionReplacement = "[REDACTED]"
+
+// redactionRule is a regular expression whose matches are replaced in file content before it is
+// sent to the API, e.g. to keep PII or internal hostnames out of prompts
+type redactionRule struct {
+	Name        string  `json:"name"`
+	Pattern     string  `json:"pattern"`
+	Replacement *string `json:"replacement"` // May reference groups as $1 or ${name}; nil for [REDACTED]
+	re          *regexp.Regexp
+}
+
+// loadRedactionRules reads redaction rules from a JSON file holding an array of
+// {"name", "pattern", "replacement"} objects. Every pattern is compiled, so an invalid one fails
+// the configuration instead of being skipped.
+func loadRedactionRules(path string) ([]redactionRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redaction rules file: %w", err)
+	}
+
+	var rules []redactionRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse redaction rules file %s: %w", path, err)
+	}
+	for i := range rules {
+		rule := &rules[i]
+		if strings.TrimSpace(rule.Name) == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if rule.Pattern == "" {
+			return nil, fmt.Errorf("redaction rule %q in %s has an empty pattern", rule.Name, path)
+		}
+		if rule.re, err = regexp.Compile(rule.Pattern); err != nil {
+			return nil, fmt.Errorf("invalid pattern of redaction rule %q in %s: %w", rule.Name, path, err)
+		}
+		if rule.Replacement == nil {
+			replacement := defaultRedactionReplacement
+			rule.Replacement = &replacement
+		}
+	}
+	return rules, nil
+}
+
+// redactContent applies the redaction rules to content in order, returning the redacted content
+// and the number of matches of each rule that matched, formatted as "name=count"
+func (c *Config) redactContent(content []byte) ([]byte, []string) {
+	var counts []string
+	for _, rule := range c.RedactionRules {
+		matches := len(rule.re.FindAllIndex(content, -1))
+		if matches == 0 {
+			continue
+		}
+		content = rule.re.ReplaceAll(content, []byte(*rule.Replacement))
+		counts = append(counts, fmt.Sprintf("%s=%d", rule.Name, matches))
+	}
+	return content, counts
+}
+
+// redactFileContent applies the configured redaction rules to the content of a file about to
+// be sent to the API, logging how often each rule matched
+func (s *DeepseekServer) redactFileContent(cfg *Config, path string, content []byte) []byte {
+	redacted, counts := cfg.redactContent(content)
+	if len(counts) > 0 {
+		s.logger.Debug("Redacted %s: %s", path, strings.Join(counts, ", "))
+	}
+	return redacted
+}
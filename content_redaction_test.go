@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRedactionRules writes a redaction rules file and returns its path
+func writeRedactionRules(t *testing.T, rules string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "redaction.json")
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRedactionRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		rules     string
+		wantNames []string
+		wantErr   string
+	}{
+		{"named and unnamed rules", `[{"name": "email", "pattern": "\\w+@example\\.com"}, {"pattern": "secret"}]`, []string{"email", "rule 2"}, ""},
+		{"no rules", `[]`, nil, ""},
+		{"invalid pattern", `[{"name": "broken", "pattern": "(unclosed"}]`, nil, `invalid pattern of redaction rule "broken"`},
+		{"empty pattern", `[{"name": "empty", "pattern": ""}]`, nil, `redaction rule "empty"`},
+		{"not an array", `{"pattern": "x"}`, nil, "failed to parse redaction rules file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := loadRedactionRules(writeRedactionRules(t, tt.rules))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadRedactionRules() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadRedactionRules() error = %v", err)
+			}
+			var names []string
+			for _, rule := range rules {
+				names = append(names, rule.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantNames, ",") {
+				t.Errorf("rule names = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
+
+func TestRedactContent(t *testing.T) {
+	rules, err := loadRedactionRules(writeRedactionRules(t, `[
+		{"name": "email", "pattern": "[\\w.]+@[\\w.]+\\.\\w+"},
+		{"name": "hostname", "pattern": "\\b([a-z0-9-]+)\\.corp\\.internal\\b", "replacement": "$1.example"},
+		{"name": "customer", "pattern": "CUST-\\d{6}", "replacement": "CUST-XXXXXX"},
+		{"name": "after email", "pattern": "\\[REDACTED\\]", "replacement": "<email>"}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{RedactionRules: rules}
+
+	tests := []struct {
+		name       string
+		content    string
+		want       string
+		wantCounts []string
+	}{
+		{"no matches", "package main", "package main", nil},
+		{"default replacement, then later rules", "mail jane.doe@acme.com", "mail <email>", []string{"email=1", "after email=1"}},
+		{"group reference", "db1.corp.internal and cache.corp.internal", "db1.example and cache.example", []string{"hostname=2"}},
+		{"several rules", "CUST-123456 at ops@acme.com on api.corp.internal",
+			"CUST-XXXXXX at <email> on api.example", []string{"email=1", "hostname=1", "customer=1", "after email=1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, counts := cfg.redactContent([]byte(tt.content))
+			if string(got) != tt.want {
+				t.Errorf("redactContent() = %q, want %q", got, tt.want)
+			}
+			if strings.Join(counts, ",") != strings.Join(tt.wantCounts, ",") {
+				t.Errorf("counts = %v, want %v", counts, tt.wantCounts)
+			}
+		})
+	}
+}
+
+func TestAskRedactsFileContent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("host: billing.corp.internal\nowner: jane.doe@acme.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	client := &mockDeepseekClient{}
+	s := newTestServer(t, client, map[string]string{
+		"DEEPSEEK_ALLOWED_FILE_PATHS":   dir,
+		"DEEPSEEK_REDACTION_RULES_FILE": writeRedactionRules(t, `[{"pattern": "[\\w.]+@[\\w.]+\\.\\w+"}, {"pattern": "\\.corp\\.internal", "replacement": ".internal"}]`),
+	})
+	result := callTool(t, s.handleAskDeepseek, map[string]any{"query": "review", "file_paths": []any{path}})
+	if result.IsError {
+		t.Fatalf("request failed: %s", resultText(result))
+	}
+	var prompt strings.Builder
+	for _, message := range client.requests[0].Messages {
+		prompt.WriteString(message.Content)
+	}
+	if strings.Contains(prompt.String(), "jane.doe") || strings.Contains(prompt.String(), "corp.internal") {
+		t.Errorf("prompt was not redacted:\n%s", prompt.String())
+	}
+	if !strings.Contains(prompt.String(), "owner: [REDACTED]") || !strings.Contains(prompt.String(), "host: billing.internal") {
+		t.Errorf("prompt lacks the replacements:\n%s", prompt.String())
+	}
+}
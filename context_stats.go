@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// modelContextWindows holds the context window in tokens of the known DeepSeek models
+var modelContextWindows = map[string]int{
+	"deepseek-chat":     128 * 1024,
+	"deepseek-reasoner": 128 * 1024,
+}
+
+// fileTokenStat is the estimated size of one included file
+type fileTokenStat struct {
+	Path   string
+	Tokens int
+}
+
+// contextStats is the estimated size of each part of an assembled deepseek_ask request
+type contextStats struct {
+	SystemPrompt  int
+	EarlierTurns  int // Few-shot examples and conversation history
+	Query         int
+	Files         []fileTokenStat
+	Commands      int
+	Total         int // Estimated for the whole prompt, so it may differ slightly from the sum of the parts
+	ContextWindow int // 0 if unknown
+	MaxTokens     int // Response limit of the request, 0 if unset
+}
+
+// estimateTokens returns the estimated number of tokens of text
+func estimateTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	return deepseek.EstimateTokenCount(text).EstimatedTokens
+}
+
+// newContextStats estimates the size of the system prompt, the earlier turns and the query of
+// the request messages. Files and command output are added by the caller as they are assembled.
+func newContextStats(messages []deepseek.ChatCompletionMessage, query, modelID string) contextStats {
+	stats := contextStats{Query: estimateTokens(query), ContextWindow: modelContextWindows[modelID]}
+	for i, message := range messages {
+		switch {
+		case message.Role == deepseek.ChatMessageRoleSystem:
+			stats.SystemPrompt += estimateTokens(message.Content)
+		case i < len(messages)-1:
+			stats.EarlierTurns += estimateTokens(message.Content)
+		}
+	}
+	return stats
+}
+
+// Headroom returns the tokens left in the context window for the response, and whether the
+// context window of the model is known
+func (s contextStats) Headroom() (int, bool) {
+	if s.ContextWindow == 0 {
+		return 0, false
+	}
+	return max(s.ContextWindow-s.Total, 0), true
+}
+
+// formatContextStats formats the context size breakdown as a compact table
+func formatContextStats(stats contextStats) string {
+	var sb strings.Builder
+	sb.WriteString("\n\n---\n**Context size (estimated tokens):**\n\n| Part | Tokens |\n|------|-------:|\n")
+	row := func(part string, tokens int) {
+		sb.WriteString(fmt.Sprintf("| %s | %d |\n", part, tokens))
+	}
+	row("System prompt", stats.SystemPrompt)
+	if stats.EarlierTurns > 0 {
+		row("Examples and history", stats.EarlierTurns)
+	}
+	row("Query", stats.Query)
+	for _, file := range stats.Files {
+		row("`"+file.Path+"`", file.Tokens)
+	}
+	if stats.Commands > 0 {
+		row("Command output", stats.Commands)
+	}
+	row("**Total**", stats.Total)
+
+	if headroom, ok := stats.Headroom(); ok {
+		sb.WriteString(fmt.Sprintf("\nHeadroom for the response: %d of the %d-token context window", headroom, stats.ContextWindow))
+	} else {
+		sb.WriteString("\nHeadroom for the response: unknown, the context window of this model is not known")
+	}
+	if stats.MaxTokens > 0 {
+		sb.WriteString(fmt.Sprintf(", limited to %d tokens by this request", stats.MaxTokens))
+	}
+	sb.WriteString(".")
+	return sb.String()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestNewContextStats(t *testing.T) {
+	system := "You are a careful reviewer."
+	example := "Here is an earlier question about Go."
+	query := "Why does this loop never end?"
+	tests := []struct {
+		name       string
+		messages   []deepseek.ChatCompletionMessage
+		model      string
+		wantEarly  int
+		wantWindow int
+	}{
+		{
+			name: "system prompt and query",
+			messages: []deepseek.ChatCompletionMessage{
+				{Role: deepseek.ChatMessageRoleSystem, Content: system},
+				{Role: deepseek.ChatMessageRoleUser, Content: query},
+			},
+			model:      "deepseek-chat",
+			wantWindow: 128 * 1024,
+		},
+		{
+			name: "earlier turns",
+			messages: []deepseek.ChatCompletionMessage{
+				{Role: deepseek.ChatMessageRoleSystem, Content: system},
+				{Role: deepseek.ChatMessageRoleUser, Content: example},
+				{Role: deepseek.ChatMessageRoleAssistant, Content: example},
+				{Role: deepseek.ChatMessageRoleUser, Content: query},
+			},
+			model:      "deepseek-reasoner",
+			wantEarly:  2 * estimateTokens(example),
+			wantWindow: 128 * 1024,
+		},
+		{
+			name: "unknown model",
+			messages: []deepseek.ChatCompletionMessage{
+				{Role: deepseek.ChatMessageRoleSystem, Content: system},
+				{Role: deepseek.ChatMessageRoleUser, Content: query},
+			},
+			model: "custom-model",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := newContextStats(tt.messages, query, tt.model)
+			if stats.SystemPrompt != estimateTokens(system) {
+				t.Errorf("SystemPrompt = %d, want %d", stats.SystemPrompt, estimateTokens(system))
+			}
+			if stats.Query != estimateTokens(query) {
+				t.Errorf("Query = %d, want %d", stats.Query, estimateTokens(query))
+			}
+			if stats.EarlierTurns != tt.wantEarly {
+				t.Errorf("EarlierTurns = %d, want %d", stats.EarlierTurns, tt.wantEarly)
+			}
+			if stats.ContextWindow != tt.wantWindow {
+				t.Errorf("ContextWindow = %d, want %d", stats.ContextWindow, tt.wantWindow)
+			}
+		})
+	}
+	if got := estimateTokens(""); got != 0 {
+		t.Errorf("estimateTokens(\"\") = %d, want 0", got)
+	}
+}
+
+func TestContextStatsHeadroom(t *testing.T) {
+	tests := []struct {
+		name      string
+		stats     contextStats
+		want      int
+		wantKnown bool
+	}{
+		{"room left", contextStats{Total: 1000, ContextWindow: 4000}, 3000, true},
+		{"exactly full", contextStats{Total: 4000, ContextWindow: 4000}, 0, true},
+		{"over the window", contextStats{Total: 5000, ContextWindow: 4000}, 0, true},
+		{"unknown window", contextStats{Total: 1000}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, known := tt.stats.Headroom()
+			if got != tt.want || known != tt.wantKnown {
+				t.Errorf("Headroom() = %d, %v, want %d, %v", got, known, tt.want, tt.wantKnown)
+			}
+		})
+	}
+}
+
+func TestFormatContextStats(t *testing.T) {
+	tests := []struct {
+		name      string
+		stats     contextStats
+		want      []string
+		wantNotIn []string
+	}{
+		{
+			name: "all parts",
+			stats: contextStats{SystemPrompt: 10, EarlierTurns: 20, Query: 5, Commands: 7, Total: 100,
+				Files: []fileTokenStat{{Path: "main.go", Tokens: 58}}, ContextWindow: 1000, MaxTokens: 500},
+			want: []string{"| System prompt | 10 |", "| Examples and history | 20 |", "| Query | 5 |", "| `main.go` | 58 |",
+				"| Command output | 7 |", "| **Total** | 100 |", "900 of the 1000-token context window, limited to 500 tokens by this request."},
+		},
+		{
+			name:      "no optional parts",
+			stats:     contextStats{SystemPrompt: 10, Query: 5, Total: 15},
+			want:      []string{"| **Total** | 15 |", "unknown, the context window of this model is not known."},
+			wantNotIn: []string{"Examples and history", "Command output", "limited to"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatContextStats(tt.stats)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("formatContextStats() = %q, want it to contain %q", got, want)
+				}
+			}
+			for _, unwanted := range tt.wantNotIn {
+				if strings.Contains(got, unwanted) {
+					t.Errorf("formatContextStats() = %q, want no %q", got, unwanted)
+				}
+			}
+		})
+	}
+}
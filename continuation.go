@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// finishReasonLength is the finish_reason of a response cut off at the output token limit
+const finishReasonLength = "length"
+
+// continuePrompt is the user turn asking the model to continue a truncated response
+const continuePrompt = "Your previous response was cut off. Continue exactly where you stopped, " +
+	"without repeating anything you already wrote and without any preamble."
+
+// truncatedResponse holds a response cut off at the output token limit, along with the request
+// that produced it, so that it can be continued
+type truncatedResponse struct {
+	request          deepseek.ChatCompletionRequest
+	bodyFields       map[string]any // Request body fields of the request, such as user and seed
+	content          string
+	jsonMode         bool // Validate the content as JSON once it is complete
+	outputFormat     string
+	maxResponseChars int
+	anonymizer       *pathAnonymizer // Restores anonymized file paths in the content, if set
+	continuations    int
+	expiresAt        time.Time
+}
+
+// truncatedResponseStore is a short-lived in-memory store for truncated responses
+type truncatedResponseStore struct {
+	mu      sync.Mutex
+	entries map[string]*truncatedResponse
+	ttl     time.Duration
+}
+
+// newTruncatedResponseStore creates a new store with the given TTL
+func newTruncatedResponseStore(ttl time.Duration) *truncatedResponseStore {
+	return &truncatedResponseStore{
+		entries: make(map[string]*truncatedResponse),
+		ttl:     ttl,
+	}
+}
+
+// Put stores a truncated response and returns a token to continue it
+func (t *truncatedResponseStore) Put(entry *truncatedResponse) (string, error) {
+	token, err := newContinuationToken()
+	if err != nil {
+		return "", err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.evictExpiredLocked()
+	entry.expiresAt = time.Now().Add(t.ttl)
+	t.entries[token] = entry
+	return token, nil
+}
+
+// Take removes and returns the truncated response for a token
+func (t *truncatedResponseStore) Take(token string) (*truncatedResponse, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.evictExpiredLocked()
+
+	entry, ok := t.entries[token]
+	if !ok {
+		return nil, fmt.Errorf("continue_from token %q is unknown or has expired", token)
+	}
+	delete(t.entries, token)
+	return entry, nil
+}
+
+// Restore puts back a truncated response taken with Take under its original token
+func (t *truncatedResponseStore) Restore(token string, entry *truncatedResponse) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry.expiresAt = time.Now().Add(t.ttl)
+	t.entries[token] = entry
+}
+
+// evictExpiredLocked removes expired entries. The caller must hold t.mu.
+func (t *truncatedResponseStore) evictExpiredLocked() {
+	now := time.Now()
+	for token, entry := range t.entries {
+		if now.After(entry.expiresAt) {
+			delete(t.entries, token)
+		}
+	}
+}
+
+// isTruncated reports whether a completion was cut off at the output token limit
+func isTruncated(response *deepseek.ChatCompletionResponse) bool {
+	return len(response.Choices) > 0 && response.Choices[0].FinishReason == finishReasonLength
+}
+
+// formatTruncationNote formats the note appended to a response that can be continued
+func formatTruncationNote(token string) string {
+	return fmt.Sprintf("\n\n---\n*The response was cut off at the model's output limit. Call `deepseek_ask` with `continue_from` set to `%s` to continue it.*", token)
+}
+
+// storeTruncatedResponse keeps a truncated response so that it can be continued, returning
+// the note to append to it. An empty note is returned if the response cannot be stored.
+func (s *DeepseekServer) storeTruncatedResponse(cfg *Config, entry *truncatedResponse) string {
+	if entry.continuations >= cfg.MaxContinuations {
+		return fmt.Sprintf("\n\n---\n*The response was cut off at the model's output limit and the maximum of %d continuation(s) has been reached.*", cfg.MaxContinuations)
+	}
+	token, err := s.truncated.Put(entry)
+	if err != nil {
+		s.logger.Error("Failed to store truncated response: %v", err)
+		return ""
+	}
+	s.logger.Info("Response was truncated at the output limit; stored for continuation")
+	return formatTruncationNote(token)
+}
+
+// continueTruncatedResponse continues a truncated response until the model finishes or the
+// maximum number of continuations is reached, and returns the stitched full answer
+func (s *DeepseekServer) continueTruncatedResponse(ctx context.Context, token string) *mcp.CallToolResult {
+	entry, err := s.truncated.Take(token)
+	if err != nil {
+		s.logger.Warn("Failed to continue truncated response: %v", err)
+		return toolError(ErrorCodeNotFound, fmt.Sprintf("Cannot continue response: %v", err))
+	}
+
+	// Continuations are sent with the user, seed and response format of the original request
+	cfg := s.configFrom(ctx)
+	ctx = withRequestBodyFields(ctx, entry.bodyFields)
+	truncated := true
+	for truncated && entry.continuations < cfg.MaxContinuations {
+		request := entry.request
+		request.Messages = append(append([]deepseek.ChatCompletionMessage{}, entry.request.Messages...),
+			deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleAssistant, Content: entry.content},
+			deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleUser, Content: continuePrompt},
+		)
+
+		s.logger.Info("Continuing truncated response (continuation %d of at most %d)", entry.continuations+1, cfg.MaxContinuations)
+		response, err := s.createChatCompletion(ctx, &request)
+		if err != nil {
+			s.logger.Error("DeepSeek API error while continuing response: %v", err)
+			// Keep the response under the same token so the continuation can be retried
+			s.truncated.Restore(token, entry)
+			return toolError(apiErrorCode(err), fmt.Sprintf("Error from DeepSeek API while continuing the response: %v", err))
+		}
+		entry.continuations++
+		if len(response.Choices) > 0 {
+			entry.content += response.Choices[0].Message.Content
+		}
+		truncated = isTruncated(response)
+	}
+
+	content := entry.anonymizer.Restore(entry.content)
+	if entry.jsonMode && !truncated {
+		cleanedJSON, err := extractStrictJSON(content)
+		if err != nil {
+			s.logger.Error("JSON mode validation of the continued response failed: %v", err)
+			return toolError(ErrorCodeInvalidResponse, fmt.Sprintf("JSON mode validation failed: %v. The continued response could not be parsed as valid JSON. Original preview: %s", err, truncateString(content, 100)))
+		}
+		return mcp.NewToolResultText(cleanedJSON)
+	}
+	if entry.outputFormat == OutputFormatPlain {
+		content = markdownToPlainText(content)
+	}
+	if truncated {
+		content += s.storeTruncatedResponse(cfg, entry)
+	}
+	return s.paginatedResult(content, entry.maxResponseChars)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// continuationTokenPattern matches the token of a truncation note
+var continuationTokenPattern = regexp.MustCompile("`continue_from` set to `([^`]+)`")
+
+func TestContinueTruncatedResponseKeepsBodyFields(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          map[string]any
+		env           map[string]string
+		first, second string // Contents of the truncated response and of its continuation
+		want          string
+		wantFields    []string
+	}{
+		{
+			name:   "no fields",
+			args:   map[string]any{"query": "q"},
+			first:  "first half, ",
+			second: "second half",
+			want:   "first half, second half",
+		},
+		{
+			name:       "user and seed",
+			args:       map[string]any{"query": "q", "user": "alice", "seed": float64(9)},
+			first:      "first half, ",
+			second:     "second half",
+			want:       "first half, second half",
+			wantFields: []string{"seed", "user"},
+		},
+		{
+			name:       "json schema",
+			args:       map[string]any{"query": "q", "response_format": "json_schema", "json_schema": `{"type":"object"}`},
+			env:        map[string]string{"DEEPSEEK_MODEL_CAPABILITIES": "deepseek-reasoner=json_schema"},
+			first:      `{"a":`,
+			second:     `1}`,
+			want:       `{"a":1}`,
+			wantFields: []string{"response_format"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+				calls++
+				if calls == 1 {
+					response := textResponse(tt.first)
+					response.Choices[0].FinishReason = finishReasonLength
+					return response, nil
+				}
+				return textResponse(tt.second), nil
+			}}
+			s := newTestServer(t, client, tt.env)
+
+			text := resultText(callTool(t, s.handleAskDeepseek, tt.args))
+			match := continuationTokenPattern.FindStringSubmatch(text)
+			if match == nil {
+				t.Fatalf("result %q has no continuation token", text)
+			}
+			text = resultText(callTool(t, s.handleAskDeepseek, map[string]any{"continue_from": match[1]}))
+			if !strings.Contains(text, tt.want) {
+				t.Errorf("continued result = %q, want the stitched answer", text)
+			}
+
+			if client.calls() != 2 {
+				t.Fatalf("requests = %d, want 2", client.calls())
+			}
+			for _, key := range tt.wantFields {
+				if _, ok := client.bodyFields[1][key]; !ok {
+					t.Errorf("continuation lacks the %s body field", key)
+				}
+			}
+			if !reflect.DeepEqual(client.bodyFields[1], client.bodyFields[0]) {
+				t.Errorf("continuation body fields = %v, want those of the original request %v", client.bodyFields[1], client.bodyFields[0])
+			}
+		})
+	}
+}
+
+func TestTruncatedResponseStoreTakeOnce(t *testing.T) {
+	store := newTruncatedResponseStore(continuationTokenTTL)
+	token, err := store.Put(&truncatedResponse{content: "partial"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := store.Take(token)
+	if err != nil || entry.content != "partial" {
+		t.Fatalf("Take() = %v, %v", entry, err)
+	}
+	if _, err := store.Take(token); err == nil {
+		t.Error("second Take() succeeded, want an unknown token error")
+	}
+	store.Restore(token, entry)
+	if _, err := store.Take(token); err != nil {
+		t.Errorf("Take() after Restore() error = %v", err)
+	}
+}
@@ -2,13 +2,18 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
+	"unicode/utf8"
 
 	"github.com/cohesion-org/deepseek-go"
 	mcp "github.com/mark3labs/mcp-go/mcp" // Changed import
@@ -16,11 +21,30 @@ import (
 
 // DeepseekServer implements the ToolHandler interface for DeepSeek API interactions
 type DeepseekServer struct {
-	config   *Config
-	client   DeepseekAPI // Use the interface
-	models   []DeepseekModelInfo // Dynamically discovered models
-	modelsMu sync.RWMutex        // Mutex for thread-safe model access
-	logger   Logger              // Added
+	configPtr atomic.Pointer[Config] // Current configuration, replaced as a whole on reload
+	client    DeepseekAPI            // Use the interface
+	models    []DeepseekModelInfo    // Dynamically discovered models
+	modelsMu  sync.RWMutex           // Mutex for thread-safe model access
+	logger    Logger                 // Added
+
+	fileTemplate *template.Template      // Parsed Config.FileTemplate, nil for the default layout
+	pages        *responsePageStore      // Remaining parts of paginated responses
+	limiter      *requestLimiter         // Bounds concurrent API requests
+	usage        *usageLedger            // Local record of token usage per completion
+	active       *activeRequests         // Cancel functions of in-flight deepseek_ask calls
+	truncated    *truncatedResponseStore // Responses cut off at the output limit, kept for continuation
+	fileCache    *fileCache              // Contents of recently included files
+	summaries    *summaryCache           // Summaries of files condensed by context_compression
+	breaker      *circuitBreaker         // Fails API calls fast while the API is down
+	balance      *balanceGuard           // Refuses API calls while the balance is below DEEPSEEK_MIN_BALANCE
+
+	reloadMu   sync.Mutex              // Serializes configuration reloads
+	loadConfig func() (*Config, error) // Loads the configuration on reload, NewConfig when nil
+
+	emptyResponses  atomic.Int64 // Number of empty responses returned by the model
+	emptyRecoveries atomic.Int64 // Number of empty responses recovered by retrying
+
+	stopBalanceLog context.CancelFunc // Stops the periodic balance logging, nil when it is off
 }
 
 // NewDeepseekServer creates a new DeepseekServer with the provided configuration
@@ -29,34 +53,112 @@ func NewDeepseekServer(ctx context.Context, config *Config) (*DeepseekServer, er
 		return nil, errors.New("config cannot be nil")
 	}
 
-	if config.DeepseekAPIKey == "" {
+	if config.DeepseekAPIKey == "" && !config.OfflineMode {
 		return nil, errors.New("DeepSeek API key is required")
 	}
+	registerSecret(config.DeepseekAPIKey)
 
-	// Create the real client and wrap it in the adapter
-	client := &realDeepseekClient{
-		client: deepseek.NewClient(config.DeepseekAPIKey),
+	logger := getLoggerFromContext(ctx) // Get logger instance
+	if err := config.checkAllowedFilePaths(logger); err != nil {
+		return nil, err
 	}
 
-	logger := getLoggerFromContext(ctx) // Get logger instance
+	// Create the real client and wrap it in the adapter, or the offline client in offline mode
+	var client DeepseekAPI
+	if config.OfflineMode {
+		logger.Warn("Offline mode is enabled: the DeepSeek API is never called and responses are canned. For development only")
+		client = newOfflineDeepseekClient(config.OfflineFixtures)
+	} else {
+		apiClient := deepseek.NewClient(config.DeepseekAPIKey)
+		apiClient.HTTPClient = newAPIHTTPClient(config.ConnectTimeout, billingHeaders(config.Organization, config.Project))
+		client = &realDeepseekClient{
+			client: apiClient,
+		}
+	}
+
+	breaker := newCircuitBreaker(config.BreakerThreshold, config.BreakerCooldown, logger)
+	setMaxOpenFiles(config.MaxOpenFiles)
 
 	server := &DeepseekServer{
-		config: config,
-		client: client, // Use the adapter
-		logger: logger, // Initialize logger
+		client:    &breakerClient{client: client, breaker: breaker}, // Use the adapter behind the circuit breaker
+		breaker:   breaker,
+		logger:    logger, // Initialize logger
+		pages:     newResponsePageStore(continuationTokenTTL),
+		truncated: newTruncatedResponseStore(continuationTokenTTL),
+		fileCache: newFileCache(config.FileCacheMaxBytes),
+		summaries: newSummaryCache(),
+		limiter:   newRequestLimiter(config.MaxConcurrentRequests).withQueue(config.QueueDepth, config.MaxQueueWait, logger),
+		active:    newActiveRequests(),
+		balance:   newBalanceGuard(),
 	}
 
-	err := server.discoverModels(ctx)
+	server.configPtr.Store(config)
+
+	usage, err := newUsageLedger(config.UsageLedgerPath, config.Organization, config.Project)
 	if err != nil {
-		server.logger.Warn("Failed to discover DeepSeek models, will use fallback models: %v", err) // Use s.logger
+		return nil, fmt.Errorf("failed to load usage ledger: %w", err)
+	}
+	server.usage = usage
+	if config.Organization != "" || config.Project != "" {
+		logger.Info("Attributing API usage to organization %q, project %q", config.Organization, config.Project)
+	}
+
+	if config.FileTemplate != "" {
+		tmpl, err := parseFileTemplate(config.FileTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid file template: %w", err)
+		}
+		server.fileTemplate = tmpl
+	}
+
+	err = server.discoverModels(ctx)
+	if err != nil {
+		server.logger.Warn("Failed to discover DeepSeek models, using unverified fallback models until discovery succeeds: %v", err) // Use s.logger
+	} else if !server.modelsVerified() {
+		server.logger.Warn("The DeepSeek API listed no models, using unverified fallback models until discovery succeeds")
+	}
+	if !server.modelsVerified() {
+		go server.refreshModelsInBackground(ctx)
+	}
+	if config.BalanceLogInterval > 0 {
+		logger.Info("Logging the balance every %v", config.BalanceLogInterval)
+		balanceLogCtx, stop := context.WithCancel(ctx)
+		server.stopBalanceLog = stop
+		go server.logBalancePeriodically(balanceLogCtx, config.BalanceLogInterval)
 	}
 
 	return server, nil
 }
 
-// Close closes the DeepSeek client connection (not needed for the DeepSeek API)
+// config returns the current configuration. Handlers may see a newer configuration on
+// each call after a reload, so values that must stay consistent should be read once.
+func (s *DeepseekServer) config() *Config {
+	return s.configPtr.Load()
+}
+
+// requestConfigKey is the context key for the configuration a request was started with
+const requestConfigKey contextKey = "requestConfig"
+
+// withRequestConfig returns a context carrying cfg as the configuration of the request, so that
+// the API calls it makes use the same limits and prompts as the handler after a reload
+func withRequestConfig(ctx context.Context, cfg *Config) context.Context {
+	return context.WithValue(ctx, requestConfigKey, cfg)
+}
+
+// configFrom returns the configuration of the request carried by ctx, or the current
+// configuration outside a request that bound one
+func (s *DeepseekServer) configFrom(ctx context.Context) *Config {
+	if cfg, ok := ctx.Value(requestConfigKey).(*Config); ok {
+		return cfg
+	}
+	return s.config()
+}
+
+// Close stops background work. The DeepSeek API client itself needs no closing.
 func (s *DeepseekServer) Close() {
-	// No need to close the client in the DeepSeek API
+	if s.stopBalanceLog != nil {
+		s.stopBalanceLog()
+	}
 }
 
 // discoverModels fetches the available models from the DeepSeek API
@@ -67,7 +169,7 @@ func (s *DeepseekServer) discoverModels(ctx context.Context) error {
 	// Get models from the API with timeout
 	var apiModels *deepseek.APIModels
 	operation := func() error {
-		timeoutCtx, cancel := context.WithTimeout(ctx, s.config.HTTPTimeout)
+		timeoutCtx, cancel := context.WithTimeout(ctx, s.attemptTimeout(ctx, s.config().HTTPTimeout))
 		defer cancel()
 		var err error
 		apiModels, err = s.client.ListAllModels(timeoutCtx)
@@ -76,9 +178,10 @@ func (s *DeepseekServer) discoverModels(ctx context.Context) error {
 
 	err := RetryWithBackoff(
 		ctx,
-		s.config.MaxRetries,
-		s.config.InitialBackoff,
-		s.config.MaxBackoff,
+		s.config().MaxRetries,
+		s.config().InitialBackoff,
+		s.config().MaxBackoff,
+		s.config().MaxRetryWait,
 		operation,
 		IsRetryableError,
 		s.logger,
@@ -128,66 +231,667 @@ func (s *DeepseekServer) formatModelName(modelID string) string {
 func (s *DeepseekServer) handleAskDeepseek(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	s.logger.Info("Handling deepseek_ask request")
 
+	// Read the configuration once, so that a reload during the request cannot mix two of them
+	cfg := s.config()
+	ctx = withRequestConfig(ctx, cfg)
+
+	// Return the next part of a previously paginated response
+	if token := req.GetString("continuation_token", ""); token != "" {
+		part, index, total, err := s.pages.Next(token)
+		if err != nil {
+			s.logger.Warn("Failed to continue paginated response: %v", err)
+			return toolError(ErrorCodeNotFound, fmt.Sprintf("Cannot continue response: %v", err)), nil
+		}
+		return mcp.NewToolResultText(formatResponsePart(part, token, index, total)), nil
+	}
+
+	// Continue a response that was cut off at the output token limit
+	if token := req.GetString("continue_from", ""); token != "" {
+		return s.continueTruncatedResponse(ctx, token), nil
+	}
+
 	query, err := req.RequireString("query")
 	if err != nil {
 		s.logger.Error("Missing required 'query' parameter: %v", err)
-		return mcp.NewToolResultError("Missing required 'query' parameter: " + err.Error()), nil
+		return toolError(ErrorCodeInvalidArgument, "Missing required 'query' parameter: "+err.Error()), nil
+	}
+	if queryChars := utf8.RuneCountInString(query); cfg.MaxQueryChars > 0 && queryChars > cfg.MaxQueryChars {
+		s.logger.Warn("Rejecting query of %d characters (limit %d)", queryChars, cfg.MaxQueryChars)
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Query is too long: %d characters (maximum is %d). "+
+			"Pass large content through file_paths instead of pasting it into the query, or summarize it first.",
+			queryChars, cfg.MaxQueryChars)), nil
 	}
 
-	modelName := s.config.DeepseekModel
+	// Track the request so it can be cancelled with deepseek_cancel
+	requestID := req.GetString("request_id", "")
+	if requestID == "" {
+		if requestID, err = newRequestID(); err != nil {
+			s.logger.Error("%v", err)
+			return toolError(ErrorCodeInternal, err.Error()), nil
+		}
+	}
+	ctx, done, err := s.active.Register(ctx, requestID)
+	if err != nil {
+		s.logger.Error("Cannot register request: %v", err)
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Cannot start request: %v", err)), nil
+	}
+	defer done()
+	s.logger.Info("Request ID: %s", requestID)
+
+	modelName := cfg.DeepseekModel
 	if customModel := req.GetString("model", ""); customModel != "" {
 		if err := s.ValidateModelID(customModel); err != nil {
 			s.logger.Error("Invalid model requested: %v", err)
-			return mcp.NewToolResultError(fmt.Sprintf("Invalid model specified: %v", err)), nil
+			return toolError(ErrorCodeInvalidModel, fmt.Sprintf("Invalid model specified: %v", err)), nil
 		}
 		s.logger.Info("Using request-specific model: %s", customModel)
 		modelName = customModel
 	}
 
-	systemPrompt := s.config.DeepseekSystemPrompt
-	if customPrompt := req.GetString("systemPrompt", ""); customPrompt != "" {
+	allowEscalation := req.GetBool("allow_escalation", false)
+	if allowEscalation && len(cfg.EscalationModels) == 0 {
+		s.logger.Warn("allow_escalation requested but DEEPSEEK_ESCALATION_MODELS is not set; not escalating")
+		allowEscalation = false
+	}
+
+	systemPrompt := cfg.DeepseekSystemPrompt
+	mode, err := lookupPromptMode(req.GetString("mode", ""))
+	if err != nil {
+		s.logger.Error("Invalid mode requested: %v", err)
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid mode: %v", err)), nil
+	}
+	switch {
+	case mode != "":
+		s.logger.Info("Using system prompt mode: %s", mode)
+		systemPrompt = promptModeProfiles[mode]
+	case systemPrompt == promptModeProfiles[PromptModeCode]:
+		s.logger.Info("Using system prompt mode: %s (default)", PromptModeCode)
+	}
+	if presetName := req.GetString("preset", ""); presetName != "" {
+		preset, ok := cfg.Presets[presetName]
+		if !ok {
+			s.logger.Error("Unknown preset requested: %s", presetName)
+			return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Unknown preset: %s. Available presets are: %s",
+				presetName, strings.Join(presetNames(cfg.Presets), ", "))), nil
+		}
+		s.logger.Info("Using system prompt preset: %s", presetName)
+		systemPrompt = preset.SystemPrompt
+	}
+	customPrompt := req.GetString("systemPrompt", "")
+	if promptFile := req.GetString("systemPromptFile", ""); promptFile != "" {
+		if customPrompt != "" {
+			s.logger.Warn("Ignoring systemPromptFile %s: an inline systemPrompt was given", promptFile)
+		} else {
+			filePrompt, err := s.readSystemPromptFile(cfg, promptFile)
+			if err != nil {
+				s.logger.Error("Failed to read system prompt file: %v", err)
+				return toolError(fileErrorCode(err), fmt.Sprintf("Failed to read systemPromptFile: %v", err)), nil
+			}
+			s.logger.Info("Using system prompt from file: %s", promptFile)
+			systemPrompt = filePrompt
+		}
+	}
+	if customPrompt != "" {
 		s.logger.Info("Using request-specific system prompt")
 		systemPrompt = customPrompt
 	}
+	if languageCode := req.GetString("response_language", ""); languageCode != "" {
+		language, err := lookupResponseLanguage(languageCode)
+		if err != nil {
+			s.logger.Error("Invalid response_language requested: %s", languageCode)
+			return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid response_language: %v", err)), nil
+		}
+		s.logger.Info("Enforcing response language: %s (%s)", language, languageCode)
+		systemPrompt = withResponseLanguage(systemPrompt, language)
+	}
+	verbosity, err := lookupVerbosity(req.GetString("verbosity", ""))
+	if err != nil {
+		s.logger.Error("Invalid verbosity requested: %v", err)
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid verbosity: %v", err)), nil
+	}
+	s.logger.Info("Using verbosity: %s", verbosity)
+	systemPrompt = withVerbosity(systemPrompt, verbosity)
+	softMaxTokens := req.GetInt("soft_max_tokens", 0)
+	if softMaxTokens < 0 {
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid soft_max_tokens: %d. It must be positive", softMaxTokens)), nil
+	}
+	if softMaxTokens > 0 {
+		s.logger.Info("Asking the model to conclude within %d tokens", softMaxTokens)
+		systemPrompt = withSoftMaxTokens(systemPrompt, softMaxTokens)
+	}
+
+	// Replace file paths in the prompt with opaque labels, restoring them in the response
+	var anonymizer *pathAnonymizer
+	if req.GetBool("anonymize_paths", false) {
+		if len(req.GetStringSlice("workspace_files", nil)) > 0 || req.GetString("include_tree", "") != "" {
+			return toolError(ErrorCodeInvalidArgument, "anonymize_paths cannot be combined with workspace_files or include_tree, which need the real paths."), nil
+		}
+		anonymizer = newPathAnonymizer()
+	}
 
 	filePaths := req.GetStringSlice("file_paths", nil) // Changed to GetStringSlice with a default
+	workspaceFiles := req.GetStringSlice("workspace_files", nil)
+	if (len(filePaths) > 0 || len(workspaceFiles) > 0) && cfg.DisableFileAccess {
+		s.logger.Warn("Rejecting request with file_paths or workspace_files: file access is disabled")
+		return toolError(ErrorCodeFileDenied, "File access is disabled on this server; remove file_paths and workspace_files and include the content in the query instead."), nil
+	}
+
+	// Ask for a multi-file diff instead of an answer when workspace files are given
+	if len(workspaceFiles) > 0 {
+		if len(filePaths) > 0 || req.GetArguments()["inline_files"] != nil {
+			return toolError(ErrorCodeInvalidArgument, "workspace_files cannot be combined with file_paths or inline_files; list every file the change needs in workspace_files."), nil
+		}
+		if result := s.checkFilePathCount(cfg, len(workspaceFiles), false); result != nil {
+			return result, nil
+		}
+		return s.askWorkspaceDiff(ctx, query, systemPrompt, modelName, workspaceFiles), nil
+	}
+
+	if result := s.checkFilePathCount(cfg, len(filePaths), false); result != nil {
+		return result, nil
+	}
+	filePaths, err = expandPaths(filePaths)
+	if err != nil {
+		s.logger.Error("Invalid file_paths: %v", err)
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid file_paths: %v", err)), nil
+	}
+
+	// Files are read with a per-request copy of the config so that allowed file types can be
+	// extended for this call only. The directory allowlist always applies.
+	fileConfig := cfg
+	if extraTypes := req.GetStringSlice("allow_file_types", nil); len(extraTypes) > 0 {
+		if !cfg.AllowPerRequestTypeOverride {
+			s.logger.Warn("Rejecting allow_file_types: per-request file type overrides are disabled")
+			return toolError(ErrorCodeFileDenied, "allow_file_types is not permitted on this server; set DEEPSEEK_ALLOW_PER_REQUEST_TYPE_OVERRIDE=true to enable it."), nil
+		}
+		extraTypes, err = parseAllowedFileTypes(extraTypes)
+		if err != nil {
+			return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid allow_file_types: %v", err)), nil
+		}
+		overrideConfig := *cfg
+		overrideConfig.AllowedFileTypes = append(append([]string{}, cfg.AllowedFileTypes...), extraTypes...)
+		fileConfig = &overrideConfig
+		s.logger.Warn("AUDIT: file type override for this request allows %s in addition to the configured types (files: %s)",
+			strings.Join(extraTypes, ", "), strings.Join(filePaths, ", "))
+	}
+	filePaths = expandFilePaths(filePaths, fileConfig, s.logger)
+	if result := s.checkFilePathCount(cfg, len(filePaths), true); result != nil {
+		return result, nil
+	}
+
+	inlineFiles, err := parseInlineFiles(req.GetArguments()["inline_files"], cfg)
+	if err != nil {
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid inline_files: %v", err)), nil
+	}
 
-	jsonMode := req.GetBool("json_mode", false) // Added default value
+	// Commands are checked against the allowlist before anything is run
+	var commands [][]string
+	if requested := req.GetStringSlice("commands", nil); len(requested) > 0 {
+		commands, err = validateCommands(requested, cfg)
+		if err != nil {
+			s.logger.Warn("Rejecting commands: %v", err)
+			return toolError(ErrorCodeCommandDenied, fmt.Sprintf("Invalid commands: %v", err)), nil
+		}
+	}
+
+	fileOrder := req.GetString("order", cfg.FileOrder)
+	if !isValidFileOrder(fileOrder) {
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid order: %s. Supported values are %q, %q, %q and %q",
+			fileOrder, FileOrderAsGiven, FileOrderAlphabetical, FileOrderSizeAsc, FileOrderSizeDesc)), nil
+	}
+	if len(filePaths) > 1 {
+		s.logger.Info("Ordering %d files: %s", len(filePaths), fileOrder)
+		filePaths = orderFilePaths(filePaths, fileOrder)
+	}
+
+	// READMEs near the files go first, as project context for the files that follow
+	var readmePaths map[string]bool
+	if req.GetBool("auto_include_readme", false) && len(filePaths) > 0 {
+		readmes := findNearestReadmes(filePaths, fileConfig)
+		s.logger.Info("Including %d README(s) as context for the directories of file_paths", len(readmes))
+		readmePaths = make(map[string]bool, len(readmes))
+		for _, readme := range readmes {
+			readmePaths[readme] = true
+		}
+		filePaths = append(readmes, filePaths...)
+		if result := s.checkFilePathCount(cfg, len(filePaths), true); result != nil {
+			return result, nil
+		}
+	}
+
+	responseFormat, err := resolveResponseFormat(req.GetString("response_format", ""), req.GetBool("json_mode", false))
+	if err != nil {
+		s.logger.Error("Invalid response format: %v", err)
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid response_format: %v", err)), nil
+	}
+	var jsonSchema map[string]any
+	if responseFormat == ResponseFormatJSONSchema {
+		if jsonSchema, err = parseJSONSchema(req.GetString("json_schema", "")); err != nil {
+			return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid response_format: %v", err)), nil
+		}
+	}
+	jsonMode := responseFormat != ResponseFormatText
 	if jsonMode {
-		s.logger.Info("JSON mode is enabled via request")
+		s.logger.Info("Using response format %s", responseFormat)
+		capability := CapabilityJSONMode
+		if responseFormat == ResponseFormatJSONSchema {
+			capability = CapabilityJSONSchema
+		}
+		known, err := cfg.checkModelCapability(modelName, capability)
+		if err != nil {
+			s.logger.Error("Rejecting %s request: %v", responseFormat, err)
+			return toolError(ErrorCodeUnsupported, fmt.Sprintf("response_format %s is not supported: %v. Use a model that supports it or another response_format.", responseFormat, err)), nil
+		}
+		if !known {
+			s.logger.Warn("Capabilities of model %s are unknown; response_format %s may not be supported", modelName, responseFormat)
+		}
+	}
+
+	contextCompression := req.GetString("context_compression", ContextCompressionNone)
+	if !isValidContextCompression(contextCompression) {
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid context_compression: %s. Supported values are %q, %q and %q",
+			contextCompression, ContextCompressionNone, ContextCompressionTruncate, ContextCompressionSummarize)), nil
+	}
+
+	// Tokens of the context window kept free for the response; files are cut to make room
+	reserveOutput := req.GetInt("reserve_output_tokens", 0)
+	contextWindow := modelContextWindows[modelName]
+	if reserveOutput < 0 {
+		return toolError(ErrorCodeInvalidArgument, "reserve_output_tokens must not be negative"), nil
+	}
+	if reserveOutput > 0 && contextWindow == 0 {
+		return toolError(ErrorCodeUnsupported, fmt.Sprintf("reserve_output_tokens requires a model with a known context window, which %s is not", modelName)), nil
+	}
+	if reserveOutput >= contextWindow && reserveOutput > 0 {
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid reserve_output_tokens: %d. It must be less than the %d-token context window of %s", reserveOutput, contextWindow, modelName)), nil
+	}
+	if _, ok := req.GetArguments()["context_compression"]; !ok && reserveOutput > 0 {
+		// Files are truncated to fit the reservation unless context_compression says otherwise
+		contextCompression = ContextCompressionTruncate
+	}
+
+	stripCodeComments := req.GetBool("strip_comments", false)
+	if stripCodeComments {
+		s.logger.Info("Stripping comments and blank lines from included files")
+	}
+
+	// Included files can also be returned as resources, keeping them apart from the answer
+	attachAsResources := req.GetBool("attach_as_resources", false)
+	if attachAsResources && len(filePaths) == 0 && len(inlineFiles) == 0 {
+		s.logger.Warn("attach_as_resources requested without file_paths or inline_files; there is nothing to attach")
+	}
+
+	// The response can be written to a file within the writable roots instead of returned
+	outputFile := req.GetString("output_file", "")
+	outputMode := req.GetString("output_mode", OutputModeOverwrite)
+	if outputFile != "" {
+		if !isValidOutputMode(outputMode) {
+			return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid output_mode: %s. Supported values are %q and %q",
+				outputMode, OutputModeOverwrite, OutputModeAppend)), nil
+		}
+		resolved, err := resolveOutputFile(outputFile, cfg)
+		if err != nil {
+			s.logger.Error("Rejecting output_file %s: %v", outputFile, err)
+			return toolError(fileErrorCode(err), fmt.Sprintf("Invalid output_file: %v", err)), nil
+		}
+		outputFile = resolved
+	}
+
+	includeCitations := req.GetBool("include_citations", false)
+	if includeCitations {
+		if jsonMode {
+			return toolError(ErrorCodeInvalidArgument, "include_citations cannot be combined with a JSON response_format"), nil
+		}
+		if len(filePaths) == 0 && len(inlineFiles) == 0 {
+			s.logger.Warn("include_citations requested without file_paths or inline_files; there is nothing to cite")
+		} else {
+			s.logger.Info("Requesting citations for the provided files")
+			systemPrompt = strings.TrimRight(systemPrompt, "\n") + "\n\n" + citationInstruction
+		}
+	}
+
+	user, err := sanitizeUserID(req.GetString("user", cfg.DefaultUser))
+	if err != nil {
+		s.logger.Error("Invalid user parameter: %v", err)
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid user parameter: %v", err)), nil
+	}
+	if user != "" {
+		s.logger.Info("Request user: %s", user)
+		ctx = withRequestBodyField(ctx, "user", user)
 	}
 
-	chatMessages := []deepseek.ChatCompletionMessage{
-		{Role: deepseek.ChatMessageRoleSystem, Content: systemPrompt},
-		{Role: deepseek.ChatMessageRoleUser, Content: query},
+	var seed *int64
+	if _, ok := req.GetArguments()["seed"]; ok {
+		seedValue := req.GetFloat("seed", 0)
+		if seedValue != math.Trunc(seedValue) || seedValue < 0 || seedValue > math.MaxInt32 {
+			return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid seed: %v. Seed must be an integer between 0 and %d", seedValue, math.MaxInt32)), nil
+		}
+		seed = new(int64)
+		*seed = int64(seedValue)
+		s.logger.Info("Using seed %d for reproducible sampling", *seed)
+		ctx = withRequestBodyField(ctx, "seed", *seed)
+	}
+
+	choiceCount := req.GetInt("n", 1)
+	if choiceCount < 1 || choiceCount > cfg.MaxChoices {
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid n: %d. It must be between 1 and %d", choiceCount, cfg.MaxChoices)), nil
+	}
+	if choiceCount > 1 {
+		s.logger.Info("Requesting %d completion choices", choiceCount)
+	}
+	returnAllChoices := req.GetBool("return_all_choices", false)
+	if returnAllChoices && jsonMode {
+		return toolError(ErrorCodeInvalidArgument, "return_all_choices cannot be combined with a JSON response_format"), nil
+	}
+
+	// Several samples of the same request can be returned together or synthesized into one answer
+	samples := req.GetInt("self_consistency", 1)
+	if samples < 1 || samples > cfg.MaxSelfConsistency {
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid self_consistency: %d. It must be between 1 and %d", samples, cfg.MaxSelfConsistency)), nil
+	}
+	consistencyMode := req.GetString("self_consistency_mode", SelfConsistencySynthesize)
+	if !isValidSelfConsistencyMode(consistencyMode) {
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid self_consistency_mode: %s. Supported values are %q and %q",
+			consistencyMode, SelfConsistencySynthesize, SelfConsistencyAll)), nil
+	}
+	if samples > 1 {
+		switch {
+		case choiceCount > 1:
+			return toolError(ErrorCodeInvalidArgument, "self_consistency cannot be combined with n"), nil
+		case allowEscalation:
+			return toolError(ErrorCodeInvalidArgument, "self_consistency cannot be combined with allow_escalation"), nil
+		case consistencyMode == SelfConsistencyAll && jsonMode:
+			return toolError(ErrorCodeInvalidArgument, "self_consistency_mode all cannot be combined with a JSON response_format"), nil
+		}
+	}
+
+	functionTools, err := parseFunctionTools(req.GetArguments()["tools"])
+	if err != nil {
+		s.logger.Error("Invalid tools: %v", err)
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid tools: %v", err)), nil
+	}
+	if len(functionTools) > 0 {
+		known, err := cfg.checkModelCapability(modelName, CapabilityFunctionCalling)
+		if err != nil {
+			s.logger.Error("Rejecting tools request: %v", err)
+			return toolError(ErrorCodeUnsupported, fmt.Sprintf("tools are not supported: %v. Use a model that supports function calling.", err)), nil
+		}
+		if !known {
+			s.logger.Warn("Capabilities of model %s are unknown; function calling may not be supported", modelName)
+		}
+		s.logger.Info("Offering %d function tool(s) to the model", len(functionTools))
+	}
+
+	rawResponse := req.GetBool("raw_response", false)
+	if samples > 1 && (len(functionTools) > 0 || rawResponse) {
+		return toolError(ErrorCodeInvalidArgument, "self_consistency cannot be combined with tools or raw_response"), nil
+	}
+
+	maxResponseChars := req.GetInt("max_response_chars", cfg.MaxResponseChars)
+	if maxResponseChars < 0 {
+		return toolError(ErrorCodeInvalidArgument, "max_response_chars must not be negative"), nil
+	}
+
+	includeTiming := req.GetBool("include_timing", false)
+	timings := newRequestTimings()
+	defer timings.Log(s.logger)
+
+	outputFormat := req.GetString("output_format", OutputFormatMarkdown)
+	if !isValidOutputFormat(outputFormat) {
+		s.logger.Error("Invalid output_format requested: %s", outputFormat)
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid output_format: %s. Supported values are %q and %q", outputFormat, OutputFormatMarkdown, OutputFormatPlain)), nil
+	}
+
+	examples, err := parseExamples(req.GetArguments()["examples"])
+	if err != nil {
+		s.logger.Error("Invalid examples: %v", err)
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid examples: %v", err)), nil
+	}
+
+	var history []deepseek.ChatCompletionMessage
+	if historyFile := req.GetString("history_file", ""); historyFile != "" {
+		history, err = s.readHistoryFile(cfg, historyFile)
+		if err != nil {
+			s.logger.Error("Failed to read history file: %v", err)
+			return toolError(fileErrorCode(err), fmt.Sprintf("Failed to read history_file: %v", err)), nil
+		}
+	}
+
+	if treeRoot := req.GetString("include_tree", ""); treeRoot != "" {
+		if cfg.DisableFileAccess {
+			s.logger.Warn("Rejecting request with include_tree: file access is disabled")
+			return toolError(ErrorCodeFileDenied, "File access is disabled on this server; remove include_tree."), nil
+		}
+		treeRoot, err := expandPath(treeRoot)
+		if err != nil {
+			s.logger.Error("Invalid include_tree: %v", err)
+			return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid include_tree: %v", err)), nil
+		}
+		if len(cfg.AllowedFilePaths) > 0 && !isPathAllowed(treeRoot, cfg.AllowedFilePaths) {
+			s.logger.Error("Directory tree requested outside the allowed file paths: %s", treeRoot)
+			return toolError(ErrorCodeFileDenied, fmt.Sprintf("Directory is not allowed: %s. Allowed roots are: %s",
+				treeRoot, strings.Join(cfg.AllowedFilePaths, ", "))), nil
+		}
+		treeDepth := req.GetInt("tree_depth", defaultTreeDepth)
+		if treeDepth < 1 || treeDepth > maxTreeDepth {
+			return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid tree_depth: %d. It must be between 1 and %d", treeDepth, maxTreeDepth)), nil
+		}
+		endTree := timings.Start("Directory tree")
+		tree, err := buildDirectoryTree(treeRoot, treeDepth, maxTreeEntries, cfg)
+		endTree()
+		if err != nil {
+			s.logger.Error("Failed to build directory tree for %s: %v", treeRoot, err)
+			return toolError(ErrorCodeFileError, fmt.Sprintf("Failed to build directory tree: %v", err)), nil
+		}
+		s.logger.Info("Including directory tree of %s (depth %d)", treeRoot, treeDepth)
+		query = formatDirectoryTree(tree) + query
+	}
+
+	// Delimit files as untrusted data, and tell the model not to follow instructions in them
+	var untrustedBoundary string
+	if cfg.MitigatePromptInjection && (len(filePaths) > 0 || len(inlineFiles) > 0) {
+		untrustedBoundary, err = newUntrustedBoundary()
+		if err != nil {
+			s.logger.Error("%v", err)
+			return toolError(ErrorCodeInternal, fmt.Sprintf("Failed to prepare the request: %v", err)), nil
+		}
+		systemPrompt = withUntrustedDataNote(systemPrompt, untrustedBoundary)
+	}
+
+	// Few-shot examples and the conversation history go between the system prompt and the
+	// query as earlier turns
+	chatMessages := []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleSystem, Content: systemPrompt}}
+	if len(examples) > 0 {
+		s.logger.Info("Including %d few-shot example(s)", len(examples))
+		chatMessages = append(chatMessages, exampleMessages(examples)...)
+	}
+	if len(history) > 0 {
+		s.logger.Info("Including %d message(s) of conversation history", len(history))
+		chatMessages = append(chatMessages, history...)
+	}
+	chatMessages = append(chatMessages, deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleUser, Content: query})
+
+	// Files get what is left of the context window after the prompt and the reserved output
+	var fileTokenBudget, fileTokens int
+	if reserveOutput > 0 {
+		fileTokenBudget = contextWindow - reserveOutput - messagesTokens(chatMessages)
+		if fileTokenBudget < 0 {
+			return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("The prompt alone is estimated at %d tokens, which leaves less than the %d tokens reserved by reserve_output_tokens in the %d-token context window",
+				messagesTokens(chatMessages), reserveOutput, contextWindow)), nil
+		}
+		s.logger.Info("Reserving %d tokens for the response; %d tokens are left for files", reserveOutput, fileTokenBudget)
 	}
 
 	finalQuery := query
-	if len(filePaths) > 0 {
-		s.logger.Info("Processing %d file_paths for context", len(filePaths))
+	var citedFiles []citedFile // Files included in the query, for verifying citations
+	var includedFiles []string
+	var skippedFiles []skippedFile
+	var attachedFiles []attachedFile // Files returned as resources when attach_as_resources is set
+	dedupeContent := req.GetBool("dedupe_content", false)
+	contentHashes := make(map[[sha256.Size]byte]string) // First file with each content, for dedupe_content
+	contentDuplicates := 0
+	includeContextStats := req.GetBool("include_context_stats", false)
+	includeFileTimestamps := req.GetBool("include_file_timestamps", false)
+	var fileTokenStats []fileTokenStat // Estimated size of each included file, for include_context_stats
+	if len(filePaths) > 0 || len(inlineFiles) > 0 {
+		s.logger.Info("Processing %d file_paths and %d inline_files for context", len(filePaths), len(inlineFiles))
 		fileContents := "\n\n# Reference Files\n"
 		successfulFiles := 0
 		var fileSizes []int64
+		var tokensBeforeStrip, tokensAfterStrip int
 
-		for _, filePath := range filePaths {
-			// Security check: Ensure file path is within allowed directories
-			if err := ValidateFilePath(filePath, s.config); err != nil {
-				s.logger.Warn("File validation failed for %s: %v", filePath, err)
+		// Validate and read files concurrently; results keep the original order
+		endFileRead := timings.Start("File reading")
+		var onRead func(fileReadResult)
+		if req.GetBool("report_progress", false) && len(filePaths) > 0 {
+			onRead = newFileReadProgress(ctx, req, len(filePaths), s.logger).fileRead
+		}
+		fileResults := readFilesConcurrently(filePaths, fileConfig, s.fileCache,
+			fileReadConcurrency(cfg.MaxFileReadConcurrency, cfg.MaxOpenFiles), onRead)
+		endFileRead()
+		fileResults = append(fileResults, inlineFiles...)
+		for _, result := range fileResults {
+			filePath, contentBytes := result.Path, result.Content
+			if result.Err != nil {
+				s.logger.Warn("Skipping file %s: %v", filePath, result.Err)
+				skippedFiles = append(skippedFiles, skippedFile{Path: filePath, Reason: fileSkipReason(result.Err), Err: result.Err})
 				continue
 			}
-
-			contentBytes, err := readFile(filePath)
+			if dedupeContent {
+				hash := sha256.Sum256(contentBytes)
+				if first, ok := contentHashes[hash]; ok {
+					s.logger.Info("Skipping file %s: same content as %s", filePath, first)
+					contentDuplicates++
+					skippedFiles = append(skippedFiles, skippedFile{Path: filePath, Reason: SkipReasonDuplicate,
+						Err: fmt.Errorf("%w: %s", errDuplicateContent, first)})
+					continue
+				}
+				contentHashes[hash] = filePath
+			}
+			if result.Omitted > 0 {
+				s.logger.Info("Including the head and tail of %s, which is over the maximum file size: %s omitted",
+					filePath, humanReadableSize(result.Omitted))
+			}
+			contentBytes = s.redactFileContent(cfg, filePath, contentBytes)
+			if untrustedBoundary != "" {
+				if phrases := scanForInjection(contentBytes); len(phrases) > 0 {
+					s.logger.Warn("Possible prompt injection in %s: %q", filePath, phrases)
+				}
+			}
+			if result.Lossy {
+				s.logger.Warn("Could not determine the encoding of %s; invalid characters were replaced", filePath)
+			} else if result.Encoding != "" && result.Encoding != EncodingUTF8 {
+				s.logger.Info("Transcoded %s from %s to UTF-8", filePath, result.Encoding)
+			}
+			if result.Notebook {
+				s.logger.Info("Extracted the cells of notebook %s (%s)", filePath, humanReadableSize(int64(len(contentBytes))))
+			} else if result.NotebookErr != nil {
+				s.logger.Warn("Including notebook %s as raw JSON: %v", filePath, result.NotebookErr)
+			}
+			language := result.Language
+			if language == "" {
+				language = fileConfig.languageForFile(filePath, contentBytes)
+			}
+			remaining, limited := cfg.MaxTotalFileSize-sumSizes(fileSizes), cfg.MaxTotalFileSize > 0
+			budget := fmt.Sprintf("total file size budget of %s", humanReadableSize(cfg.MaxTotalFileSize))
+			if reserveOutput > 0 {
+				if room := tokenBudgetBytes(contentBytes, fileTokenBudget-fileTokens); !limited || room < remaining {
+					remaining, limited = room, true
+					budget = fmt.Sprintf("token budget of %d left for files by reserve_output_tokens", fileTokenBudget)
+				}
+			}
+			if limited && int64(len(contentBytes)) > remaining {
+				switch contextCompression {
+				case ContextCompressionTruncate:
+					if remaining <= 0 {
+						s.logger.Warn("Skipping file %s: the %s is used up", filePath, budget)
+						skippedFiles = append(skippedFiles, skippedFile{Path: filePath, Reason: SkipReasonBudget,
+							Err: fmt.Errorf("%w: the %s is used up", errFileBudgetExceeded, budget)})
+						continue
+					}
+					s.logger.Info("Truncating %s to the remaining budget of %s", filePath, humanReadableSize(remaining))
+					contentBytes = truncateFileContent(contentBytes, remaining)
+				case ContextCompressionSummarize:
+					summary, err := s.summarizeFile(ctx, filePath, contentBytes)
+					if err != nil {
+						s.logger.Warn("Skipping file %s: %v", filePath, err)
+						skippedFiles = append(skippedFiles, skippedFile{Path: filePath, Reason: SkipReasonBudget, Err: err})
+						continue
+					}
+					summarized := formatSummarizedFile(summary, len(contentBytes))
+					if int64(len(summarized)) > remaining {
+						s.logger.Warn("Skipping file %s: its summary does not fit in the remaining budget of %s",
+							filePath, humanReadableSize(remaining))
+						skippedFiles = append(skippedFiles, skippedFile{Path: filePath, Reason: SkipReasonBudget,
+							Err: fmt.Errorf("%w: summary does not fit in the remaining %s", errFileBudgetExceeded, humanReadableSize(remaining))})
+						continue
+					}
+					contentBytes, language = summarized, "text"
+				default:
+					s.logger.Warn("Skipping file %s: the %s would be exceeded", filePath, budget)
+					skippedFiles = append(skippedFiles, skippedFile{Path: filePath, Reason: SkipReasonBudget,
+						Err: fmt.Errorf("%w: the %s would be exceeded", errFileBudgetExceeded, budget)})
+					continue
+				}
+			}
+			renderedBytes := contentBytes
+			if stripCodeComments {
+				stripped := stripComments(string(contentBytes), language)
+				tokensBeforeStrip += deepseek.EstimateTokenCount(string(contentBytes)).EstimatedTokens
+				tokensAfterStrip += deepseek.EstimateTokenCount(stripped).EstimatedTokens
+				renderedBytes = []byte(stripped)
+			}
+			if includeCitations {
+				renderedBytes = addLineNumbers(renderedBytes)
+			}
+			promptPath := filePath
+			if anonymizer != nil {
+				promptPath = anonymizer.Label(filePath)
+			}
+			var fileInfo os.FileInfo
+			if includeFileTimestamps {
+				fileInfo = result.Info
+			}
+			rendered, err := renderFileContent(s.fileTemplate, promptPath, language, renderedBytes, fileInfo)
 			if err != nil {
-				s.logger.Error("Failed to read file %s: %v", filePath, err)
+				s.logger.Error("%v", err)
+				skippedFiles = append(skippedFiles, skippedFile{Path: filePath, Reason: SkipReasonUnreadable, Err: err})
 				continue
 			}
+			if readmePaths[filePath] {
+				rendered = readmeLabel + rendered
+			}
+			if untrustedBoundary != "" {
+				rendered = wrapUntrusted(rendered, promptPath, untrustedBoundary)
+			}
+			includedFiles = append(includedFiles, filePath)
+			if attachAsResources {
+				attachedFiles = append(attachedFiles, attachedFile{Path: filePath, Content: result.Content, Inline: result.Inline})
+			}
+			citedFiles = append(citedFiles, citedFile{Path: promptPath, Lines: countLines(renderedBytes)})
 			successfulFiles++
 			fileSizes = append(fileSizes, int64(len(contentBytes)))
-			language := getLanguageFromPath(filePath)
-			fileContents += fmt.Sprintf("\n\n## %s\n\n```%s\n%s\n```",
-				filepath.Base(filePath), language, string(contentBytes))
+			fileContents += rendered
+			if reserveOutput > 0 {
+				fileTokens += estimateTokens(rendered)
+			}
+			if includeContextStats {
+				fileTokenStats = append(fileTokenStats, fileTokenStat{Path: filePath, Tokens: estimateTokens(rendered)})
+			}
 		}
 
+		if contentDuplicates > 0 {
+			s.logger.Info("Removed %d file(s) with duplicate content", contentDuplicates)
+		}
+		if anonymizer != nil && successfulFiles > 0 {
+			s.logger.Info("Anonymized the paths of %d file(s) in the prompt", successfulFiles)
+			s.logger.Debug("Anonymized file paths: %s", anonymizer.Mapping())
+		}
+		if stripCodeComments && tokensBeforeStrip > 0 {
+			s.logger.Info("Stripping comments saved an estimated %d of %d tokens (%.0f%%)",
+				tokensBeforeStrip-tokensAfterStrip, tokensBeforeStrip,
+				100*float64(tokensBeforeStrip-tokensAfterStrip)/float64(tokensBeforeStrip))
+		}
 		if successfulFiles > 0 {
 			s.logger.Info("Including %d file(s) in the query, total size: %s",
 				successfulFiles, humanReadableSize(sumSizes(fileSizes)))
@@ -197,43 +901,143 @@ func (s *DeepseekServer) handleAskDeepseek(ctx context.Context, req mcp.CallTool
 		}
 	}
 
-	chatMessages[1].Content = finalQuery
+	commandTokens := 0
+	if len(commands) > 0 {
+		endCommands := timings.Start("Commands")
+		var outputs []*commandContext
+		for _, args := range commands {
+			s.logger.Info("AUDIT: running command for request context in %s: %s", cfg.CommandWorkDir, strings.Join(args, " "))
+			output, err := runCommand(ctx, args, cfg)
+			if err != nil {
+				endCommands()
+				s.logger.Error("%v", err)
+				return toolError(ErrorCodeCommandFailed, fmt.Sprintf("Failed to run command: %v", err)), nil
+			}
+			if output.TimedOut {
+				s.logger.Warn("Command %s timed out after %v", output.Command, cfg.CommandTimeout)
+			}
+			outputs = append(outputs, output)
+		}
+		endCommands()
+		commandContext := formatCommandContext(outputs, cfg.CommandTimeout)
+		if includeContextStats {
+			commandTokens = estimateTokens(commandContext)
+		}
+		finalQuery += commandContext
+	}
+
+	// Put the focus instruction last so it is not buried above the file context
+	if focus := strings.TrimSpace(req.GetString("focus", "")); focus != "" {
+		s.logger.Info("Applying focus instruction: %s", truncateLogField(focus, cfg.LogMaxFieldChars))
+		finalQuery += "\n\n---\n\n**Focus:** " + focus + "\n\nLimit your answer to this focus and ignore unrelated parts of the context."
+	}
+
+	chatMessages[len(chatMessages)-1].Content = finalQuery
+
+	endEstimate := timings.Start("Token estimate")
+	var promptText strings.Builder
+	for _, message := range chatMessages {
+		promptText.WriteString(message.Content)
+	}
+	promptEstimate := deepseek.EstimateTokenCount(promptText.String())
+	endEstimate()
+	s.logger.Debug("Estimated prompt size: %d tokens", promptEstimate.EstimatedTokens)
+	if reserveOutput > 0 && promptEstimate.EstimatedTokens > contextWindow-reserveOutput {
+		// Command output and the focus instruction are added after the files were fitted
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("The input is estimated at %d tokens, which leaves less than the %d tokens reserved by reserve_output_tokens in the %d-token context window. Shorten the query or the commands, or lower reserve_output_tokens.",
+			promptEstimate.EstimatedTokens, reserveOutput, contextWindow)), nil
+	}
+
+	// Guard against accidentally sending an expensive request
+	if cfg.CostWarningThreshold > 0 {
+		pricing, ok := cfg.ModelPricing[modelName]
+		if !ok {
+			s.logger.Warn("No pricing known for model %s; skipping the cost check", modelName)
+		} else {
+			completionTokens := cfg.projectedCompletionTokens(modelName)
+			projectedCost := pricing.Cost(promptEstimate.EstimatedTokens, completionTokens) * float64(choiceCount*samples)
+			if samples > 1 && consistencyMode == SelfConsistencySynthesize {
+				// The synthesis request carries the prompt and all the sampled answers
+				projectedCost += pricing.Cost(promptEstimate.EstimatedTokens+samples*completionTokens, completionTokens)
+			}
+			s.logger.Debug("Projected cost: $%.4f (threshold $%.4f)", projectedCost, cfg.CostWarningThreshold)
+			if projectedCost > cfg.CostWarningThreshold && !req.GetBool("confirm_cost", false) {
+				s.logger.Warn("Request not sent: projected cost $%.4f exceeds the threshold of $%.4f", projectedCost, cfg.CostWarningThreshold)
+				return mcp.NewToolResultText(formatCostConfirmation(modelName, promptEstimate.EstimatedTokens,
+					completionTokens, projectedCost, cfg.CostWarningThreshold)), nil
+			}
+		}
+	}
 
 	requestPayload := &deepseek.ChatCompletionRequest{
 		Model:       modelName,
 		Messages:    chatMessages,
-		Temperature: s.config.DeepseekTemperature,
-		JSONMode:    jsonMode,
+		Temperature: s.requestTemperature(cfg, modelName),
+		MaxTokens:   cfg.verbosityMaxTokens(verbosity, modelName),
+		Tools:       functionTools,
 	}
+	if softMaxTokens > 0 {
+		requestPayload.MaxTokens = cfg.softMaxTokensLimit(softMaxTokens, modelName)
+	}
+	var stats contextStats
+	if includeContextStats {
+		stats = newContextStats(chatMessages, query, modelName)
+		stats.Files, stats.Commands = fileTokenStats, commandTokens
+		stats.Total, stats.MaxTokens = promptEstimate.EstimatedTokens, requestPayload.MaxTokens
+	}
+	ctx = applyResponseFormat(ctx, requestPayload, responseFormat, jsonSchema)
 
-	s.logger.Debug("Using temperature: %v for model %s. JSON mode: %v", s.config.DeepseekTemperature, modelName, jsonMode)
+	s.logger.Debug("Using temperature: %v for model %s. Response format: %s", requestPayload.Temperature, modelName, responseFormat)
 
-	// Create timeout context for the API call
+	endAPICall := timings.Start("API round-trip")
 	var response *deepseek.ChatCompletionResponse
-	operation := func() error {
-		timeoutCtx, cancel := context.WithTimeout(ctx, s.config.HTTPTimeout)
-		defer cancel()
-		var err error
-		response, err = s.client.CreateChatCompletion(timeoutCtx, requestPayload)
-		return err
+	var consistency *selfConsistencyResult
+	if samples > 1 {
+		consistency, err = s.selfConsistency(ctx, requestPayload, samples, consistencyMode)
+		if err == nil {
+			requestPayload, response = consistency.request, consistency.response
+		}
+	} else {
+		// Only this request asks for several choices; retries and escalations read one
+		primaryCtx := ctx
+		if choiceCount > 1 {
+			primaryCtx = withRequestBodyField(ctx, "n", choiceCount)
+		}
+		response, err = s.createChatCompletion(primaryCtx, requestPayload)
+	}
+	endAPICall()
+	if err != nil && isCancelledByRequest(ctx) {
+		s.logger.Info("Request %s was cancelled", requestID)
+		return toolError(ErrorCodeCancelled, fmt.Sprintf("Request %s was cancelled by request.", requestID)), nil
 	}
-
-	err = RetryWithBackoff(
-		ctx,
-		s.config.MaxRetries,
-		s.config.InitialBackoff,
-		s.config.MaxBackoff,
-		operation,
-		IsRetryableError,
-		s.logger,
-	)
 	if err != nil {
 		s.logger.Error("DeepSeek API error: %v", err)
 		errorMsg := fmt.Sprintf("Error from DeepSeek API: %v", err)
 		if len(filePaths) > 0 {
 			errorMsg += fmt.Sprintf("\n\nThe request included %d file(s).", len(filePaths))
 		}
-		return mcp.NewToolResultError(errorMsg), nil
+		return toolError(apiErrorCode(err), errorMsg), nil
+	}
+
+	// Return the unmodified API response for debugging if requested
+	if rawResponse {
+		rawJSON, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			s.logger.Error("Failed to marshal raw API response: %v", err)
+			return toolError(ErrorCodeInternal, fmt.Sprintf("Failed to marshal raw API response: %v", err)), nil
+		}
+		return mcp.NewToolResultText(string(rawJSON)), nil
+	}
+
+	// Return function calls as structured JSON so clients can run them and continue the loop
+	if len(response.Choices) > 0 && len(response.Choices[0].Message.ToolCalls) > 0 {
+		s.logger.Info("Model requested %d tool call(s)", len(response.Choices[0].Message.ToolCalls))
+		toolCalls, err := formatToolCalls(response.Choices[0])
+		if err != nil {
+			s.logger.Error("Failed to format tool calls: %v", err)
+			return toolError(ErrorCodeInternal, fmt.Sprintf("Failed to format tool calls: %v", err)), nil
+		}
+		return mcp.NewToolResultText(toolCalls), nil
 	}
 
 	var responseContent string
@@ -241,21 +1045,307 @@ func (s *DeepseekServer) handleAskDeepseek(ctx context.Context, req mcp.CallTool
 		responseContent = response.Choices[0].Message.Content
 	}
 	if responseContent == "" {
-		s.logger.Warn("DeepSeek model returned an empty response.")
+		s.emptyResponses.Add(1)
+		if cfg.RetryOnEmpty {
+			if retried := s.retryEmptyResponse(ctx, requestPayload); retried != nil {
+				response = retried
+				responseContent = retried.Choices[0].Message.Content
+			}
+		}
+	}
+	if responseContent == "" {
+		s.logger.Warn("DeepSeek model returned an empty response (%d empty responses so far, %d recovered by retry).",
+			s.emptyResponses.Load(), s.emptyRecoveries.Load())
 		responseContent = "The DeepSeek model returned an empty response. This might indicate that the model couldn't generate an appropriate response for your query. Please try rephrasing your question or providing more context."
 	}
 
-	// If JSON mode is enabled, validate and clean the response
-	if jsonMode {
+	// Retry a refusal or a too short answer with the next models of the escalation chain
+	var answeredBy []string
+	if allowEscalation {
+		escalation := s.escalate(ctx, requestPayload, response)
+		if len(escalation.models) > 1 {
+			requestPayload, response = escalation.request, escalation.response
+			modelName, responseContent = requestPayload.Model, response.Choices[0].Message.Content
+		}
+		answeredBy = escalation.models
+	}
+
+	refusal := cfg.matchRefusal(responseContent)
+	if refusal != "" {
+		s.logger.Warn("Response from %s matched the refusal pattern %q", modelName, refusal)
+	}
+
+	reasoningModel := cfg.isReasoningModel(modelName)
+	var reasoningTokens int
+	if reasoningModel {
+		reasoningTokens = estimateReasoningTokens(response)
+		s.logger.Debug("Model %s spent an estimated %d of %d completion tokens on reasoning",
+			modelName, reasoningTokens, response.Usage.CompletionTokens)
+		if cfg.ReasoningTokenReserve > 0 && float64(reasoningTokens) >= reasoningReserveWarnRatio*float64(cfg.ReasoningTokenReserve) {
+			s.logger.Warn("Reasoning used an estimated %d tokens, close to or above the reserve of %d; cost estimates for similar queries will be low. Consider raising DEEPSEEK_REASONING_TOKEN_RESERVE",
+				reasoningTokens, cfg.ReasoningTokenReserve)
+		}
+	}
+
+	// If a JSON response format is used, validate and clean the response. A truncated response
+	// is validated once it has been continued to the end.
+	if jsonMode && !isTruncated(response) {
 		cleanedJSON, err := extractStrictJSON(responseContent)
 		if err != nil {
-			s.logger.Error("JSON mode validation failed: %v. Original content: %s", err, responseContent)
-			return mcp.NewToolResultError(fmt.Sprintf("JSON mode validation failed: %v. The model returned content that could not be parsed as valid JSON. Original preview: %s", err, truncateString(responseContent, 100))), nil
+			s.logger.Error("JSON mode validation failed: %v. Original content: %s", err, truncateLogField(responseContent, cfg.LogMaxFieldChars))
+			return toolError(ErrorCodeInvalidResponse, fmt.Sprintf("JSON mode validation failed: %v. The model returned content that could not be parsed as valid JSON. Original preview: %s", err, truncateString(responseContent, 100))), nil
+		}
+		if outputFile != "" {
+			return s.writeResponseFile(cfg, outputFile, outputMode, cleanedJSON), nil
+		}
+		result := s.withStructuredContent(cfg, mcp.NewToolResultText(cleanedJSON), "metadata", newResponseMetadata(requestID, requestPayload, response))
+		return attachFileResources(result, attachedFiles), nil
+	}
+
+	// Keep the unprocessed content so that a truncated response can be continued
+	modelContent := responseContent
+
+	if choiceCount > 1 && len(response.Choices) < choiceCount {
+		s.logger.Warn("Requested %d choices but the API returned %d", choiceCount, len(response.Choices))
+	}
+	if returnAllChoices && len(response.Choices) > 1 {
+		responseContent = formatChoices(response.Choices)
+	} else if req.GetBool("trim_chatter", false) {
+		if trimmed := trimChatter(responseContent); trimmed != responseContent {
+			s.logger.Debug("Trimmed conversational preamble or postamble from the response")
+			responseContent = trimmed
+		}
+	}
+
+	if includeCitations && len(citedFiles) > 0 {
+		var flagged int
+		responseContent, flagged = verifyCitations(responseContent, citedFiles)
+		if flagged > 0 {
+			s.logger.Warn("Response contains %d citation(s) that could not be verified", flagged)
+		}
+	}
+	responseContent = anonymizer.Restore(responseContent)
+	if req.GetBool("show_diff", false) {
+		codeDiffs, diffs := formatCodeDiffs(req.GetString("query", ""), responseContent)
+		s.logger.Info("Computed %d diff(s) between the code of the query and the response", diffs)
+		responseContent += codeDiffs
+	}
+
+	if outputFormat == OutputFormatPlain {
+		s.logger.Debug("Converting response to plain text")
+		responseContent = markdownToPlainText(responseContent)
+	}
+
+	if len(answeredBy) > 0 {
+		responseContent += formatEscalationFooter(answeredBy)
+	}
+	if consistency != nil {
+		responseContent += formatSelfConsistencyFooter(consistency, consistencyMode)
+	}
+	if refusal != "" {
+		responseContent += formatRefusalFooter(refusal)
+	}
+	if len(skippedFiles) > 0 {
+		responseContent += formatFileInclusionNote(includedFiles, skippedFiles)
+	}
+	if cfg.NoteIgnoredTemperature && cfg.temperatureIgnored(modelName) {
+		responseContent += formatIgnoredTemperatureNote(cfg.DeepseekTemperature, modelName)
+	}
+	if seed != nil {
+		responseContent += formatSeedFooter(*seed, response.SystemFingerprint)
+	}
+	if includeTiming {
+		responseContent += formatTimingFooter(timings)
+	}
+	if req.GetBool("include_metadata", false) {
+		responseContent += formatMetadataFooter(requestPayload.Model, response.Model, response.SystemFingerprint)
+	}
+	if req.GetBool("include_usage", false) {
+		responseContent += formatUsageFooter(response.Usage, reasoningTokens, reasoningModel)
+	}
+	if includeContextStats {
+		responseContent += formatContextStats(stats)
+	}
+	if reserveOutput > 0 {
+		responseContent += formatOutputReservation(promptEstimate.EstimatedTokens, reserveOutput, contextWindow)
+	}
+	if isTruncated(response) {
+		responseContent += s.storeTruncatedResponse(cfg, &truncatedResponse{
+			request:          *requestPayload,
+			bodyFields:       requestBodyFieldsFromContext(ctx),
+			jsonMode:         jsonMode,
+			content:          modelContent,
+			outputFormat:     outputFormat,
+			maxResponseChars: maxResponseChars,
+			anonymizer:       anonymizer,
+		})
+	}
+
+	if outputFile != "" {
+		return s.writeResponseFile(cfg, outputFile, outputMode, responseContent), nil
+	}
+	if len(attachedFiles) > 0 {
+		s.logger.Info("Attaching %d file(s) to the response as resources", len(attachedFiles))
+	}
+	result := s.withStructuredContent(cfg, s.paginatedResult(responseContent, maxResponseChars), "metadata",
+		newResponseMetadata(requestID, requestPayload, response))
+	return attachFileResources(result, attachedFiles), nil
+}
+
+// emptyRetryNudge is appended to the query when retrying after an empty response
+const emptyRetryNudge = "\n\nPlease provide a complete answer to the request above."
+
+// retryEmptyResponse retries a request once after the model returned an empty response, with a
+// slightly higher temperature and a nudge in the prompt. It returns the new response, or nil if
+// the retry failed or was empty as well.
+func (s *DeepseekServer) retryEmptyResponse(ctx context.Context, requestPayload *deepseek.ChatCompletionRequest) *deepseek.ChatCompletionResponse {
+	s.logger.Warn("Model %s returned an empty response; retrying once", requestPayload.Model)
+
+	retryPayload := *requestPayload
+	retryPayload.Temperature = min(requestPayload.Temperature+0.2, maxTemperature)
+	retryPayload.Messages = append([]deepseek.ChatCompletionMessage{}, requestPayload.Messages...)
+	last := &retryPayload.Messages[len(retryPayload.Messages)-1]
+	last.Content += emptyRetryNudge
+
+	response, err := s.createChatCompletion(ctx, &retryPayload)
+	if err != nil {
+		s.logger.Warn("Retry after empty response failed: %v", err)
+		return nil
+	}
+	if len(response.Choices) == 0 || response.Choices[0].Message.Content == "" {
+		s.logger.Warn("Retry after empty response was empty as well")
+		return nil
+	}
+	recovered := s.emptyRecoveries.Add(1)
+	s.logger.Info("Retry after empty response succeeded (%d of %d empty responses recovered)", recovered, s.emptyResponses.Load())
+	return response
+}
+
+// formatMetadataFooter formats the model that served a response and its system fingerprint,
+// noting the requested model when the API routed the request to a different one
+func formatMetadataFooter(requestedModel, servedModel string, systemFingerprint *string) string {
+	model := servedModel
+	if model == "" {
+		model = requestedModel + " (not returned)"
+	} else if model != requestedModel {
+		model += fmt.Sprintf(" (requested %s)", requestedModel)
+	}
+	fingerprint := "not returned"
+	if systemFingerprint != nil && *systemFingerprint != "" {
+		fingerprint = *systemFingerprint
+	}
+	return fmt.Sprintf("\n\n---\nModel: %s | System fingerprint: %s", model, fingerprint)
+}
+
+// formatChoices formats all completion choices under numbered headers
+func formatChoices(choices []deepseek.Choice) string {
+	var sb strings.Builder
+	for i, choice := range choices {
+		if i > 0 {
+			sb.WriteString("\n\n")
 		}
-		return mcp.NewToolResultText(cleanedJSON), nil
+		content := choice.Message.Content
+		if content == "" {
+			content = "*Empty response*"
+		}
+		sb.WriteString(fmt.Sprintf("## Choice %d\n\n%s", i+1, content))
 	}
+	return sb.String()
+}
 
-	return mcp.NewToolResultText(responseContent), nil
+// formatSeedFooter formats the reproducibility footer appended to responses generated with a seed
+func formatSeedFooter(seed int64, systemFingerprint *string) string {
+	fingerprint := "not returned"
+	if systemFingerprint != nil && *systemFingerprint != "" {
+		fingerprint = *systemFingerprint
+	}
+	return fmt.Sprintf("\n\n---\nSeed: %d | System fingerprint: %s", seed, fingerprint)
+}
+
+// createChatCompletion sends a chat completion request to the DeepSeek API with timeout and retries
+func (s *DeepseekServer) createChatCompletion(ctx context.Context, requestPayload *deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+	cfg := s.configFrom(ctx)
+	if err := s.checkBalance(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.limiter.Acquire(ctx); err != nil {
+		return nil, fmt.Errorf("waiting for a free request slot: %w", err)
+	}
+	defer s.limiter.Release()
+
+	// Enforce the operator's system prompt prefix and suffix on a copy of the request,
+	// so stored requests (e.g. for continuation) are not wrapped twice
+	if cfg.SystemPromptPrefix != "" || cfg.SystemPromptSuffix != "" {
+		wrapped := *requestPayload
+		wrapped.Messages = applySystemPromptPolicy(requestPayload.Messages, cfg.SystemPromptPrefix, cfg.SystemPromptSuffix)
+		requestPayload = &wrapped
+	}
+
+	if len(requestPayload.Messages) > 0 {
+		lastMessage := requestPayload.Messages[len(requestPayload.Messages)-1]
+		s.logger.Debug("Sending %s message to %s: %s", lastMessage.Role, requestPayload.Model,
+			truncateLogField(lastMessage.Content, cfg.LogMaxFieldChars))
+	}
+
+	// Create timeout context for each attempt of the API call
+	var response *deepseek.ChatCompletionResponse
+	operation := func() error {
+		timeoutCtx, cancel := context.WithTimeout(ctx, s.attemptTimeout(ctx, cfg.ResponseTimeout))
+		defer cancel()
+		var err error
+		response, err = s.client.CreateChatCompletion(timeoutCtx, requestPayload)
+		return err
+	}
+
+	err := RetryWithBackoff(
+		ctx,
+		cfg.MaxRetries,
+		cfg.InitialBackoff,
+		cfg.MaxBackoff,
+		cfg.MaxRetryWait,
+		operation,
+		IsRetryableError,
+		s.logger,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(response.Choices) > 0 {
+		s.logger.Debug("Received response from %s: %s", requestPayload.Model,
+			truncateLogField(response.Choices[0].Message.Content, cfg.LogMaxFieldChars))
+	}
+
+	if err := s.usage.Record(requestPayload.Model, response.Usage); err != nil {
+		s.logger.Warn("Failed to record usage: %v", err)
+	}
+	return response, nil
+}
+
+// applySystemPromptPolicy returns a copy of messages with prefix and suffix added around the
+// system prompt. A system message is inserted if the messages have none.
+func applySystemPromptPolicy(messages []deepseek.ChatCompletionMessage, prefix, suffix string) []deepseek.ChatCompletionMessage {
+	wrap := func(prompt string) string {
+		parts := make([]string, 0, 3)
+		for _, part := range []string{prefix, prompt, suffix} {
+			if part = strings.TrimSpace(part); part != "" {
+				parts = append(parts, part)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	}
+
+	result := make([]deepseek.ChatCompletionMessage, 0, len(messages)+1)
+	for i, message := range messages {
+		if message.Role == deepseek.ChatMessageRoleSystem {
+			result = append(result, messages[:i]...)
+			message.Content = wrap(message.Content)
+			result = append(result, message)
+			return append(result, messages[i+1:]...)
+		}
+	}
+	result = append(result, deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleSystem, Content: wrap("")})
+	return append(result, messages...)
 }
 
 // extractStrictJSON attempts to find and extract a valid JSON object or array from a string.
@@ -364,33 +1454,60 @@ func truncateString(s string, maxLen int) string {
 func (s *DeepseekServer) handleDeepseekModels(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	s.logger.Info("Listing available DeepSeek models")
 
-	models := s.GetAvailableDeepseekModels()
-	if len(models) == 0 {
-		s.logger.Warn("No models available, attempting to refresh from API")
-		err := s.discoverModels(ctx)
-		if err != nil {
+	if !s.modelsVerified() {
+		s.logger.Warn("Models have not been discovered, attempting to refresh from API")
+		if err := s.discoverModels(ctx); err != nil {
 			s.logger.Error("Failed to refresh models from API: %v", err)
-		} else {
-			models = s.GetAvailableDeepseekModels()
 		}
 	}
+	models := s.GetAvailableDeepseekModels()
+	verified := s.modelsVerified()
 
 	var formattedContent strings.Builder
 	writeStringf := func(format string, args ...interface{}) {
 		formattedContent.WriteString(fmt.Sprintf(format, args...))
 	}
 
+	cfg := s.config()
+	listings := make([]modelListing, 0, len(models))
 	writeStringf("# Available DeepSeek Models\n\n")
+	if !verified {
+		writeStringf("*The DeepSeek API could not be reached, so these models come from a built-in list and are unverified.*\n\n")
+	}
 	for _, model := range models {
-		writeStringf("## %s\n", model.Name)
+		listing := modelListing{ID: model.ID, Name: model.Name, Description: model.Description,
+			Default: model.ID == cfg.DeepseekModel, Verified: verified, Recommended: cfg.ModelRecommendations[model.ID]}
+		if capabilities, ok := cfg.ModelCapabilities(model.ID); ok {
+			listing.Capabilities = append([]string{}, capabilities...)
+		}
+		listings = append(listings, listing)
+
+		if model.ID == cfg.DeepseekModel {
+			writeStringf("## %s (default)\n", model.Name)
+		} else {
+			writeStringf("## %s\n", model.Name)
+		}
 		writeStringf("- ID: `%s`\n", model.ID)
+		if !verified {
+			writeStringf("- Status: unverified\n")
+		}
+		if recommended := cfg.ModelRecommendations[model.ID]; len(recommended) > 0 {
+			writeStringf("- Recommended: %s\n", strings.Join(recommended, ", "))
+		}
+		if capabilities, ok := cfg.ModelCapabilities(model.ID); !ok {
+			writeStringf("- Capabilities: unknown\n")
+		} else if len(capabilities) == 0 {
+			writeStringf("- Capabilities: none\n")
+		} else {
+			writeStringf("- Capabilities: %s\n", strings.Join(capabilities, ", "))
+		}
 		writeStringf("- Description: %s\n\n", model.Description)
 	}
 	writeStringf("## Usage\n")
 	writeStringf("You can specify a model ID in the `model` parameter when using the `deepseek_ask` tool:\n")
 	writeStringf("```json\n{\n  \"query\": \"Your question here\",\n  \"model\": \"deepseek-chat\"\n}\n```\n")
 
-	return mcp.NewToolResultText(formattedContent.String()), nil
+	return s.withStructuredContent(cfg, mcp.NewToolResultText(formattedContent.String()), "models", listings), nil
 }
 
 // handleDeepseekBalance handles requests to the deepseek_balance tool
@@ -399,7 +1516,7 @@ func (s *DeepseekServer) handleDeepseekBalance(ctx context.Context, req mcp.Call
 
 	var balanceResponse *deepseek.BalanceResponse
 	operation := func() error {
-		timeoutCtx, cancel := context.WithTimeout(ctx, s.config.HTTPTimeout)
+		timeoutCtx, cancel := context.WithTimeout(ctx, s.attemptTimeout(ctx, s.config().HTTPTimeout))
 		defer cancel()
 		var err error
 		balanceResponse, err = s.client.GetBalance(timeoutCtx)
@@ -408,17 +1525,19 @@ func (s *DeepseekServer) handleDeepseekBalance(ctx context.Context, req mcp.Call
 
 	err := RetryWithBackoff(
 		ctx,
-		s.config.MaxRetries,
-		s.config.InitialBackoff,
-		s.config.MaxBackoff,
+		s.config().MaxRetries,
+		s.config().InitialBackoff,
+		s.config().MaxBackoff,
+		s.config().MaxRetryWait,
 		operation,
 		IsRetryableError,
 		s.logger,
 	)
 	if err != nil {
 		s.logger.Error("Failed to get balance from DeepSeek API: %v", err)
-		return mcp.NewToolResultError(fmt.Sprintf("Error checking balance: %v", err)), nil
+		return toolError(apiErrorCode(err), fmt.Sprintf("Error checking balance: %v", err)), nil
 	}
+	s.refreshBalanceGuard(balanceResponse)
 
 	var formattedContent strings.Builder
 	formattedContent.WriteString("# DeepSeek API Balance Information\n\n")
@@ -446,6 +1565,16 @@ func (s *DeepseekServer) handleDeepseekBalance(ctx context.Context, req mcp.Call
 func (s *DeepseekServer) handleTokenEstimate(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	s.logger.Info("Estimating token count")
 
+	format := req.GetString("format", EstimateFormatMarkdown)
+	if !isValidEstimateFormat(format) {
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid format: %s. Supported values are %q and %q",
+			format, EstimateFormatMarkdown, EstimateFormatJSON)), nil
+	}
+
+	if dirPath := req.GetString("dir_path", ""); dirPath != "" {
+		return s.handleDirectoryTokenEstimate(dirPath, format, req)
+	}
+
 	text := req.GetString("text", "")
 	filePath := req.GetString("file_path", "")
 
@@ -455,15 +1584,20 @@ func (s *DeepseekServer) handleTokenEstimate(ctx context.Context, req mcp.CallTo
 	var contentToEstimate string
 
 	if filePath != "" {
-		if err := ValidateFilePath(filePath, s.config); err != nil {
+		filePath, err := expandPath(filePath)
+		if err != nil {
+			s.logger.Warn("Invalid file_path: %v", err)
+			return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid file_path: %v", err)), nil
+		}
+		if err := ValidateFilePath(filePath, s.config()); err != nil {
 			s.logger.Warn("File validation failed for %s: %v", filePath, err)
-			return mcp.NewToolResultError(fmt.Sprintf("File validation failed: %v", err)), nil
+			return toolError(fileErrorCode(err), fmt.Sprintf("File validation failed: %v", err)), nil
 		}
 
 		fileContentBytes, err := readFile(filePath)
 		if err != nil {
 			s.logger.Error("Failed to read file for token estimation %s: %v", filePath, err)
-			return mcp.NewToolResultError(fmt.Sprintf("Error reading file: %v", err)), nil
+			return toolError(ErrorCodeFileError, fmt.Sprintf("Error reading file: %v", err)), nil
 		}
 		contentToEstimate = string(fileContentBytes)
 		sourceType = "file"
@@ -479,8 +1613,24 @@ func (s *DeepseekServer) handleTokenEstimate(ctx context.Context, req mcp.CallTo
 		estimatedTokens = estimate.EstimatedTokens
 		s.logger.Info("Estimated %d tokens for provided text", estimatedTokens)
 	} else {
-		s.logger.Warn("handleTokenEstimate called without 'text' or 'file_path'")
-		return mcp.NewToolResultError("Please provide either 'text' or 'file_path' parameter"), nil
+		s.logger.Warn("handleTokenEstimate called without 'text', 'file_path' or 'dir_path'")
+		return toolError(ErrorCodeInvalidArgument, "Please provide either 'text', 'file_path' or 'dir_path' parameter"), nil
+	}
+
+	contentSize := len(contentToEstimate)
+	charCount := len([]rune(contentToEstimate))
+	if format == EstimateFormatJSON {
+		result := tokenEstimateJSON{
+			EstimatedTokens: estimatedTokens,
+			ByteSize:        contentSize,
+			CharCount:       charCount,
+			SourceType:      sourceType,
+			Source:          sourceName,
+		}
+		if charCount > 0 {
+			result.TokensPerChar = float64(estimatedTokens) / float64(charCount)
+		}
+		return jsonToolResult(result), nil
 	}
 
 	var formattedResponse strings.Builder
@@ -488,8 +1638,6 @@ func (s *DeepseekServer) handleTokenEstimate(ctx context.Context, req mcp.CallTo
 	formattedResponse.WriteString(fmt.Sprintf("**Source Type:** %s\n", sourceType))
 	formattedResponse.WriteString(fmt.Sprintf("**Source:** %s\n", sourceName))
 	formattedResponse.WriteString(fmt.Sprintf("**Estimated Token Count:** %d\n\n", estimatedTokens))
-	contentSize := len(contentToEstimate)
-	charCount := len([]rune(contentToEstimate))
 	formattedResponse.WriteString("## Content Statistics\n\n")
 	formattedResponse.WriteString(fmt.Sprintf("- **Byte Size:** %s (%d bytes)\n", humanReadableSize(int64(contentSize)), contentSize))
 	formattedResponse.WriteString(fmt.Sprintf("- **Character Count:** %d characters\n", charCount))
@@ -529,8 +1677,10 @@ func getAvailabilityStatus(isAvailable bool) string {
 // Helper function to read a file
 // This is declared at package level so it can be used by other files in the package
 func readFile(path string) ([]byte, error) {
-	// Use os.ReadFile to read the file from the file system
+	// Use os.ReadFile to read the file from the file system, which closes it before returning
+	release := acquireOpenFile()
 	content, err := os.ReadFile(path)
+	release()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read file %s: %w", path, err)
 	}
@@ -552,6 +1702,8 @@ func getMimeTypeFromPath(path string) string {
 		return "text/javascript"
 	case ".json":
 		return "application/json"
+	case ".ipynb":
+		return "application/x-ipynb+json"
 	case ".xml":
 		return "application/xml"
 	case ".yaml", ".yml":
@@ -598,7 +1750,13 @@ func getMimeTypeFromPath(path string) string {
 		return "text/plain"
 	case ".md":
 		return "text/markdown"
+	case ".sql":
+		return "text/x-sql"
 	default:
+		// Well-known files like Dockerfile or Makefile are plain text despite lacking an extension
+		if getLanguageFromFilename(path) != "" {
+			return "text/plain"
+		}
 		return "application/octet-stream"
 	}
 }
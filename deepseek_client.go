@@ -1,7 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/cohesion-org/deepseek-go"
 )
@@ -19,14 +28,145 @@ type realDeepseekClient struct {
 	client *deepseek.Client
 }
 
+// Errors of the adapter methods carry the Retry-After header of the response, if any,
+// so that retries can wait as long as the API asked.
+
 func (r *realDeepseekClient) CreateChatCompletion(ctx context.Context, req *deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
-	return r.client.CreateChatCompletion(ctx, req)
+	ctx, hint := withRetryAfterHint(ctx)
+	response, err := r.client.CreateChatCompletion(ctx, req)
+	return response, hint.wrap(err)
 }
 
 func (r *realDeepseekClient) ListAllModels(ctx context.Context) (*deepseek.APIModels, error) {
-	return deepseek.ListAllModels(r.client, ctx)
+	ctx, hint := withRetryAfterHint(ctx)
+	models, err := deepseek.ListAllModels(r.client, ctx)
+	return models, hint.wrap(err)
 }
 
 func (r *realDeepseekClient) GetBalance(ctx context.Context) (*deepseek.BalanceResponse, error) {
-	return deepseek.GetBalance(r.client, ctx)
+	ctx, hint := withRetryAfterHint(ctx)
+	balance, err := deepseek.GetBalance(r.client, ctx)
+	return balance, hint.wrap(err)
+}
+
+// maxUserIDLength is the maximum accepted length of the end-user identifier
+const maxUserIDLength = 256
+
+// requestBodyFieldsKey is the context key for extra fields added to API request bodies
+const requestBodyFieldsKey contextKey = "requestBodyFields"
+
+// withRequestBodyField returns a context that adds a top-level field to the JSON body of
+// API requests made with it. This is used for request parameters that the deepseek-go
+// library does not expose, such as "user" and "seed".
+func withRequestBodyField(ctx context.Context, key string, value any) context.Context {
+	fields := map[string]any{key: value}
+	for k, v := range requestBodyFieldsFromContext(ctx) {
+		if k != key {
+			fields[k] = v
+		}
+	}
+	return context.WithValue(ctx, requestBodyFieldsKey, fields)
+}
+
+// withoutRequestBodyFields returns a context that adds no fields to request bodies, for
+// auxiliary requests that must not inherit the fields of the request they serve
+func withoutRequestBodyFields(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestBodyFieldsKey, map[string]any(nil))
+}
+
+// withRequestBodyFields returns a context that adds exactly fields to request bodies, for
+// requests that repeat an earlier one, such as continuations of a truncated response
+func withRequestBodyFields(ctx context.Context, fields map[string]any) context.Context {
+	return context.WithValue(ctx, requestBodyFieldsKey, fields)
+}
+
+// requestBodyFieldsFromContext returns the extra request body fields stored in the context
+func requestBodyFieldsFromContext(ctx context.Context) map[string]any {
+	fields, _ := ctx.Value(requestBodyFieldsKey).(map[string]any)
+	return fields
+}
+
+// sanitizeUserID strips control characters from a user identifier and checks its length
+func sanitizeUserID(user string) (string, error) {
+	user = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, user))
+	if len(user) > maxUserIDLength {
+		return "", fmt.Errorf("user identifier is too long: %d characters (maximum %d)", len(user), maxUserIDLength)
+	}
+	return user, nil
+}
+
+// apiHTTPClient wraps an HTTP client to add request fields that the deepseek-go
+// library does not expose, such as the "user" field for abuse monitoring, and headers
+// sent with every request, such as the billing organization and project.
+type apiHTTPClient struct {
+	client  *http.Client
+	headers http.Header
+}
+
+// newAPIHTTPClient creates the HTTP client for API requests. The connect timeout bounds dialing
+// and the TLS handshake only; the time allowed for the response is set by the request context,
+// so slow models are not cut off while unreachable hosts still fail fast.
+func newAPIHTTPClient(connectTimeout time.Duration, headers http.Header) *apiHTTPClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+	return &apiHTTPClient{client: &http.Client{Transport: transport}, headers: headers}
+}
+
+// Do sends the HTTP request after applying request-scoped additions from its context, and
+// records the Retry-After header of the response for the caller
+func (c *apiHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	for name, values := range c.headers {
+		req.Header[name] = values
+	}
+	if fields := requestBodyFieldsFromContext(req.Context()); len(fields) > 0 && req.Method == http.MethodPost && req.Body != nil {
+		if err := setJSONBodyFields(req, fields); err != nil {
+			return nil, err
+		}
+	}
+	resp, err := c.client.Do(req)
+	if hint, ok := req.Context().Value(retryAfterHintKey).(*retryAfterHint); ok && err == nil {
+		hint.record(resp, time.Now())
+	}
+	return resp, err
+}
+
+// setJSONBodyFields adds top-level fields to the JSON body of a request
+func setJSONBodyFields(req *http.Request, extra map[string]any) error {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+	_ = req.Body.Close()
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return fmt.Errorf("failed to decode request body: %w", err)
+	}
+	for key, value := range extra {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s field: %w", key, err)
+		}
+		fields[key] = encoded
+	}
+
+	body, err = json.Marshal(fields)
+	if err != nil {
+		return fmt.Errorf("failed to encode request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return nil
 }
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSanitizeUserID(t *testing.T) {
+	tests := []struct {
+		name    string
+		user    string
+		want    string
+		wantErr bool
+	}{
+		{"plain", "user-42", "user-42", false},
+		{"trimmed", "  alice \n", "alice", false},
+		{"control characters removed", "al\x00ice\x1b", "alice", false},
+		{"empty", "", "", false},
+		{"longest accepted", strings.Repeat("a", maxUserIDLength), strings.Repeat("a", maxUserIDLength), false},
+		{"too long", strings.Repeat("a", maxUserIDLength+1), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sanitizeUserID(tt.user)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("sanitizeUserID(%q) = %q, %v, want %q, wantErr %v", tt.user, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequestBodyFieldsContext(t *testing.T) {
+	ctx := withRequestBodyField(context.Background(), "user", "alice")
+	ctx = withRequestBodyField(ctx, "seed", 1)
+	overridden := withRequestBodyField(ctx, "user", "bob")
+
+	if got := requestBodyFieldsFromContext(ctx); got["user"] != "alice" || got["seed"] != 1 {
+		t.Errorf("fields = %v, want user alice and seed 1", got)
+	}
+	if got := requestBodyFieldsFromContext(overridden); got["user"] != "bob" || got["seed"] != 1 {
+		t.Errorf("overridden fields = %v, want user bob and seed 1", got)
+	}
+	if got := requestBodyFieldsFromContext(withoutRequestBodyFields(ctx)); len(got) != 0 {
+		t.Errorf("fields without body fields = %v, want none", got)
+	}
+}
+
+func TestAPIHTTPClientAddsBodyFieldsAndHeaders(t *testing.T) {
+	var gotBody map[string]any
+	var gotHeader http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Clone()
+		body, _ := io.ReadAll(r.Body)
+		gotBody = nil
+		_ = json.Unmarshal(body, &gotBody)
+	}))
+	defer server.Close()
+
+	headers := http.Header{"Openai-Organization": {"org-1"}}
+	client := newAPIHTTPClient(time.Second, headers)
+	tests := []struct {
+		name   string
+		method string
+		ctx    context.Context
+		want   map[string]any
+	}{
+		{"no fields", http.MethodPost, context.Background(), map[string]any{"model": "m"}},
+		{"user", http.MethodPost, withRequestBodyField(context.Background(), "user", "alice"), map[string]any{"model": "m", "user": "alice"}},
+		{"field replaces the body's", http.MethodPost, withRequestBodyField(context.Background(), "model", "other"), map[string]any{"model": "other"}},
+		{"get requests are unchanged", http.MethodGet, withRequestBodyField(context.Background(), "user", "alice"), map[string]any{"model": "m"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(tt.ctx, tt.method, server.URL, strings.NewReader(`{"model":"m"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if len(gotBody) != len(tt.want) {
+				t.Fatalf("body = %v, want %v", gotBody, tt.want)
+			}
+			for key, value := range tt.want {
+				if gotBody[key] != value {
+					t.Errorf("body[%s] = %v, want %v", key, gotBody[key], value)
+				}
+			}
+			if gotHeader.Get("OpenAI-Organization") != "org-1" {
+				t.Errorf("organization header = %q, want org-1", gotHeader.Get("OpenAI-Organization"))
+			}
+		})
+	}
+}
+
+func TestAskUser(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		args     map[string]any
+		wantUser any
+		wantErr  string
+	}{
+		{"none", nil, map[string]any{"query": "q"}, nil, ""},
+		{"default user", map[string]string{"DEEPSEEK_DEFAULT_USER": "team-a"}, map[string]any{"query": "q"}, "team-a", ""},
+		{"request user wins", map[string]string{"DEEPSEEK_DEFAULT_USER": "team-a"}, map[string]any{"query": "q", "user": "alice"}, "alice", ""},
+		{"too long", nil, map[string]any{"query": "q", "user": strings.Repeat("a", maxUserIDLength+1)}, nil, "Invalid user parameter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{}
+			s := newTestServer(t, client, tt.env)
+			text := resultText(callTool(t, s.handleAskDeepseek, tt.args))
+			if tt.wantErr != "" {
+				if !strings.Contains(text, tt.wantErr) || client.calls() != 0 {
+					t.Errorf("result = %q after %d requests, want %q before any request", text, client.calls(), tt.wantErr)
+				}
+				return
+			}
+			if got := client.bodyFields[0]["user"]; got != tt.wantUser {
+				t.Errorf("user body field = %v, want %v", got, tt.wantUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestAskRetryOnEmpty(t *testing.T) {
+	tests := []struct {
+		name            string
+		retryOnEmpty    string
+		temperature     string
+		retry           *deepseek.ChatCompletionResponse
+		retryErr        error
+		wantCalls       int
+		wantText        string
+		wantTemperature float64
+		wantRecoveries  int64
+	}{
+		{
+			name:         "disabled",
+			retryOnEmpty: "false",
+			wantCalls:    1,
+			wantText:     "returned an empty response",
+		},
+		{
+			name:            "recovered",
+			retryOnEmpty:    "true",
+			temperature:     "0.5",
+			retry:           textResponse("second try"),
+			wantCalls:       2,
+			wantText:        "second try",
+			wantTemperature: 0.7,
+			wantRecoveries:  1,
+		},
+		{
+			name:            "temperature capped",
+			retryOnEmpty:    "true",
+			temperature:     "1.9",
+			retry:           textResponse("second try"),
+			wantCalls:       2,
+			wantText:        "second try",
+			wantTemperature: 2.0,
+			wantRecoveries:  1,
+		},
+		{
+			name:            "retry empty as well",
+			retryOnEmpty:    "true",
+			temperature:     "0.5",
+			retry:           textResponse(""),
+			wantCalls:       2,
+			wantText:        "returned an empty response",
+			wantTemperature: 0.7,
+		},
+		{
+			name:            "retry failed",
+			retryOnEmpty:    "true",
+			temperature:     "0.5",
+			retryErr:        errors.New("connection reset"),
+			wantCalls:       2,
+			wantText:        "returned an empty response",
+			wantTemperature: 0.7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+				calls++
+				if calls == 1 {
+					return textResponse(""), nil
+				}
+				return tt.retry, tt.retryErr
+			}}
+			env := map[string]string{"DEEPSEEK_RETRY_ON_EMPTY": tt.retryOnEmpty, "DEEPSEEK_MAX_RETRIES": "0"}
+			if tt.temperature != "" {
+				env["DEEPSEEK_TEMPERATURE"] = tt.temperature
+			}
+			s := newTestServer(t, client, env)
+			text := resultText(callTool(t, s.handleAskDeepseek, map[string]any{"query": "explain"}))
+			if !strings.Contains(text, tt.wantText) {
+				t.Errorf("result %q does not contain %q", text, tt.wantText)
+			}
+			if client.calls() != tt.wantCalls {
+				t.Fatalf("got %d requests, want %d", client.calls(), tt.wantCalls)
+			}
+			if got := s.emptyRecoveries.Load(); got != tt.wantRecoveries {
+				t.Errorf("emptyRecoveries = %d, want %d", got, tt.wantRecoveries)
+			}
+			if s.emptyResponses.Load() != 1 {
+				t.Errorf("emptyResponses = %d, want 1", s.emptyResponses.Load())
+			}
+			if tt.wantCalls < 2 {
+				return
+			}
+
+			first, retried := client.requests[0], client.requests[1]
+			if got := float64(retried.Temperature); math.Abs(got-tt.wantTemperature) > 1e-6 {
+				t.Errorf("retry temperature = %v, want %v", got, tt.wantTemperature)
+			}
+			firstQuery := first.Messages[len(first.Messages)-1].Content
+			retriedQuery := retried.Messages[len(retried.Messages)-1].Content
+			if retriedQuery != firstQuery+emptyRetryNudge {
+				t.Errorf("retry query = %q, want the original query plus the nudge", retriedQuery)
+			}
+			if strings.HasSuffix(firstQuery, emptyRetryNudge) {
+				t.Error("the nudge leaked into the original request")
+			}
+		})
+	}
+}
+
+func TestAskDedupeContent(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.go": "package same", "b.go": "package same", "c.go": "package other"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths := []any{filepath.Join(dir, "a.go"), filepath.Join(dir, "b.go"), filepath.Join(dir, "c.go")}
+	tests := []struct {
+		name       string
+		dedupe     bool
+		wantCopies int
+		wantNote   string
+	}{
+		{"off", false, 2, ""},
+		{"on", true, 1, "Skipped (duplicate content): 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{}
+			s := newTestServer(t, client, map[string]string{"DEEPSEEK_ALLOWED_FILE_PATHS": dir})
+			text := resultText(callTool(t, s.handleAskDeepseek, map[string]any{
+				"query": "review", "file_paths": paths, "dedupe_content": tt.dedupe,
+			}))
+			messages := client.requests[0].Messages
+			prompt := messages[len(messages)-1].Content
+			if got := strings.Count(prompt, "package same"); got != tt.wantCopies {
+				t.Errorf("prompt contains the duplicated content %d times, want %d", got, tt.wantCopies)
+			}
+			if tt.wantNote != "" && !strings.Contains(text, tt.wantNote) {
+				t.Errorf("result %q does not contain %q", text, tt.wantNote)
+			}
+			if tt.wantNote == "" && strings.Contains(text, "Skipped") {
+				t.Errorf("result %q reports skipped files", text)
+			}
+		})
+	}
+}
+
+func TestFormatMetadataFooter(t *testing.T) {
+	fingerprint, empty := "fp_3a5770e1b4", ""
+	tests := []struct {
+		name        string
+		served      string
+		fingerprint *string
+		want        string
+	}{
+		{"same model", "deepseek-chat", &fingerprint, "\n\n---\nModel: deepseek-chat | System fingerprint: fp_3a5770e1b4"},
+		{"routed model", "deepseek-chat-v3", &fingerprint, "\n\n---\nModel: deepseek-chat-v3 (requested deepseek-chat) | System fingerprint: fp_3a5770e1b4"},
+		{"model not returned", "", nil, "\n\n---\nModel: deepseek-chat (not returned) | System fingerprint: not returned"},
+		{"empty fingerprint", "deepseek-chat", &empty, "\n\n---\nModel: deepseek-chat | System fingerprint: not returned"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatMetadataFooter("deepseek-chat", tt.served, tt.fingerprint); got != tt.want {
+				t.Errorf("formatMetadataFooter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAskIncludeMetadata(t *testing.T) {
+	tests := []struct {
+		name       string
+		include    bool
+		wantFooter bool
+	}{
+		{"omitted", false, false},
+		{"requested", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{}
+			s := newTestServer(t, client, nil)
+			text := resultText(callTool(t, s.handleAskDeepseek, map[string]any{
+				"query": "q", "model": "deepseek-chat", "include_metadata": tt.include,
+			}))
+			if got := strings.Contains(text, "Model: deepseek-chat | System fingerprint: not returned"); got != tt.wantFooter {
+				t.Errorf("result %q contains the metadata footer = %v, want %v", text, got, tt.wantFooter)
+			}
+		})
+	}
+}
+
+func TestAskKeepsRequestConfig(t *testing.T) {
+	client := &mockDeepseekClient{}
+	s := newTestServer(t, client, map[string]string{"DEEPSEEK_SYSTEM_PROMPT_PREFIX": "FIRST", "DEEPSEEK_RETRY_ON_EMPTY": "true"})
+	reloaded := newTestConfig(t, map[string]string{"DEEPSEEK_SYSTEM_PROMPT_PREFIX": "SECOND", "DEEPSEEK_RETRY_ON_EMPTY": "false"})
+
+	// The configuration is reloaded while the request is being handled
+	client.respond = func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+		s.configPtr.Store(reloaded)
+		return textResponse(""), nil
+	}
+	callTool(t, s.handleAskDeepseek, map[string]any{"query": "q"})
+
+	if client.calls() != 2 {
+		t.Fatalf("got %d requests, want the request and its retry on empty", client.calls())
+	}
+	for i, request := range client.requests {
+		if systemPrompt := request.Messages[0].Content; !strings.HasPrefix(systemPrompt, "FIRST") {
+			t.Errorf("request %d system prompt = %q, want the prefix of the configuration the request started with", i, systemPrompt)
+		}
+	}
+	if got := s.configFrom(context.Background()); got != reloaded {
+		t.Errorf("configFrom() outside a request did not return the reloaded configuration")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cohesion-org/deepseek-go"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// Limits for directory token estimation
+const (
+	defaultEstimateDepth = 5
+	maxEstimateFiles     = 1000
+	estimateTopFiles     = 20
+)
+
+// fileTokenEstimate is the estimated token count of one file in a directory
+type fileTokenEstimate struct {
+	Path   string
+	Size   int64
+	Tokens int
+}
+
+// directoryTokenEstimate is the result of estimating the tokens of a directory
+type directoryTokenEstimate struct {
+	Files     []fileTokenEstimate
+	Skipped   int  // Files that were too large or unreadable
+	Truncated bool // The file cap was reached before the walk finished
+}
+
+// estimateDirectoryTokens estimates the tokens of the files under root, descending at most
+// maxDepth levels and scanning at most maxFiles files. Files ignored by the root .gitignore,
+// of disallowed types or larger than the maximum file size are not counted.
+func estimateDirectoryTokens(root string, maxDepth, maxFiles int, cfg *Config) (*directoryTokenEstimate, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("directory not found or not accessible: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("path is not a directory: %s", root)
+	}
+
+	result := &directoryTokenEstimate{}
+	ignore := loadGitignore(root)
+
+	var walk func(dir, rel string, depth int)
+	walk = func(dir, rel string, depth int) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
+			if result.Truncated {
+				return
+			}
+
+			name := entry.Name()
+			entryRel := path.Join(rel, name)
+			entryPath := filepath.Join(dir, name)
+			if name == ".git" || ignore.Ignored(entryRel, entry.IsDir()) {
+				continue
+			}
+			if entry.IsDir() {
+				if depth < maxDepth {
+					walk(entryPath, entryRel, depth+1)
+				}
+				continue
+			}
+			if checkFileType(entryPath, cfg) != nil {
+				continue
+			}
+			if err := ValidateFilePath(entryPath, cfg); err != nil {
+				result.Skipped++
+				continue
+			}
+
+			if len(result.Files) >= maxFiles {
+				result.Truncated = true
+				return
+			}
+			content, err := readFile(entryPath)
+			if err != nil {
+				result.Skipped++
+				continue
+			}
+			result.Files = append(result.Files, fileTokenEstimate{
+				Path:   entryRel,
+				Size:   int64(len(content)),
+				Tokens: deepseek.EstimateTokenCount(string(content)).EstimatedTokens,
+			})
+		}
+	}
+	walk(root, "", 1)
+
+	sort.SliceStable(result.Files, func(i, j int) bool {
+		return result.Files[i].Tokens > result.Files[j].Tokens
+	})
+	return result, nil
+}
+
+// handleDirectoryTokenEstimate handles deepseek_token_estimate requests with a dir_path
+func (s *DeepseekServer) handleDirectoryTokenEstimate(dirPath, format string, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if s.config().DisableFileAccess {
+		return toolError(ErrorCodeFileDenied, "File access is disabled on this server."), nil
+	}
+	dirPath, err := expandPath(dirPath)
+	if err != nil {
+		s.logger.Warn("Invalid dir_path: %v", err)
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid dir_path: %v", err)), nil
+	}
+	if len(s.config().AllowedFilePaths) > 0 && !isPathAllowed(dirPath, s.config().AllowedFilePaths) {
+		s.logger.Warn("Directory token estimate requested outside the allowed file paths: %s", dirPath)
+		return toolError(ErrorCodeFileDenied, fmt.Sprintf("Directory is not allowed: %s. Allowed roots are: %s",
+			dirPath, strings.Join(s.config().AllowedFilePaths, ", "))), nil
+	}
+	maxDepth := req.GetInt("max_depth", defaultEstimateDepth)
+	if maxDepth < 1 || maxDepth > maxTreeDepth {
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid max_depth: %d. It must be between 1 and %d", maxDepth, maxTreeDepth)), nil
+	}
+
+	estimate, err := estimateDirectoryTokens(dirPath, maxDepth, maxEstimateFiles, s.config())
+	if err != nil {
+		s.logger.Error("Failed to estimate tokens for directory %s: %v", dirPath, err)
+		return toolError(ErrorCodeFileError, fmt.Sprintf("Failed to estimate tokens: %v", err)), nil
+	}
+
+	var totalTokens int
+	var totalSize int64
+	for _, file := range estimate.Files {
+		totalTokens += file.Tokens
+		totalSize += file.Size
+	}
+	s.logger.Info("Estimated %d tokens for %d file(s) in %s", totalTokens, len(estimate.Files), dirPath)
+
+	if format == EstimateFormatJSON {
+		result := directoryTokenEstimateJSON{
+			EstimatedTokens: totalTokens,
+			ByteSize:        totalSize,
+			SourceType:      "directory",
+			Source:          dirPath,
+			FileCount:       len(estimate.Files),
+			Skipped:         estimate.Skipped,
+			Truncated:       estimate.Truncated,
+			Files:           make([]fileTokenEstimateJSON, 0, len(estimate.Files)),
+		}
+		for _, file := range estimate.Files {
+			result.Files = append(result.Files, fileTokenEstimateJSON{Path: file.Path, ByteSize: file.Size, EstimatedTokens: file.Tokens})
+		}
+		return jsonToolResult(result), nil
+	}
+
+	var formattedResponse strings.Builder
+	formattedResponse.WriteString("# Directory Token Estimation Results\n\n")
+	formattedResponse.WriteString(fmt.Sprintf("**Directory:** %s\n", dirPath))
+	formattedResponse.WriteString(fmt.Sprintf("**Files Counted:** %d\n", len(estimate.Files)))
+	formattedResponse.WriteString(fmt.Sprintf("**Total Size:** %s\n", humanReadableSize(totalSize)))
+	formattedResponse.WriteString(fmt.Sprintf("**Estimated Token Count:** %d\n\n", totalTokens))
+
+	if len(estimate.Files) > 0 {
+		shown := estimate.Files
+		if len(shown) > estimateTopFiles {
+			shown = shown[:estimateTopFiles]
+		}
+		formattedResponse.WriteString(fmt.Sprintf("## Largest Files (top %d)\n\n", len(shown)))
+		formattedResponse.WriteString("| File | Size | Estimated Tokens | Share |\n")
+		formattedResponse.WriteString("|------|------|------------------|-------|\n")
+		for _, file := range shown {
+			share := 0.0
+			if totalTokens > 0 {
+				share = 100 * float64(file.Tokens) / float64(totalTokens)
+			}
+			formattedResponse.WriteString(fmt.Sprintf("| %s | %s | %d | %.1f%% |\n",
+				file.Path, humanReadableSize(file.Size), file.Tokens, share))
+		}
+	}
+
+	formattedResponse.WriteString("\n## Note\n\n")
+	if estimate.Truncated {
+		formattedResponse.WriteString(fmt.Sprintf("*The scan stopped after %d files, so the totals are incomplete.* ", maxEstimateFiles))
+	}
+	if estimate.Skipped > 0 {
+		formattedResponse.WriteString(fmt.Sprintf("*%d file(s) were skipped because they were too large or unreadable.* ", estimate.Skipped))
+	}
+	formattedResponse.WriteString(fmt.Sprintf("*Files ignored by .gitignore, of disallowed types or deeper than %d level(s) are not counted. ", maxDepth))
+	formattedResponse.WriteString("Token counts are estimates and may not exactly match the API.*\n")
+
+	return mcp.NewToolResultText(formattedResponse.String()), nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// formatLanguageOverrides formats overrides in the form accepted by parseLanguageOverrides
+func formatLanguageOverrides(overrides map[string]string) string {
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	entries := make([]string, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, key+"="+overrides[key])
+	}
+	return strings.Join(entries, ",")
+}
+
+// parseLanguageOverrides parses language overrides in the form ".tpl=html,Jenkinsfile.ci=groovy".
+// A key starting with a dot is a file extension; any other key is a whole file name.
+func parseLanguageOverrides(value string) (map[string]string, error) {
+	overrides := make(map[string]string)
+	for _, entry := range splitList(value) {
+		key, language, ok := strings.Cut(entry, "=")
+		key, language = strings.TrimSpace(key), strings.TrimSpace(language)
+		if !ok || key == "" || language == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected extension=language or filename=language", entry)
+		}
+		overrides[strings.ToLower(key)] = language
+	}
+	return overrides, nil
+}
+
+// languageForFile returns the language identifier used to tag an included file. Configured
+// overrides come first, then a language property from the nearest .editorconfig, then the
+// built-in detection by extension, file name and shebang.
+func (c *Config) languageForFile(path string, content []byte) string {
+	if c != nil {
+		base := strings.ToLower(filepath.Base(path))
+		if language, ok := c.LanguageOverrides[base]; ok {
+			return language
+		}
+		if language, ok := c.LanguageOverrides[strings.ToLower(filepath.Ext(path))]; ok {
+			return language
+		}
+		if c.UseEditorConfig {
+			if language := editorConfigLanguage(path); language != "" {
+				return language
+			}
+		}
+	}
+	return detectLanguage(path, content)
+}
+
+// editorConfigSection is a section of an .editorconfig file with a language property
+type editorConfigSection struct {
+	pattern  *regexp.Regexp
+	language string
+}
+
+// editorConfigFile is a parsed .editorconfig file, keeping only what language detection needs
+type editorConfigFile struct {
+	modTime  time.Time
+	root     bool
+	sections []editorConfigSection
+}
+
+// editorConfigCache caches parsed .editorconfig files by directory. A file is re-parsed when
+// its modification time changes.
+var editorConfigCache sync.Map // Directory -> *editorConfigFile
+
+// editorConfigLanguage returns the language property that the nearest .editorconfig files set
+// for path, e.g. "language = html" in a [*.tpl] section. Files closer to path take precedence,
+// and the search stops at a file with root = true.
+func editorConfigLanguage(path string) string {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	for dir := filepath.Dir(absPath); ; {
+		if config := loadEditorConfig(dir); config != nil {
+			rel, err := filepath.Rel(dir, absPath)
+			if err == nil {
+				rel = filepath.ToSlash(rel)
+				// Later sections override earlier ones within a file
+				for i := len(config.sections) - 1; i >= 0; i-- {
+					if config.sections[i].pattern.MatchString(rel) {
+						return config.sections[i].language
+					}
+				}
+			}
+			if config.root {
+				return ""
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadEditorConfig returns the parsed .editorconfig of a directory, or nil if it has none
+func loadEditorConfig(dir string) *editorConfigFile {
+	path := filepath.Join(dir, ".editorconfig")
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+	if cached, ok := editorConfigCache.Load(dir); ok {
+		if config := cached.(*editorConfigFile); config.modTime.Equal(info.ModTime()) {
+			return config
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	config := parseEditorConfig(data)
+	config.modTime = info.ModTime()
+	editorConfigCache.Store(dir, config)
+	return config
+}
+
+// parseEditorConfig parses the root flag and the sections setting a language property.
+// Malformed lines and patterns are ignored, as editors do.
+func parseEditorConfig(data []byte) *editorConfigFile {
+	config := &editorConfigFile{}
+	var pattern *regexp.Regexp
+	inPreamble := true
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inPreamble = false
+			pattern, _ = editorConfigPattern(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch {
+		case inPreamble && key == "root":
+			config.root = strings.EqualFold(value, "true")
+		case !inPreamble && key == "language" && pattern != nil && value != "":
+			config.sections = append(config.sections, editorConfigSection{pattern: pattern, language: value})
+		}
+	}
+	return config
+}
+
+// editorConfigPattern converts an .editorconfig section glob into a regular expression
+// matched against slash-separated paths relative to the .editorconfig file. Globs without
+// a slash match the file name in any directory.
+func editorConfigPattern(glob string) (*regexp.Regexp, error) {
+	var sb strings.Builder
+	if strings.Contains(glob, "/") {
+		sb.WriteString("^")
+		glob = strings.TrimPrefix(glob, "/")
+	} else {
+		sb.WriteString("^(?:.*/)?")
+	}
+	for i := 0; i < len(glob); i++ {
+		switch ch := glob[i]; ch {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '{':
+			end := strings.IndexByte(glob[i:], '}')
+			if end < 0 {
+				sb.WriteString(`\{`)
+				continue
+			}
+			alternatives := strings.Split(glob[i+1:i+end], ",")
+			for j, alternative := range alternatives {
+				alternatives[j] = regexp.QuoteMeta(alternative)
+			}
+			sb.WriteString("(?:" + strings.Join(alternatives, "|") + ")")
+			i += end
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
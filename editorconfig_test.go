@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseLanguageOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"empty", "", map[string]string{}, false},
+		{"extension and file name", ".tpl=html, Jenkinsfile.ci = groovy", map[string]string{".tpl": "html", "jenkinsfile.ci": "groovy"}, false},
+		{"keys are lower-cased", ".TPL=html", map[string]string{".tpl": "html"}, false},
+		{"later entries win", ".tpl=html,.tpl=jinja", map[string]string{".tpl": "jinja"}, false},
+		{"missing language", ".tpl=", nil, true},
+		{"missing key", "=html", nil, true},
+		{"no separator", ".tpl", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLanguageOverrides(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLanguageOverrides(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLanguageOverrides(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+
+	overrides := map[string]string{"jenkinsfile.ci": "groovy", ".tpl": "html"}
+	if got := formatLanguageOverrides(overrides); got != ".tpl=html,jenkinsfile.ci=groovy" {
+		t.Errorf("formatLanguageOverrides() = %q", got)
+	}
+}
+
+func TestEditorConfigPattern(t *testing.T) {
+	tests := []struct {
+		glob, path string
+		want       bool
+	}{
+		{"*.tpl", "page.tpl", true},
+		{"*.tpl", "views/page.tpl", true},
+		{"*.tpl", "page.tpl.bak", false},
+		{"views/*.tpl", "views/page.tpl", true},
+		{"views/*.tpl", "views/sub/page.tpl", false},
+		{"/views/**.tpl", "views/sub/page.tpl", true},
+		{"*.{tpl,tmpl}", "a.tmpl", true},
+		{"*.{tpl,tmpl}", "a.txt", false},
+		{"file?.txt", "file1.txt", true},
+		{"file?.txt", "file10.txt", false},
+		{"[!a]*.txt", "b.txt", true},
+		{"[!a]*.txt", "a.txt", false},
+		{"a.b", "aXb", false},
+	}
+	for _, tt := range tests {
+		pattern, err := editorConfigPattern(tt.glob)
+		if err != nil {
+			t.Fatalf("editorConfigPattern(%q) error = %v", tt.glob, err)
+		}
+		if got := pattern.MatchString(tt.path); got != tt.want {
+			t.Errorf("editorConfigPattern(%q) matches %q = %v, want %v", tt.glob, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestParseEditorConfig(t *testing.T) {
+	config := parseEditorConfig([]byte(`# comment
+root = true
+
+[*.tpl]
+indent_style = tab
+language = html
+
+[*.go]
+; no language here
+indent_size = 4
+
+[*.tmpl]
+language =
+`))
+	if !config.root {
+		t.Error("root = false, want true")
+	}
+	if len(config.sections) != 1 || config.sections[0].language != "html" {
+		t.Errorf("sections = %+v, want only the html section", config.sections)
+	}
+}
+
+func TestLanguageForFile(t *testing.T) {
+	// top/.editorconfig maps .tpl and .tmpl; top/project/.editorconfig is a root overriding .tpl
+	top := t.TempDir()
+	project := filepath.Join(top, "project")
+	if err := os.MkdirAll(filepath.Join(project, "views"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		filepath.Join(top, ".editorconfig"):     "[*.tpl]\nlanguage = jinja\n[*.tmpl]\nlanguage = gotmpl\n",
+		filepath.Join(project, ".editorconfig"): "root = true\n[*.tpl]\nlanguage = html\n[views/special.tpl]\nlanguage = svelte\n",
+		filepath.Join(top, "outer.tmpl"):        "",
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		path string
+		want string
+	}{
+		{"editorconfig is off by default", nil, filepath.Join(project, "views", "page.tpl"), detectLanguage("page.tpl", nil)},
+		{"nearest file", map[string]string{"DEEPSEEK_USE_EDITORCONFIG": "true"}, filepath.Join(project, "views", "page.tpl"), "html"},
+		{"later section wins", map[string]string{"DEEPSEEK_USE_EDITORCONFIG": "true"}, filepath.Join(project, "views", "special.tpl"), "svelte"},
+		{"root stops the search", map[string]string{"DEEPSEEK_USE_EDITORCONFIG": "true"}, filepath.Join(project, "page.tmpl"), detectLanguage("page.tmpl", nil)},
+		{"parent file", map[string]string{"DEEPSEEK_USE_EDITORCONFIG": "true"}, filepath.Join(top, "outer.tmpl"), "gotmpl"},
+		{"override first", map[string]string{"DEEPSEEK_USE_EDITORCONFIG": "true", "DEEPSEEK_LANGUAGE_OVERRIDES": ".tpl=twig"}, filepath.Join(project, "page.tpl"), "twig"},
+		{"file name override", map[string]string{"DEEPSEEK_LANGUAGE_OVERRIDES": "special.tpl=svelte,.tpl=twig"}, filepath.Join(project, "views", "special.tpl"), "svelte"},
+		{"built-in detection", map[string]string{"DEEPSEEK_USE_EDITORCONFIG": "true"}, filepath.Join(project, "main.go"), "go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, tt.env)
+			if got := cfg.languageForFile(tt.path, nil); got != tt.want {
+				t.Errorf("languageForFile(%s) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
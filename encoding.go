@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encodings detected by transcodeToUTF8
+const (
+	EncodingUTF8        = "utf-8"
+	EncodingUTF8BOM     = "utf-8-bom"
+	EncodingUTF16LE     = "utf-16le"
+	EncodingUTF16BE     = "utf-16be"
+	EncodingWindows1252 = "windows-1252"
+	EncodingLatin1      = "iso-8859-1"
+	EncodingUnknown     = "unknown"
+)
+
+// windows1252High maps bytes 0x80-0x9F of Windows-1252 to runes.
+// Bytes undefined in Windows-1252 map to utf8.RuneError.
+var windows1252High = [32]rune{
+	'€', utf8.RuneError, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', utf8.RuneError, 'Ž', utf8.RuneError,
+	utf8.RuneError, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', utf8.RuneError, 'ž', 'Ÿ',
+}
+
+// transcodeToUTF8 detects the encoding of file content and converts it to UTF-8.
+// It handles UTF-8 and UTF-16 byte order marks and falls back to Windows-1252 or
+// ISO-8859-1 for content that is not valid UTF-8. When the encoding cannot be determined,
+// invalid sequences are replaced with U+FFFD and lossy is true.
+func transcodeToUTF8(content []byte) (out []byte, encoding string, lossy bool) {
+	switch {
+	case bytes.HasPrefix(content, []byte{0xEF, 0xBB, 0xBF}):
+		content = content[3:]
+		if utf8.Valid(content) {
+			return content, EncodingUTF8BOM, false
+		}
+		return []byte(strings.ToValidUTF8(string(content), string(utf8.RuneError))), EncodingUTF8BOM, true
+	case bytes.HasPrefix(content, []byte{0xFF, 0xFE}):
+		return decodeUTF16(content[2:], false), EncodingUTF16LE, len(content)%2 != 0
+	case bytes.HasPrefix(content, []byte{0xFE, 0xFF}):
+		return decodeUTF16(content[2:], true), EncodingUTF16BE, len(content)%2 != 0
+	case utf8.Valid(content):
+		return content, EncodingUTF8, false
+	}
+
+	// NUL bytes suggest UTF-16 without a byte order mark or binary data, which single-byte
+	// decoding would turn into garbage
+	if bytes.IndexByte(content, 0) >= 0 {
+		return []byte(strings.ToValidUTF8(string(content), string(utf8.RuneError))), EncodingUnknown, true
+	}
+
+	usesWindows1252 := false
+	for _, b := range content {
+		if b >= 0x80 && b <= 0x9F {
+			if windows1252High[b-0x80] == utf8.RuneError {
+				// Undefined in Windows-1252 and a control character in ISO-8859-1
+				return []byte(strings.ToValidUTF8(string(content), string(utf8.RuneError))), EncodingUnknown, true
+			}
+			usesWindows1252 = true
+		}
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(content) + len(content)/2)
+	for _, b := range content {
+		if b >= 0x80 && b <= 0x9F {
+			sb.WriteRune(windows1252High[b-0x80])
+		} else {
+			// ISO-8859-1 bytes map directly to the first 256 code points
+			sb.WriteRune(rune(b))
+		}
+	}
+	if usesWindows1252 {
+		return []byte(sb.String()), EncodingWindows1252, false
+	}
+	return []byte(sb.String()), EncodingLatin1, false
+}
+
+// decodeUTF16 decodes UTF-16 content without a byte order mark into UTF-8.
+// A trailing odd byte is dropped.
+func decodeUTF16(content []byte, bigEndian bool) []byte {
+	units := make([]uint16, len(content)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(content[2*i])<<8 | uint16(content[2*i+1])
+		} else {
+			units[i] = uint16(content[2*i+1])<<8 | uint16(content[2*i])
+		}
+	}
+	return []byte(string(utf16.Decode(units)))
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// encodingFixtures hold "café €" or similar text in each encoding transcodeToUTF8 detects
+var encodingFixtures = []struct {
+	name         string
+	content      []byte
+	want         string
+	wantEncoding string
+	wantLossy    bool
+}{
+	{"utf-8", []byte("café €"), "café €", EncodingUTF8, false},
+	{"utf-8 with bom", append([]byte{0xEF, 0xBB, 0xBF}, "café €"...), "café €", EncodingUTF8BOM, false},
+	{"utf-8 with bom and invalid bytes", []byte{0xEF, 0xBB, 0xBF, 'a', 0xFF}, "a�", EncodingUTF8BOM, true},
+	{"utf-16le", []byte{0xFF, 0xFE, 'c', 0, 'a', 0, 'f', 0, 0xE9, 0, ' ', 0, 0xAC, 0x20}, "café €", EncodingUTF16LE, false},
+	{"utf-16be", []byte{0xFE, 0xFF, 0, 'c', 0, 'a', 0, 'f', 0, 0xE9, 0, ' ', 0x20, 0xAC}, "café €", EncodingUTF16BE, false},
+	{"utf-16le odd length", []byte{0xFF, 0xFE, 'a', 0, 'b'}, "a", EncodingUTF16LE, true},
+	{"windows-1252", []byte{'c', 'a', 'f', 0xE9, ' ', 0x80, ' ', 0x93, 'q', 0x94}, "café € “q”", EncodingWindows1252, false},
+	{"iso-8859-1", []byte{'c', 'a', 'f', 0xE9, ' ', 0xA3}, "café £", EncodingLatin1, false},
+	{"undefined windows-1252 byte", []byte{'a', 0x81, 0xE9}, "a�", EncodingUnknown, true},
+	{"utf-16 without bom", []byte{'a', 0, 0xE9, 0}, "a\x00�\x00", EncodingUnknown, true},
+}
+
+func TestTranscodeToUTF8(t *testing.T) {
+	for _, tt := range encodingFixtures {
+		t.Run(tt.name, func(t *testing.T) {
+			out, encoding, lossy := transcodeToUTF8(tt.content)
+			if string(out) != tt.want || encoding != tt.wantEncoding || lossy != tt.wantLossy {
+				t.Errorf("transcodeToUTF8() = %q, %s, %v, want %q, %s, %v",
+					out, encoding, lossy, tt.want, tt.wantEncoding, tt.wantLossy)
+			}
+		})
+	}
+}
+
+func TestReadValidatedFileTranscoding(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name      string
+		transcode string
+	}{
+		{"default", ""},
+		{"disabled", "false"},
+		{"enabled", "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]string{
+				"DEEPSEEK_ALLOWED_FILE_PATHS": dir,
+				"DEEPSEEK_TRANSCODE_FILES":    tt.transcode,
+			})
+			for i, fixture := range encodingFixtures {
+				path := filepath.Join(dir, fmt.Sprintf("fixture%d.txt", i))
+				if err := os.WriteFile(path, fixture.content, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				result := readValidatedFile(path, cfg, nil)
+				if result.Err != nil {
+					t.Fatalf("%s: readValidatedFile() error = %v", fixture.name, result.Err)
+				}
+				want, wantEncoding := string(fixture.content), ""
+				if tt.transcode == "true" {
+					want, wantEncoding = fixture.want, fixture.wantEncoding
+				}
+				if string(result.Content) != want || result.Encoding != wantEncoding {
+					t.Errorf("%s: content = %q (encoding %q), want %q (encoding %q)",
+						fixture.name, result.Content, result.Encoding, want, wantEncoding)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// ErrorCode is a machine-readable code identifying why a tool call failed
+type ErrorCode string
+
+// Error codes returned in the structured content of failed tool calls
+const (
+	ErrorCodeInvalidArgument ErrorCode = "ERR_INVALID_ARGUMENT"     // A parameter is missing or malformed
+	ErrorCodeInvalidModel    ErrorCode = "ERR_INVALID_MODEL"        // The requested model is not available
+	ErrorCodeUnsupported     ErrorCode = "ERR_UNSUPPORTED"          // The model lacks a capability the request needs
+	ErrorCodeNotFound        ErrorCode = "ERR_NOT_FOUND"            // A token or request ID is unknown or has expired
+	ErrorCodeFileDenied      ErrorCode = "ERR_FILE_DENIED"          // File access is disabled or the path is outside the allowed roots
+	ErrorCodeFileError       ErrorCode = "ERR_FILE_ERROR"           // A file could not be read, parsed or written
+	ErrorCodeCommandDenied   ErrorCode = "ERR_COMMAND_DENIED"       // Command context is disabled or the command is not allowed
+	ErrorCodeCommandFailed   ErrorCode = "ERR_COMMAND_FAILED"       // An allowed command could not be started
+	ErrorCodeRateLimited     ErrorCode = "ERR_RATE_LIMITED"         // The API rejected the request with a rate limit
+	ErrorCodeOverloaded      ErrorCode = "ERR_OVERLOADED"           // The request queue is full or the wait for a request slot was exceeded
+	ErrorCodeTimeout         ErrorCode = "ERR_TIMEOUT"              // The API did not answer in time
+	ErrorCodeUnavailable     ErrorCode = "ERR_UNAVAILABLE"          // The circuit breaker is open after repeated API failures
+	ErrorCodeBalance         ErrorCode = "ERR_INSUFFICIENT_BALANCE" // The account balance is below DEEPSEEK_MIN_BALANCE
+	ErrorCodeAPI             ErrorCode = "ERR_API"                  // Any other API failure
+	ErrorCodeCancelled       ErrorCode = "ERR_CANCELLED"            // The request was cancelled with deepseek_cancel
+	ErrorCodeInvalidResponse ErrorCode = "ERR_INVALID_RESPONSE"     // The model's response is empty or unusable
+	ErrorCodeConfig          ErrorCode = "ERR_CONFIG"               // The configuration could not be loaded
+	ErrorCodeInternal        ErrorCode = "ERR_INTERNAL"             // An unexpected server-side failure
+)
+
+// toolErrorDetail is the structured content of a failed tool call
+type toolErrorDetail struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// toolError returns a failed tool result carrying the message as text, for people, and the
+// code with the message as structured content, for clients that branch on the failure
+func toolError(code ErrorCode, message string) *mcp.CallToolResult {
+	result := mcp.NewToolResultError(message)
+	result.StructuredContent = map[string]any{"error": toolErrorDetail{Code: code, Message: message}}
+	return result
+}
+
+// apiErrorCode classifies an error from a DeepSeek API call
+func apiErrorCode(err error) ErrorCode {
+	switch {
+	case errors.Is(err, errServiceUnavailable):
+		return ErrorCodeUnavailable
+	case errors.Is(err, errInsufficientBalance):
+		return ErrorCodeBalance
+	case errors.Is(err, errQueueFull) || errors.Is(err, errQueueWaitMax):
+		return ErrorCodeOverloaded
+	case IsRateLimitError(err):
+		return ErrorCodeRateLimited
+	case errors.Is(err, context.DeadlineExceeded) || IsTimeoutError(err):
+		return ErrorCodeTimeout
+	default:
+		return ErrorCodeAPI
+	}
+}
+
+// fileErrorCode classifies an error from validating or reading a file
+func fileErrorCode(err error) ErrorCode {
+	if errors.Is(err, errFileAccessDisabled) || errors.Is(err, errPathNotAllowed) {
+		return ErrorCodeFileDenied
+	}
+	return ErrorCodeFileError
+}
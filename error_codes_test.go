@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestAPIErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{"circuit breaker open", fmt.Errorf("call failed: %w", errServiceUnavailable), ErrorCodeUnavailable},
+		{"balance too low", fmt.Errorf("call failed: %w", errInsufficientBalance), ErrorCodeBalance},
+		{"queue full", fmt.Errorf("waiting for a free request slot: %w", errQueueFull), ErrorCodeOverloaded},
+		{"queue wait exceeded", fmt.Errorf("waiting for a free request slot: %w", errQueueWaitMax), ErrorCodeOverloaded},
+		{"rate limited", &deepseek.APIError{StatusCode: 429, Message: "slow down"}, ErrorCodeRateLimited},
+		{"deadline", fmt.Errorf("call failed: %w", context.DeadlineExceeded), ErrorCodeTimeout},
+		{"timeout message", errors.New("net/http: request timed out"), ErrorCodeTimeout},
+		{"other API error", &deepseek.APIError{StatusCode: 500, Message: "boom"}, ErrorCodeAPI},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := apiErrorCode(tt.err); got != tt.want {
+				t.Errorf("apiErrorCode(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{"file access disabled", fmt.Errorf("%w: a.go", errFileAccessDisabled), ErrorCodeFileDenied},
+		{"outside the allowed roots", fmt.Errorf("%w: /etc/passwd", errPathNotAllowed), ErrorCodeFileDenied},
+		{"unreadable", errors.New("file not found or not accessible"), ErrorCodeFileError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fileErrorCode(tt.err); got != tt.want {
+				t.Errorf("fileErrorCode(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAskErrorCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    map[string]string
+		args   map[string]any
+		apiErr error
+		want   ErrorCode
+	}{
+		{name: "missing query", args: map[string]any{}, want: ErrorCodeInvalidArgument},
+		{name: "invalid mode", args: map[string]any{"query": "q", "mode": "poetry"}, want: ErrorCodeInvalidArgument},
+		{name: "unknown continuation token", args: map[string]any{"continuation_token": "nope"}, want: ErrorCodeNotFound},
+		{name: "file access disabled", env: map[string]string{"DEEPSEEK_DISABLE_FILE_ACCESS": "true"},
+			args: map[string]any{"query": "q", "file_paths": []any{"main.go"}}, want: ErrorCodeFileDenied},
+		{name: "wildcard file type override", env: map[string]string{"DEEPSEEK_ALLOW_PER_REQUEST_TYPE_OVERRIDE": "true"},
+			args: map[string]any{"query": "q", "file_paths": []any{"main.go"}, "allow_file_types": []any{"text/*"}}, want: ErrorCodeInvalidArgument},
+		{name: "malformed file type override", env: map[string]string{"DEEPSEEK_ALLOW_PER_REQUEST_TYPE_OVERRIDE": "true"},
+			args: map[string]any{"query": "q", "file_paths": []any{"main.go"}, "allow_file_types": []any{"not a type"}}, want: ErrorCodeInvalidArgument},
+		{name: "command context disabled", args: map[string]any{"query": "q", "commands": []any{"go vet"}}, want: ErrorCodeCommandDenied},
+		{name: "API failure", args: map[string]any{"query": "q"}, apiErr: &deepseek.APIError{StatusCode: 500, Message: "boom"}, want: ErrorCodeAPI},
+		{name: "rate limited", args: map[string]any{"query": "q"}, apiErr: &deepseek.APIError{StatusCode: 429, Message: "slow down"}, want: ErrorCodeRateLimited},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"DEEPSEEK_MAX_RETRIES": "0"}
+			for key, value := range tt.env {
+				env[key] = value
+			}
+			client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+				if tt.apiErr != nil {
+					return nil, tt.apiErr
+				}
+				return textResponse("ok"), nil
+			}}
+			s := newTestServer(t, client, env)
+			result := callTool(t, s.handleAskDeepseek, tt.args)
+			if !result.IsError {
+				t.Fatalf("result = %q, want an error", resultText(result))
+			}
+			if got := errorCodeOf(t, result); got != tt.want {
+				t.Errorf("error code = %s, want %s (%s)", got, tt.want, resultText(result))
+			}
+		})
+	}
+}
+
+// errorCodeOf returns the error code in the structured content of a failed tool result
+func errorCodeOf(t *testing.T, result *mcp.CallToolResult) ErrorCode {
+	t.Helper()
+	structured, ok := result.StructuredContent.(map[string]any)
+	if !ok {
+		t.Fatalf("structured content = %#v, want an error object", result.StructuredContent)
+	}
+	detail, ok := structured["error"].(toolErrorDetail)
+	if !ok {
+		t.Fatalf("structured error = %#v, want a toolErrorDetail", structured["error"])
+	}
+	if detail.Message != resultText(result) {
+		t.Errorf("structured message = %q, want the text of the result %q", detail.Message, resultText(result))
+	}
+	return detail.Code
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// escalationReason returns why a response should be retried with a larger model,
+// or an empty string if it is acceptable
+func (c *Config) escalationReason(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if pattern := c.matchRefusal(trimmed); pattern != "" {
+		return fmt.Sprintf("refusal matching %q", pattern)
+	}
+	if chars := len([]rune(trimmed)); chars < c.EscalationMinChars {
+		return fmt.Sprintf("response of %d characters is below %d", chars, c.EscalationMinChars)
+	}
+	return ""
+}
+
+// responseCost returns the USD cost of a response from its reported usage,
+// and false if the model has no known pricing
+func (c *Config) responseCost(modelID string, usage deepseek.Usage) (float64, bool) {
+	pricing, ok := c.ModelPricing[modelID]
+	if !ok {
+		return 0, false
+	}
+	return pricing.Cost(usage.PromptTokens, usage.CompletionTokens), true
+}
+
+// escalationChain returns the models to escalate to after currentModel: the models following it
+// in EscalationModels, or the whole chain if it is not part of it
+func (c *Config) escalationChain(currentModel string) []string {
+	for i, model := range c.EscalationModels {
+		if model == currentModel {
+			return c.EscalationModels[i+1:]
+		}
+	}
+	return c.EscalationModels
+}
+
+// escalationResult is the outcome of escalating a request through the escalation chain
+type escalationResult struct {
+	request  *deepseek.ChatCompletionRequest
+	response *deepseek.ChatCompletionResponse
+	models   []string // Models that answered, in order; the last one gave the final response
+	cost     float64  // Total cost of all attempts, when pricing is known
+}
+
+// escalate retries a request with the next models of the escalation chain while the response
+// looks like a refusal or is too short. Escalation stops at DEEPSEEK_MAX_ESCALATIONS attempts
+// or before an attempt whose projected cost would take the total above
+// DEEPSEEK_ESCALATION_MAX_COST. A failed attempt keeps the previous response.
+func (s *DeepseekServer) escalate(ctx context.Context, request *deepseek.ChatCompletionRequest, response *deepseek.ChatCompletionResponse) *escalationResult {
+	cfg := s.configFrom(ctx)
+	result := &escalationResult{request: request, response: response, models: []string{request.Model}}
+	costKnown := true
+	if cost, ok := cfg.responseCost(request.Model, response.Usage); ok {
+		result.cost = cost
+	} else {
+		costKnown = false
+	}
+
+	attempts := 0
+	for _, model := range cfg.escalationChain(request.Model) {
+		var content string
+		if len(result.response.Choices) > 0 {
+			content = result.response.Choices[0].Message.Content
+		}
+		reason := cfg.escalationReason(content)
+		if reason == "" {
+			break
+		}
+		if attempts >= cfg.MaxEscalations {
+			s.logger.Warn("Not escalating further: the limit of %d escalation(s) is reached", cfg.MaxEscalations)
+			break
+		}
+		if model == result.request.Model {
+			continue
+		}
+		if err := s.ValidateModelID(model); err != nil {
+			s.logger.Warn("Skipping escalation model %s: %v", model, err)
+			continue
+		}
+		if cfg.EscalationMaxCost > 0 {
+			pricing, ok := cfg.ModelPricing[model]
+			if !costKnown || !ok {
+				s.logger.Warn("Not escalating to %s: its cost cannot be bounded without pricing", model)
+				break
+			}
+			projected := pricing.Cost(result.response.Usage.PromptTokens, cfg.projectedCompletionTokens(model))
+			if result.cost+projected > cfg.EscalationMaxCost {
+				s.logger.Warn("Not escalating to %s: projected total cost $%.4f exceeds the limit of $%.4f",
+					model, result.cost+projected, cfg.EscalationMaxCost)
+				break
+			}
+		}
+
+		s.logger.Info("Escalating from %s to %s (%s)", result.request.Model, model, reason)
+		attempts++
+		escalated := *result.request
+		escalated.Model = model
+		if cfg.isReasoningModel(model) {
+			// Output caps such as the concise verbosity limit would cut off hidden reasoning
+			escalated.MaxTokens = 0
+		}
+		next, err := s.createChatCompletion(ctx, &escalated)
+		if err != nil {
+			s.logger.Warn("Escalation to %s failed: %v", model, err)
+			break
+		}
+		if cost, ok := cfg.responseCost(model, next.Usage); ok {
+			result.cost += cost
+		} else {
+			costKnown = false
+		}
+		if len(next.Choices) == 0 || next.Choices[0].Message.Content == "" {
+			s.logger.Warn("Escalation to %s returned an empty response; keeping the previous one", model)
+			continue
+		}
+		result.request, result.response = &escalated, next
+		result.models = append(result.models, model)
+	}
+	if attempts > 0 && costKnown {
+		s.logger.Info("Escalation made %d extra request(s); total cost $%.4f", attempts, result.cost)
+	}
+	return result
+}
+
+// formatEscalationFooter formats the note naming the model that answered an escalated request
+func formatEscalationFooter(models []string) string {
+	answered := models[len(models)-1]
+	if len(models) == 1 {
+		return fmt.Sprintf("\n\n---\n*Answered by %s.*", answered)
+	}
+	return fmt.Sprintf("\n\n---\n*Answered by %s after escalation from %s.*",
+		answered, strings.Join(models[:len(models)-1], " → "))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// Output formats of the deepseek_token_estimate tool
+const (
+	EstimateFormatMarkdown = "markdown"
+	EstimateFormatJSON     = "json"
+)
+
+// isValidEstimateFormat reports whether format is a supported token estimate format
+func isValidEstimateFormat(format string) bool {
+	return format == EstimateFormatMarkdown || format == EstimateFormatJSON
+}
+
+// tokenEstimateJSON is the machine-readable token estimate of a text or file
+type tokenEstimateJSON struct {
+	EstimatedTokens int     `json:"estimated_tokens"`
+	ByteSize        int     `json:"byte_size"`
+	CharCount       int     `json:"char_count"`
+	TokensPerChar   float64 `json:"tokens_per_char"`
+	SourceType      string  `json:"source_type"`
+	Source          string  `json:"source"`
+}
+
+// fileTokenEstimateJSON is the machine-readable token estimate of one file in a directory
+type fileTokenEstimateJSON struct {
+	Path            string `json:"path"`
+	ByteSize        int64  `json:"byte_size"`
+	EstimatedTokens int    `json:"estimated_tokens"`
+}
+
+// directoryTokenEstimateJSON is the machine-readable token estimate of a directory
+type directoryTokenEstimateJSON struct {
+	EstimatedTokens int                     `json:"estimated_tokens"`
+	ByteSize        int64                   `json:"byte_size"`
+	SourceType      string                  `json:"source_type"`
+	Source          string                  `json:"source"`
+	FileCount       int                     `json:"file_count"`
+	Skipped         int                     `json:"skipped"`
+	Truncated       bool                    `json:"truncated"`
+	Files           []fileTokenEstimateJSON `json:"files"` // Largest first
+}
+
+// jsonToolResult returns value encoded as indented JSON, or an error result if encoding fails
+func jsonToolResult(value any) *mcp.CallToolResult {
+	encoded, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return toolError(ErrorCodeInternal, fmt.Sprintf("Failed to encode result as JSON: %v", err))
+	}
+	return mcp.NewToolResultText(string(encoded))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestTokenEstimateJSON(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("héllo world"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tokens := deepseek.EstimateTokenCount("héllo world").EstimatedTokens
+	tests := []struct {
+		name string
+		args map[string]any
+		want tokenEstimateJSON
+	}{
+		{
+			name: "text",
+			args: map[string]any{"text": "héllo world", "format": "json"},
+			want: tokenEstimateJSON{EstimatedTokens: tokens, ByteSize: 12, CharCount: 11,
+				TokensPerChar: float64(tokens) / 11, SourceType: "text", Source: "provided input"},
+		},
+		{
+			name: "file",
+			args: map[string]any{"file_path": file, "format": "json"},
+			want: tokenEstimateJSON{EstimatedTokens: tokens, ByteSize: 12, CharCount: 11,
+				TokensPerChar: float64(tokens) / 11, SourceType: "file", Source: "notes.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, &mockDeepseekClient{}, map[string]string{"DEEPSEEK_ALLOWED_FILE_PATHS": dir})
+			result := callTool(t, s.handleTokenEstimate, tt.args)
+			var got tokenEstimateJSON
+			if err := json.Unmarshal([]byte(resultText(result)), &got); err != nil {
+				t.Fatalf("result %q is not JSON: %v", resultText(result), err)
+			}
+			if got != tt.want {
+				t.Errorf("estimate = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDirectoryTokenEstimateJSON(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"small.go": "package a", "large.go": strings.Repeat("package b\n", 50)} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := newTestServer(t, &mockDeepseekClient{}, map[string]string{"DEEPSEEK_ALLOWED_FILE_PATHS": dir})
+	result := callTool(t, s.handleTokenEstimate, map[string]any{"dir_path": dir, "format": "json"})
+
+	var got directoryTokenEstimateJSON
+	if err := json.Unmarshal([]byte(resultText(result)), &got); err != nil {
+		t.Fatalf("result %q is not JSON: %v", resultText(result), err)
+	}
+	if got.SourceType != "directory" || got.Source != dir || got.FileCount != 2 || got.ByteSize != 509 {
+		t.Errorf("estimate = %+v, want 2 files of 509 bytes in %s", got, dir)
+	}
+	if len(got.Files) != 2 || got.Files[0].Path != "large.go" || got.Files[0].ByteSize != 500 {
+		t.Fatalf("files = %+v, want large.go first", got.Files)
+	}
+	if sum := got.Files[0].EstimatedTokens + got.Files[1].EstimatedTokens; sum != got.EstimatedTokens {
+		t.Errorf("file tokens sum to %d, total is %d", sum, got.EstimatedTokens)
+	}
+}
+
+func TestTokenEstimateInvalidFormat(t *testing.T) {
+	s := newTestServer(t, &mockDeepseekClient{}, nil)
+	result := callTool(t, s.handleTokenEstimate, map[string]any{"text": "x", "format": "xml"})
+	if !result.IsError || !strings.Contains(resultText(result), "Invalid format: xml") {
+		t.Errorf("result = %q, want an invalid format error", resultText(result))
+	}
+}
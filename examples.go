@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// Limits for few-shot examples given to deepseek_ask
+const (
+	maxExamples           = 10
+	maxExamplesTotalChars = 50000
+)
+
+// fewShotExample is an input and the output the model should produce for it
+type fewShotExample struct {
+	Input  string
+	Output string
+}
+
+// parseExamples converts the examples argument, an array of {input, output} objects, into
+// few-shot examples, enforcing the count and total size limits
+func parseExamples(raw any) ([]fewShotExample, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("examples must be an array of objects")
+	}
+	if len(items) > maxExamples {
+		return nil, fmt.Errorf("too many examples: %d (maximum %d)", len(items), maxExamples)
+	}
+
+	examples := make([]fewShotExample, 0, len(items))
+	var totalChars int
+	for i, item := range items {
+		fields, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("examples[%d] must be an object", i)
+		}
+		input, _ := fields["input"].(string)
+		output, _ := fields["output"].(string)
+		if strings.TrimSpace(input) == "" || strings.TrimSpace(output) == "" {
+			return nil, fmt.Errorf("examples[%d] requires a non-empty input and output", i)
+		}
+		totalChars += utf8.RuneCountInString(input) + utf8.RuneCountInString(output)
+		examples = append(examples, fewShotExample{Input: input, Output: output})
+	}
+	if totalChars > maxExamplesTotalChars {
+		return nil, fmt.Errorf("examples are too long: %d characters (maximum %d)", totalChars, maxExamplesTotalChars)
+	}
+	return examples, nil
+}
+
+// exampleMessages returns the examples as alternating user and assistant messages
+func exampleMessages(examples []fewShotExample) []deepseek.ChatCompletionMessage {
+	messages := make([]deepseek.ChatCompletionMessage, 0, 2*len(examples))
+	for _, example := range examples {
+		messages = append(messages,
+			deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleUser, Content: example.Input},
+			deepseek.ChatCompletionMessage{Role: deepseek.ChatMessageRoleAssistant, Content: example.Output},
+		)
+	}
+	return messages
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestParseExamples(t *testing.T) {
+	example := map[string]any{"input": "2+2", "output": "4"}
+	tooMany := make([]any, maxExamples+1)
+	for i := range tooMany {
+		tooMany[i] = example
+	}
+	tests := []struct {
+		name    string
+		raw     any
+		want    []fewShotExample
+		wantErr string
+	}{
+		{name: "absent", raw: nil},
+		{
+			name: "examples",
+			raw:  []any{example, map[string]any{"input": "3*3", "output": "9"}},
+			want: []fewShotExample{{Input: "2+2", Output: "4"}, {Input: "3*3", Output: "9"}},
+		},
+		{name: "not an array", raw: "2+2=4", wantErr: "must be an array"},
+		{name: "too many", raw: tooMany, wantErr: "too many examples"},
+		{name: "not an object", raw: []any{"2+2"}, wantErr: "examples[0] must be an object"},
+		{name: "blank output", raw: []any{example, map[string]any{"input": "x", "output": "  "}}, wantErr: "examples[1] requires a non-empty input and output"},
+		{
+			name:    "too long",
+			raw:     []any{map[string]any{"input": strings.Repeat("é", maxExamplesTotalChars), "output": "y"}},
+			wantErr: "examples are too long",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseExamples(tt.raw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseExamples() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseExamples() = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAskExamplesPrecedeQuery(t *testing.T) {
+	client := &mockDeepseekClient{}
+	s := newTestServer(t, client, nil)
+	callTool(t, s.handleAskDeepseek, map[string]any{
+		"query":    "5+5",
+		"examples": []any{map[string]any{"input": "2+2", "output": "4"}},
+	})
+	if client.calls() != 1 {
+		t.Fatalf("got %d requests, want 1", client.calls())
+	}
+	var roles, contents []string
+	for _, message := range client.requests[0].Messages {
+		roles = append(roles, message.Role)
+		contents = append(contents, message.Content)
+	}
+	wantRoles := []string{deepseek.ChatMessageRoleSystem, deepseek.ChatMessageRoleUser, deepseek.ChatMessageRoleAssistant, deepseek.ChatMessageRoleUser}
+	if !reflect.DeepEqual(roles, wantRoles) {
+		t.Fatalf("message roles = %v, want %v", roles, wantRoles)
+	}
+	if contents[1] != "2+2" || contents[2] != "4" || !strings.Contains(contents[3], "5+5") {
+		t.Errorf("message contents = %q, want the example before the query", contents)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors wrapped by file validation, used to explain why a file was skipped
+var (
+	errFileAccessDisabled = errors.New("file access is disabled by configuration")
+	errPathNotAllowed     = errors.New("file path is not allowed")
+	errFileTooLarge       = errors.New("file is too large")
+	errFileTypeNotAllowed = errors.New("file type not allowed")
+	errFileBudgetExceeded = errors.New("total file size budget exceeded")
+	errDuplicateContent   = errors.New("same content as an earlier file")
+)
+
+// Reasons reported for files that were not included in a query
+const (
+	SkipReasonAccessDisabled = "file access disabled"
+	SkipReasonOutsideAllowed = "outside allowed dirs"
+	SkipReasonTooLarge       = "too large"
+	SkipReasonDisallowedType = "disallowed type"
+	SkipReasonBudget         = "over total size budget"
+	SkipReasonUnreadable     = "unreadable"
+	SkipReasonDuplicate      = "duplicate content"
+)
+
+// skippedFile is a requested file that was left out of the query
+type skippedFile struct {
+	Path   string
+	Reason string // One of the SkipReason constants
+	Err    error
+}
+
+// fileSkipReason classifies the error that kept a file out of the query
+func fileSkipReason(err error) string {
+	switch {
+	case errors.Is(err, errFileAccessDisabled):
+		return SkipReasonAccessDisabled
+	case errors.Is(err, errPathNotAllowed):
+		return SkipReasonOutsideAllowed
+	case errors.Is(err, errFileTooLarge):
+		return SkipReasonTooLarge
+	case errors.Is(err, errFileTypeNotAllowed):
+		return SkipReasonDisallowedType
+	case errors.Is(err, errFileBudgetExceeded):
+		return SkipReasonBudget
+	case errors.Is(err, errDuplicateContent):
+		return SkipReasonDuplicate
+	}
+	return SkipReasonUnreadable
+}
+
+// formatFileInclusionNote formats a note listing the files included in the query and the files
+// that were skipped, grouped by reason, so that skips are visible to the caller and not only in
+// the server log
+func formatFileInclusionNote(included []string, skipped []skippedFile) string {
+	var sb strings.Builder
+	sb.WriteString("\n\n---\n")
+	sb.WriteString(fmt.Sprintf("**Files:** %d of %d included, %d skipped\n",
+		len(included), len(included)+len(skipped), len(skipped)))
+	for _, path := range included {
+		sb.WriteString(fmt.Sprintf("- Included: `%s`\n", path))
+	}
+
+	counts := make(map[string]int)
+	var reasons []string
+	for _, file := range skipped {
+		if counts[file.Reason] == 0 {
+			reasons = append(reasons, file.Reason)
+		}
+		counts[file.Reason]++
+	}
+	for _, reason := range reasons {
+		sb.WriteString(fmt.Sprintf("- Skipped (%s): %d\n", reason, counts[reason]))
+		for _, file := range skipped {
+			if file.Reason == reason {
+				sb.WriteString(fmt.Sprintf("  - `%s`: %v\n", file.Path, file.Err))
+			}
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
@@ -1,32 +1,416 @@
 package main
 
 import (
+	"bytes"
+	"container/list"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 )
 
+// fileReadResult holds the outcome of validating and reading a single file
+type fileReadResult struct {
+	Path     string
+	Content  []byte
+	Err      error
+	Encoding string      // Detected source encoding when the content was transcoded to UTF-8
+	Lossy    bool        // Invalid sequences were replaced because the encoding was uncertain
+	Language string      // Language given with an inline file; detected from the path when empty
+	Inline   bool        // Given through inline_files, so Path is only a name
+	Omitted  int64       // Bytes left out of the middle of a file over the maximum size
+	Info     os.FileInfo // From validating the file; nil for inline files
+	Notebook bool        // Only the cells of a Jupyter notebook were kept
+	// NotebookErr is why the cells of a notebook could not be extracted; its raw JSON is kept
+	NotebookErr error
+}
+
+// parseInlineFiles converts the inline_files argument, an array of {name, language, content}
+// objects, into file results. Files larger than the maximum file size get an error result,
+// so they are skipped like oversized files on disk.
+func parseInlineFiles(raw any, cfg *Config) ([]fileReadResult, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("inline_files must be an array of objects")
+	}
+
+	var maxSize int64 = 10 * 1024 * 1024
+	if cfg != nil && cfg.MaxFileSize > 0 {
+		maxSize = cfg.MaxFileSize
+	}
+
+	results := make([]fileReadResult, 0, len(items))
+	for i, item := range items {
+		fields, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("inline_files[%d] must be an object", i)
+		}
+		name, _ := fields["name"].(string)
+		if strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("inline_files[%d] requires a name", i)
+		}
+		content, ok := fields["content"].(string)
+		if !ok {
+			return nil, fmt.Errorf("inline_files[%d] (%s) requires a string content", i, name)
+		}
+		language, _ := fields["language"].(string)
+
+		result := fileReadResult{Path: name, Language: strings.TrimSpace(language), Inline: true}
+		if size := int64(len(content)); size > maxSize && cfg.truncatesLargeFiles() {
+			result.Content, result.Omitted = excerptContent([]byte(content), cfg.largeFileExcerptBytes())
+		} else if size > maxSize {
+			result.Err = fmt.Errorf("inline %w: %s (%s)", errFileTooLarge, name, humanReadableSize(size))
+		} else {
+			result.Content = []byte(content)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Orders in which attached files are assembled into the prompt
+const (
+	FileOrderAsGiven      = "as-given"
+	FileOrderAlphabetical = "alphabetical"
+	FileOrderSizeAsc      = "size-asc"
+	FileOrderSizeDesc     = "size-desc"
+)
+
+// isValidFileOrder reports whether order is a supported file ordering
+func isValidFileOrder(order string) bool {
+	switch order {
+	case FileOrderAsGiven, FileOrderAlphabetical, FileOrderSizeAsc, FileOrderSizeDesc:
+		return true
+	}
+	return false
+}
+
+// orderFilePaths returns paths sorted by order. Files whose size cannot be read sort last when
+// ordering by size, and ties keep their given order.
+func orderFilePaths(paths []string, order string) []string {
+	ordered := append([]string{}, paths...)
+	switch order {
+	case FileOrderAlphabetical:
+		sort.SliceStable(ordered, func(i, j int) bool { return ordered[i] < ordered[j] })
+	case FileOrderSizeAsc, FileOrderSizeDesc:
+		sizes := make(map[string]int64, len(ordered))
+		for _, path := range ordered {
+			sizes[path] = -1
+			if info, err := os.Stat(path); err == nil {
+				sizes[path] = info.Size()
+			}
+		}
+		sort.SliceStable(ordered, func(i, j int) bool {
+			a, b := sizes[ordered[i]], sizes[ordered[j]]
+			if a < 0 || b < 0 {
+				return b < 0 && a >= 0
+			}
+			if order == FileOrderSizeAsc {
+				return a < b
+			}
+			return a > b
+		})
+	}
+	return ordered
+}
+
+// readFilesConcurrently validates and reads files using a bounded pool of workers.
+// Results are returned in the same order as paths, and a failure for one file
+// is recorded in its result without affecting the others. If onRead is not nil, it is
+// called concurrently with each result as soon as the file is read.
+func readFilesConcurrently(paths []string, cfg *Config, cache *fileCache, concurrency int, onRead func(fileReadResult)) []fileReadResult {
+	results := make([]fileReadResult, len(paths))
+	if len(paths) == 0 {
+		return results
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if concurrency > len(paths) {
+		concurrency = len(paths)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = readValidatedFile(paths[i], cfg, cache)
+				if onRead != nil {
+					onRead(results[i])
+				}
+			}
+		}()
+	}
+
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// readValidatedFile validates a file path against the config and reads its content,
+// using the cache if one is given
+func readValidatedFile(path string, cfg *Config, cache *fileCache) fileReadResult {
+	result := fileReadResult{Path: path}
+	info, err := validateFile(path, cfg)
+	result.Info = info
+	decompressed := false
+	if err == nil {
+		result.Content, result.Err = cache.Read(path)
+	} else if errors.Is(err, errFileTooLarge) && cfg.truncatesLargeFiles() {
+		if isGzipPath(path) {
+			// A cut through the compressed stream cannot be decompressed, so the decompressed content is excerpted
+			result.Content, result.Omitted, result.Err = readGzipExcerpt(path, cfg)
+			decompressed = true
+		} else {
+			result.Content, result.Omitted, result.Err = readLargeFileExcerpt(path, cfg)
+		}
+	} else {
+		result.Err = fmt.Errorf("file validation failed: %w", err)
+		return result
+	}
+	if result.Err == nil && isGzipPath(path) {
+		if !decompressed {
+			limit := int64(10 * 1024 * 1024)
+			if cfg != nil && cfg.MaxFileSize > 0 {
+				limit = cfg.MaxFileSize
+			}
+			compressed := result.Content
+			result.Content, result.Err = decompressGzip(compressed, limit)
+			if errors.Is(result.Err, errFileTooLarge) && cfg.truncatesLargeFiles() {
+				result.Content, result.Omitted, result.Err = excerptGzip(bytes.NewReader(compressed), cfg.largeFileExcerptBytes(), cfg.gzipExcerptScanBytes())
+			}
+			if result.Err != nil {
+				return result
+			}
+		}
+		result.Language = cfg.languageForFile(uncompressedPath(path), result.Content)
+	}
+	if result.Err == nil && cfg != nil && cfg.TranscodeFiles {
+		result.Content, result.Encoding, result.Lossy = transcodeToUTF8(result.Content)
+	}
+	if result.Err == nil && cfg != nil && cfg.ExtractNotebookCells && isNotebookPath(uncompressedPath(path)) {
+		if cells, err := extractNotebookCells(result.Content); err != nil {
+			result.NotebookErr = err
+		} else {
+			result.Content, result.Language, result.Notebook = cells, "markdown", true
+		}
+	}
+	return result
+}
+
+// fileCacheEntry is a cached file content along with the metadata used to detect changes
+type fileCacheEntry struct {
+	path    string
+	content []byte
+	size    int64
+	modTime time.Time
+}
+
+// fileCache is an in-memory cache of file contents keyed by path. An entry is only used
+// while the file's size and modification time are unchanged. The cache is bounded by the
+// total size of the cached contents, evicting the least recently used files first.
+type fileCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	bytes    int64
+	entries  map[string]*list.Element
+	lru      *list.List // Front is the most recently used
+}
+
+// newFileCache creates a file cache holding at most maxBytes of content.
+// A maxBytes of 0 or less disables caching.
+func newFileCache(maxBytes int64) *fileCache {
+	return &fileCache{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Read returns the content of the file at path, from the cache if the file has not changed.
+// A nil cache reads directly from disk.
+func (c *fileCache) Read(path string) ([]byte, error) {
+	if c == nil || c.maxBytes <= 0 {
+		return readFile(path)
+	}
+
+	key, err := filepath.Abs(path)
+	if err != nil {
+		return readFile(path)
+	}
+	info, err := os.Stat(key)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*fileCacheEntry)
+		if entry.size == info.Size() && entry.modTime.Equal(info.ModTime()) {
+			c.lru.MoveToFront(elem)
+			c.mu.Unlock()
+			return entry.content, nil
+		}
+		// The file changed since it was cached
+		c.removeLocked(elem)
+	}
+	c.mu.Unlock()
+
+	content, unchanged, err := readFileUnchanged(path, info)
+	if err != nil {
+		return nil, err
+	}
+	if !unchanged || int64(len(content)) > c.maxBytes {
+		return content, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		// Another reader cached the file meanwhile
+		c.removeLocked(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&fileCacheEntry{
+		path:    key,
+		content: content,
+		size:    info.Size(),
+		modTime: info.ModTime(),
+	})
+	c.bytes += int64(len(content))
+	for c.bytes > c.maxBytes {
+		c.removeLocked(c.lru.Back())
+	}
+	return content, nil
+}
+
+// readFileUnchanged reads a file and reports whether the content read belongs to the file
+// described by before: the open handle is stated after reading and must be the same file,
+// with the same size and modification time, and the content must be of that size. A file
+// replaced or written to since before was stated is read but reported as changed, so that
+// content not matching the metadata it would be cached under is not cached.
+func readFileUnchanged(path string, before os.FileInfo) ([]byte, bool, error) {
+	release := acquireOpenFile()
+	defer release()
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	after, err := file.Stat()
+	if err != nil {
+		return content, false, nil
+	}
+	unchanged := os.SameFile(before, after) && after.Size() == before.Size() &&
+		after.ModTime().Equal(before.ModTime()) && int64(len(content)) == after.Size()
+	return content, unchanged, nil
+}
+
+// removeLocked removes an entry from the cache. The caller must hold c.mu.
+func (c *fileCache) removeLocked(elem *list.Element) {
+	entry := elem.Value.(*fileCacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.path)
+	c.bytes -= int64(len(entry.content))
+}
+
+// FileTemplateData holds the fields available to a file template
+type FileTemplateData struct {
+	Path     string
+	Base     string
+	Language string
+	Content  string
+	Size     int64
+	ModTime  string // Last modification time in RFC3339, empty unless file timestamps are included
+	DiskSize int64  // Size of the file on disk, 0 unless file timestamps are included
+}
+
+// parseFileTemplate parses a text/template used to render an included file
+func parseFileTemplate(text string) (*template.Template, error) {
+	return template.New("file").Option("missingkey=error").Parse(text)
+}
+
+// renderFileContent renders a file for inclusion in the query context.
+// If tmpl is nil, the default markdown layout with a heading and fenced code block is used.
+// If info is not nil, the modification time and size of the file are added to the heading.
+func renderFileContent(tmpl *template.Template, path, language string, content []byte, info os.FileInfo) (string, error) {
+	data := FileTemplateData{
+		Path:     path,
+		Base:     filepath.Base(path),
+		Language: language,
+		Content:  string(content),
+		Size:     int64(len(content)),
+	}
+	if info != nil {
+		data.ModTime, data.DiskSize = info.ModTime().UTC().Format(time.RFC3339), info.Size()
+	}
+	if tmpl == nil {
+		var stamp string
+		if data.ModTime != "" {
+			stamp = fmt.Sprintf("\n\n*Last modified %s, %s*", data.ModTime, humanReadableSize(data.DiskSize))
+		}
+		return fmt.Sprintf("\n\n## %s%s\n\n```%s\n%s\n```", data.Base, stamp, data.Language, data.Content), nil
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render file template for %s: %w", path, err)
+	}
+	return sb.String(), nil
+}
+
 // ValidateFilePath validates a file path exists and conforms to the
 // constraints defined in the provided Config (max size and allowed types).
 // If cfg is nil, a 10MB default max size is used and types are not restricted.
 func ValidateFilePath(path string, cfg *Config) error {
+	_, err := validateFile(path, cfg)
+	return err
+}
+
+// validateFile validates a file like ValidateFilePath, also returning the file info once the
+// file is found, so that callers need not stat it again
+func validateFile(path string, cfg *Config) (os.FileInfo, error) {
+	if cfg != nil && cfg.DisableFileAccess {
+		return nil, fmt.Errorf("%w: %s", errFileAccessDisabled, path)
+	}
+
 	// First, check if the path is in the allowed list of directories
 	if cfg != nil && len(cfg.AllowedFilePaths) > 0 {
 		if !isPathAllowed(path, cfg.AllowedFilePaths) {
-			return fmt.Errorf("file path is not allowed: %s. Allowed roots are: %s", path, strings.Join(cfg.AllowedFilePaths, ", "))
+			return nil, fmt.Errorf("%w: %s. Allowed roots are: %s", errPathNotAllowed, path, strings.Join(cfg.AllowedFilePaths, ", "))
 		}
 	}
 
 	// Check if file exists
 	info, err := os.Stat(path)
 	if err != nil {
-		return fmt.Errorf("file not found or not accessible: %w", err)
+		return nil, fmt.Errorf("file not found or not accessible: %w", err)
 	}
 
 	// Check if it's a regular file
 	if info.IsDir() {
-		return fmt.Errorf("path is a directory, not a file: %s", path)
+		return info, fmt.Errorf("path is a directory, not a file: %s", path)
 	}
 
 	// Determine max file size from config (default 10MB if cfg is nil)
@@ -37,29 +421,11 @@ func ValidateFilePath(path string, cfg *Config) error {
 
 	// Check if file is too large
 	if info.Size() > maxSize {
-		return fmt.Errorf("file is too large: %s (%s)", path, humanReadableSize(info.Size()))
+		return info, fmt.Errorf("%w: %s (%s)", errFileTooLarge, path, humanReadableSize(info.Size()))
 	}
 
-	// Check file extension is allowed
-	var allowedTypes []string
-	if cfg != nil {
-		allowedTypes = cfg.AllowedFileTypes
-	}
-	if len(allowedTypes) > 0 {
-		mimeType := getMimeTypeFromPath(path)
-		allowed := false
-		for _, allowedType := range allowedTypes {
-			if mimeType == allowedType {
-				allowed = true
-				break
-			}
-		}
-		if !allowed {
-			return fmt.Errorf("file type not allowed: %s (type: %s)", path, mimeType)
-		}
-	}
-
-	return nil
+	// Check file type is allowed, by extension and/or content depending on the config
+	return info, checkFileType(path, cfg)
 }
 
 // GetFileInfo returns information about a file
@@ -73,8 +439,50 @@ func GetFileInfo(path string) (string, int64, error) {
 	return mimeType, info.Size(), nil
 }
 
+// expandPath expands a leading ~ to the home directory and $VAR or ${VAR} references to
+// environment variables. Referencing an unset variable is an error rather than expanding to an
+// empty string, which could silently turn a relative path into an absolute one.
+func expandPath(path string) (string, error) {
+	if path == "~" || strings.HasPrefix(path, "~/") || (runtime.GOOS == "windows" && strings.HasPrefix(path, `~\`)) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot expand ~ in %s: %w", path, err)
+		}
+		path = home + path[1:]
+	}
+
+	var unset []string
+	expanded := os.Expand(path, func(name string) string {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			unset = append(unset, name)
+		}
+		return value
+	})
+	if len(unset) > 0 {
+		return "", fmt.Errorf("cannot expand %s: environment variable %s is not set", path, strings.Join(unset, ", "))
+	}
+	return expanded, nil
+}
+
+// expandPaths expands each path with expandPath
+func expandPaths(paths []string) ([]string, error) {
+	expanded := make([]string, 0, len(paths))
+	for _, path := range paths {
+		path, err := expandPath(path)
+		if err != nil {
+			return nil, err
+		}
+		expanded = append(expanded, path)
+	}
+	return expanded, nil
+}
+
 // isPathAllowed checks if a given file path is within the allowed directories.
 // This is a security measure to prevent arbitrary file system access.
+// Both the path and the allowed directories are expected to be expanded with expandPath
+// already: the config expands its allowed paths when loaded and the handlers expand incoming
+// paths, so that each side is expanded exactly once before symlinks are resolved.
 func isPathAllowed(path string, allowedDirs []string) bool {
 	// Resolve the target path to an absolute, symlink-free path
 	absPath, err := filepath.Abs(path)
@@ -102,18 +510,45 @@ func isPathAllowed(path string, allowedDirs []string) bool {
 			continue
 		}
 
-		// Compute the relative path from the allowed directory to the target
-		rel, err := filepath.Rel(resolvedDir, resolvedPath)
-		if err != nil {
-			continue
-		}
-
-		rel = filepath.Clean(rel)
-
-		// Allowed if the relative path does not traverse outside the dir
-		if rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(os.PathSeparator))) {
+		// Allowed if the target is the dir itself or inside it
+		if pathWithinRoot(resolvedDir, resolvedPath, runtime.GOOS == "windows") {
 			return true
 		}
 	}
 	return false
 }
+
+// pathWithinRoot reports whether the absolute path target is root or inside it. Windows paths
+// are compared case-insensitively with either separator, so the volume (drive letter or UNC
+// share) and every directory must match regardless of case.
+func pathWithinRoot(root, target string, windows bool) bool {
+	if windows {
+		root, target = normalizeWindowsPath(root), normalizeWindowsPath(target)
+	} else {
+		root, target = filepath.Clean(root), filepath.Clean(target)
+	}
+	if target == root {
+		return true
+	}
+	if !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+	return strings.HasPrefix(target, root)
+}
+
+// normalizeWindowsPath converts an absolute Windows path to a lower-case, forward-slash form
+// for comparison. Extended-length prefixes are removed, so \\?\C:\dir becomes c:/dir and
+// \\?\UNC\server\share becomes //server/share.
+func normalizeWindowsPath(p string) string {
+	p = strings.ReplaceAll(p, `\`, "/")
+	p = strings.TrimPrefix(p, "//?/")
+	if len(p) >= 4 && strings.EqualFold(p[:4], "UNC/") {
+		p = "//" + p[4:]
+	}
+	unc := strings.HasPrefix(p, "//")
+	p = path.Clean(p)
+	if unc {
+		p = "/" + p // path.Clean reduces the leading // of a UNC path to a single slash
+	}
+	return strings.ToLower(p)
+}
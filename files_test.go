@@ -0,0 +1,488 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestNormalizeWindowsPath(t *testing.T) {
+	tests := []struct {
+		path, want string
+	}{
+		{`C:\Users\Dev`, "c:/users/dev"},
+		{`c:\users\dev\`, "c:/users/dev"},
+		{`C:\Users\Dev\..\Other`, "c:/users/other"},
+		{`C:\Users\.\Dev`, "c:/users/dev"},
+		{`\\?\C:\Users\Dev`, "c:/users/dev"},
+		{`\\Server\Share\Dir`, "//server/share/dir"},
+		{`\\?\UNC\Server\Share\Dir`, "//server/share/dir"},
+		{`\\?\unc\server\share\`, "//server/share"},
+		{`C:/Mixed\Separators/`, "c:/mixed/separators"},
+	}
+	for _, tt := range tests {
+		if got := normalizeWindowsPath(tt.path); got != tt.want {
+			t.Errorf("normalizeWindowsPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPathWithinRoot(t *testing.T) {
+	tests := []struct {
+		name         string
+		root, target string
+		windows      bool
+		want         bool
+	}{
+		{"windows root itself", `C:\foo`, `C:\foo`, true, true},
+		{"windows child", `C:\foo`, `C:\foo\bar.go`, true, true},
+		{"windows drive letter case", `c:\Foo`, `C:\FOO\bar.go`, true, true},
+		{"windows trailing separator on root", `C:\foo\`, `C:\foo\bar.go`, true, true},
+		{"windows trailing separator on target", `C:\foo`, `C:\foo\`, true, true},
+		{"windows sibling prefix", `C:\foo`, `C:\foobar\x.go`, true, false},
+		{"windows traversal", `C:\foo`, `C:\foo\..\bar\x.go`, true, false},
+		{"windows traversal back inside", `C:\foo`, `C:\foo\sub\..\x.go`, true, true},
+		{"windows other drive", `C:\foo`, `D:\foo\x.go`, true, false},
+		{"windows drive root", `C:\`, `C:\foo\x.go`, true, true},
+		{"windows extended-length target", `C:\foo`, `\\?\C:\foo\x.go`, true, true},
+		{"unc child", `\\server\share`, `\\server\share\dir\x.go`, true, true},
+		{"unc extended-length", `\\server\share`, `\\?\UNC\server\share\x.go`, true, true},
+		{"unc sibling share", `\\server\share`, `\\server\shared\x.go`, true, false},
+		{"unc other server", `\\server\share`, `\\other\share\x.go`, true, false},
+		{"unix child", "/srv/foo", "/srv/foo/bar.go", false, true},
+		{"unix root itself with trailing separator", "/srv/foo/", "/srv/foo", false, true},
+		{"unix sibling prefix", "/srv/foo", "/srv/foobar/x.go", false, false},
+		{"unix traversal", "/srv/foo", "/srv/foo/../bar/x.go", false, false},
+		{"unix case sensitive", "/srv/foo", "/srv/FOO/x.go", false, false},
+		{"unix filesystem root", "/", "/etc/passwd", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pathWithinRoot(tt.root, tt.target, tt.windows); got != tt.want {
+				t.Errorf("pathWithinRoot(%q, %q, %v) = %v, want %v", tt.root, tt.target, tt.windows, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileCacheRead(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	stamp := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	write := func(content string, modTime time.Time) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		maxBytes int64
+		change   func()
+		want     string
+	}{
+		// Same size and modification time: served from the cache, so the old content is returned
+		{"unchanged metadata is a hit", 1024, func() { write("new", stamp) }, "old"},
+		{"new modification time", 1024, func() { write("new", stamp.Add(time.Second)) }, "new"},
+		{"new size", 1024, func() { write("newer", stamp) }, "newer"},
+		{"disabled cache", 0, func() { write("new", stamp) }, "new"},
+		{"file larger than the cache", 2, func() { write("new", stamp) }, "new"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			write("old", stamp)
+			c := newFileCache(tt.maxBytes)
+			if got, err := c.Read(path); err != nil || string(got) != "old" {
+				t.Fatalf("first Read() = %q, %v", got, err)
+			}
+			tt.change()
+			if got, err := c.Read(path); err != nil || string(got) != tt.want {
+				t.Errorf("second Read() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	paths := make(map[string]string)
+	for _, name := range []string{"a", "b", "c"} {
+		paths[name] = filepath.Join(dir, name)
+		if err := os.WriteFile(paths[name], []byte("0123456789"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := newFileCache(25)
+	for _, name := range []string{"a", "b", "a", "c"} { // b is the least recently used when c is added
+		if _, err := c.Read(paths[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		key, _ := filepath.Abs(paths[name])
+		if _, ok := c.entries[key]; ok != want {
+			t.Errorf("%s cached = %v, want %v", name, ok, want)
+		}
+	}
+	if c.bytes != 20 {
+		t.Errorf("cached bytes = %d, want 20", c.bytes)
+	}
+}
+
+func TestReadFileUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	other := filepath.Join(dir, "b.txt")
+	for _, p := range []string{path, other} {
+		if err := os.WriteFile(p, []byte("content"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stat := func(p string) os.FileInfo {
+		t.Helper()
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+
+	tests := []struct {
+		name   string
+		before func() os.FileInfo
+		want   bool
+	}{
+		{"unchanged", func() os.FileInfo { return stat(path) }, true},
+		{"replaced by another file", func() os.FileInfo { return stat(other) }, false},
+		{"written since stated", func() os.FileInfo {
+			info := stat(path)
+			if err := os.WriteFile(path, []byte("longer content"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			return info
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(path, []byte("content"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			content, unchanged, err := readFileUnchanged(path, tt.before())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if unchanged != tt.want {
+				t.Errorf("readFileUnchanged() unchanged = %v, want %v (content %q)", unchanged, tt.want, content)
+			}
+		})
+	}
+}
+
+func TestReadFilesConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newTestConfig(t, map[string]string{"DEEPSEEK_ALLOWED_FILE_PATHS": dir})
+	var paths []string
+	for i := 0; i < 20; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("f%02d.txt", i))
+		if i%5 == 0 {
+			path = filepath.Join(dir, fmt.Sprintf("missing%02d.txt", i)) // Fails on its own
+		} else if err := os.WriteFile(path, []byte(fmt.Sprintf("content %d", i)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	for _, concurrency := range []int{0, 1, 4, 100} {
+		t.Run(fmt.Sprintf("concurrency %d", concurrency), func(t *testing.T) {
+			var mu sync.Mutex
+			seen := make(map[string]bool)
+			results := readFilesConcurrently(paths, cfg, nil, concurrency, func(result fileReadResult) {
+				mu.Lock()
+				defer mu.Unlock()
+				seen[result.Path] = true
+			})
+			if len(results) != len(paths) || len(seen) != len(paths) {
+				t.Fatalf("got %d results and %d callbacks, want %d of each", len(results), len(seen), len(paths))
+			}
+			for i, result := range results {
+				if result.Path != paths[i] {
+					t.Errorf("result %d is for %s, want %s", i, result.Path, paths[i])
+				}
+				if i%5 == 0 {
+					if result.Err == nil {
+						t.Errorf("%s: want an error for a missing file", result.Path)
+					}
+				} else if result.Err != nil || string(result.Content) != fmt.Sprintf("content %d", i) {
+					t.Errorf("%s: content %q, error %v", result.Path, result.Content, result.Err)
+				}
+			}
+		})
+	}
+
+	if results := readFilesConcurrently(nil, cfg, nil, 4, nil); len(results) != 0 {
+		t.Errorf("readFilesConcurrently(nil) = %v, want no results", results)
+	}
+}
+
+func TestMaxFileReadConcurrencyConfig(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"", 8, false},
+		{"2", 2, false},
+		{"0", 0, true},
+		{"many", 0, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("DEEPSEEK_API_KEY", "test-key")
+		t.Setenv("DEEPSEEK_MAX_FILE_READ_CONCURRENCY", tt.value)
+		cfg, err := NewConfig()
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("DEEPSEEK_MAX_FILE_READ_CONCURRENCY=%q: error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+		if err == nil && cfg.MaxFileReadConcurrency != tt.want {
+			t.Errorf("DEEPSEEK_MAX_FILE_READ_CONCURRENCY=%q gives %d, want %d", tt.value, cfg.MaxFileReadConcurrency, tt.want)
+		}
+	}
+}
+
+func TestParseInlineFiles(t *testing.T) {
+	cfg := &Config{MaxFileSize: 8}
+	tests := []struct {
+		name    string
+		raw     any
+		want    []fileReadResult
+		wantErr string
+	}{
+		{name: "absent", raw: nil},
+		{
+			name: "files",
+			raw: []any{
+				map[string]any{"name": "main.go", "content": "package"},
+				map[string]any{"name": "query.txt", "language": " sql ", "content": ""},
+			},
+			want: []fileReadResult{
+				{Path: "main.go", Content: []byte("package")},
+				{Path: "query.txt", Language: "sql", Content: []byte("")},
+			},
+		},
+		{
+			name: "too large",
+			raw:  []any{map[string]any{"name": "big.txt", "content": "123456789"}},
+			want: []fileReadResult{{Path: "big.txt", Err: fmt.Errorf("inline file is too large: big.txt (9 B)")}},
+		},
+		{name: "not an array", raw: "main.go", wantErr: "must be an array"},
+		{name: "not an object", raw: []any{"main.go"}, wantErr: "inline_files[0] must be an object"},
+		{name: "missing name", raw: []any{map[string]any{"name": " ", "content": "x"}}, wantErr: "requires a name"},
+		{name: "missing content", raw: []any{map[string]any{"name": "a.go"}}, wantErr: "requires a string content"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseInlineFiles(tt.raw, cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseInlineFiles() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseInlineFiles() returned %d files, want %d", len(got), len(tt.want))
+			}
+			for i, want := range tt.want {
+				g := got[i]
+				if g.Path != want.Path || g.Language != want.Language || string(g.Content) != string(want.Content) {
+					t.Errorf("file %d = %+v, want %+v", i, g, want)
+				}
+				if (g.Err == nil) != (want.Err == nil) || (g.Err != nil && g.Err.Error() != want.Err.Error()) {
+					t.Errorf("file %d error = %v, want %v", i, g.Err, want.Err)
+				}
+			}
+		})
+	}
+}
+
+func TestAskInlineFiles(t *testing.T) {
+	client := &mockDeepseekClient{}
+	s := newTestServer(t, client, nil)
+	callTool(t, s.handleAskDeepseek, map[string]any{
+		"query": "review",
+		"inline_files": []any{
+			map[string]any{"name": "snippet.txt", "language": "rust", "content": "fn main() {}"},
+			map[string]any{"name": "util.py", "content": "def f(): pass"},
+		},
+	})
+	if client.calls() != 1 {
+		t.Fatalf("got %d requests, want 1", client.calls())
+	}
+	messages := client.requests[0].Messages
+	prompt := messages[len(messages)-1].Content
+	for _, want := range []string{"snippet.txt", "```rust\nfn main() {}", "util.py", "```python\ndef f(): pass"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("prompt %q does not contain %q", prompt, want)
+		}
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("PROJECT_ROOT", "/srv/project")
+	tests := []struct {
+		path    string
+		want    string
+		wantErr string
+	}{
+		{path: "~", want: home},
+		{path: "~/src", want: home + "/src"},
+		{path: "~other/src", want: "~other/src"},
+		{path: "$PROJECT_ROOT/main.go", want: "/srv/project/main.go"},
+		{path: "${PROJECT_ROOT}/cmd", want: "/srv/project/cmd"},
+		{path: "~/$PROJECT_ROOT", want: home + "//srv/project"},
+		{path: "/plain/path", want: "/plain/path"},
+		{path: "$DEEPSEEK_TEST_UNSET/main.go", wantErr: "environment variable DEEPSEEK_TEST_UNSET is not set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := expandPath(tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expandPath(%q) error = %v, want %q", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("expandPath(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowedFilePathsExpanded(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("DEEPSEEK_TEST_PROJECTS", filepath.Join(home, "projects"))
+	cfg := newTestConfig(t, map[string]string{"DEEPSEEK_ALLOWED_FILE_PATHS": "~/notes,$DEEPSEEK_TEST_PROJECTS"})
+	want := []string{filepath.Join(home, "notes"), filepath.Join(home, "projects")}
+	if fmt.Sprint(cfg.AllowedFilePaths) != fmt.Sprint(want) {
+		t.Errorf("AllowedFilePaths = %v, want %v", cfg.AllowedFilePaths, want)
+	}
+
+	t.Setenv("DEEPSEEK_ALLOWED_FILE_PATHS", "$DEEPSEEK_TEST_UNSET/src")
+	if _, err := NewConfig(); err == nil {
+		t.Error("NewConfig() accepted an allowed path with an unset variable")
+	}
+}
+
+func TestRenderFileContent(t *testing.T) {
+	tests := []struct {
+		name      string
+		template  string
+		want      string
+		wantError string
+	}{
+		{
+			name: "default layout",
+			want: "\n\n## main.go\n\n```go\npackage main\n```",
+		},
+		{
+			name:     "custom placeholders",
+			template: "<file path=\"{{.Path}}\" name=\"{{.Base}}\" lang=\"{{.Language}}\" size=\"{{.Size}}\">{{.Content}}</file>",
+			want:     "<file path=\"src/main.go\" name=\"main.go\" lang=\"go\" size=\"12\">package main</file>",
+		},
+		{
+			name:      "malformed template",
+			template:  "{{.Path",
+			wantError: "unclosed action",
+		},
+		{
+			name:      "unknown field",
+			template:  "{{.Missing}}",
+			wantError: "failed to render file template for src/main.go",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tmpl *template.Template
+			if tt.template != "" {
+				var err error
+				tmpl, err = parseFileTemplate(tt.template)
+				if err != nil {
+					if tt.wantError == "" || !strings.Contains(err.Error(), tt.wantError) {
+						t.Fatalf("parseFileTemplate() error = %v, want %q", err, tt.wantError)
+					}
+					return
+				}
+			}
+			got, err := renderFileContent(tmpl, "src/main.go", "go", []byte("package main"), nil)
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("renderFileContent() error = %v, want %q", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderFileContent() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("renderFileContent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderFileContentTimestamp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.go")
+	if err := os.WriteFile(path, []byte("package main\n\nfunc main() {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Date(2024, 3, 9, 14, 30, 0, 0, time.FixedZone("CET", 3600))
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		info     os.FileInfo
+		want     string
+	}{
+		{"default layout", "", info, "\n\n## main.go\n\n*Last modified 2024-03-09T13:30:00Z, 29 B*\n\n```go\npackage main\n```"},
+		{"default layout without timestamps", "", nil, "\n\n## main.go\n\n```go\npackage main\n```"},
+		{"template fields", "{{.Base}} {{.ModTime}} {{.DiskSize}}", info, "main.go 2024-03-09T13:30:00Z 29"},
+		{"template fields without timestamps", "{{.Base}} [{{.ModTime}}] {{.DiskSize}}", nil, "main.go [] 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tmpl *template.Template
+			if tt.template != "" {
+				tmpl = template.Must(parseFileTemplate(tt.template))
+			}
+			got, err := renderFileContent(tmpl, path, "go", []byte("package main"), tt.info)
+			if err != nil {
+				t.Fatalf("renderFileContent() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("renderFileContent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
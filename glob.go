@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// hasGlobMeta reports whether a path contains glob metacharacters
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// canonicalPath returns the absolute, symlink-free form of path used to detect duplicates,
+// falling back to the absolute path when symlinks cannot be resolved
+func canonicalPath(path string) string {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if resolved, err := filepath.EvalSymlinks(absPath); err == nil {
+		return resolved
+	}
+	return absPath
+}
+
+// expandFilePaths expands glob patterns in paths into the matching files.
+// Plain paths are passed through unchanged. Matched files must be within the allowed
+// roots and of an allowed type; other matches are skipped. A pattern that matches
+// nothing is logged as a warning and does not cause an error. Paths resolving to the same
+// file, e.g. through overlapping patterns or symlinks, are kept only once.
+func expandFilePaths(paths []string, cfg *Config, logger Logger) []string {
+	var expanded []string
+	seen := make(map[string]bool)
+	duplicates := 0
+	add := func(path string) {
+		key := canonicalPath(path)
+		if seen[key] {
+			duplicates++
+			return
+		}
+		seen[key] = true
+		expanded = append(expanded, path)
+	}
+
+	for _, path := range paths {
+		if !hasGlobMeta(path) {
+			add(path)
+			continue
+		}
+
+		matches := expandGlob(path, cfg, logger)
+		if len(matches) == 0 {
+			logger.Warn("Glob pattern %q did not match any allowed files", path)
+			continue
+		}
+		logger.Info("Glob pattern %q matched %d file(s)", path, len(matches))
+		for _, match := range matches {
+			add(match)
+		}
+	}
+	if duplicates > 0 {
+		logger.Info("Removed %d duplicate file path(s)", duplicates)
+	}
+	return expanded
+}
+
+// checkFilePathCount rejects a request with more file paths than MaxFilePaths. It is
+// checked on the paths as given, before globs are expanded, and again on the expanded paths,
+// before any file is read.
+func (s *DeepseekServer) checkFilePathCount(cfg *Config, count int, expanded bool) *mcp.CallToolResult {
+	limit := cfg.MaxFilePaths
+	if limit <= 0 || count <= limit {
+		return nil
+	}
+	what := "file_paths"
+	if expanded {
+		what = "files matched by file_paths"
+	}
+	s.logger.Warn("Rejecting request with %d %s (limit %d)", count, what, limit)
+	return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Too many %s: %d (maximum is %d). "+
+		"Use narrower glob patterns, list only the files that matter, or split the question into several requests "+
+		"and use context_compression=summarize for large files.", what, count, limit))
+}
+
+// maxGlobWalkEntries bounds the number of files and directories visited to expand one glob
+// pattern, so that a broad pattern over a large tree cannot stall the request. It is a
+// variable so that tests can lower it.
+var maxGlobWalkEntries = 50_000
+
+// expandGlob returns the allowed files matching a single glob pattern.
+// Supports the standard single-segment wildcards and "**" to match any number of directories.
+// Only the allowed roots are walked: a pattern starting above them, such as "/**/*.go", walks
+// the allowed roots inside its base directory. Without allowed roots, the pattern's literal base
+// directory is walked, and the matches are validated when read like any other path.
+// Directories that cannot contain a match are skipped, and the walk stops after
+// maxGlobWalkEntries entries.
+func expandGlob(pattern string, cfg *Config, logger Logger) []string {
+	if cfg != nil && cfg.DisableFileAccess {
+		logger.Warn("Glob pattern %q ignored: file access is disabled", pattern)
+		return nil
+	}
+	var allowedPaths []string
+	if cfg != nil {
+		allowedPaths = cfg.AllowedFilePaths
+	}
+
+	pattern = filepath.Clean(pattern)
+	patternSegments := strings.Split(filepath.ToSlash(pattern), "/")
+
+	// Walk from the longest leading directory that contains no wildcards
+	baseSegments := 0
+	for baseSegments < len(patternSegments)-1 && !hasGlobMeta(patternSegments[baseSegments]) {
+		baseSegments++
+	}
+	baseDir := filepath.FromSlash(strings.Join(patternSegments[:baseSegments], "/"))
+	if baseDir == "" {
+		if filepath.IsAbs(pattern) {
+			baseDir = string(filepath.Separator)
+		} else {
+			baseDir = "."
+		}
+	}
+
+	walkDirs := []string{baseDir}
+	if len(allowedPaths) > 0 && !isPathAllowed(baseDir, allowedPaths) {
+		walkDirs = allowedRootsWithin(baseDir, allowedPaths)
+		if len(walkDirs) == 0 {
+			logger.Warn("Glob pattern %q starts outside the allowed file paths", pattern)
+			return nil
+		}
+	}
+
+	var matches []string
+	visited := 0
+	for _, walkDir := range walkDirs {
+		err := filepath.WalkDir(walkDir, func(path string, d fs.DirEntry, err error) error {
+			if visited++; visited > maxGlobWalkEntries {
+				logger.Warn("Glob pattern %q visited more than %d entries; stopped expanding it. Use a narrower pattern.", pattern, maxGlobWalkEntries)
+				return fs.SkipAll
+			}
+			if err != nil {
+				logger.Debug("Skipping %s during glob expansion: %v", path, err)
+				if d != nil && d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			pathSegments := strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+			if d.IsDir() {
+				if path != baseDir && (d.Name() == ".git" || !matchGlobPrefix(patternSegments, pathSegments)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if !matchGlobSegments(patternSegments, pathSegments) {
+				return nil
+			}
+			if len(allowedPaths) > 0 && !isPathAllowed(path, allowedPaths) {
+				logger.Debug("Glob match %s is outside the allowed file paths", path)
+				return nil
+			}
+			if err := checkFileType(path, cfg); err != nil {
+				logger.Debug("Glob match %s skipped: %v", path, err)
+				return nil
+			}
+			matches = append(matches, path)
+			return nil
+		})
+		if err != nil {
+			logger.Warn("Failed to expand glob pattern %q: %v", pattern, err)
+		}
+		if visited > maxGlobWalkEntries {
+			break
+		}
+	}
+	return matches
+}
+
+// allowedRootsWithin returns the allowed roots that lie inside dir, as paths starting with dir
+// so that they match the glob pattern dir was taken from
+func allowedRootsWithin(dir string, allowedDirs []string) []string {
+	resolvedDir := canonicalPath(dir)
+	var roots []string
+	for _, allowed := range allowedDirs {
+		if strings.TrimSpace(allowed) == "" {
+			continue
+		}
+		if _, err := os.Stat(allowed); err != nil || !isPathAllowed(allowed, []string{dir}) {
+			continue
+		}
+		rel, err := filepath.Rel(resolvedDir, canonicalPath(allowed))
+		if err != nil {
+			continue
+		}
+		roots = append(roots, filepath.Join(dir, rel))
+	}
+	return roots
+}
+
+// matchGlobPrefix reports whether path segments of a directory can be the start of a path
+// matching the pattern segments, that is whether the directory may contain a match
+func matchGlobPrefix(pattern, path []string) bool {
+	if len(path) == 0 {
+		return len(pattern) > 0
+	}
+	if len(pattern) == 0 {
+		return false
+	}
+	if pattern[0] == "**" {
+		return true
+	}
+	matched, err := filepath.Match(pattern[0], path[0])
+	if err != nil || !matched {
+		return false
+	}
+	return matchGlobPrefix(pattern[1:], path[1:])
+}
+
+// matchGlobSegments matches path segments against pattern segments, where a "**"
+// segment matches zero or more path segments
+func matchGlobSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchGlobSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(path) == 0 {
+		return false
+	}
+	matched, err := filepath.Match(pattern[0], path[0])
+	if err != nil || !matched {
+		return false
+	}
+	return matchGlobSegments(pattern[1:], path[1:])
+}
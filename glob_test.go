@@ -0,0 +1,255 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMatchGlobSegments(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"src/*.go", "src/main.go", true},
+		{"src/*.go", "src/sub/main.go", false},
+		{"src/**/*.go", "src/main.go", true},
+		{"src/**/*.go", "src/a/b/main.go", true},
+		{"src/**/*.go", "lib/main.go", false},
+		{"**", "a/b/c", true},
+		{"src/?.go", "src/ab.go", false},
+		{"src/[ab].go", "src/b.go", true},
+		{"src/*.go", "src", false},
+	}
+	for _, tt := range tests {
+		got := matchGlobSegments(strings.Split(tt.pattern, "/"), strings.Split(tt.path, "/"))
+		if got != tt.want {
+			t.Errorf("matchGlobSegments(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMatchGlobPrefix(t *testing.T) {
+	tests := []struct {
+		pattern, dir string
+		want         bool
+	}{
+		{"src/*.go", "src", true},
+		{"src/*.go", "src/sub", false},
+		{"src/*/*.go", "src/sub", true},
+		{"src/*/*.go", "src/sub/deeper", false},
+		{"src/**/*.go", "src/a/b/c", true},
+		{"src/**/*.go", "lib", false},
+		{"*/test/*.go", "pkg/test", true},
+		{"*/test/*.go", "pkg/other", false},
+	}
+	for _, tt := range tests {
+		got := matchGlobPrefix(strings.Split(tt.pattern, "/"), strings.Split(tt.dir, "/"))
+		if got != tt.want {
+			t.Errorf("matchGlobPrefix(%q, %q) = %v, want %v", tt.pattern, tt.dir, got, tt.want)
+		}
+	}
+}
+
+// newGlobTree creates an allowed root and a sibling directory outside it, both holding Go files
+func newGlobTree(t *testing.T) (parent, root string) {
+	t.Helper()
+	parent = t.TempDir()
+	root = filepath.Join(parent, "root")
+	for _, file := range []string{
+		"root/a.go", "root/b.txt", "root/sub/c.go", "root/sub/deep/d.go", "root/.git/e.go", "outside/x.go",
+	} {
+		path := filepath.Join(parent, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return parent, root
+}
+
+func TestExpandGlob(t *testing.T) {
+	parent, root := newGlobTree(t)
+	tests := []struct {
+		name    string
+		pattern string
+		allowed []string
+		want    []string // Relative to parent
+	}{
+		{"single star does not recurse", "root/*.go", []string{root}, []string{"root/a.go"}},
+		{"double star", "root/**/*.go", []string{root}, []string{"root/a.go", "root/sub/c.go", "root/sub/deep/d.go"}},
+		{"star directory", "root/*/*.go", []string{root}, []string{"root/sub/c.go"}},
+		{"outside the roots", "outside/*.go", []string{root}, nil},
+		{"above the root", "**/*.go", []string{root}, []string{"root/a.go", "root/sub/c.go", "root/sub/deep/d.go"}},
+		{"above the root, pattern not matching it", "outside/**/*.go", []string{root}, nil},
+		{"no allowed roots", "root/*.go", nil, []string{"root/a.go"}},
+		{"no allowed roots, double star", "root/**/*.go", nil, []string{"root/a.go", "root/sub/c.go", "root/sub/deep/d.go"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := expandGlob(filepath.Join(parent, tt.pattern), &Config{AllowedFilePaths: tt.allowed}, NewLogger("error"))
+			var rel []string
+			for _, path := range got {
+				r, err := filepath.Rel(parent, path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rel = append(rel, filepath.ToSlash(r))
+			}
+			sort.Strings(rel)
+			if !reflect.DeepEqual(rel, tt.want) {
+				t.Errorf("expandGlob(%s) = %v, want %v", tt.pattern, rel, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandGlobFromFilesystemRoot(t *testing.T) {
+	_, root := newGlobTree(t)
+	// Walking the whole filesystem would exceed the limit, walking the allowed root does not
+	saved := maxGlobWalkEntries
+	maxGlobWalkEntries = 20
+	t.Cleanup(func() { maxGlobWalkEntries = saved })
+
+	logger := &recordingLogger{}
+	got := expandGlob(string(filepath.Separator)+filepath.Join("**", "*.go"), &Config{AllowedFilePaths: []string{root}}, logger)
+	if len(got) != 3 {
+		t.Errorf("expandGlob(/**/*.go) = %v, want the 3 Go files of the allowed root", got)
+	}
+	for _, path := range got {
+		if !strings.HasPrefix(canonicalPath(path), canonicalPath(root)) {
+			t.Errorf("match %s is outside the allowed root", path)
+		}
+	}
+	if logger.contains("stopped expanding") {
+		t.Error("the walk went beyond the allowed root")
+	}
+}
+
+func TestExpandGlobWalkLimit(t *testing.T) {
+	parent, root := newGlobTree(t)
+	for i := 0; i < 20; i++ {
+		if err := os.WriteFile(filepath.Join(root, "sub", "deep", strings.Repeat("f", i+1)+".txt"), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved := maxGlobWalkEntries
+	maxGlobWalkEntries = 8
+	t.Cleanup(func() { maxGlobWalkEntries = saved })
+
+	tests := []struct {
+		name        string
+		pattern     string
+		wantLimited bool
+	}{
+		// The large subdirectories are pruned, so the walk stays within the limit
+		{"pruned", "root/*.go", false},
+		{"recursive", "root/**/*.go", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+			expandGlob(filepath.Join(parent, tt.pattern), &Config{AllowedFilePaths: []string{root}}, logger)
+			if limited := logger.contains("stopped expanding"); limited != tt.wantLimited {
+				t.Errorf("walk limit reached = %v, want %v", limited, tt.wantLimited)
+			}
+		})
+	}
+}
+
+func TestExpandFilePathsDeduplicates(t *testing.T) {
+	parent, root := newGlobTree(t)
+	link := filepath.Join(root, "link.go")
+	if err := os.Symlink(filepath.Join(root, "a.go"), link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	a := filepath.Join(root, "a.go")
+	tests := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{"same path twice", []string{a, a}, []string{a}},
+		{"unclean path", []string{a, filepath.Join(root, "sub", "..", "a.go")}, []string{a}},
+		{"symlink to a listed file", []string{a, link}, []string{a}},
+		{"overlapping glob", []string{filepath.Join(root, "sub", "c.go"), filepath.Join(root, "**", "c.go")}, []string{filepath.Join(root, "sub", "c.go")}},
+		{"distinct files", []string{a, filepath.Join(parent, "root", "b.txt")}, []string{a, filepath.Join(root, "b.txt")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := expandFilePaths(tt.paths, &Config{AllowedFilePaths: []string{root}}, NewLogger("error"))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expandFilePaths(%v) = %v, want %v", tt.paths, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAskMaxFilePaths(t *testing.T) {
+	_, root := newGlobTree(t)
+	tests := []struct {
+		name      string
+		limit     string
+		paths     []any
+		wantError string
+	}{
+		{"within the limit", "2", []any{filepath.Join(root, "a.go"), filepath.Join(root, "b.txt")}, ""},
+		{"too many paths", "1", []any{filepath.Join(root, "a.go"), filepath.Join(root, "b.txt")}, "Too many file_paths: 2 (maximum is 1)"},
+		{"too many matches", "2", []any{filepath.Join(root, "**", "*.go")}, "Too many files matched by file_paths: 3 (maximum is 2)"},
+		{"disabled", "0", []any{filepath.Join(root, "**", "*.go")}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{}
+			s := newTestServer(t, client, map[string]string{"DEEPSEEK_ALLOWED_FILE_PATHS": root, "DEEPSEEK_MAX_FILE_PATHS": tt.limit})
+			result := callTool(t, s.handleAskDeepseek, map[string]any{"query": "review", "file_paths": tt.paths})
+			if tt.wantError == "" {
+				if result.IsError || client.calls() != 1 {
+					t.Errorf("request rejected: %s", resultText(result))
+				}
+				return
+			}
+			if !result.IsError || !strings.Contains(resultText(result), tt.wantError) {
+				t.Errorf("result = %q, want error %q", resultText(result), tt.wantError)
+			}
+			if client.calls() != 0 {
+				t.Errorf("got %d requests, want none", client.calls())
+			}
+		})
+	}
+}
+
+func TestAskGlobWithoutAllowedPaths(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"small.go": "package small", "big.go": "package big\n" + strings.Repeat("// padding\n", 20)}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := &mockDeepseekClient{}
+	s := newTestServer(t, client, map[string]string{"DEEPSEEK_MAX_FILE_SIZE": "100"})
+	unrestricted := *s.config()
+	unrestricted.AllowedFilePaths = nil
+	s.configPtr.Store(&unrestricted)
+
+	text := resultText(callTool(t, s.handleAskDeepseek, map[string]any{
+		"query": "review", "file_paths": []any{filepath.Join(dir, "*.go")},
+	}))
+	if client.calls() != 1 {
+		t.Fatalf("got %d requests, want 1: %s", client.calls(), text)
+	}
+	messages := client.requests[0].Messages
+	prompt := messages[len(messages)-1].Content
+	if !strings.Contains(prompt, "package small") {
+		t.Errorf("prompt %q does not contain the file matched under the pattern's base directory", prompt)
+	}
+	if strings.Contains(prompt, "package big") || !strings.Contains(text, "too large") {
+		t.Errorf("the file over the maximum size was not validated: prompt %q, result %q", prompt, text)
+	}
+}
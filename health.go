@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// handleDeepseekHealth handles requests to the deepseek_health tool
+func (s *DeepseekServer) handleDeepseekHealth(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.logger.Info("Reporting server health")
+
+	var formattedContent strings.Builder
+	formattedContent.WriteString("# DeepSeek MCP Server Health\n\n")
+
+	if s.config().BreakerThreshold <= 0 {
+		formattedContent.WriteString("**Circuit Breaker:** disabled\n")
+	} else {
+		state, failures := s.breaker.State()
+		formattedContent.WriteString(fmt.Sprintf("**Circuit Breaker:** %s\n", state))
+		formattedContent.WriteString(fmt.Sprintf("**Consecutive Failures:** %d (opens at %d, cooldown %s)\n",
+			failures, s.config().BreakerThreshold, s.config().BreakerCooldown))
+	}
+	formattedContent.WriteString(fmt.Sprintf("**In-flight Requests:** %d\n", len(s.active.IDs())))
+	formattedContent.WriteString(fmt.Sprintf("**Queued API Requests:** %d\n", s.limiter.Waiting()))
+	formattedContent.WriteString(fmt.Sprintf("**Empty Responses:** %d (%d recovered by retry)\n",
+		s.emptyResponses.Load(), s.emptyRecoveries.Load()))
+
+	return mcp.NewToolResultText(formattedContent.String()), nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// mockDeepseekClient is a DeepseekAPI that records the requests it receives, along with the
+// request body fields of their contexts, and answers them with respond
+type mockDeepseekClient struct {
+	mu           sync.Mutex
+	requests     []deepseek.ChatCompletionRequest
+	bodyFields   []map[string]any
+	respond      func(request *deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error)
+	models       *deepseek.APIModels
+	modelsErr    error
+	balance      *deepseek.BalanceResponse
+	balanceErr   error
+	balanceCalls int
+}
+
+func (m *mockDeepseekClient) CreateChatCompletion(ctx context.Context, request *deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+	m.mu.Lock()
+	m.requests = append(m.requests, *request)
+	m.bodyFields = append(m.bodyFields, requestBodyFieldsFromContext(ctx))
+	respond := m.respond
+	m.mu.Unlock()
+	if respond == nil {
+		return textResponse("ok"), nil
+	}
+	return respond(request)
+}
+
+func (m *mockDeepseekClient) ListAllModels(ctx context.Context) (*deepseek.APIModels, error) {
+	if m.models == nil && m.modelsErr == nil {
+		return &deepseek.APIModels{}, nil
+	}
+	return m.models, m.modelsErr
+}
+
+func (m *mockDeepseekClient) GetBalance(ctx context.Context) (*deepseek.BalanceResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.balanceCalls++
+	return m.balance, m.balanceErr
+}
+
+// calls returns the number of completion requests received so far
+func (m *mockDeepseekClient) calls() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.requests)
+}
+
+// textResponse returns a completion with a single choice holding content
+func textResponse(content string) *deepseek.ChatCompletionResponse {
+	return &deepseek.ChatCompletionResponse{
+		Model: "deepseek-chat",
+		Choices: []deepseek.Choice{{
+			Message:      deepseek.Message{Role: deepseek.ChatMessageRoleAssistant, Content: content},
+			FinishReason: "stop",
+		}},
+	}
+}
+
+// newTestConfig loads the configuration from env on top of a test API key
+func newTestConfig(t *testing.T, env map[string]string) *Config {
+	t.Helper()
+	t.Setenv("DEEPSEEK_API_KEY", "test-key")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig() error = %v", err)
+	}
+	return cfg
+}
+
+// newTestServer creates a server answering through client, without discovering models
+func newTestServer(t *testing.T, client DeepseekAPI, env map[string]string) *DeepseekServer {
+	t.Helper()
+	cfg := newTestConfig(t, env)
+	s := &DeepseekServer{
+		client:    client,
+		logger:    NewLogger("error"),
+		pages:     newResponsePageStore(continuationTokenTTL),
+		truncated: newTruncatedResponseStore(continuationTokenTTL),
+		fileCache: newFileCache(cfg.FileCacheMaxBytes),
+		summaries: newSummaryCache(),
+		limiter:   newRequestLimiter(cfg.MaxConcurrentRequests),
+		active:    newActiveRequests(),
+		breaker:   newCircuitBreaker(0, 0, NewLogger("error")),
+		balance:   newBalanceGuard(),
+		usage:     &usageLedger{},
+	}
+	s.configPtr.Store(cfg)
+	return s
+}
+
+// callTool calls a tool handler with args and fails the test if it returns a Go error
+func callTool(t *testing.T, handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), args map[string]any) *mcp.CallToolResult {
+	t.Helper()
+	var req mcp.CallToolRequest
+	req.Params.Arguments = args
+	result, err := handler(context.Background(), req)
+	if err != nil {
+		t.Fatalf("handler returned error %v", err)
+	}
+	return result
+}
+
+// resultText joins the text blocks of a tool result
+func resultText(result *mcp.CallToolResult) string {
+	var parts []string
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			parts = append(parts, text.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// recordingLogger is a Logger that keeps the messages logged to it
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) record(level, format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, level+" "+fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) Debug(format string, args ...interface{}) {
+	l.record("DEBUG", format, args...)
+}
+
+func (l *recordingLogger) Info(format string, args ...interface{}) {
+	l.record("INFO", format, args...)
+}
+
+func (l *recordingLogger) Warn(format string, args ...interface{}) {
+	l.record("WARN", format, args...)
+}
+
+func (l *recordingLogger) Error(format string, args ...interface{}) {
+	l.record("ERROR", format, args...)
+}
+
+// contains reports whether a logged message contains text
+func (l *recordingLogger) contains(text string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, message := range l.messages {
+		if strings.Contains(message, text) {
+			return true
+		}
+	}
+	return false
+}
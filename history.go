@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// historyMessage is one prior turn of a conversation loaded with history_file
+type historyMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// parseHistory parses conversation history, either a JSON array of {role, content} messages
+// or an object with such an array under "messages". The turns must alternate between user
+// and assistant, starting with user and ending with assistant, so that the current query
+// follows as the next user turn.
+func parseHistory(data []byte) ([]deepseek.ChatCompletionMessage, error) {
+	var messages []historyMessage
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		var wrapper struct {
+			Messages []historyMessage `json:"messages"`
+		}
+		if err := json.Unmarshal(trimmed, &wrapper); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		messages = wrapper.Messages
+	} else if err := json.Unmarshal(trimmed, &messages); err != nil {
+		return nil, fmt.Errorf("invalid JSON, expected an array of {role, content} messages: %w", err)
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("history contains no messages")
+	}
+
+	history := make([]deepseek.ChatCompletionMessage, 0, len(messages))
+	for i, message := range messages {
+		expected := deepseek.ChatMessageRoleUser
+		if i%2 == 1 {
+			expected = deepseek.ChatMessageRoleAssistant
+		}
+		switch message.Role {
+		case deepseek.ChatMessageRoleUser, deepseek.ChatMessageRoleAssistant:
+		case "":
+			return nil, fmt.Errorf("messages[%d] has no role", i)
+		default:
+			return nil, fmt.Errorf("messages[%d] has unsupported role %q; only %q and %q are allowed",
+				i, message.Role, deepseek.ChatMessageRoleUser, deepseek.ChatMessageRoleAssistant)
+		}
+		if message.Role != expected {
+			return nil, fmt.Errorf("messages[%d] has role %q, expected %q: turns must alternate, starting with %q",
+				i, message.Role, expected, deepseek.ChatMessageRoleUser)
+		}
+		if strings.TrimSpace(message.Content) == "" {
+			return nil, fmt.Errorf("messages[%d] has empty content", i)
+		}
+		history = append(history, deepseek.ChatCompletionMessage{Role: message.Role, Content: message.Content})
+	}
+	if last := history[len(history)-1]; last.Role != deepseek.ChatMessageRoleAssistant {
+		return nil, fmt.Errorf("history must end with an %q message, since the query is the next %q turn",
+			deepseek.ChatMessageRoleAssistant, deepseek.ChatMessageRoleUser)
+	}
+	return history, nil
+}
+
+// readHistoryFile reads conversation history from a JSON file within the allowed file paths,
+// rejecting history estimated to exceed MaxHistoryTokens
+func (s *DeepseekServer) readHistoryFile(cfg *Config, path string) ([]deepseek.ChatCompletionMessage, error) {
+	if cfg.DisableFileAccess {
+		return nil, fmt.Errorf("%w: %s", errFileAccessDisabled, path)
+	}
+	path, err := expandPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.AllowedFilePaths) > 0 && !isPathAllowed(path, cfg.AllowedFilePaths) {
+		return nil, fmt.Errorf("%w: %s. Allowed roots are: %s", errPathNotAllowed, path, strings.Join(cfg.AllowedFilePaths, ", "))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("file not found or not accessible: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("path is a directory, not a file: %s", path)
+	}
+	if info.Size() > cfg.MaxFileSize {
+		return nil, fmt.Errorf("file is too large: %s (%s, maximum %s)", path,
+			humanReadableSize(info.Size()), humanReadableSize(cfg.MaxFileSize))
+	}
+
+	content, err := s.fileCache.Read(path)
+	if err != nil {
+		return nil, err
+	}
+	history, err := parseHistory(content)
+	if err != nil {
+		return nil, fmt.Errorf("malformed history file %s: %w", path, err)
+	}
+
+	if cfg.MaxHistoryTokens > 0 {
+		var text strings.Builder
+		for _, message := range history {
+			text.WriteString(message.Content)
+		}
+		if tokens := deepseek.EstimateTokenCount(text.String()).EstimatedTokens; tokens > cfg.MaxHistoryTokens {
+			return nil, fmt.Errorf("history in %s is too long: an estimated %d tokens (maximum %d). "+
+				"Drop or summarize earlier turns", path, tokens, cfg.MaxHistoryTokens)
+		}
+	}
+	return history, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestParseHistory(t *testing.T) {
+	turns := []deepseek.ChatCompletionMessage{
+		{Role: deepseek.ChatMessageRoleUser, Content: "What is Go?"},
+		{Role: deepseek.ChatMessageRoleAssistant, Content: "A language."},
+	}
+	tests := []struct {
+		name    string
+		data    string
+		want    []deepseek.ChatCompletionMessage
+		wantErr string
+	}{
+		{
+			name: "array",
+			data: `[{"role":"user","content":"What is Go?"},{"role":"assistant","content":"A language."}]`,
+			want: turns,
+		},
+		{
+			name: "messages object",
+			data: ` {"messages":[{"role":"user","content":"What is Go?"},{"role":"assistant","content":"A language."}]}`,
+			want: turns,
+		},
+		{name: "invalid JSON", data: `[{"role":`, wantErr: "invalid JSON"},
+		{name: "empty", data: `[]`, wantErr: "no messages"},
+		{name: "missing role", data: `[{"content":"hi"}]`, wantErr: "messages[0] has no role"},
+		{name: "system role", data: `[{"role":"system","content":"hi"}]`, wantErr: `unsupported role "system"`},
+		{name: "not alternating", data: `[{"role":"user","content":"a"},{"role":"user","content":"b"}]`, wantErr: `messages[1] has role "user", expected "assistant"`},
+		{name: "starts with assistant", data: `[{"role":"assistant","content":"a"}]`, wantErr: `messages[0] has role "assistant", expected "user"`},
+		{name: "empty content", data: `[{"role":"user","content":" "},{"role":"assistant","content":"b"}]`, wantErr: "messages[0] has empty content"},
+		{name: "ends with user", data: `[{"role":"user","content":"a"},{"role":"assistant","content":"b"},{"role":"user","content":"c"}]`, wantErr: "must end with an \"assistant\" message"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHistory([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseHistory() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseHistory() = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAskHistoryFile(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	history := `[{"role":"user","content":"What is Go?"},{"role":"assistant","content":"A language."}]`
+	for _, path := range []string{filepath.Join(dir, "history.json"), filepath.Join(outside, "history.json")} {
+		if err := os.WriteFile(path, []byte(history), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name      string
+		path      string
+		maxTokens string
+		wantRoles []string
+		wantError string
+	}{
+		{
+			name:      "history before the query",
+			path:      filepath.Join(dir, "history.json"),
+			wantRoles: []string{"system", "user", "assistant", "user"},
+		},
+		{name: "outside the allowed paths", path: filepath.Join(outside, "history.json"), wantError: "file path is not allowed"},
+		{name: "directory", path: dir, wantError: "path is a directory"},
+		{name: "too long", path: filepath.Join(dir, "history.json"), maxTokens: "1", wantError: "too long"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"DEEPSEEK_ALLOWED_FILE_PATHS": dir}
+			if tt.maxTokens != "" {
+				env["DEEPSEEK_MAX_HISTORY_TOKENS"] = tt.maxTokens
+			}
+			client := &mockDeepseekClient{}
+			s := newTestServer(t, client, env)
+			result := callTool(t, s.handleAskDeepseek, map[string]any{"query": "And Rust?", "history_file": tt.path})
+			if tt.wantError != "" {
+				if !result.IsError || !strings.Contains(resultText(result), tt.wantError) {
+					t.Errorf("result = %q, want error %q", resultText(result), tt.wantError)
+				}
+				return
+			}
+			var roles []string
+			for _, message := range client.requests[0].Messages {
+				roles = append(roles, message.Role)
+			}
+			if !reflect.DeepEqual(roles, tt.wantRoles) {
+				t.Errorf("message roles = %v, want %v", roles, tt.wantRoles)
+			}
+		})
+	}
+}
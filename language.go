@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// wellKnownFilenames maps extensionless or special file names to their language identifiers
+var wellKnownFilenames = map[string]string{
+	"dockerfile":     "dockerfile",
+	"containerfile":  "dockerfile",
+	"makefile":       "makefile",
+	"gnumakefile":    "makefile",
+	"jenkinsfile":    "groovy",
+	"vagrantfile":    "ruby",
+	"gemfile":        "ruby",
+	"rakefile":       "ruby",
+	"podfile":        "ruby",
+	"brewfile":       "ruby",
+	"procfile":       "yaml",
+	"cmakelists.txt": "cmake",
+	".bashrc":        "bash",
+	".bash_profile":  "bash",
+	".zshrc":         "zsh",
+	".profile":       "sh",
+	".gitignore":     "gitignore",
+	".dockerignore":  "gitignore",
+	".env":           "dotenv",
+}
+
+// shebangInterpreters maps interpreter names found in shebang lines to language identifiers
+var shebangInterpreters = map[string]string{
+	"sh":      "sh",
+	"bash":    "bash",
+	"zsh":     "zsh",
+	"ksh":     "sh",
+	"dash":    "sh",
+	"fish":    "fish",
+	"python":  "python",
+	"python2": "python",
+	"python3": "python",
+	"node":    "javascript",
+	"nodejs":  "javascript",
+	"deno":    "typescript",
+	"ts-node": "typescript",
+	"ruby":    "ruby",
+	"perl":    "perl",
+	"php":     "php",
+	"lua":     "lua",
+	"Rscript": "r",
+	"pwsh":    "powershell",
+	"awk":     "awk",
+	"tclsh":   "tcl",
+	"groovy":  "groovy",
+	"elixir":  "elixir",
+	"escript": "erlang",
+	"julia":   "julia",
+}
+
+// detectLanguage returns the language identifier for a file, falling back to well-known
+// file names and shebang sniffing when the extension is not recognized
+func detectLanguage(path string, content []byte) string {
+	if language := getLanguageFromPath(path); language != "text" {
+		return language
+	}
+
+	if language := getLanguageFromFilename(path); language != "" {
+		return language
+	}
+
+	if language := getLanguageFromShebang(content); language != "" {
+		return language
+	}
+
+	return "text"
+}
+
+// getLanguageFromFilename recognizes well-known file names such as Dockerfile or Makefile
+func getLanguageFromFilename(path string) string {
+	base := strings.ToLower(filepath.Base(path))
+	if language, ok := wellKnownFilenames[base]; ok {
+		return language
+	}
+
+	// Handle common variants like Dockerfile.dev or Makefile.linux
+	for _, prefix := range []string{"dockerfile", "makefile", "jenkinsfile"} {
+		if strings.HasPrefix(base, prefix+".") {
+			return wellKnownFilenames[prefix]
+		}
+	}
+	return ""
+}
+
+// getLanguageFromShebang infers the language from a shebang line such as "#!/usr/bin/env python3"
+func getLanguageFromShebang(content []byte) string {
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return ""
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	if !scanner.Scan() {
+		return ""
+	}
+	fields := strings.Fields(strings.TrimPrefix(scanner.Text(), "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		// Skip env flags such as "-S" to reach the interpreter name
+		interpreter = ""
+		for _, field := range fields[1:] {
+			if !strings.HasPrefix(field, "-") && !strings.Contains(field, "=") {
+				interpreter = filepath.Base(field)
+				break
+			}
+		}
+	}
+	if interpreter == "" {
+		return ""
+	}
+
+	if language, ok := shebangInterpreters[interpreter]; ok {
+		return language
+	}
+
+	// Handle versioned interpreters like python3.12 or perl5
+	trimmed := strings.TrimRight(interpreter, "0123456789.")
+	if language, ok := shebangInterpreters[trimmed]; ok {
+		return language
+	}
+	return ""
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		content string
+		want    string
+	}{
+		{"extension", "src/main.go", "", "go"},
+		{"extension wins over shebang", "tool.py", "#!/bin/bash\n", "python"},
+		{"dockerfile", "build/Dockerfile", "", "dockerfile"},
+		{"dockerfile variant", "Dockerfile.dev", "", "dockerfile"},
+		{"makefile case-insensitive", "GNUmakefile", "", "makefile"},
+		{"makefile variant", "Makefile.linux", "", "makefile"},
+		{"dotfile", "home/.bashrc", "", "bash"},
+		{"cmake", "CMakeLists.txt", "", "cmake"},
+		{"shebang", "run", "#!/bin/sh\necho hi\n", "sh"},
+		{"env shebang", "run", "#!/usr/bin/env python3\n", "python"},
+		{"env flags", "run", "#!/usr/bin/env -S NODE_OPTIONS=--x node --flag\n", "javascript"},
+		{"versioned interpreter", "run", "#!/usr/local/bin/python3.12\n", "python"},
+		{"versioned perl", "run", "#!/usr/bin/perl5\n", "perl"},
+		{"unknown interpreter", "run", "#!/usr/bin/unknownlang\n", "text"},
+		{"env without interpreter", "run", "#!/usr/bin/env -i\n", "text"},
+		{"empty shebang", "run", "#!\n", "text"},
+		{"no shebang", "run", "echo hi\n", "text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detectLanguage(tt.path, []byte(tt.content)); got != tt.want {
+				t.Errorf("detectLanguage(%q, %q) = %q, want %q", tt.path, tt.content, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// gzipExcerptScanFactor bounds the decompressed bytes read to excerpt a large gzip file, as a
+// multiple of the maximum file size, so that a decompression bomb cannot keep a request busy
+const gzipExcerptScanFactor = 10
+
+// truncatesLargeFiles reports whether a file over the maximum size is included as an excerpt of
+// its head and tail instead of being skipped. Gzip files are excerpted from their decompressed
+// content, whether the file itself or only its decompressed content is over the maximum size.
+func (c *Config) truncatesLargeFiles() bool {
+	return c != nil && c.TruncateLargeFiles
+}
+
+// largeFileExcerptBytes returns the size of the excerpt kept of a file over the maximum size
+func (c *Config) largeFileExcerptBytes() int64 {
+	if c.LargeFileExcerptBytes > 0 {
+		return c.LargeFileExcerptBytes
+	}
+	if c.MaxFileSize > 0 {
+		return c.MaxFileSize
+	}
+	return 10 * 1024 * 1024
+}
+
+// gzipExcerptScanBytes returns the most decompressed bytes read to excerpt a large gzip file
+func (c *Config) gzipExcerptScanBytes() int64 {
+	limit := int64(10 * 1024 * 1024)
+	if c.MaxFileSize > 0 {
+		limit = c.MaxFileSize
+	}
+	return gzipExcerptScanFactor * limit
+}
+
+// readLargeFileExcerpt reads the head and tail of a file over the maximum size, returning the
+// excerpt and the number of bytes omitted. Only the excerpt is read from disk, so arbitrarily
+// large logs can be attached. The file type is still checked as for any other file.
+func readLargeFileExcerpt(path string, cfg *Config) ([]byte, int64, error) {
+	if err := checkFileType(path, cfg); err != nil {
+		return nil, 0, fmt.Errorf("file validation failed: %w", err)
+	}
+
+	release := acquireOpenFile()
+	defer release()
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+
+	size, limit := info.Size(), cfg.largeFileExcerptBytes()
+	if size <= limit {
+		content, err := io.ReadAll(f)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+		return content, 0, nil
+	}
+	head, err := readAtMost(f, 0, limit/2)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	tail, err := readAtMost(f, size-(limit-limit/2), limit-limit/2)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	content, omitted := joinExcerpt(head, tail, size)
+	return content, omitted, nil
+}
+
+// readGzipExcerpt decompresses a gzip file and returns the head and tail of its content, along
+// with the number of bytes omitted. Only the excerpt is kept in memory while the file is
+// decompressed, and decompression stops once the content exceeds gzipExcerptScanBytes.
+func readGzipExcerpt(path string, cfg *Config) ([]byte, int64, error) {
+	if err := checkFileType(path, cfg); err != nil {
+		return nil, 0, fmt.Errorf("file validation failed: %w", err)
+	}
+
+	release := acquireOpenFile()
+	defer release()
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	defer f.Close()
+	return excerptGzip(f, cfg.largeFileExcerptBytes(), cfg.gzipExcerptScanBytes())
+}
+
+// excerptGzip decompresses gzip content from r, keeping the head and tail of the decompressed
+// content within limit bytes in total. Content over scanLimit bytes is rejected as too large.
+func excerptGzip(r io.Reader, limit, scanLimit int64) ([]byte, int64, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decompress gzip content: %w", err)
+	}
+	defer gz.Close()
+	reader := io.LimitReader(gz, scanLimit+1)
+
+	headLimit, tailLimit := limit/2, limit-limit/2
+	var head, tail []byte
+	var size int64
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := reader.Read(buf)
+		chunk := buf[:n]
+		size += int64(n)
+		if size > scanLimit {
+			return nil, 0, fmt.Errorf("%w: decompressed content exceeds %s, too large to excerpt",
+				errFileTooLarge, humanReadableSize(scanLimit))
+		}
+		if room := headLimit - int64(len(head)); room > 0 {
+			take := min(room, int64(len(chunk)))
+			head = append(head, chunk[:take]...)
+			chunk = chunk[take:]
+		}
+		tail = append(tail, chunk...)
+		if int64(len(tail)) > 2*tailLimit {
+			tail = append(tail[:0], tail[int64(len(tail))-tailLimit:]...)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to decompress gzip content: %w", err)
+		}
+	}
+
+	if size <= limit {
+		return append(head, tail...), 0, nil
+	}
+	if int64(len(tail)) > tailLimit {
+		tail = tail[int64(len(tail))-tailLimit:]
+	}
+	content, omitted := joinExcerpt(head, tail, size)
+	return content, omitted, nil
+}
+
+// readAtMost reads up to n bytes of f at offset, returning fewer if the file ends first
+func readAtMost(f *os.File, offset, n int64) ([]byte, error) {
+	buf := make([]byte, n)
+	read, err := f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf[:read], nil
+}
+
+// excerptContent keeps the head and tail of content larger than limit, returning the excerpt
+// and the number of bytes omitted. Content within the limit is returned unchanged.
+func excerptContent(content []byte, limit int64) ([]byte, int64) {
+	size := int64(len(content))
+	if size <= limit {
+		return content, 0
+	}
+	return joinExcerpt(content[:limit/2], content[size-(limit-limit/2):], size)
+}
+
+// joinExcerpt joins the head and tail of a file of the given size with a marker giving the number
+// of bytes omitted between them. The cuts are moved to line boundaries where possible, and
+// otherwise to character boundaries, so that no UTF-8 sequence is split.
+func joinExcerpt(head, tail []byte, size int64) ([]byte, int64) {
+	if i := bytes.LastIndexByte(head, '\n'); i > 0 {
+		head = head[:i+1]
+	} else {
+		head = trimPartialRuneEnd(head)
+	}
+	if i := bytes.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	} else {
+		tail = trimPartialRuneStart(tail)
+	}
+
+	omitted := size - int64(len(head)) - int64(len(tail))
+	marker := fmt.Sprintf("... %d bytes omitted ...\n", omitted)
+	if len(head) > 0 && head[len(head)-1] != '\n' {
+		marker = "\n" + marker
+	}
+	excerpt := make([]byte, 0, len(head)+len(marker)+len(tail))
+	excerpt = append(append(append(excerpt, head...), marker...), tail...)
+	return excerpt, omitted
+}
+
+// trimPartialRuneEnd drops an incomplete UTF-8 sequence from the end of b
+func trimPartialRuneEnd(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// trimPartialRuneStart drops the continuation bytes of a UTF-8 sequence cut at the start of b
+func trimPartialRuneStart(b []byte) []byte {
+	for i := 0; i < len(b) && i < utf8.UTFMax; i++ {
+		if utf8.RuneStart(b[i]) {
+			return b[i:]
+		}
+	}
+	return b
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestExcerptContent(t *testing.T) {
+	lines := "line 1\nline 2\nline 3\nline 4\nline 5\nline 6\n"
+	tests := []struct {
+		name        string
+		content     string
+		limit       int64
+		want        string
+		wantOmitted int64
+	}{
+		{"within the limit", lines, 100, lines, 0},
+		{"cut at line boundaries", lines, 20, "line 1\n... 28 bytes omitted ...\nline 6\n", 28},
+		{"no line boundaries", "abcdefghijklmnop", 8, "abcd\n... 8 bytes omitted ...\nmnop", 8},
+		{"UTF-8 sequence not split", "ééééé", 5, "é\n... 6 bytes omitted ...\né", 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, omitted := excerptContent([]byte(tt.content), tt.limit)
+			if string(got) != tt.want || omitted != tt.wantOmitted {
+				t.Errorf("excerptContent() = %q, %d, want %q, %d", got, omitted, tt.want, tt.wantOmitted)
+			}
+			if !utf8.Valid(got) {
+				t.Errorf("excerpt %q is not valid UTF-8", got)
+			}
+		})
+	}
+}
+
+func TestAskLargeFileExcerpt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.txt")
+	var log strings.Builder
+	log.WriteString("START\n")
+	for log.Len() < 4096 {
+		log.WriteString("a routine log line\n")
+	}
+	log.WriteString("CRASH\n")
+	if err := os.WriteFile(path, []byte(log.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		truncate    string
+		wantExcerpt bool
+	}{
+		{"skipped by default", "false", false},
+		{"head and tail included", "true", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+				return textResponse("ok"), nil
+			}}
+			s := newTestServer(t, client, map[string]string{
+				"DEEPSEEK_ALLOWED_FILE_PATHS":       dir,
+				"DEEPSEEK_MAX_FILE_SIZE":            "1024",
+				"DEEPSEEK_TRUNCATE_LARGE_FILES":     tt.truncate,
+				"DEEPSEEK_LARGE_FILE_EXCERPT_BYTES": "256",
+			})
+			result := callTool(t, s.handleAskDeepseek, map[string]any{"query": "why did it crash?", "file_paths": []any{path}})
+			if result.IsError {
+				t.Fatalf("request failed: %s", resultText(result))
+			}
+			var contents []string
+			for _, message := range client.requests[0].Messages {
+				contents = append(contents, message.Content)
+			}
+			prompt := strings.Join(contents, "\n")
+			got := strings.Contains(prompt, "START") && strings.Contains(prompt, "CRASH") && strings.Contains(prompt, "bytes omitted")
+			if got != tt.wantExcerpt {
+				t.Errorf("prompt holds the excerpt = %v, want %v:\n%s", got, tt.wantExcerpt, prompt)
+			}
+			if skipped := strings.Contains(resultText(result), "Skipped (too large)"); skipped == tt.wantExcerpt {
+				t.Errorf("file skipped as too large = %v, want %v", skipped, !tt.wantExcerpt)
+			}
+			if strings.Count(prompt, "a routine log line") > 256/len("a routine log line\n") {
+				t.Errorf("prompt holds more than the excerpt:\n%s", prompt)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Errors returned by requestLimiter.Acquire when a request is rejected instead of waiting
+var (
+	errQueueFull    = errors.New("too many requests are waiting for a free request slot")
+	errQueueWaitMax = errors.New("gave up waiting for a free request slot")
+)
+
+// requestLimiter bounds the number of concurrent DeepSeek API requests. Requests beyond the
+// limit wait in FIFO order for a free slot; the wait can be bounded with withQueue.
+type requestLimiter struct {
+	slots   chan struct{}
+	queue   chan struct{} // Places for waiting requests, nil for no bound
+	maxWait time.Duration // Longest wait for a slot, 0 for no bound
+	waiting atomic.Int64
+	logger  Logger
+}
+
+// newRequestLimiter creates a limiter allowing up to max concurrent requests.
+// A max of zero or less disables limiting.
+func newRequestLimiter(max int) *requestLimiter {
+	if max <= 0 {
+		return &requestLimiter{}
+	}
+	return &requestLimiter{slots: make(chan struct{}, max)}
+}
+
+// withQueue bounds the requests waiting for a slot to depth, each waiting at most maxWait.
+// A depth or maxWait of zero or less leaves that bound off. Queued requests are logged.
+func (l *requestLimiter) withQueue(depth int, maxWait time.Duration, logger Logger) *requestLimiter {
+	if depth > 0 {
+		l.queue = make(chan struct{}, depth)
+	}
+	l.maxWait = max(maxWait, 0)
+	l.logger = logger
+	return l
+}
+
+// Acquire takes a free slot, waiting in the queue while all slots are taken. It fails when the
+// queue is full, the maximum wait is exceeded or the context is cancelled.
+func (l *requestLimiter) Acquire(ctx context.Context) error {
+	if l.slots == nil {
+		return nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	if l.queue != nil {
+		select {
+		case l.queue <- struct{}{}:
+			defer func() { <-l.queue }()
+		default:
+			return fmt.Errorf("%w: %d requests running and %d waiting", errQueueFull, cap(l.slots), cap(l.queue))
+		}
+	}
+	waiting := l.waiting.Add(1)
+	defer l.waiting.Add(-1)
+	if l.logger != nil {
+		l.logger.Info("Request queued: all %d request slots are busy, %d request(s) waiting", cap(l.slots), waiting)
+	}
+
+	var expired <-chan time.Time
+	if l.maxWait > 0 {
+		timer := time.NewTimer(l.maxWait)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	start := time.Now()
+	select {
+	case l.slots <- struct{}{}:
+		if l.logger != nil {
+			l.logger.Debug("Request left the queue after %v", time.Since(start).Round(time.Millisecond))
+		}
+		return nil
+	case <-expired:
+		return fmt.Errorf("%w after %v", errQueueWaitMax, l.maxWait)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Release frees a slot acquired with Acquire
+func (l *requestLimiter) Release() {
+	if l.slots == nil {
+		return
+	}
+	<-l.slots
+}
+
+// Waiting returns the number of requests waiting for a slot
+func (l *requestLimiter) Waiting() int {
+	return int(l.waiting.Load())
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRequestLimiterDisabled(t *testing.T) {
+	for _, max := range []int{0, -1} {
+		l := newRequestLimiter(max).withQueue(1, time.Millisecond, nil)
+		for i := 0; i < 100; i++ {
+			if err := l.Acquire(context.Background()); err != nil {
+				t.Fatalf("newRequestLimiter(%d).Acquire() error = %v", max, err)
+			}
+		}
+		l.Release()
+	}
+}
+
+func TestRequestLimiterRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		depth   int
+		maxWait time.Duration
+		queued  int // Requests already waiting when the request is made
+		cancel  bool
+		wantErr error
+	}{
+		{"queue full", 1, 0, 1, false, errQueueFull},
+		{"wait exceeded", 2, 10 * time.Millisecond, 0, false, errQueueWaitMax},
+		{"cancelled", 0, 0, 0, true, context.Canceled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRequestLimiter(1).withQueue(tt.depth, tt.maxWait, NewLogger("error"))
+			if err := l.Acquire(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			// Fill the queue with requests that wait until the test ends
+			waitCtx, stopWaiting := context.WithCancel(context.Background())
+			defer stopWaiting()
+			for i := 0; i < tt.queued; i++ {
+				go func() { _ = l.Acquire(waitCtx) }()
+			}
+			waitFor(t, func() bool { return l.Waiting() == tt.queued })
+
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancel {
+				cancel()
+			}
+			defer cancel()
+			if err := l.Acquire(ctx); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Acquire() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequestLimiterQueuedRequestGetsReleasedSlot(t *testing.T) {
+	l := newRequestLimiter(2).withQueue(1, time.Second, NewLogger("error"))
+	for i := 0; i < 2; i++ {
+		if err := l.Acquire(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- l.Acquire(context.Background()) }()
+	waitFor(t, func() bool { return l.Waiting() == 1 })
+
+	// The queue of depth 1 is now full
+	if err := l.Acquire(context.Background()); !errors.Is(err, errQueueFull) {
+		t.Errorf("Acquire() with a full queue error = %v, want %v", err, errQueueFull)
+	}
+
+	l.Release()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatalf("queued Acquire() error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued request did not get the released slot")
+	}
+	if l.Waiting() != 0 {
+		t.Errorf("Waiting() = %d after the queued request left, want 0", l.Waiting())
+	}
+}
+
+func TestMaxConcurrentRequestsConfig(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", 0},
+		{"8", 8},
+	}
+	for _, tt := range tests {
+		cfg := newTestConfig(t, map[string]string{"DEEPSEEK_MAX_CONCURRENT_REQUESTS": tt.value})
+		if cfg.MaxConcurrentRequests != tt.want {
+			t.Errorf("DEEPSEEK_MAX_CONCURRENT_REQUESTS=%q gives %d, want %d", tt.value, cfg.MaxConcurrentRequests, tt.want)
+		}
+	}
+}
+
+// waitFor polls cond until it holds, failing the test after a second
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within a second")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // LogLevel represents the severity level for logging
@@ -99,13 +100,29 @@ func (l *SimpleLogger) Error(format string, args ...interface{}) {
 	}
 }
 
-// log formats and outputs a log message
+// log formats and outputs a log message, with secrets redacted
 func (l *SimpleLogger) log(level LogLevel, format string, args ...interface{}) {
 	timestamp := time.Now().Format("2006-01-02 15:04:05.000")
-	message := fmt.Sprintf(format, args...)
+	message := redactSecrets(fmt.Sprintf(format, args...))
 	l.logger.Printf("[%s] [%s] %s", timestamp, level.String(), message)
 }
 
+// truncateLogField shortens a value for logging to at most maxChars characters, noting the
+// original length. Truncation is UTF-8 safe. A maxChars of 0 or less disables truncation.
+func truncateLogField(value string, maxChars int) string {
+	if maxChars <= 0 {
+		return value
+	}
+	runeCount := 0
+	for i := range value {
+		if runeCount == maxChars {
+			return fmt.Sprintf("%s... (%d chars total)", value[:i], utf8.RuneCountInString(value))
+		}
+		runeCount++
+	}
+	return value
+}
+
 // Context key for the logger
 type contextKey string
 
@@ -2,27 +2,42 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"os"
 	"strings"
 
+	"github.com/joho/godotenv"
 	_ "github.com/joho/godotenv/autoload"
 	mcp "github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
+// serverVersion is the version reported to MCP clients and in the startup summary
+const serverVersion = "1.0.0"
+
 // main is the entry point for the application.
 // It sets up the MCP server with the appropriate handlers and starts it.
 func main() {
 	// Define command-line flags for configuration override
 	deepseekModelFlag := flag.String("deepseek-model", "", "DeepSeek model name (overrides env var)")
 	deepseekSystemPromptFlag := flag.String("deepseek-system-prompt", "", "System prompt (overrides env var)")
-	deepseekTemperatureFlag := flag.Float64("deepseek-temperature", -1, "Temperature setting (0.0-1.0, overrides env var)")
+	deepseekTemperatureFlag := flag.Float64("deepseek-temperature", -1, "Temperature setting (0.0-2.0, overrides env var)")
 	deepseekAllowedFilePathsFlag := flag.String("deepseek-allowed-file-paths", "", "Comma-separated list of allowed file paths for file operations (overrides env var)")
 	logLevelFlag := flag.String("log-level", "", "Log level (debug, info, warn, error), overrides DEEPSEEK_LOG_LEVEL")
+	dumpConfigFlag := flag.Bool("dump-config", false, "Print the effective configuration in dotenv format with secrets masked, then exit")
 	flag.Parse()
 
+	overrides := flagOverrides{
+		model:            *deepseekModelFlag,
+		systemPrompt:     *deepseekSystemPromptFlag,
+		temperature:      *deepseekTemperatureFlag,
+		allowedFilePaths: *deepseekAllowedFilePathsFlag,
+		logLevel:         *logLevelFlag,
+	}
+
 	// Create configuration from environment variables
 	config, err := NewConfig()
 	if err != nil {
@@ -39,43 +54,25 @@ func main() {
 
 	// Override with command-line flags if provided
 	// Model ID validation will happen after deepseekServer is initialized
-	if *deepseekModelFlag != "" {
-		logger.Info("Overriding DeepSeek model with flag value: %s", *deepseekModelFlag)
-		config.DeepseekModel = *deepseekModelFlag
-	}
-	if *deepseekSystemPromptFlag != "" {
-		logger.Info("Overriding DeepSeek system prompt with flag value")
-		config.DeepseekSystemPrompt = *deepseekSystemPromptFlag
-	}
-
-	// Override temperature if provided and valid
-	if *deepseekTemperatureFlag >= 0 {
-		// Validate temperature is within range
-		if *deepseekTemperatureFlag > 1.0 {
-			logger.Error("Invalid temperature value: %v. Must be between 0.0 and 1.0", *deepseekTemperatureFlag)
-			handleStartupError(ctx, fmt.Errorf("invalid temperature: %v", *deepseekTemperatureFlag))
-			return
-		}
-		logger.Info("Overriding DeepSeek temperature with flag value: %v", *deepseekTemperatureFlag)
-		config.DeepseekTemperature = float32(*deepseekTemperatureFlag)
-	}
-
-	// Override allowed file paths if provided
-	if *deepseekAllowedFilePathsFlag != "" {
-		paths := strings.Split(*deepseekAllowedFilePathsFlag, ",")
-		logger.Info("Overriding DeepSeek allowed file paths with flag values: %v", paths)
-		config.AllowedFilePaths = paths
+	if err := overrides.apply(config, logger); err != nil {
+		handleStartupError(ctx, err)
+		return
 	}
-
-	// Override log level if provided
-	if *logLevelFlag != "" {
-		logger.Info("Overriding log level with flag value: %s", *logLevelFlag)
-		config.LogLevel = *logLevelFlag
+	if overrides.logLevel != "" {
 		// Re-create logger with the new level
 		logger = NewLogger(config.LogLevel)
 		ctx = context.WithValue(context.Background(), loggerKey, logger)
 	}
 
+	// Print the effective configuration and exit if requested
+	if *dumpConfigFlag {
+		if err := config.WriteDotenv(os.Stdout); err != nil {
+			logger.Error("Failed to dump configuration: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Store config in context for error handler to access
 	ctx = context.WithValue(ctx, configKey, config)
 
@@ -83,7 +80,7 @@ func main() {
 	// NewHandlerRegistry is a constructor that doesn't return an error
 
 	// Create the MCP server instance
-	srv := server.NewMCPServer("deepseek", "1.0.0")
+	srv := server.NewMCPServer("deepseek", serverVersion)
 
 	// Create and register the DeepSeek server (now passing the created srv)
 	deepseekServer, err := setupDeepseekServer(ctx, srv, config)
@@ -92,8 +89,10 @@ func main() {
 		return
 	}
 
-	// Validate the effective model ID (from config, possibly overridden by flag)
-	if err := deepseekServer.ValidateModelID(config.DeepseekModel); err != nil {
+	// Validate the effective model ID (from config, possibly overridden by flag),
+	// falling back to another model unless strict validation is enabled
+	activeModel, err := deepseekServer.ResolveActiveModel()
+	if err != nil {
 		logger.Error("Effective model ID validation failed: %v", err)
 		// Use a more specific error message for startup failure
 		startupErr := fmt.Errorf("effective model ID \"%s\" is invalid: %w", config.DeepseekModel, err)
@@ -101,15 +100,85 @@ func main() {
 		handleStartupError(ctx, startupErr)           // Pass the specific startup error
 		return
 	}
+	config.DeepseekModel = activeModel
+	logger.Info("Active model: %s", config.DeepseekModel)
+
+	logStartupSummary(logger, config, "stdio")
+
+	// Reload re-reads .env and the environment, then applies the same command-line overrides
+	deepseekServer.loadConfig = func() (*Config, error) {
+		if err := godotenv.Overload(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read .env: %w", err)
+		}
+		next, err := NewConfig()
+		if err != nil {
+			return nil, err
+		}
+		if err := overrides.apply(next, logger); err != nil {
+			return nil, err
+		}
+		return next, nil
+	}
+	go deepseekServer.reloadOnSignal(ctx)
 
 	// Start the MCP server
 	logger.Info("Starting DeepSeek MCP server via Stdio")
-	if err := server.ServeStdio(srv); err != nil {
+	err = server.ServeStdio(srv)
+	deepseekServer.Close()
+	if err != nil {
 		logger.Error("Server error: %v", err)
 		os.Exit(1)
 	}
 }
 
+// flagOverrides holds the configuration overrides given on the command line
+type flagOverrides struct {
+	model            string
+	systemPrompt     string
+	temperature      float64 // Negative when not given
+	allowedFilePaths string
+	logLevel         string
+}
+
+// apply overrides the settings of config that were given on the command line
+func (f flagOverrides) apply(config *Config, logger Logger) error {
+	if f.model != "" {
+		logger.Info("Overriding DeepSeek model with flag value: %s", f.model)
+		config.DeepseekModel = f.model
+	}
+	if f.systemPrompt != "" {
+		logger.Info("Overriding DeepSeek system prompt with flag value")
+		config.DeepseekSystemPrompt = f.systemPrompt
+	}
+
+	// Override temperature if provided. Values outside a model's valid range are clamped per request.
+	if f.temperature >= 0 {
+		if f.temperature > float64(maxTemperature) {
+			logger.Warn("Temperature %v is above the maximum of %v and will be clamped", f.temperature, maxTemperature)
+		}
+		logger.Info("Overriding DeepSeek temperature with flag value: %v", f.temperature)
+		config.DeepseekTemperature = float32(f.temperature)
+		config.TemperatureSet = true
+	}
+
+	// Override allowed file paths if provided
+	if f.allowedFilePaths != "" {
+		paths, err := expandPaths(strings.Split(f.allowedFilePaths, ","))
+		if err != nil {
+			return fmt.Errorf("invalid -deepseek-allowed-file-paths: %w", err)
+		}
+		logger.Info("Overriding DeepSeek allowed file paths with flag values: %v", paths)
+		config.AllowedFilePaths = paths
+	}
+
+	// Override log level if provided
+	if f.logLevel != "" {
+		logger.Info("Overriding log level with flag value: %s", f.logLevel)
+		config.LogLevel = f.logLevel
+	}
+	return nil
+}
+
 // setupDeepseekServer creates and registers a DeepSeek server
 func setupDeepseekServer(ctx context.Context, srv *server.MCPServer, config *Config) (*DeepseekServer, error) {
 	loggerValue := ctx.Value(loggerKey)
@@ -124,38 +193,202 @@ func setupDeepseekServer(ctx context.Context, srv *server.MCPServer, config *Con
 		return nil, fmt.Errorf("failed to create DeepSeek server: %w", err)
 	}
 
+	// Register only the tools permitted by the configuration
+	var enabledTools, disabledTools []string
+	addTool := func(tool mcp.Tool, handler server.ToolHandlerFunc) {
+		if !config.IsToolEnabled(tool.Name) {
+			disabledTools = append(disabledTools, tool.Name)
+			return
+		}
+		srv.AddTool(tool, redactToolErrors(withToolName(tool.Name, handler)))
+		enabledTools = append(enabledTools, tool.Name)
+	}
+
 	// Wrap the server with logger middleware
 
 	// Register the wrapped server
 	// Define and register tools
 	askTool := mcp.NewTool("deepseek_ask",
 		mcp.WithDescription("Use DeepSeek's AI model to ask about complex coding problems."),
-		mcp.WithString("query", mcp.Required(), mcp.Description("The coding problem or question for DeepSeek AI, including any relevant code.")),
+		mcp.WithString("query", mcp.Description("The coding problem or question for DeepSeek AI, including any relevant code. Required unless continuation_token is set.")),
 		mcp.WithString("model", mcp.Description("Optional: Specific DeepSeek model to use (e.g., deepseek-chat, deepseek-coder). Overrides default configuration.")),
 		mcp.WithString("systemPrompt", mcp.Description("Optional: Custom system prompt to guide the AI's behavior for this request. Overrides default configuration.")),
-		mcp.WithArray("file_paths", mcp.Description("Optional: Paths to files to include in the request context. Content will be appended to the query."), mcp.Items(map[string]any{"type": "string"})),
-		mcp.WithBoolean("json_mode", mcp.Description("Optional: Enable JSON mode for structured JSON responses. Set to true when expecting JSON output.")),
+		mcp.WithString("systemPromptFile", mcp.Description("Optional: Path to a file containing the system prompt (max 64KB, within the allowed file paths). Used only when systemPrompt is empty.")),
+		mcp.WithString("preset", mcp.Description("Optional: Name of a system prompt preset (see deepseek_presets). An explicit systemPrompt takes precedence.")),
+		mcp.WithString("mode", mcp.Description("Optional: Built-in system prompt profile: 'code' for terse, correct, idiomatic code, 'prose' for explanatory prose. Defaults to the configured system prompt, which is the code profile unless DEEPSEEK_SYSTEM_PROMPT is set. A preset, systemPromptFile or systemPrompt takes precedence."), mcp.Enum(PromptModeCode, PromptModeProse)),
+		mcp.WithArray("file_paths", mcp.Description("Optional: Paths or glob patterns (e.g., src/**/*.go) of files to include in the request context. Content will be appended to the query."), mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithArray("inline_files", mcp.Description("Optional: Files given by content instead of path, for clients that cannot share paths. Formatted like file_paths and subject to the same size limits; language is detected from name when omitted."),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name":     map[string]any{"type": "string", "description": "File name or path shown to the model"},
+					"language": map[string]any{"type": "string", "description": "Optional language for syntax highlighting"},
+					"content":  map[string]any{"type": "string", "description": "File content"},
+				},
+				"required": []string{"name", "content"},
+			})),
+		mcp.WithString("history_file", mcp.Description("Optional: Path to a JSON file of prior messages, [{\"role\": \"user\"|\"assistant\", \"content\": \"...\"}], sent before the query. Turns must alternate starting with user and ending with assistant; the file must be within the allowed file paths.")),
+		mcp.WithArray("examples", mcp.Description("Optional: Few-shot examples (up to 10) sent as earlier user/assistant turns before the query, to steer the output format."),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"input":  map[string]any{"type": "string", "description": "Example user input"},
+					"output": map[string]any{"type": "string", "description": "Expected assistant output for the input"},
+				},
+				"required": []string{"input", "output"},
+			})),
+		mcp.WithArray("tools", mcp.Description("Optional: Function definitions the model may call. When it does, the result is JSON with the tool_calls (id, name, arguments) and any content instead of text."),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"name":        map[string]any{"type": "string", "description": "Function name (letters, digits, underscores or dashes)"},
+					"description": map[string]any{"type": "string", "description": "What the function does"},
+					"parameters":  map[string]any{"type": "object", "description": "JSON schema of the function arguments"},
+				},
+				"required": []string{"name"},
+			})),
+		mcp.WithArray("allow_file_types", mcp.Description("Optional: Extra MIME types (e.g. application/pdf) allowed in file_paths for this request only. Requires DEEPSEEK_ALLOW_PER_REQUEST_TYPE_OVERRIDE; the allowed directories still apply."), mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithString("order", mcp.Description("Optional: Order of file_paths in the prompt: 'as-given', 'alphabetical', 'size-asc' or 'size-desc'. Defaults to DEEPSEEK_FILE_ORDER."), mcp.Enum(FileOrderAsGiven, FileOrderAlphabetical, FileOrderSizeAsc, FileOrderSizeDesc)),
+		mcp.WithString("response_format", mcp.Description("Optional: 'text' (default), 'json_object' for a JSON object response, or 'json_schema' for a response matching json_schema."), mcp.Enum(ResponseFormatText, ResponseFormatJSONObject, ResponseFormatJSONSchema)),
+		mcp.WithString("json_schema", mcp.Description("Optional: JSON schema (as a JSON object string) the response must match. Required with response_format 'json_schema'.")),
+		mcp.WithBoolean("json_mode", mcp.Description("Deprecated: Use response_format 'json_object' instead. Setting it to true is an alias for that.")),
+		mcp.WithString("user", mcp.Description("Optional: End-user identifier sent to the API for abuse monitoring and usage attribution. Overrides the configured default.")),
+		mcp.WithNumber("seed", mcp.Description("Optional: Integer seed (0-2147483647) for best-effort deterministic sampling. The seed and system fingerprint are shown in a response footer.")),
+		mcp.WithNumber("n", mcp.Description("Optional: Number of completion choices to generate (default 1, limited by DEEPSEEK_MAX_CHOICES). Each choice is billed.")),
+		mcp.WithBoolean("return_all_choices", mcp.Description("Optional: Return all generated choices under numbered headers instead of only the first. Defaults to false.")),
+		mcp.WithNumber("self_consistency", mcp.Description("Optional: Send the request this many times in parallel and combine the answers, for more reliable reasoning (default 1, limited by DEEPSEEK_MAX_SELF_CONSISTENCY). Each sample is billed. Cannot be combined with n, tools, raw_response or allow_escalation.")),
+		mcp.WithString("self_consistency_mode", mcp.Description("Optional: How self_consistency samples are combined: 'synthesize' (default) asks the model to merge them into one answer, following the majority where they disagree; 'all' returns every answer under numbered headers.")),
+		mcp.WithBoolean("confirm_cost", mcp.Description("Optional: Send the request even if its estimated cost exceeds DEEPSEEK_COST_WARNING_THRESHOLD. Defaults to false.")),
+		mcp.WithBoolean("raw_response", mcp.Description("Optional: Return the full, unmodified API response as JSON (usage, finish_reason, all choices) for debugging. May contain large content.")),
+		mcp.WithNumber("max_response_chars", mcp.Description("Optional: Split responses longer than this many characters into parts. The first part is returned with a continuation_token for the rest. 0 disables splitting.")),
+		mcp.WithString("continuation_token", mcp.Description("Optional: Token from a previous paginated response. Returns the next part; all other parameters are ignored.")),
+		mcp.WithString("continue_from", mcp.Description("Optional: Token from a response that was cut off at the model's output limit. Continues that response and returns the full stitched answer; other parameters are ignored.")),
+		mcp.WithString("request_id", mcp.Description("Optional: Caller-chosen ID for this request, which can be passed to deepseek_cancel to cancel it while in flight.")),
+		mcp.WithString("include_tree", mcp.Description("Optional: Directory whose file tree (names only, respecting .gitignore and allowed file types) is prepended to the query to show the project structure.")),
+		mcp.WithNumber("tree_depth", mcp.Description("Optional: Maximum depth of the include_tree listing (1-10, default 3).")),
+		mcp.WithString("response_language", mcp.Description("Optional: ISO 639-1 code of the language to respond in (e.g. 'ja', 'de', 'pl'). Appended to the system prompt, so it composes with systemPrompt and preset.")),
+		mcp.WithNumber("soft_max_tokens", mcp.Description("Optional: Token budget for a bounded-cost answer that still ends cleanly. Sets max_tokens to the budget and asks the model to conclude within it; an answer that is cut off anyway can be continued with continue_from. Takes precedence over the concise verbosity cap.")),
+		mcp.WithString("verbosity", mcp.Description("Optional: Answer length: 'concise' asks for a direct answer without preamble and caps the completion at 1024 tokens for non-reasoning models, 'detailed' asks for a thorough explanation, 'normal' (default) adds no instruction. Composes with response_language and focus."), mcp.Enum(VerbosityConcise, VerbosityNormal, VerbosityDetailed)),
+		mcp.WithString("focus", mcp.Description("Optional: Narrow instruction placed after the file context, e.g. 'Focus only on the authentication logic'.")),
+		mcp.WithNumber("reserve_output_tokens", mcp.Description("Optional: Tokens of the model's context window to keep free for the response. Files are cut to fit the rest (truncated unless context_compression says otherwise) and the request is rejected if the prompt alone leaves too little. The input/output split is reported. Requires a model with a known context window.")),
+		mcp.WithString("context_compression", mcp.Description("Optional: What to do with files that exceed DEEPSEEK_MAX_TOTAL_FILE_SIZE: 'none' skips them (default), 'truncate' cuts them to the remaining budget, 'summarize' includes a summary made by DEEPSEEK_SUMMARY_MODEL."), mcp.Enum(ContextCompressionNone, ContextCompressionTruncate, ContextCompressionSummarize)),
+		mcp.WithBoolean("strip_comments", mcp.Description("Optional: Remove comments and collapse blank lines in included files to save tokens. String literals are preserved. Defaults to false.")),
+		mcp.WithBoolean("include_file_timestamps", mcp.Description("Optional: Add the last-modified time (RFC3339, UTC) and size on disk of each file in file_paths to its heading, e.g. when debugging stale code. Defaults to false.")),
+		mcp.WithBoolean("auto_include_readme", mcp.Description("Optional: Also include the nearest README.md of the directory of each file in file_paths, searching parent directories up to the allowed root, as labeled project context. Each README is included once. Defaults to false.")),
+		mcp.WithBoolean("allow_escalation", mcp.Description("Optional: If the response is a refusal or shorter than DEEPSEEK_ESCALATION_MIN_CHARS, retry with the next model of DEEPSEEK_ESCALATION_MODELS, within DEEPSEEK_MAX_ESCALATIONS and DEEPSEEK_ESCALATION_MAX_COST. The response names the model that answered. Defaults to false.")),
+		mcp.WithBoolean("dedupe_content", mcp.Description("Optional: Include files with identical content only once, keeping the first. Paths resolving to the same file are always included once. Defaults to false.")),
+		mcp.WithArray("workspace_files", mcp.Description("Optional: Paths of files to change. The model is asked for a git-applyable unified diff touching only these files, which is returned verbatim. Paths in the diff are relative to the deepest directory containing all of them. Cannot be combined with file_paths or inline_files."), mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithArray("commands", mcp.Description("Optional: Commands (e.g. \"go vet ./...\") whose exit code, stdout and stderr are included as context. Each must start with a prefix in DEEPSEEK_COMMAND_ALLOWLIST; commands are run directly, never through a shell. Requires DEEPSEEK_ALLOW_COMMAND_CONTEXT=true."), mcp.Items(map[string]any{"type": "string"})),
+		mcp.WithString("output_file", mcp.Description("Optional: Write the response to this file instead of returning it, and return a short confirmation with the path and size. The file must be within DEEPSEEK_WRITABLE_FILE_PATHS.")),
+		mcp.WithString("output_mode", mcp.Description("Optional: How output_file is written: 'overwrite' (default) replaces the file, 'append' adds to it."), mcp.Enum(OutputModeOverwrite, OutputModeAppend)),
+		mcp.WithBoolean("attach_as_resources", mcp.Description("Optional: Also return each included file as a separate resource content block with its MIME type, after the answer, so clients can show sources apart from the response. Files are still sent to the model inline. Defaults to false.")),
+		mcp.WithBoolean("include_citations", mcp.Description("Optional: Ask the model to cite the files and line ranges it relied on in a Citations section. Citations of files that were not provided or of lines outside them are flagged. Defaults to false.")),
+		mcp.WithBoolean("anonymize_paths", mcp.Description("Optional: Replace the paths of included files in the prompt with opaque labels such as file1.go, so internal directory and file names are not sent. Labels in the response, including citations, are turned back into the real paths. Defaults to false.")),
+		mcp.WithBoolean("include_context_stats", mcp.Description("Optional: Append a table of the estimated tokens of the system prompt, query and each included file, with the total and the headroom left for the response in the model's context window. Defaults to false.")),
+		mcp.WithBoolean("include_usage", mcp.Description("Optional: Append token usage (prompt, cached, completion) to the response. For reasoning models the completion is split into estimated reasoning and answer tokens. Defaults to false.")),
+		mcp.WithBoolean("report_progress", mcp.Description("Optional: Send MCP progress notifications while file_paths are read (files read of total, bytes so far), before the API call starts. Requires the client to send a progress token. Defaults to false.")),
+		mcp.WithBoolean("trim_chatter", mcp.Description("Optional: Strip conversational preamble (\"Sure! Here's the code:\") and postamble (\"Let me know if...\") from the response, keeping the answer and code blocks intact. Defaults to false.")),
+		mcp.WithBoolean("show_diff", mcp.Description("Optional: Append unified diffs between the fenced code blocks of the query and the revised versions of them in the response, to show exactly what the suggested fix changes. Defaults to false.")),
+		mcp.WithBoolean("include_metadata", mcp.Description("Optional: Append the model that actually served the request and its system fingerprint, to audit which model version answered. Defaults to false.")),
+		mcp.WithBoolean("include_timing", mcp.Description("Optional: Append a timing breakdown (file reading, token estimate, API round-trip) to the response. Defaults to false.")),
+		mcp.WithString("output_format", mcp.Description("Optional: Response format, either 'markdown' (default) or 'plain'. Plain strips markdown syntax for clients that render plain text."), mcp.Enum(OutputFormatMarkdown, OutputFormatPlain)),
 	)
-	srv.AddTool(askTool, deepseekServer.handleAskDeepseek)
+	addTool(askTool, deepseekServer.handleAskDeepseek)
 
 	modelsTool := mcp.NewTool("deepseek_models",
-		mcp.WithDescription("List available DeepSeek models with descriptions."),
+		mcp.WithDescription("List available DeepSeek models with descriptions, marking the default model and any configured recommendations."),
 		// No parameters for this tool
 	)
-	srv.AddTool(modelsTool, deepseekServer.handleDeepseekModels)
+	addTool(modelsTool, deepseekServer.handleDeepseekModels)
 
 	balanceTool := mcp.NewTool("deepseek_balance",
 		mcp.WithDescription("Check your DeepSeek API account balance."),
 		// No parameters for this tool
 	)
-	srv.AddTool(balanceTool, deepseekServer.handleDeepseekBalance)
+	addTool(balanceTool, deepseekServer.handleDeepseekBalance)
+
+	cancelTool := mcp.NewTool("deepseek_cancel",
+		mcp.WithDescription("Cancel an in-flight deepseek_ask request by its request_id."),
+		mcp.WithString("request_id", mcp.Required(), mcp.Description("The request_id given to the deepseek_ask call to cancel.")),
+	)
+	addTool(cancelTool, deepseekServer.handleDeepseekCancel)
+
+	usageTool := mcp.NewTool("deepseek_usage",
+		mcp.WithDescription("Show token usage recorded by this server, by day and model."),
+		mcp.WithString("since", mcp.Description("Optional: Start date (YYYY-MM-DD), inclusive. Defaults to 30 days ago.")),
+		mcp.WithString("until", mcp.Description("Optional: End date (YYYY-MM-DD), inclusive. Defaults to today.")),
+	)
+	addTool(usageTool, deepseekServer.handleDeepseekUsage)
+
+	allowedPathsTool := mcp.NewTool("deepseek_allowed_paths",
+		mcp.WithDescription("List the directories this server accepts files from, whether each is readable, and a shallow listing of each."),
+		mcp.WithNumber("depth", mcp.Description("Optional: Depth of each root listing (1-10, default 1).")),
+		mcp.WithNumber("max_entries", mcp.Description("Optional: Maximum entries listed per root (1-500, default 50).")),
+	)
+	addTool(allowedPathsTool, deepseekServer.handleDeepseekAllowedPaths)
+
+	healthTool := mcp.NewTool("deepseek_health",
+		mcp.WithDescription("Show the health of this server: circuit breaker state, in-flight requests and empty responses."),
+		// No parameters for this tool
+	)
+	addTool(healthTool, deepseekServer.handleDeepseekHealth)
+
+	if config.AllowReloadTool {
+		reloadTool := mcp.NewTool("deepseek_reload",
+			mcp.WithDescription("Reload the server configuration from the environment and .env without restarting. Reports the changed settings and those that need a restart."),
+			// No parameters for this tool
+		)
+		addTool(reloadTool, deepseekServer.handleDeepseekReload)
+	}
 
 	tokenEstimateTool := mcp.NewTool("deepseek_token_estimate",
-		mcp.WithDescription("Estimate the number of tokens in a given text or file content."),
-		mcp.WithString("text", mcp.Description("Text to estimate token count for. Use this or file_path.")),
-		mcp.WithString("file_path", mcp.Description("Path to a file to estimate token count for. Use this or text.")),
+		mcp.WithDescription("Estimate the number of tokens in a given text, file or directory."),
+		mcp.WithString("text", mcp.Description("Text to estimate token count for. Use this, file_path or dir_path.")),
+		mcp.WithString("file_path", mcp.Description("Path to a file to estimate token count for. Use this, text or dir_path.")),
+		mcp.WithString("dir_path", mcp.Description("Path to a directory to estimate. Returns a ranked table of the largest files and a total, respecting .gitignore and allowed file types.")),
+		mcp.WithNumber("max_depth", mcp.Description("Optional: Maximum directory depth scanned with dir_path (1-10, default 5).")),
+		mcp.WithString("format", mcp.Description("Optional: 'markdown' (default) for a readable report, or 'json' for {estimated_tokens, byte_size, char_count, tokens_per_char, source_type, source}. With dir_path, json returns the totals and every counted file."), mcp.Enum(EstimateFormatMarkdown, EstimateFormatJSON)),
+	)
+	addTool(tokenEstimateTool, deepseekServer.handleTokenEstimate)
+
+	batchTool := mcp.NewTool("deepseek_batch",
+		mcp.WithDescription("Run several independent DeepSeek queries concurrently and return their results aligned by index."),
+		mcp.WithArray("queries", mcp.Required(), mcp.Description("The queries to run. Each item has a required 'query' and optional 'model' and 'systemPrompt'."),
+			mcp.Items(map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"query":        map[string]any{"type": "string", "description": "The question for DeepSeek AI."},
+					"model":        map[string]any{"type": "string", "description": "Optional model ID for this query."},
+					"systemPrompt": map[string]any{"type": "string", "description": "Optional system prompt for this query."},
+				},
+				"required": []string{"query"},
+			})),
 	)
-	srv.AddTool(tokenEstimateTool, deepseekServer.handleTokenEstimate)
+	addTool(batchTool, deepseekServer.handleDeepseekBatch)
+
+	presetsTool := mcp.NewTool("deepseek_presets",
+		mcp.WithDescription("List available system prompt presets for the deepseek_ask tool."),
+		// No parameters for this tool
+	)
+	addTool(presetsTool, deepseekServer.handleDeepseekPresets)
+
+	sqlTool := mcp.NewTool("deepseek_sql",
+		mcp.WithDescription("Translate a natural-language question into a SQL query grounded in the provided schema."),
+		mcp.WithString("question", mcp.Required(), mcp.Description("The question to answer with a SQL query.")),
+		mcp.WithString("schema", mcp.Description("Optional: Database schema (e.g., CREATE TABLE statements) to ground the query in.")),
+		mcp.WithString("schema_file", mcp.Description("Optional: Path to a file containing the database schema. Appended to 'schema' if both are given.")),
+		mcp.WithString("dialect", mcp.Description("Optional: SQL dialect to target (postgres, mysql or sqlite). Defaults to postgres."), mcp.Enum("postgres", "mysql", "sqlite")),
+		mcp.WithBoolean("explain", mcp.Description("Optional: Include a short explanation of how the query works.")),
+	)
+	addTool(sqlTool, deepseekServer.handleDeepseekSQL)
+
+	logger.Info("Enabled tools: %v", enabledTools)
+	if len(disabledTools) > 0 {
+		logger.Info("Disabled tools: %v", disabledTools)
+	}
+	if config.DisableFileAccess {
+		logger.Info("File access is disabled; file_paths and other file parameters will be rejected")
+	}
 
 	for _, p := range Prompts {
 		prompt := mcp.NewPrompt(p.Name,
@@ -176,25 +409,50 @@ func setupDeepseekServer(ctx context.Context, srv *server.MCPServer, config *Con
 		humanReadableSize(config.MaxFileSize),
 		config.AllowedFileTypes,
 		config.AllowedFilePaths)
+	if config.FileTemplate != "" {
+		logger.Info("Using custom file template for included files")
+	}
 
 	// Log a truncated version of the system prompt for security/brevity
-	promptPreview := config.DeepseekSystemPrompt
-	if len(promptPreview) > 50 {
-		// Use proper UTF-8 safe truncation
-		runeCount := 0
-		for i := range promptPreview {
-			runeCount++
-			if runeCount > 50 {
-				promptPreview = promptPreview[:i] + "..."
-				break
-			}
-		}
-	}
+	promptPreview := truncateLogField(config.DeepseekSystemPrompt, 50)
 	logger.Info("Using system prompt: %s", promptPreview)
+	if config.SystemPromptPrefix != "" {
+		logger.Debug("System prompt guardrail prefix is active: %s", truncateLogField(config.SystemPromptPrefix, config.LogMaxFieldChars))
+	}
+	if config.SystemPromptSuffix != "" {
+		logger.Debug("System prompt guardrail suffix is active: %s", truncateLogField(config.SystemPromptSuffix, config.LogMaxFieldChars))
+	}
 
 	return deepseekServer, nil
 }
 
+// logStartupSummary logs the effective settings once at startup as a single line,
+// so operators can confirm the server came up as intended
+func logStartupSummary(logger Logger, config *Config, transport string) {
+	fileAccess := "enabled"
+	if config.DisableFileAccess {
+		fileAccess = "disabled"
+	}
+	maxTotalFileSize := "unlimited"
+	if config.MaxTotalFileSize > 0 {
+		maxTotalFileSize = humanReadableSize(config.MaxTotalFileSize)
+	}
+	fileCache := "disabled"
+	if config.FileCacheMaxBytes > 0 {
+		fileCache = humanReadableSize(config.FileCacheMaxBytes)
+	}
+	usageLedger := "memory"
+	if config.UsageLedgerPath != "" {
+		usageLedger = config.UsageLedgerPath
+	}
+	logger.Info("Startup summary: version=%s transport=%s model=%s temperature=%v file_access=%s "+
+		"max_file_size=%s max_total_file_size=%s file_cache=%s allowed_paths=%d retries=%d timeout=%v "+
+		"max_concurrent_requests=%d usage_ledger=%s offline=%t",
+		serverVersion, transport, config.DeepseekModel, config.DeepseekTemperature, fileAccess,
+		humanReadableSize(config.MaxFileSize), maxTotalFileSize, fileCache, len(config.AllowedFilePaths),
+		config.MaxRetries, config.HTTPTimeout, config.MaxConcurrentRequests, usageLedger, config.OfflineMode)
+}
+
 // handleStartupError handles initialization errors by setting up an error server
 func handleStartupError(ctx context.Context, err error) {
 	// Safely extract logger from context
@@ -204,7 +462,7 @@ func handleStartupError(ctx context.Context, err error) {
 		// Fallback to a new logger if type assertion fails
 		logger = NewLogger("error")
 	}
-	errorMsg := err.Error()
+	errorMsg := redactSecrets(err.Error())
 
 	logger.Error("Initialization error: %v", err)
 
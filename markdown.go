@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Output formats supported by the deepseek_ask tool
+const (
+	OutputFormatMarkdown = "markdown"
+	OutputFormatPlain    = "plain"
+)
+
+var (
+	mdHeadingRe     = regexp.MustCompile(`^#{1,6}\s+`)
+	mdListItemRe    = regexp.MustCompile(`^\s*[-*+]\s+`)
+	mdOrderedItemRe = regexp.MustCompile(`^\s*(\d+)[.)]\s+`)
+	mdBlockquoteRe  = regexp.MustCompile(`^\s*>\s?`)
+	mdRuleRe        = regexp.MustCompile(`^\s*([-*_])(\s*[-*_]){2,}\s*$`)
+	mdTableSepRe    = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+	mdImageRe       = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLinkRe        = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+	mdInlineCodeRe  = regexp.MustCompile("`([^`]+)`")
+	mdBoldRe        = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	mdItalicStarRe  = regexp.MustCompile(`\*(\S(?:[^*]*?\S)?)\*`)
+	mdItalicUnderRe = regexp.MustCompile(`(^|[^\w])_(\S(?:[^_]*?\S)?)_([^\w]|$)`)
+	mdStrikeRe      = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+)
+
+// isValidOutputFormat reports whether the given output format is supported
+func isValidOutputFormat(format string) bool {
+	return format == OutputFormatMarkdown || format == OutputFormatPlain
+}
+
+// markdownToPlainText converts a markdown document into plain text.
+// Fenced code blocks are turned into indented blocks with their contents preserved verbatim,
+// emphasis markers are removed, and nested lists are flattened.
+func markdownToPlainText(md string) string {
+	var out []string
+	inFence := false
+	fenceMarker := ""
+
+	for _, line := range strings.Split(md, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		// Handle opening and closing code fences
+		if marker := fenceMarkerOf(trimmed); marker != "" {
+			if !inFence {
+				inFence = true
+				fenceMarker = marker
+				continue
+			}
+			if strings.HasPrefix(trimmed, fenceMarker) && strings.Trim(trimmed, fenceMarker[:1]) == "" {
+				inFence = false
+				fenceMarker = ""
+				continue
+			}
+		}
+
+		// Preserve code block contents verbatim, indented by four spaces
+		if inFence {
+			if line == "" {
+				out = append(out, "")
+			} else {
+				out = append(out, "    "+line)
+			}
+			continue
+		}
+
+		switch {
+		case mdRuleRe.MatchString(line):
+			out = append(out, "")
+			continue
+		case strings.Contains(line, "|") && mdTableSepRe.MatchString(line):
+			continue
+		}
+
+		line = mdHeadingRe.ReplaceAllString(trimmed, "")
+		if mdBlockquoteRe.MatchString(line) {
+			line = mdBlockquoteRe.ReplaceAllString(line, "")
+		}
+		if mdListItemRe.MatchString(line) {
+			line = "- " + mdListItemRe.ReplaceAllString(line, "")
+		} else if mdOrderedItemRe.MatchString(line) {
+			line = mdOrderedItemRe.ReplaceAllString(line, "$1. ")
+		}
+
+		out = append(out, stripInlineMarkdown(line))
+	}
+
+	// Only blank lines are trimmed at the start, keeping the indentation of a leading code block
+	return strings.TrimRight(strings.TrimLeft(strings.Join(out, "\n"), "\n"), " \t\n")
+}
+
+// fenceMarkerOf returns the code fence marker (``` or ~~~) a line starts with, if any
+func fenceMarkerOf(line string) string {
+	for _, marker := range []string{"```", "~~~"} {
+		if strings.HasPrefix(line, marker) {
+			return marker
+		}
+	}
+	return ""
+}
+
+// stripInlineMarkdown removes inline markdown syntax such as emphasis, links and inline code
+func stripInlineMarkdown(line string) string {
+	// Protect inline code spans so their contents are not altered
+	var spans []string
+	line = mdInlineCodeRe.ReplaceAllStringFunc(line, func(m string) string {
+		spans = append(spans, mdInlineCodeRe.FindStringSubmatch(m)[1])
+		return fmt.Sprintf("\x00%d\x00", len(spans)-1)
+	})
+
+	line = mdImageRe.ReplaceAllString(line, "$1")
+	line = mdLinkRe.ReplaceAllString(line, "$1 ($2)")
+	line = mdBoldRe.ReplaceAllString(line, "$2")
+	line = mdStrikeRe.ReplaceAllString(line, "$1")
+	line = mdItalicStarRe.ReplaceAllString(line, "$1")
+	line = mdItalicUnderRe.ReplaceAllString(line, "$1$2$3")
+
+	for i, span := range spans {
+		line = strings.Replace(line, fmt.Sprintf("\x00%d\x00", i), span, 1)
+	}
+	return line
+}
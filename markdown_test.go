@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestMarkdownToPlainText(t *testing.T) {
+	tests := []struct {
+		name string
+		md   string
+		want string
+	}{
+		{"heading", "## Summary\nText", "Summary\nText"},
+		{"emphasis", "**bold**, *italic*, _under_ and ~~gone~~", "bold, italic, under and gone"},
+		{"inline code kept", "Use `**not bold**` here", "Use **not bold** here"},
+		{"link and image", "See [docs](https://x.dev) ![alt](img.png)", "See docs (https://x.dev) alt"},
+		{"nested lists", "* one\n  + two\n3) three", "- one\n- two\n3. three"},
+		{"blockquote", "> quoted *text*", "quoted text"},
+		{"rule", "above\n---\nbelow", "above\n\nbelow"},
+		{"table separator dropped", "| a | b |\n|---|:-:|\n| 1 | 2 |", "| a | b |\n| 1 | 2 |"},
+		{"code block verbatim", "```go\nx := **y**\n\n```\nafter", "    x := **y**\n\nafter"},
+		{"tilde fence with backticks inside", "~~~\n```\n~~~", "    ```"},
+		{"unterminated fence", "```\ncode", "    code"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := markdownToPlainText(tt.md); got != tt.want {
+				t.Errorf("markdownToPlainText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAskOutputFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{"markdown by default", "", "## Title\n\n**bold**"},
+		{"plain", OutputFormatPlain, "Title\n\nbold"},
+		{"invalid", "html", "Invalid output_format: html"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+				return textResponse("## Title\n\n**bold**"), nil
+			}}
+			s := newTestServer(t, client, nil)
+			args := map[string]any{"query": "q"}
+			if tt.format != "" {
+				args["output_format"] = tt.format
+			}
+			if text := resultText(callTool(t, s.handleAskDeepseek, args)); !strings.Contains(text, tt.want) {
+				t.Errorf("result = %q, want it to contain %q", text, tt.want)
+			}
+		})
+	}
+}
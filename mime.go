@@ -0,0 +1,152 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// MIME detection modes for Config.MimeDetection
+const (
+	MimeDetectionExtension = "extension" // Use the file extension only
+	MimeDetectionContent   = "content"   // Sniff the file content only
+	MimeDetectionBoth      = "both"      // Extension and content must agree
+)
+
+// isValidMimeDetection reports whether the given MIME detection mode is supported
+func isValidMimeDetection(mode string) bool {
+	switch mode {
+	case MimeDetectionExtension, MimeDetectionContent, MimeDetectionBoth:
+		return true
+	}
+	return false
+}
+
+// detectFileMimeType determines the MIME type of a file according to the detection mode.
+// In "both" mode an error is returned when the extension and the content disagree.
+// Gzip-compressed files are detected by their inner name and decompressed content.
+func detectFileMimeType(path, mode string) (string, error) {
+	extensionType := getMimeTypeFromPath(uncompressedPath(path))
+	if mode == "" || mode == MimeDetectionExtension {
+		return extensionType, nil
+	}
+
+	contentType, err := sniffMimeType(path)
+	if err != nil {
+		return "", err
+	}
+
+	if mode == MimeDetectionContent {
+		return contentType, nil
+	}
+
+	if !mimeTypesAgree(extensionType, contentType) {
+		return "", fmt.Errorf("file extension and content disagree: %s (extension: %s, content: %s)", path, extensionType, contentType)
+	}
+	return extensionType, nil
+}
+
+// sniffMimeType detects the MIME type of a file from its first 512 bytes, after decompression
+// for gzip-compressed files
+func sniffMimeType(path string) (string, error) {
+	release := acquireOpenFile()
+	defer release()
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file for content sniffing: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if isGzipPath(path) {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return "", fmt.Errorf("failed to decompress file for content sniffing: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	buf := make([]byte, 512)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", fmt.Errorf("failed to read file for content sniffing: %w", err)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(buf[:n]))
+	if err != nil {
+		return "application/octet-stream", nil
+	}
+	return mediaType, nil
+}
+
+// mimeTypesAgree reports whether an extension-derived and a sniffed MIME type are compatible.
+// Content sniffing cannot tell programming languages apart, so any textual extension type
+// agrees with any textual content type.
+func mimeTypesAgree(extensionType, contentType string) bool {
+	if extensionType == contentType {
+		return true
+	}
+	return isTextualMimeType(extensionType) && isTextualMimeType(contentType)
+}
+
+// isTextualMimeType reports whether a MIME type describes text content
+func isTextualMimeType(mimeType string) bool {
+	if strings.HasPrefix(mimeType, "text/") {
+		return true
+	}
+	switch mimeType {
+	case "application/json", "application/x-ipynb+json", "application/xml", "application/javascript", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// isMimeTypeAllowed checks if a MIME type is in the list of allowed types
+func isMimeTypeAllowed(mimeType string, allowedTypes []string) bool {
+	for _, allowedType := range allowedTypes {
+		if mimeType == allowedType {
+			return true
+		}
+	}
+	return false
+}
+
+// parseAllowedFileTypes validates MIME types requested with allow_file_types. Entries are trimmed
+// and normalized; wildcards and entries that are not a single type/subtype are rejected, since
+// types are matched exactly and such entries would never allow a file.
+func parseAllowedFileTypes(values []string) ([]string, error) {
+	types := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if strings.Contains(value, "*") {
+			return nil, fmt.Errorf("wildcard MIME type %q is not allowed", value)
+		}
+		mediaType, params, err := mime.ParseMediaType(value)
+		if err != nil || len(params) > 0 || !strings.Contains(mediaType, "/") {
+			return nil, fmt.Errorf("invalid MIME type %q", value)
+		}
+		types = append(types, mediaType)
+	}
+	return types, nil
+}
+
+// checkFileType verifies that the detected MIME type of a file is allowed by the config
+func checkFileType(path string, cfg *Config) error {
+	if cfg == nil || len(cfg.AllowedFileTypes) == 0 {
+		return nil
+	}
+
+	mimeType, err := detectFileMimeType(path, cfg.MimeDetection)
+	if err != nil {
+		return err
+	}
+	if !isMimeTypeAllowed(mimeType, cfg.AllowedFileTypes) {
+		return fmt.Errorf("%w: %s (type: %s)", errFileTypeNotAllowed, path, mimeType)
+	}
+	return nil
+}
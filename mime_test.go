@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pngHeader is the signature of a PNG image, sniffed as image/png
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// writeMimeFixtures writes files whose extension and content agree or disagree
+func writeMimeFixtures(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, _ = w.Write([]byte("package main\n"))
+	_ = w.Close()
+	fixtures := map[string][]byte{
+		"main.go":     []byte("package main\n"),
+		"image.png":   pngHeader,
+		"fake.txt":    pngHeader,
+		"main.go.gz":  gz.Bytes(),
+		"notes.md":    []byte("# Notes\n"),
+		"corrupt.gz":  []byte("not gzip"),
+		"data.json":   []byte(`{"a": 1}`),
+		"picture.svg": []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`),
+	}
+	for name, content := range fixtures {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestDetectFileMimeType(t *testing.T) {
+	dir := writeMimeFixtures(t)
+	tests := []struct {
+		file    string
+		mode    string
+		want    string
+		wantErr string
+	}{
+		{"main.go", "", "text/x-go", ""},
+		{"main.go", MimeDetectionExtension, "text/x-go", ""},
+		{"main.go", MimeDetectionContent, "text/plain", ""},
+		{"main.go", MimeDetectionBoth, "text/x-go", ""},
+		{"fake.txt", MimeDetectionExtension, "text/plain", ""},
+		{"fake.txt", MimeDetectionContent, "image/png", ""},
+		{"fake.txt", MimeDetectionBoth, "", "disagree"},
+		{"image.png", MimeDetectionBoth, "image/png", ""},
+		{"main.go.gz", MimeDetectionExtension, "text/x-go", ""},
+		{"main.go.gz", MimeDetectionBoth, "text/x-go", ""},
+		{"corrupt.gz", MimeDetectionContent, "", "decompress"},
+		{"notes.md", MimeDetectionBoth, "text/markdown", ""},
+		{"data.json", MimeDetectionBoth, "application/json", ""},
+		{"missing.txt", MimeDetectionContent, "", "failed to open"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file+" "+tt.mode, func(t *testing.T) {
+			got, err := detectFileMimeType(filepath.Join(dir, tt.file), tt.mode)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("detectFileMimeType() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("detectFileMimeType() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMimeTypesAgree(t *testing.T) {
+	tests := []struct {
+		extensionType, contentType string
+		want                       bool
+	}{
+		{"text/x-go", "text/plain", true},
+		{"application/json", "text/plain", true},
+		{"image/svg+xml", "text/xml", true},
+		{"image/png", "image/png", true},
+		{"text/plain", "image/png", false},
+		{"image/png", "application/octet-stream", false},
+	}
+	for _, tt := range tests {
+		if got := mimeTypesAgree(tt.extensionType, tt.contentType); got != tt.want {
+			t.Errorf("mimeTypesAgree(%q, %q) = %v, want %v", tt.extensionType, tt.contentType, got, tt.want)
+		}
+	}
+}
+
+func TestCheckFileType(t *testing.T) {
+	dir := writeMimeFixtures(t)
+	tests := []struct {
+		name    string
+		file    string
+		cfg     *Config
+		wantErr error
+	}{
+		{"no config", "fake.txt", nil, nil},
+		{"no allowed types", "fake.txt", &Config{}, nil},
+		{"allowed by extension", "fake.txt", &Config{AllowedFileTypes: []string{"text/plain"}}, nil},
+		{"disguised binary rejected by content", "fake.txt", &Config{AllowedFileTypes: []string{"text/plain"}, MimeDetection: MimeDetectionContent}, errFileTypeNotAllowed},
+		{"type not allowed", "image.png", &Config{AllowedFileTypes: []string{"text/plain"}}, errFileTypeNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkFileType(filepath.Join(dir, tt.file), tt.cfg); !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkFileType() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseAllowedFileTypes(t *testing.T) {
+	tests := []struct {
+		values  []string
+		want    []string
+		wantErr string
+	}{
+		{[]string{" application/x-ipynb+json ", "Text/X-Go"}, []string{"application/x-ipynb+json", "text/x-go"}, ""},
+		{[]string{"text/*"}, nil, "wildcard"},
+		{[]string{"*/*"}, nil, "wildcard"},
+		{[]string{"text"}, nil, "invalid MIME type"},
+		{[]string{"text/plain; charset=utf-8"}, nil, "invalid MIME type"},
+		{[]string{"  "}, nil, "invalid MIME type"},
+		{[]string{"text/plain", "not a type"}, nil, "invalid MIME type"},
+	}
+	for _, tt := range tests {
+		got, err := parseAllowedFileTypes(tt.values)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseAllowedFileTypes(%q) error = %v, want one containing %q", tt.values, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("parseAllowedFileTypes(%q) = %v, %v, want %v", tt.values, got, err, tt.want)
+		}
+	}
+}
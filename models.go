@@ -1,9 +1,17 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
+)
+
+// Intervals between attempts to discover the models after discovery failed at startup
+const (
+	modelRefreshInitialInterval = 30 * time.Second
+	modelRefreshMaxInterval     = 10 * time.Minute
 )
 
 // DeepseekModelInfo holds information about a DeepSeek model
@@ -41,6 +49,40 @@ func (s *DeepseekServer) ValidateModelID(modelID string) error {
 	return errors.New(sb.String())
 }
 
+// ResolveActiveModel validates the configured model and, unless strict validation is enabled,
+// falls back to the configured fallback model or the first available model when it is not served.
+// It returns the ID of the model that should be used.
+func (s *DeepseekServer) ResolveActiveModel() (string, error) {
+	return s.resolveModel(s.config())
+}
+
+// resolveModel resolves the model that should be used with the given configuration
+func (s *DeepseekServer) resolveModel(cfg *Config) (string, error) {
+	configured := cfg.DeepseekModel
+	err := s.ValidateModelID(configured)
+	if err == nil {
+		return configured, nil
+	}
+	if cfg.StrictModelValidation {
+		return "", err
+	}
+
+	if fallback := cfg.ModelFallback; fallback != "" {
+		if s.GetModelByID(fallback) != nil {
+			s.logger.Warn("Configured model %q is not available, falling back to %q", configured, fallback)
+			return fallback, nil
+		}
+		s.logger.Warn("Fallback model %q is not available either", fallback)
+	}
+
+	models := s.GetAvailableDeepseekModels()
+	if len(models) == 0 {
+		return "", fmt.Errorf("configured model %q is not available and no other models were found", configured)
+	}
+	s.logger.Warn("Configured model %q is not available, falling back to first available model %q", configured, models[0].ID)
+	return models[0].ID, nil
+}
+
 // getFallbackDeepseekModels returns a hardcoded list of DeepSeek models as a fallback
 func getFallbackDeepseekModels() []DeepseekModelInfo {
 	return []DeepseekModelInfo{
@@ -62,6 +104,33 @@ func getFallbackDeepseekModels() []DeepseekModelInfo {
 	}
 }
 
+// modelsVerified reports whether the available models were discovered from the API, rather
+// than taken from the fallback list
+func (s *DeepseekServer) modelsVerified() bool {
+	s.modelsMu.RLock()
+	defer s.modelsMu.RUnlock()
+	return len(s.models) > 0
+}
+
+// refreshModelsInBackground retries model discovery with growing intervals until it succeeds
+// or ctx is done. It is started when discovery fails at startup, so that the fallback models
+// are replaced once the API is reachable again.
+func (s *DeepseekServer) refreshModelsInBackground(ctx context.Context) {
+	interval := modelRefreshInitialInterval
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		if err := s.discoverModels(ctx); err == nil && s.modelsVerified() {
+			return
+		}
+		interval = min(interval*2, modelRefreshMaxInterval)
+		s.logger.Warn("Still using fallback models; retrying model discovery in %v", interval)
+	}
+}
+
 // GetAvailableDeepseekModels returns a list of available DeepSeek models from the server
 // If no models were discovered from the API, it returns the fallback models
 func (s *DeepseekServer) GetAvailableDeepseekModels() []DeepseekModelInfo {
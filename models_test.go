@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestResolveModel(t *testing.T) {
+	served := []DeepseekModelInfo{{ID: "deepseek-v4"}, {ID: "deepseek-chat"}}
+	tests := []struct {
+		name     string
+		models   []DeepseekModelInfo // Discovered models, nil for the fallback list
+		cfg      Config
+		want     string
+		wantErr  string
+		wantWarn string
+	}{
+		{"configured model served", served, Config{DeepseekModel: "deepseek-chat"}, "deepseek-chat", "", ""},
+		{"configured fallback", served, Config{DeepseekModel: "deepseek-old", ModelFallback: "deepseek-chat"}, "deepseek-chat", "", `falling back to "deepseek-chat"`},
+		{"fallback not served", served, Config{DeepseekModel: "deepseek-old", ModelFallback: "deepseek-gone"}, "deepseek-v4", "", "not available either"},
+		{"first available model", served, Config{DeepseekModel: "deepseek-old"}, "deepseek-v4", "", "first available model"},
+		{"strict validation", served, Config{DeepseekModel: "deepseek-old", ModelFallback: "deepseek-chat", StrictModelValidation: true}, "", "Invalid model ID: deepseek-old", ""},
+		{"fallback list before discovery", nil, Config{DeepseekModel: "deepseek-reasoner"}, "deepseek-reasoner", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+			s := &DeepseekServer{logger: logger, models: tt.models}
+			got, err := s.resolveModel(&tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveModel() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("resolveModel() = %q, %v, want %q", got, err, tt.want)
+			}
+			if tt.wantWarn != "" && !logger.contains(tt.wantWarn) {
+				t.Errorf("no warning containing %q in %v", tt.wantWarn, logger.messages)
+			}
+		})
+	}
+}
+
+func TestValidateModelIDListsAvailableModels(t *testing.T) {
+	s := &DeepseekServer{logger: NewLogger("error"), models: []DeepseekModelInfo{{ID: "a", Name: "Model A"}}}
+	if err := s.ValidateModelID("a"); err != nil {
+		t.Errorf("ValidateModelID(a) error = %v", err)
+	}
+	err := s.ValidateModelID("b")
+	if err == nil || !strings.Contains(err.Error(), "- a: Model A") {
+		t.Errorf("ValidateModelID(b) error = %v, want one listing model a", err)
+	}
+}
+
+func TestModelDiscoveryFallback(t *testing.T) {
+	served := &deepseek.APIModels{Data: []deepseek.Model{{ID: "deepseek-v4", OwnedBy: "deepseek"}}}
+	tests := []struct {
+		name         string
+		client       *mockDeepseekClient
+		wantVerified bool
+		wantValid    string // A model ID ValidateModelID accepts
+		wantNote     bool   // deepseek_models marks the models as unverified
+	}{
+		{"discovery fails", &mockDeepseekClient{modelsErr: errors.New("connection refused")}, false, "deepseek-chat", true},
+		{"no models listed", &mockDeepseekClient{models: &deepseek.APIModels{}}, false, "deepseek-reasoner", true},
+		{"models discovered", &mockDeepseekClient{models: served}, true, "deepseek-v4", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, tt.client, map[string]string{"DEEPSEEK_MAX_RETRIES": "0"})
+			err := s.discoverModels(context.Background())
+			if (err == nil) != (tt.client.modelsErr == nil) {
+				t.Errorf("discoverModels() error = %v", err)
+			}
+			if got := s.modelsVerified(); got != tt.wantVerified {
+				t.Errorf("modelsVerified() = %v, want %v", got, tt.wantVerified)
+			}
+			if err := s.ValidateModelID(tt.wantValid); err != nil {
+				t.Errorf("ValidateModelID(%s) error = %v", tt.wantValid, err)
+			}
+			result := callTool(t, s.handleDeepseekModels, nil)
+			if got := strings.Contains(resultText(result), "unverified"); got != tt.wantNote {
+				t.Errorf("models listed as unverified = %v, want %v:\n%s", got, tt.wantNote, resultText(result))
+			}
+		})
+	}
+}
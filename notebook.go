@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// notebookSource is the source of a notebook cell, stored either as one string or as a list of lines
+type notebookSource string
+
+func (s *notebookSource) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*s = notebookSource(text)
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return fmt.Errorf("cell source is neither a string nor a list of strings")
+	}
+	*s = notebookSource(strings.Join(lines, ""))
+	return nil
+}
+
+// notebook is the part of a Jupyter notebook (nbformat 4) kept when including it
+type notebook struct {
+	NBFormat int `json:"nbformat"`
+	Metadata struct {
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+	Cells []struct {
+		CellType string         `json:"cell_type"`
+		Source   notebookSource `json:"source"`
+	} `json:"cells"`
+}
+
+// isNotebookPath reports whether a path names a Jupyter notebook
+func isNotebookPath(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".ipynb")
+}
+
+// extractNotebookCells converts a Jupyter notebook into markdown holding only its markdown
+// and code cells. Outputs, metadata and raw cells are dropped, which removes most of the
+// tokens of a notebook. Code cells are fenced and tagged with the notebook's language.
+func extractNotebookCells(content []byte) ([]byte, error) {
+	var nb notebook
+	if err := json.Unmarshal(content, &nb); err != nil {
+		return nil, fmt.Errorf("failed to parse notebook: %w", err)
+	}
+	if nb.NBFormat < 4 {
+		return nil, fmt.Errorf("unsupported notebook format %d", nb.NBFormat)
+	}
+
+	language := nb.Metadata.Kernelspec.Language
+	if language == "" {
+		language = nb.Metadata.LanguageInfo.Name
+	}
+
+	var out strings.Builder
+	for _, cell := range nb.Cells {
+		source := strings.TrimRight(string(cell.Source), "\n")
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+		switch cell.CellType {
+		case "markdown":
+			out.WriteString(source)
+		case "code":
+			fence := "```"
+			for strings.Contains(source, fence) {
+				fence += "`"
+			}
+			out.WriteString(fence + language + "\n" + source + "\n" + fence)
+		default:
+			continue
+		}
+		out.WriteString("\n\n")
+	}
+	return []byte(out.String()), nil
+}
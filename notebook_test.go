@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// notebookFixtureCells is the markdown extracted from testdata/analysis.ipynb
+const notebookFixtureCells = "# Analysis\n\nLoad the data.\n\n" +
+	"```python\nimport pandas as pd\ndf = pd.read_csv(\"data.csv\")\n```\n\n" +
+	"````python\nprint(\"\"\"\n```\n\"\"\")\n````\n\n"
+
+func TestExtractNotebookCells(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "analysis.ipynb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{"fixture", string(fixture), notebookFixtureCells, ""},
+		{"language from language_info", `{"nbformat":4,"metadata":{"language_info":{"name":"julia"}},"cells":[{"cell_type":"code","source":"x = 1"}]}`, "```julia\nx = 1\n```\n\n", ""},
+		{"no cells", `{"nbformat":4,"cells":[]}`, "", ""},
+		{"old format", `{"nbformat":3,"worksheets":[]}`, "", "unsupported notebook format 3"},
+		{"invalid json", `{"cells":`, "", "failed to parse notebook"},
+		{"invalid source", `{"nbformat":4,"cells":[{"cell_type":"code","source":42}]}`, "", "neither a string nor a list"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := extractNotebookCells([]byte(tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("extractNotebookCells() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("extractNotebookCells() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadValidatedFileNotebook(t *testing.T) {
+	fixture, err := os.ReadFile(filepath.Join("testdata", "analysis.ipynb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "analysis.ipynb")
+	if err := os.WriteFile(path, fixture, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		extract      string
+		want         string
+		wantNotebook bool
+	}{
+		{"default keeps the raw json", "", string(fixture), false},
+		{"disabled", "false", string(fixture), false},
+		{"enabled", "true", notebookFixtureCells, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]string{
+				"DEEPSEEK_ALLOWED_FILE_PATHS":     dir,
+				"DEEPSEEK_ALLOWED_FILE_TYPES":     "application/x-ipynb+json",
+				"DEEPSEEK_EXTRACT_NOTEBOOK_CELLS": tt.extract,
+			})
+			result := readValidatedFile(path, cfg, nil)
+			if result.Err != nil {
+				t.Fatal(result.Err)
+			}
+			if string(result.Content) != tt.want || result.Notebook != tt.wantNotebook {
+				t.Errorf("content = %q (notebook %v), want %q (notebook %v)", result.Content, result.Notebook, tt.want, tt.wantNotebook)
+			}
+		})
+	}
+}
+
+func TestNotebookFileType(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "analysis.ipynb")
+	if err := os.WriteFile(path, []byte(`{"cells": [], "nbformat": 4}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		allowedTypes string
+		detection    string
+		wantErr      error
+	}{
+		{"not allowed by default", "", "", errFileTypeNotAllowed},
+		{"json does not allow notebooks", "application/json", "", errFileTypeNotAllowed},
+		{"allowed explicitly", "application/x-ipynb+json", "", nil},
+		{"allowed with content detection", "application/x-ipynb+json", MimeDetectionBoth, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]string{
+				"DEEPSEEK_ALLOWED_FILE_TYPES": tt.allowedTypes,
+				"DEEPSEEK_MIME_DETECTION":     tt.detection,
+			})
+			if err := checkFileType(path, cfg); !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkFileType() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
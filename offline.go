@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// offlineResponse is a canned answer returned when the last user message contains Match
+type offlineResponse struct {
+	Match   string `json:"match"`
+	Content string `json:"content"`
+}
+
+// offlineFixtures are the canned data returned by the offline client. Fields left empty in
+// a fixtures file keep their defaults.
+type offlineFixtures struct {
+	Responses []offlineResponse `json:"responses"` // Checked in order, the first match wins
+	Models    []string          `json:"models"`
+	Balance   string            `json:"balance"`
+	Currency  string            `json:"currency"`
+}
+
+// defaultOfflineFixtures returns the fixtures used when no fixtures file is configured
+func defaultOfflineFixtures() *offlineFixtures {
+	return &offlineFixtures{
+		Models:   []string{"deepseek-chat", "deepseek-reasoner"},
+		Balance:  "100.00",
+		Currency: "USD",
+	}
+}
+
+// loadOfflineFixtures loads canned responses for offline mode from a JSON file
+func loadOfflineFixtures(path string) (*offlineFixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offline fixtures file: %w", err)
+	}
+
+	fixtures := defaultOfflineFixtures()
+	if err := json.Unmarshal(data, fixtures); err != nil {
+		return nil, fmt.Errorf("failed to parse offline fixtures file %s: %w", path, err)
+	}
+	for i, response := range fixtures.Responses {
+		if response.Match == "" {
+			return nil, fmt.Errorf("response %d in %s has an empty match", i, path)
+		}
+	}
+	if len(fixtures.Models) == 0 {
+		return nil, fmt.Errorf("offline fixtures file %s lists no models", path)
+	}
+	return fixtures, nil
+}
+
+// offlineDeepseekClient is a DeepseekAPI that never calls the API. It returns deterministic
+// canned responses for developing and testing MCP clients without spending API credits.
+type offlineDeepseekClient struct {
+	fixtures *offlineFixtures
+	calls    atomic.Int64 // Number of completions, used for deterministic response IDs
+}
+
+// newOfflineDeepseekClient creates an offline client serving the given fixtures
+func newOfflineDeepseekClient(fixtures *offlineFixtures) *offlineDeepseekClient {
+	if fixtures == nil {
+		fixtures = defaultOfflineFixtures()
+	}
+	return &offlineDeepseekClient{fixtures: fixtures}
+}
+
+// CreateChatCompletion returns the first canned response matching the last user message,
+// or echoes the message when none matches
+func (o *offlineDeepseekClient) CreateChatCompletion(ctx context.Context, req *deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var query, prompt strings.Builder
+	for _, message := range req.Messages {
+		prompt.WriteString(message.Content)
+		if message.Role == deepseek.ChatMessageRoleUser {
+			query.Reset()
+			query.WriteString(message.Content)
+		}
+	}
+
+	content := "[offline] " + query.String()
+	for _, response := range o.fixtures.Responses {
+		if strings.Contains(query.String(), response.Match) {
+			content = response.Content
+			break
+		}
+	}
+
+	promptTokens := deepseek.EstimateTokenCount(prompt.String()).EstimatedTokens
+	completionTokens := deepseek.EstimateTokenCount(content).EstimatedTokens
+	return &deepseek.ChatCompletionResponse{
+		ID:     fmt.Sprintf("offline-%d", o.calls.Add(1)),
+		Object: "chat.completion",
+		Model:  req.Model,
+		Choices: []deepseek.Choice{{
+			Index:        0,
+			Message:      deepseek.Message{Role: deepseek.ChatMessageRoleAssistant, Content: content},
+			FinishReason: "stop",
+		}},
+		Usage: deepseek.Usage{
+			PromptTokens:          promptTokens,
+			CompletionTokens:      completionTokens,
+			TotalTokens:           promptTokens + completionTokens,
+			PromptCacheMissTokens: promptTokens,
+		},
+	}, nil
+}
+
+// ListAllModels returns the models listed in the fixtures
+func (o *offlineDeepseekClient) ListAllModels(ctx context.Context) (*deepseek.APIModels, error) {
+	models := &deepseek.APIModels{Object: "list"}
+	for _, id := range o.fixtures.Models {
+		models.Data = append(models.Data, deepseek.Model{ID: id, Object: "model", OwnedBy: "offline"})
+	}
+	return models, nil
+}
+
+// GetBalance returns the balance given in the fixtures
+func (o *offlineDeepseekClient) GetBalance(ctx context.Context) (*deepseek.BalanceResponse, error) {
+	return &deepseek.BalanceResponse{
+		IsAvailable: true,
+		BalanceInfos: []deepseek.BalanceInfo{{
+			Currency:        o.fixtures.Currency,
+			TotalBalance:    o.fixtures.Balance,
+			GrantedBalance:  "0.00",
+			ToppedUpBalance: o.fixtures.Balance,
+		}},
+	}, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// openFiles bounds the number of files held open at once across all requests, so that reading
+// many files concurrently cannot exhaust the process's file descriptors. It is set from
+// MaxOpenFiles when the server starts and is unbounded until then.
+var openFiles atomic.Pointer[requestLimiter]
+
+// setMaxOpenFiles sets the maximum number of files held open at once. A max of zero or less
+// disables the limit.
+func setMaxOpenFiles(max int) {
+	openFiles.Store(newRequestLimiter(max))
+}
+
+// acquireOpenFile waits until a file may be opened and returns the function that releases the
+// slot. Callers must hold at most one slot at a time, and release it once the file is closed.
+func acquireOpenFile() func() {
+	limiter := openFiles.Load()
+	if limiter == nil {
+		return func() {}
+	}
+	// Acquire only fails when the context is cancelled, which the background context never is
+	_ = limiter.Acquire(context.Background())
+	return limiter.Release
+}
+
+// fileReadConcurrency bounds the number of file reader workers by the open file limit, since
+// each worker holds at most one file open and further workers would only wait for a slot
+func fileReadConcurrency(concurrency, maxOpenFiles int) int {
+	if maxOpenFiles > 0 && concurrency > maxOpenFiles {
+		return maxOpenFiles
+	}
+	return concurrency
+}
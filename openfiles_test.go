@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestFileReadConcurrency(t *testing.T) {
+	tests := []struct {
+		concurrency, maxOpenFiles, want int
+	}{
+		{8, 0, 8},
+		{8, 64, 8},
+		{8, 8, 8},
+		{8, 2, 2},
+		{1, 2, 1},
+	}
+	for _, tt := range tests {
+		if got := fileReadConcurrency(tt.concurrency, tt.maxOpenFiles); got != tt.want {
+			t.Errorf("fileReadConcurrency(%d, %d) = %d, want %d", tt.concurrency, tt.maxOpenFiles, got, tt.want)
+		}
+	}
+}
+
+func TestAcquireOpenFileWaitsForSlot(t *testing.T) {
+	defer openFiles.Store(openFiles.Load())
+	setMaxOpenFiles(1)
+
+	release := acquireOpenFile()
+	acquired := make(chan func())
+	go func() { acquired <- acquireOpenFile() }()
+	select {
+	case <-acquired:
+		t.Fatal("a second file was opened while the only slot was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	select {
+	case releaseSecond := <-acquired:
+		releaseSecond()
+	case <-time.After(5 * time.Second):
+		t.Fatal("the slot was not handed over after being released")
+	}
+}
+
+func TestReadManyFilesUnderOpenFileLimit(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for i := 0; i < 200; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("f%03d.txt", i))
+		if err := os.WriteFile(path, []byte(fmt.Sprintf("content %d", i)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	defer openFiles.Store(openFiles.Load())
+
+	for _, limit := range []int{1, 2, 8} {
+		t.Run(fmt.Sprintf("limit %d", limit), func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]string{
+				"DEEPSEEK_ALLOWED_FILE_PATHS": dir,
+				"DEEPSEEK_MAX_OPEN_FILES":     fmt.Sprint(limit),
+				"DEEPSEEK_MIME_DETECTION":     "content",
+			})
+			setMaxOpenFiles(cfg.MaxOpenFiles)
+			limiter := openFiles.Load()
+
+			done := make(chan []fileReadResult)
+			go func() {
+				done <- readFilesConcurrently(paths, cfg, newFileCache(0), 32, nil)
+			}()
+			peak := 0
+			deadline := time.After(10 * time.Second)
+			for {
+				select {
+				case results := <-done:
+					for i, result := range results {
+						if result.Err != nil || string(result.Content) != fmt.Sprintf("content %d", i) {
+							t.Errorf("%s: content %q, error %v", result.Path, result.Content, result.Err)
+						}
+					}
+					if peak > limit {
+						t.Errorf("%d files were open at once, want at most %d", peak, limit)
+					}
+					return
+				case <-deadline:
+					t.Fatal("reading the files did not finish; the open file limit may deadlock")
+				default:
+					peak = max(peak, len(limiter.slots))
+					runtime.Gosched()
+				}
+			}
+		})
+	}
+}
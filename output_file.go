@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// Modes of writing a response with output_file
+const (
+	OutputModeOverwrite = "overwrite"
+	OutputModeAppend    = "append"
+)
+
+// isValidOutputMode reports whether mode is a supported output_file mode
+func isValidOutputMode(mode string) bool {
+	return mode == OutputModeOverwrite || mode == OutputModeAppend
+}
+
+// resolveOutputFile validates a path to write a response to. The file must be inside one of
+// the writable roots, which are separate from the paths allowed for reading. Its directory
+// must exist, and an existing file must be a regular file; symlinks are rejected so that a
+// link inside a writable root cannot redirect the write elsewhere.
+func resolveOutputFile(path string, cfg *Config) (string, error) {
+	if cfg.DisableFileAccess {
+		return "", errFileAccessDisabled
+	}
+	if len(cfg.WritableFilePaths) == 0 {
+		return "", errors.New("writing responses to files is disabled; set DEEPSEEK_WRITABLE_FILE_PATHS to enable it")
+	}
+	path, err := expandPath(path)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid output path %s: %w", path, err)
+	}
+
+	if !isPathAllowed(filepath.Dir(absPath), cfg.WritableFilePaths) {
+		return "", fmt.Errorf("%w for writing: %s. Writable roots are: %v", errPathNotAllowed, absPath, cfg.WritableFilePaths)
+	}
+	info, err := os.Lstat(absPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return absPath, nil
+	case err != nil:
+		return "", fmt.Errorf("cannot access output path %s: %w", absPath, err)
+	case info.Mode()&os.ModeSymlink != 0:
+		return "", fmt.Errorf("output path is a symlink: %s", absPath)
+	case !info.Mode().IsRegular():
+		return "", fmt.Errorf("output path is not a regular file: %s", absPath)
+	}
+	return absPath, nil
+}
+
+// writeOutputFile writes content to a path validated by resolveOutputFile, replacing or
+// appending to an existing file, and returns the size of the file afterwards. The path may
+// have changed since it was validated, so the file is opened first and the opened file is
+// checked before anything is written: a new file is created exclusively, which fails on a
+// symlink, and an existing file must be the regular file found at the path within the
+// writable roots. An existing file is only truncated once it has passed these checks.
+func writeOutputFile(path, mode, content string, writableRoots []string) (int64, error) {
+	flags := os.O_WRONLY
+	if mode == OutputModeAppend {
+		flags |= os.O_APPEND
+	}
+	created := false
+	file, err := os.OpenFile(path, flags, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		file, err = os.OpenFile(path, flags|os.O_CREATE|os.O_EXCL, 0o644)
+		created = err == nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to open output file: %w", err)
+	}
+	if err := checkOpenedOutputFile(file, path, writableRoots); err != nil {
+		_ = file.Close()
+		if created {
+			_ = os.Remove(path)
+		}
+		return 0, err
+	}
+
+	if mode == OutputModeOverwrite && !created {
+		if err := file.Truncate(0); err != nil {
+			_ = file.Close()
+			return 0, fmt.Errorf("failed to truncate output file: %w", err)
+		}
+	}
+	if _, err := file.WriteString(content); err != nil {
+		_ = file.Close()
+		return 0, fmt.Errorf("failed to write output file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return 0, fmt.Errorf("failed to stat output file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("failed to write output file: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// checkOpenedOutputFile verifies that an opened output file is a regular file, that path still
+// names that same file without going through a symlink, and that it is inside the writable roots
+func checkOpenedOutputFile(file *os.File, path string, writableRoots []string) error {
+	opened, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat output file: %w", err)
+	}
+	if !opened.Mode().IsRegular() {
+		return fmt.Errorf("output path is not a regular file: %s", path)
+	}
+	current, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("cannot access output path %s: %w", path, err)
+	}
+	if current.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("output path is a symlink: %s", path)
+	}
+	if !os.SameFile(opened, current) {
+		return fmt.Errorf("output path changed while it was being opened: %s", path)
+	}
+	if !isPathAllowed(path, writableRoots) {
+		return fmt.Errorf("%w for writing: %s. Writable roots are: %v", errPathNotAllowed, path, writableRoots)
+	}
+	return nil
+}
+
+// writeResponseFile writes a response to an output file and returns the confirmation result
+func (s *DeepseekServer) writeResponseFile(cfg *Config, path, mode, content string) *mcp.CallToolResult {
+	size, err := writeOutputFile(path, mode, content, cfg.WritableFilePaths)
+	if err != nil {
+		s.logger.Error("Failed to write response to %s: %v", path, err)
+		return toolError(ErrorCodeFileError, fmt.Sprintf("The response could not be written to %s: %v", path, err))
+	}
+	s.logger.Info("Wrote response of %d bytes to %s (%s)", len(content), path, mode)
+	return mcp.NewToolResultText(formatOutputFileConfirmation(path, mode, len(content), size))
+}
+
+// formatOutputFileConfirmation formats the result returned instead of a response written to a file
+func formatOutputFileConfirmation(path, mode string, written int, size int64) string {
+	action := "Written"
+	if mode == OutputModeAppend {
+		action = "Appended"
+	}
+	return fmt.Sprintf("%s the response (%s) to `%s`. The file is now %s.",
+		action, humanReadableSize(int64(written)), path, humanReadableSize(size))
+}
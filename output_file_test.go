@@ -0,0 +1,172 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveOutputFile(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	existing := filepath.Join(root, "existing.md")
+	if err := os.WriteFile(existing, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "target.md"), filepath.Join(root, "link.md")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		cfg     Config
+		path    string
+		wantErr string
+	}{
+		{"new file", Config{WritableFilePaths: []string{root}}, filepath.Join(root, "new.md"), ""},
+		{"existing file", Config{WritableFilePaths: []string{root}}, existing, ""},
+		{"file access disabled", Config{WritableFilePaths: []string{root}, DisableFileAccess: true}, existing, "disabled"},
+		{"no writable roots", Config{AllowedFilePaths: []string{root}}, existing, "DEEPSEEK_WRITABLE_FILE_PATHS"},
+		{"outside the roots", Config{WritableFilePaths: []string{root}}, filepath.Join(outside, "out.md"), "for writing"},
+		{"traversal", Config{WritableFilePaths: []string{root}}, filepath.Join(root, "..", filepath.Base(outside), "out.md"), "for writing"},
+		{"missing directory", Config{WritableFilePaths: []string{root}}, filepath.Join(root, "missing", "out.md"), "for writing"},
+		{"symlink", Config{WritableFilePaths: []string{root}}, filepath.Join(root, "link.md"), "symlink"},
+		{"directory", Config{WritableFilePaths: []string{root}}, filepath.Join(root, "dir"), "not a regular file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveOutputFile(tt.path, &tt.cfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveOutputFile(%s) error = %v, want one containing %q", tt.path, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.path {
+				t.Fatalf("resolveOutputFile(%s) = %q, %v", tt.path, got, err)
+			}
+		})
+	}
+}
+
+func TestWriteOutputFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing string // Content of the file before the write, "" for no file
+		mode     string
+		want     string
+	}{
+		{"create", "", OutputModeOverwrite, "new"},
+		{"overwrite", "old content", OutputModeOverwrite, "new"},
+		{"append", "old ", OutputModeAppend, "old new"},
+		{"append to new file", "", OutputModeAppend, "new"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			path := filepath.Join(root, "out.md")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			size, err := writeOutputFile(path, tt.mode, "new", []string{root})
+			if err != nil {
+				t.Fatalf("writeOutputFile() error = %v", err)
+			}
+			got, _ := os.ReadFile(path)
+			if string(got) != tt.want || size != int64(len(tt.want)) {
+				t.Errorf("file = %q with size %d, want %q", got, size, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteOutputFileRejectsPathsSwappedAfterValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing bool // Whether the output file exists when it is validated
+		// swap replaces the validated path, returning the outside file the write must not touch
+		swap func(t *testing.T, root, outside, path string) string
+	}{
+		{
+			name:     "existing file replaced by a symlink",
+			existing: true,
+			swap: func(t *testing.T, root, outside, path string) string {
+				target := filepath.Join(outside, "secret.txt")
+				mustWrite(t, target, "secret")
+				mustRemove(t, path)
+				mustSymlink(t, target, path)
+				return target
+			},
+		},
+		{
+			name: "new file replaced by a dangling symlink",
+			swap: func(t *testing.T, root, outside, path string) string {
+				target := filepath.Join(outside, "created.txt")
+				mustSymlink(t, target, path)
+				return target
+			},
+		},
+		{
+			name: "directory replaced by a symlink",
+			swap: func(t *testing.T, root, outside, path string) string {
+				dir := filepath.Dir(path)
+				mustRemove(t, dir)
+				mustSymlink(t, outside, dir)
+				return filepath.Join(outside, filepath.Base(path))
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root, outside := t.TempDir(), t.TempDir()
+			dir := filepath.Join(root, "sub")
+			if err := os.Mkdir(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(dir, "out.md")
+			if tt.existing {
+				mustWrite(t, path, "old")
+			}
+			resolved, err := resolveOutputFile(path, &Config{WritableFilePaths: []string{root}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			target := tt.swap(t, root, outside, resolved)
+			before, beforeErr := os.ReadFile(target)
+			if _, err := writeOutputFile(resolved, OutputModeOverwrite, "overwritten", []string{root}); err == nil {
+				t.Fatal("writeOutputFile() succeeded, want an error")
+			}
+			after, afterErr := os.ReadFile(target)
+			if string(after) != string(before) || (beforeErr == nil) != (afterErr == nil) {
+				t.Errorf("outside file changed from %q (%v) to %q (%v)", before, beforeErr, after, afterErr)
+			}
+		})
+	}
+}
+
+func mustWrite(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustRemove(t *testing.T, path string) {
+	t.Helper()
+	if err := os.RemoveAll(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustSymlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+}
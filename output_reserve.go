@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// messagesTokens returns the estimated tokens of the content of messages
+func messagesTokens(messages []deepseek.ChatCompletionMessage) int {
+	total := 0
+	for _, message := range messages {
+		total += estimateTokens(message.Content)
+	}
+	return total
+}
+
+// tokenBudgetBytes converts a number of tokens into the bytes of content they cover, using
+// the bytes per token of content itself, so that dense and sparse files are both cut fairly
+func tokenBudgetBytes(content []byte, tokens int) int64 {
+	if tokens <= 0 {
+		return 0
+	}
+	contentTokens := estimateTokens(string(content))
+	if contentTokens <= tokens {
+		return int64(len(content))
+	}
+	return int64(len(content)) * int64(tokens) / int64(contentTokens)
+}
+
+// formatOutputReservation formats how the context window was split between the input and the
+// output reserved with reserve_output_tokens
+func formatOutputReservation(inputTokens, reserved, contextWindow int) string {
+	return fmt.Sprintf("\n\n---\n*Token budget: ~%d input tokens, leaving %d of the %d-token context window for the response (%d reserved).*",
+		inputTokens, contextWindow-inputTokens, contextWindow, reserved)
+}
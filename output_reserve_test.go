@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestTokenBudgetBytes(t *testing.T) {
+	content := []byte(strings.Repeat("word ", 400))
+	tokens := estimateTokens(string(content))
+	tests := []struct {
+		name   string
+		tokens int
+		want   int64
+	}{
+		{"no budget", 0, 0},
+		{"negative budget", -5, 0},
+		{"whole content fits", tokens, int64(len(content))},
+		{"more than enough", 10 * tokens, int64(len(content))},
+		{"half the tokens", tokens / 2, int64(len(content)) * int64(tokens/2) / int64(tokens)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tokenBudgetBytes(content, tt.tokens); got != tt.want {
+				t.Errorf("tokenBudgetBytes(%d) = %d, want %d", tt.tokens, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAskReserveOutputTokens(t *testing.T) {
+	dir := t.TempDir()
+	small, large := filepath.Join(dir, "small.go"), filepath.Join(dir, "large.go")
+	if err := os.WriteFile(small, []byte("package small\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var content strings.Builder
+	for i := 0; content.Len() < 64*1024; i++ {
+		fmt.Fprintf(&content, "var value%d = %d\n", i, i)
+	}
+	if err := os.WriteFile(large, []byte(content.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	window := modelContextWindows["deepseek-chat"]
+	budgetRe := regexp.MustCompile(`~(\d+) input tokens, leaving (\d+) of the (\d+)-token context window for the response \((\d+) reserved\)`)
+
+	tests := []struct {
+		name          string
+		file          string
+		reserve       int
+		wantTruncated bool
+		wantError     string
+	}{
+		{name: "input fits", file: small, reserve: 4096},
+		{name: "file truncated to make room", file: large, reserve: window - 2000, wantTruncated: true},
+		{name: "prompt alone too large", file: small, reserve: window - 10, wantError: "The prompt alone is estimated at"},
+		{name: "reserve over the window", file: small, reserve: window, wantError: "It must be less than the"},
+		{name: "negative reserve", file: small, reserve: -1, wantError: "must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{}
+			s := newTestServer(t, client, map[string]string{"DEEPSEEK_ALLOWED_FILE_PATHS": dir, "DEEPSEEK_MODEL": "deepseek-chat"})
+			result := callTool(t, s.handleAskDeepseek, map[string]any{"query": "review", "file_paths": []any{tt.file}, "reserve_output_tokens": tt.reserve})
+			if tt.wantError != "" {
+				if !result.IsError || !strings.Contains(resultText(result), tt.wantError) {
+					t.Errorf("result = %q, want error %q", resultText(result), tt.wantError)
+				}
+				return
+			}
+			if result.IsError {
+				t.Fatalf("request failed: %s", resultText(result))
+			}
+
+			var prompt strings.Builder
+			for _, message := range client.requests[0].Messages {
+				prompt.WriteString(message.Content)
+			}
+			if got := strings.Contains(prompt.String(), "[truncated:"); got != tt.wantTruncated {
+				t.Errorf("file truncated = %v, want %v", got, tt.wantTruncated)
+			}
+			if input := messagesTokens(client.requests[0].Messages); input > window-tt.reserve {
+				t.Errorf("input is ~%d tokens, want at most %d", input, window-tt.reserve)
+			}
+
+			match := budgetRe.FindStringSubmatch(resultText(result))
+			if match == nil {
+				t.Fatalf("no token budget note in %q", resultText(result))
+			}
+			input, _ := strconv.Atoi(match[1])
+			left, _ := strconv.Atoi(match[2])
+			if match[3] != strconv.Itoa(window) || match[4] != strconv.Itoa(tt.reserve) || input+left != window || left < tt.reserve {
+				t.Errorf("token budget note %q does not split the %d-token window leaving %d reserved", match[0], window, tt.reserve)
+			}
+		})
+	}
+}
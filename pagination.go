@@ -0,0 +1,247 @@
+package main
+
+import (
+	"container/list"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// continuationTokenTTL is how long the remaining parts of a paginated response are kept
+const continuationTokenTTL = 10 * time.Minute
+
+// Bounds of the responsePageStore. When storing a response would exceed either of them, the
+// oldest responses are evicted first.
+const (
+	maxPagedResponses     = 256
+	maxPagedResponseBytes = 64 * 1024 * 1024
+)
+
+// pagedResponse holds the remaining parts of a response split by splitResponse
+type pagedResponse struct {
+	token     string
+	parts     []string
+	next      int
+	size      int // Bytes of the parts not yet returned
+	expiresAt time.Time
+}
+
+// responsePageStore is a short-lived in-memory store for paginated responses, bounded by the
+// number of responses and the bytes of their remaining parts
+type responsePageStore struct {
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	order      *list.List // Oldest response at the front
+	size       int
+	maxEntries int
+	maxBytes   int
+	ttl        time.Duration
+}
+
+// newResponsePageStore creates a new page store with the given TTL
+func newResponsePageStore(ttl time.Duration) *responsePageStore {
+	return &responsePageStore{
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		maxEntries: maxPagedResponses,
+		maxBytes:   maxPagedResponseBytes,
+		ttl:        ttl,
+	}
+}
+
+// Put stores the parts of a response and returns a continuation token for the second part.
+// The oldest responses are evicted to keep within the bounds; a response larger than the byte
+// budget on its own is still stored, as the only one.
+func (p *responsePageStore) Put(parts []string) (string, error) {
+	token, err := newContinuationToken()
+	if err != nil {
+		return "", err
+	}
+	size := 0
+	for _, part := range parts[1:] {
+		size += len(part)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.evictExpiredLocked()
+	for p.order.Len() > 0 && (p.order.Len() >= p.maxEntries || p.size+size > p.maxBytes) {
+		p.removeLocked(p.order.Front())
+	}
+	p.entries[token] = p.order.PushBack(&pagedResponse{
+		token:     token,
+		parts:     parts,
+		next:      1,
+		size:      size,
+		expiresAt: time.Now().Add(p.ttl),
+	})
+	p.size += size
+	return token, nil
+}
+
+// Next returns the next part for a continuation token along with its 1-based index and the total
+// number of parts. The token is removed once the last part has been returned.
+func (p *responsePageStore) Next(token string) (string, int, int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.evictExpiredLocked()
+
+	elem, ok := p.entries[token]
+	if !ok {
+		return "", 0, 0, fmt.Errorf("continuation token %q is unknown or has expired", token)
+	}
+	entry := elem.Value.(*pagedResponse)
+
+	part := entry.parts[entry.next]
+	entry.next++
+	entry.size -= len(part)
+	p.size -= len(part)
+	if entry.next >= len(entry.parts) {
+		p.removeLocked(elem)
+	} else {
+		entry.expiresAt = time.Now().Add(p.ttl)
+	}
+	return part, entry.next, len(entry.parts), nil
+}
+
+// removeLocked removes a stored response. The caller must hold p.mu.
+func (p *responsePageStore) removeLocked(elem *list.Element) {
+	entry := p.order.Remove(elem).(*pagedResponse)
+	delete(p.entries, entry.token)
+	p.size -= entry.size
+}
+
+// evictExpiredLocked removes expired entries. The caller must hold p.mu.
+func (p *responsePageStore) evictExpiredLocked() {
+	now := time.Now()
+	for elem := p.order.Front(); elem != nil; {
+		next := elem.Next()
+		if now.After(elem.Value.(*pagedResponse).expiresAt) {
+			p.removeLocked(elem)
+		}
+		elem = next
+	}
+}
+
+// newContinuationToken generates a random continuation token
+func newContinuationToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate continuation token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// paginatedResult returns the response as a tool result, splitting it into parts and storing the
+// remainder behind a continuation token when it exceeds maxChars
+func (s *DeepseekServer) paginatedResult(content string, maxChars int) *mcp.CallToolResult {
+	parts := splitResponse(content, maxChars)
+	if len(parts) == 1 {
+		return mcp.NewToolResultText(content)
+	}
+
+	token, err := s.pages.Put(parts)
+	if err != nil {
+		s.logger.Error("Failed to store paginated response, returning it in full: %v", err)
+		return mcp.NewToolResultText(content)
+	}
+	s.logger.Info("Response of %d characters split into %d parts", len([]rune(content)), len(parts))
+	return mcp.NewToolResultText(formatResponsePart(parts[0], token, 1, len(parts)))
+}
+
+// formatResponsePart appends a pagination note to a response part
+func formatResponsePart(part, token string, index, total int) string {
+	if index >= total {
+		return part + fmt.Sprintf("\n\n---\n*Part %d of %d (final part).*", index, total)
+	}
+	return part + fmt.Sprintf("\n\n---\n*Response truncated: part %d of %d. Call `deepseek_ask` with `continuation_token` set to `%s` to get the next part.*",
+		index, total, token)
+}
+
+// splitResponse splits content into parts of at most maxChars characters, preferring paragraph
+// boundaries and keeping fenced code blocks intact where they fit.
+func splitResponse(content string, maxChars int) []string {
+	if maxChars <= 0 || len([]rune(content)) <= maxChars {
+		return []string{content}
+	}
+
+	var parts []string
+	var current strings.Builder
+	currentLen := 0
+
+	flush := func() {
+		if currentLen > 0 {
+			parts = append(parts, strings.TrimRight(current.String(), "\n"))
+			current.Reset()
+			currentLen = 0
+		}
+	}
+
+	for _, block := range splitIntoBlocks(content) {
+		blockLen := len([]rune(block))
+		if currentLen > 0 && currentLen+blockLen > maxChars {
+			flush()
+		}
+		if blockLen > maxChars {
+			// The block alone is too large, so split it further by lines
+			parts = append(parts, splitByLines(block, maxChars)...)
+			continue
+		}
+		current.WriteString(block)
+		currentLen += blockLen
+	}
+	flush()
+
+	return parts
+}
+
+// splitIntoBlocks splits content into paragraphs separated by blank lines,
+// treating each fenced code block as a single block
+func splitIntoBlocks(content string) []string {
+	var blocks []string
+	var current strings.Builder
+	inFence := false
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		current.WriteString(line)
+		if !inFence && strings.TrimSpace(line) == "" {
+			blocks = append(blocks, current.String())
+			current.Reset()
+		}
+	}
+	if current.Len() > 0 {
+		blocks = append(blocks, current.String())
+	}
+	return blocks
+}
+
+// splitByLines splits an oversized block on line boundaries, breaking overlong lines by characters
+func splitByLines(block string, maxChars int) []string {
+	var chunks []string
+	var current []rune
+
+	for _, line := range strings.SplitAfter(block, "\n") {
+		runes := []rune(line)
+		if len(current)+len(runes) > maxChars && len(current) > 0 {
+			chunks = append(chunks, string(current))
+			current = nil
+		}
+		for len(runes) > maxChars {
+			chunks = append(chunks, string(runes[:maxChars]))
+			runes = runes[maxChars:]
+		}
+		current = append(current, runes...)
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, string(current))
+	}
+	return chunks
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponsePageStoreNext(t *testing.T) {
+	p := newResponsePageStore(time.Minute)
+	token, err := p.Put([]string{"one", "two", "three"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []struct {
+		part  string
+		index int
+	}{{"two", 2}, {"three", 3}} {
+		part, index, total, err := p.Next(token)
+		if err != nil || part != want.part || index != want.index || total != 3 {
+			t.Fatalf("Next() = %q, %d, %d, %v, want %q, %d, 3", part, index, total, err, want.part, want.index)
+		}
+	}
+	if _, _, _, err := p.Next(token); err == nil {
+		t.Error("Next() after the last part succeeded, want an unknown token error")
+	}
+	if p.size != 0 || p.order.Len() != 0 {
+		t.Errorf("store holds %d responses of %d bytes after the last part, want none", p.order.Len(), p.size)
+	}
+}
+
+func TestResponsePageStoreEvictsOldest(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxEntries int
+		maxBytes   int
+		sizes      []int // Bytes of the remaining parts of each stored response, oldest first
+		wantKept   []bool
+	}{
+		{"within bounds", 3, 100, []int{10, 10, 10}, []bool{true, true, true}},
+		{"too many responses", 2, 100, []int{10, 10, 10}, []bool{false, true, true}},
+		{"over the byte budget", 10, 25, []int{10, 10, 10}, []bool{false, true, true}},
+		{"large response evicts several", 10, 30, []int{10, 10, 10, 25}, []bool{false, false, false, true}},
+		{"response larger than the budget is kept alone", 10, 20, []int{10, 50}, []bool{false, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newResponsePageStore(time.Minute)
+			p.maxEntries, p.maxBytes = tt.maxEntries, tt.maxBytes
+			var tokens []string
+			for _, size := range tt.sizes {
+				token, err := p.Put([]string{"first", strings.Repeat("x", size)})
+				if err != nil {
+					t.Fatal(err)
+				}
+				tokens = append(tokens, token)
+			}
+			for i, token := range tokens {
+				if _, ok := p.entries[token]; ok != tt.wantKept[i] {
+					t.Errorf("response %d kept = %v, want %v", i, ok, tt.wantKept[i])
+				}
+			}
+			if p.order.Len() > tt.maxEntries {
+				t.Errorf("store holds %d responses, want at most %d", p.order.Len(), tt.maxEntries)
+			}
+		})
+	}
+}
+
+func TestResponsePageStoreExpiry(t *testing.T) {
+	p := newResponsePageStore(time.Millisecond)
+	token, err := p.Put([]string{"one", "two"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, _, _, err := p.Next(token); err == nil {
+		t.Error("Next() on an expired token succeeded")
+	}
+	if p.size != 0 {
+		t.Errorf("size = %d after expiry, want 0", p.size)
+	}
+}
+
+func TestSplitResponse(t *testing.T) {
+	code := "```go\nfunc main() {\n}\n```\n"
+	tests := []struct {
+		name      string
+		content   string
+		maxChars  int
+		wantParts int
+	}{
+		{"fits", "short answer", 100, 1},
+		{"no limit", strings.Repeat("a", 1000), 0, 1},
+		{"paragraphs", "first paragraph\n\nsecond paragraph\n\nthird paragraph", 20, 3},
+		{"code block kept whole", "intro\n\n" + code, 30, 2},
+		{"long line", strings.Repeat("é", 25), 10, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := splitResponse(tt.content, tt.maxChars)
+			if len(parts) != tt.wantParts {
+				t.Fatalf("splitResponse() = %q, want %d parts", parts, tt.wantParts)
+			}
+			for _, part := range parts {
+				if tt.maxChars > 0 && len([]rune(part)) > tt.maxChars {
+					t.Errorf("part %q is longer than %d characters", part, tt.maxChars)
+				}
+			}
+			if strings.Contains(tt.content, code) && !strings.Contains(strings.Join(parts, "\n"), strings.TrimRight(code, "\n")) {
+				t.Errorf("code block was split: %q", parts)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// Preset is a named system prompt that can be selected with the deepseek_ask "preset" parameter
+type Preset struct {
+	Description  string `json:"description"`
+	SystemPrompt string `json:"system_prompt"`
+}
+
+// defaultPresets returns the built-in system prompt presets
+func defaultPresets() map[string]Preset {
+	return map[string]Preset{
+		"senior-go-reviewer": {
+			Description: "Senior Go engineer reviewing code for idioms, concurrency and error handling",
+			SystemPrompt: "You are a senior Go engineer performing a code review. " +
+				"Focus on idiomatic Go, error handling and wrapping, goroutine and channel safety, context propagation, " +
+				"and API design. Point out specific lines and suggest concrete, idiomatic fixes.",
+		},
+		"security-auditor": {
+			Description: "Application security auditor looking for vulnerabilities",
+			SystemPrompt: "You are an application security auditor. " +
+				"Analyze the provided code for vulnerabilities such as injection, broken access control, insecure deserialization, " +
+				"secrets exposure and unsafe file handling. Rate each finding by severity and give a remediation for each.",
+		},
+		"teacher": {
+			Description: "Patient teacher explaining concepts step by step",
+			SystemPrompt: "You are a patient programming teacher. " +
+				"Explain concepts step by step in plain language, define any jargon you use, " +
+				"and include small examples that build intuition before going into details.",
+		},
+	}
+}
+
+// loadPresetsFile reads presets from a JSON file mapping preset names to presets
+func loadPresetsFile(path string) (map[string]Preset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read presets file: %w", err)
+	}
+
+	var presets map[string]Preset
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return nil, fmt.Errorf("failed to parse presets file %s: %w", path, err)
+	}
+
+	for name, preset := range presets {
+		if strings.TrimSpace(preset.SystemPrompt) == "" {
+			return nil, fmt.Errorf("preset %q in %s has an empty system_prompt", name, path)
+		}
+	}
+	return presets, nil
+}
+
+// maxSystemPromptFileSize is the maximum size of a system prompt loaded with systemPromptFile
+const maxSystemPromptFileSize = 64 * 1024
+
+// readSystemPromptFile reads a system prompt from a file within the allowed file paths.
+// The file cache re-reads the file when its modification time or size changes.
+func (s *DeepseekServer) readSystemPromptFile(cfg *Config, path string) (string, error) {
+	if cfg.DisableFileAccess {
+		return "", fmt.Errorf("%w: %s", errFileAccessDisabled, path)
+	}
+	path, err := expandPath(path)
+	if err != nil {
+		return "", err
+	}
+	if len(cfg.AllowedFilePaths) > 0 && !isPathAllowed(path, cfg.AllowedFilePaths) {
+		return "", fmt.Errorf("%w: %s. Allowed roots are: %s", errPathNotAllowed, path, strings.Join(cfg.AllowedFilePaths, ", "))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("file not found or not accessible: %w", err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("path is a directory, not a file: %s", path)
+	}
+	if info.Size() > maxSystemPromptFileSize {
+		return "", fmt.Errorf("file is too large: %s (%s, maximum %s)", path,
+			humanReadableSize(info.Size()), humanReadableSize(maxSystemPromptFileSize))
+	}
+
+	content, err := s.fileCache.Read(path)
+	if err != nil {
+		return "", err
+	}
+	prompt := strings.TrimSpace(string(content))
+	if prompt == "" {
+		return "", fmt.Errorf("file is empty: %s", path)
+	}
+	return prompt, nil
+}
+
+// presetNames returns the sorted names of the given presets
+func presetNames(presets map[string]Preset) []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// handleDeepseekPresets handles requests to the deepseek_presets tool
+func (s *DeepseekServer) handleDeepseekPresets(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.logger.Info("Listing available system prompt presets")
+
+	var formattedContent strings.Builder
+	formattedContent.WriteString("# Available System Prompt Presets\n\n")
+	if len(s.config().Presets) == 0 {
+		formattedContent.WriteString("*No presets configured*\n")
+		return mcp.NewToolResultText(formattedContent.String()), nil
+	}
+
+	for _, name := range presetNames(s.config().Presets) {
+		formattedContent.WriteString(fmt.Sprintf("## %s\n", name))
+		formattedContent.WriteString(fmt.Sprintf("- Description: %s\n\n", s.config().Presets[name].Description))
+	}
+	formattedContent.WriteString("## Usage\n")
+	formattedContent.WriteString("Specify a preset name in the `preset` parameter when using the `deepseek_ask` tool. ")
+	formattedContent.WriteString("An explicit `systemPrompt` takes precedence over the preset.\n")
+
+	return mcp.NewToolResultText(formattedContent.String()), nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// projectedOutputTokens is the completion size assumed when estimating the cost of a request
+// before it is sent, since the actual output length is not known in advance
+const projectedOutputTokens = 4096
+
+// ModelPricing is the price of a model in USD per million tokens
+type ModelPricing struct {
+	InputPerMillion  float64 // Prompt tokens (cache miss)
+	OutputPerMillion float64 // Completion tokens
+}
+
+// defaultModelPricing returns the built-in prices of the known DeepSeek models.
+// Prices change over time, so they can be overridden in the config.
+func defaultModelPricing() map[string]ModelPricing {
+	return map[string]ModelPricing{
+		"deepseek-chat":     {InputPerMillion: 0.28, OutputPerMillion: 0.42},
+		"deepseek-reasoner": {InputPerMillion: 0.28, OutputPerMillion: 0.42},
+	}
+}
+
+// parseModelPricing parses price overrides in the form "model=input/output;model2=input/output",
+// with prices in USD per million tokens
+func parseModelPricing(value string) (map[string]ModelPricing, error) {
+	pricing := make(map[string]ModelPricing)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		model, prices, ok := strings.Cut(entry, "=")
+		model = strings.TrimSpace(model)
+		input, output, hasOutput := strings.Cut(prices, "/")
+		if !ok || !hasOutput || model == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected model=input/output", entry)
+		}
+		inputPrice, err := strconv.ParseFloat(strings.TrimSpace(input), 64)
+		if err != nil || inputPrice < 0 {
+			return nil, fmt.Errorf("invalid input price for model %s: %q", model, input)
+		}
+		outputPrice, err := strconv.ParseFloat(strings.TrimSpace(output), 64)
+		if err != nil || outputPrice < 0 {
+			return nil, fmt.Errorf("invalid output price for model %s: %q", model, output)
+		}
+		pricing[model] = ModelPricing{InputPerMillion: inputPrice, OutputPerMillion: outputPrice}
+	}
+	return pricing, nil
+}
+
+// formatModelPricing formats prices in the form accepted by parseModelPricing
+func formatModelPricing(pricing map[string]ModelPricing) string {
+	models := make([]string, 0, len(pricing))
+	for model := range pricing {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	entries := make([]string, 0, len(models))
+	for _, model := range models {
+		p := pricing[model]
+		entries = append(entries, fmt.Sprintf("%s=%g/%g", model, p.InputPerMillion, p.OutputPerMillion))
+	}
+	return strings.Join(entries, ";")
+}
+
+// Cost returns the cost in USD of the given numbers of prompt and completion tokens
+func (p ModelPricing) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.InputPerMillion + float64(completionTokens)*p.OutputPerMillion) / 1e6
+}
+
+// formatCostConfirmation formats the result returned instead of sending a request whose
+// estimated cost exceeds the warning threshold
+func formatCostConfirmation(model string, promptTokens, completionTokens int, cost, threshold float64) string {
+	var sb strings.Builder
+	sb.WriteString("# Cost Confirmation Required\n\n")
+	sb.WriteString("The request was not sent because its estimated cost exceeds the configured threshold.\n\n")
+	sb.WriteString(fmt.Sprintf("- **Model:** %s\n", model))
+	sb.WriteString(fmt.Sprintf("- **Estimated prompt tokens:** %d\n", promptTokens))
+	sb.WriteString(fmt.Sprintf("- **Projected completion tokens:** %d\n", completionTokens))
+	sb.WriteString(fmt.Sprintf("- **Projected cost:** $%.4f\n", cost))
+	sb.WriteString(fmt.Sprintf("- **Threshold:** $%.4f\n\n", threshold))
+	sb.WriteString("Repeat the request with `confirm_cost` set to `true` to send it anyway, ")
+	sb.WriteString("or reduce the context by attaching fewer files.\n")
+	return sb.String()
+}
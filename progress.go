@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// fileReadProgress sends MCP progress notifications while the files of a request are read,
+// so clients get feedback during long context assembly. Notifications go through the MCP
+// server's session, which frames them like any other message, so stdio is not disturbed.
+type fileReadProgress struct {
+	ctx    context.Context
+	server *server.MCPServer
+	token  mcp.ProgressToken
+	logger Logger
+	total  int
+
+	mu    sync.Mutex // Serializes notifications so progress only increases
+	done  int
+	bytes int64
+}
+
+// newFileReadProgress returns a progress reporter for reading total files, or nil when the
+// client did not ask for progress with a progress token
+func newFileReadProgress(ctx context.Context, req mcp.CallToolRequest, total int, logger Logger) *fileReadProgress {
+	if req.Params.Meta == nil || req.Params.Meta.ProgressToken == nil {
+		logger.Debug("report_progress requested without a progress token; no progress will be sent")
+		return nil
+	}
+	mcpServer := server.ServerFromContext(ctx)
+	if mcpServer == nil {
+		return nil
+	}
+	return &fileReadProgress{ctx: ctx, server: mcpServer, token: req.Params.Meta.ProgressToken, logger: logger, total: total}
+}
+
+// fileRead reports that one more file was read. It is safe for concurrent use and does
+// nothing on a nil reporter.
+func (p *fileReadProgress) fileRead(result fileReadResult) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.bytes += int64(len(result.Content))
+	err := p.server.SendNotificationToClient(p.ctx, "notifications/progress", map[string]any{
+		"progressToken": p.token,
+		"progress":      p.done,
+		"total":         p.total,
+		"message":       fmt.Sprintf("Read %d of %d files (%s)", p.done, p.total, humanReadableSize(p.bytes)),
+	})
+	if err != nil {
+		p.logger.Debug("Failed to send file read progress: %v", err)
+	}
+}
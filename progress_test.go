@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// testSession is an initialized client session that collects the notifications sent to it
+type testSession struct {
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *testSession) Initialize()       {}
+func (s *testSession) Initialized() bool { return true }
+func (s *testSession) SessionID() string { return "test-session" }
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func TestNewFileReadProgressWithoutToken(t *testing.T) {
+	tests := []struct {
+		name string
+		meta *mcp.Meta
+	}{
+		{"no meta", nil},
+		{"no progress token", &mcp.Meta{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req mcp.CallToolRequest
+			req.Params.Meta = tt.meta
+			progress := newFileReadProgress(context.Background(), req, 3, NewLogger("error"))
+			if progress != nil {
+				t.Fatalf("newFileReadProgress() = %+v, want nil", progress)
+			}
+			progress.fileRead(fileReadResult{}) // A nil reporter ignores reads
+		})
+	}
+}
+
+func TestAskReportProgress(t *testing.T) {
+	dir := t.TempDir()
+	var paths []any
+	for i := range 3 {
+		path := filepath.Join(dir, fmt.Sprintf("f%d.txt", i))
+		if err := os.WriteFile(path, []byte("0123456789"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	tests := []struct {
+		name   string
+		report bool
+		want   int
+	}{
+		{"requested", true, 3},
+		{"not requested", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, &mockDeepseekClient{}, map[string]string{"DEEPSEEK_ALLOWED_FILE_PATHS": dir})
+			srv := server.NewMCPServer("test", "1.0")
+			srv.AddTool(mcp.NewTool("deepseek_ask"), s.handleAskDeepseek)
+			session := &testSession{notifications: make(chan mcp.JSONRPCNotification, 10)}
+			ctx := srv.WithContext(context.Background(), session)
+
+			message, err := json.Marshal(map[string]any{
+				"jsonrpc": "2.0", "id": 1, "method": "tools/call",
+				"params": map[string]any{
+					"name":      "deepseek_ask",
+					"arguments": map[string]any{"query": "q", "file_paths": paths, "report_progress": tt.report},
+					"_meta":     map[string]any{"progressToken": "token-1"},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			srv.HandleMessage(ctx, message)
+			close(session.notifications)
+
+			var progress []int
+			for notification := range session.notifications {
+				if notification.Method != "notifications/progress" {
+					continue
+				}
+				fields := notification.Params.AdditionalFields
+				if fields["progressToken"] != "token-1" || fields["total"] != 3 {
+					t.Errorf("progress notification %v, want token-1 with a total of 3", fields)
+				}
+				progress = append(progress, fields["progress"].(int))
+			}
+			if len(progress) != tt.want {
+				t.Fatalf("got %d progress notifications, want %d", len(progress), tt.want)
+			}
+			for i, value := range progress {
+				if value != i+1 {
+					t.Errorf("progress = %v, want increasing by one", progress)
+					break
+				}
+			}
+		})
+	}
+}
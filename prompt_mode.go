@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Built-in system prompt profiles selected by the mode parameter
+const (
+	PromptModeCode  = "code"
+	PromptModeProse = "prose"
+)
+
+// promptModeProfiles are the built-in system prompts of each mode. The code profile is also the
+// default system prompt when DEEPSEEK_SYSTEM_PROMPT and DEEPSEEK_SYSTEM_PROMPT_FILE are unset.
+var promptModeProfiles = map[string]string{
+	PromptModeCode: "You are a helpful AI assistant that specializes in code review and software engineering. " +
+		"Write code that is correct, idiomatic for its language and consistent with the code around it, and prefer " +
+		"the simplest solution that handles the edge cases. Keep explanations terse: state what changes and why, " +
+		"and skip restating the question. When reviewing, give specific, actionable feedback on bugs, security " +
+		"vulnerabilities, performance problems and code quality, with examples.",
+	PromptModeProse: "You are a helpful AI assistant that explains software engineering topics clearly. " +
+		"Write well-structured prose for a reader who may not know the code: introduce the concepts involved, " +
+		"explain the reasoning behind each point and the trade-offs between alternatives, and use short code " +
+		"snippets only where they illustrate the explanation. Prefer plain language over jargon, and define " +
+		"terms the reader may not know.",
+}
+
+// lookupPromptMode validates a mode parameter. An empty mode is returned as is, meaning the
+// configured system prompt is used.
+func lookupPromptMode(mode string) (string, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	if mode == "" {
+		return "", nil
+	}
+	if _, ok := promptModeProfiles[mode]; !ok {
+		return "", fmt.Errorf("unsupported mode %q, supported values are %q and %q", mode, PromptModeCode, PromptModeProse)
+	}
+	return mode, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupPromptMode(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"code", PromptModeCode, false},
+		{" Prose ", PromptModeProse, false},
+		{"poetry", "", true},
+	}
+	for _, tt := range tests {
+		got, err := lookupPromptMode(tt.mode)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("lookupPromptMode(%q) = %q, %v, want %q, error %v", tt.mode, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestAskPromptMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		args       map[string]any
+		wantPrompt string
+		wantError  string
+	}{
+		{name: "code by default", args: map[string]any{}, wantPrompt: promptModeProfiles[PromptModeCode]},
+		{name: "prose", args: map[string]any{"mode": "prose"}, wantPrompt: promptModeProfiles[PromptModeProse]},
+		{name: "configured prompt kept without a mode", env: map[string]string{"DEEPSEEK_SYSTEM_PROMPT": "Be brief."},
+			args: map[string]any{}, wantPrompt: "Be brief."},
+		{name: "mode overrides the configured prompt", env: map[string]string{"DEEPSEEK_SYSTEM_PROMPT": "Be brief."},
+			args: map[string]any{"mode": "code"}, wantPrompt: promptModeProfiles[PromptModeCode]},
+		{name: "inline prompt overrides the mode", args: map[string]any{"mode": "prose", "systemPrompt": "Answer in haiku."},
+			wantPrompt: "Answer in haiku."},
+		{name: "unknown mode", args: map[string]any{"mode": "poetry"}, wantError: "Invalid mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{}
+			s := newTestServer(t, client, tt.env)
+			tt.args["query"] = "q"
+			result := callTool(t, s.handleAskDeepseek, tt.args)
+			if tt.wantError != "" {
+				if !result.IsError || !strings.Contains(resultText(result), tt.wantError) {
+					t.Errorf("result = %q, want error %q", resultText(result), tt.wantError)
+				}
+				return
+			}
+			if result.IsError {
+				t.Fatalf("request failed: %s", resultText(result))
+			}
+			if got := client.requests[0].Messages[0].Content; got != tt.wantPrompt {
+				t.Errorf("system prompt = %q, want %q", got, tt.wantPrompt)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// readmeNames are the file names looked up by auto_include_readme, in order of preference
+var readmeNames = []string{"README.md", "readme.md", "Readme.md"}
+
+// readmeLabel introduces a README added by auto_include_readme, so that the model can tell
+// project context apart from the files it was asked about
+const readmeLabel = "\n\n*Project context added automatically: the README nearest to one or more of the files below.*"
+
+// findNearestReadmes returns the nearest README of the directory of each path, looking in the
+// directory itself and then in its parents up to the allowed root containing it. Each README is
+// returned once, in the order first needed, and READMEs that are already among paths, also
+// through a symlink or a relative path, are left out. Without allowed roots only the directory
+// of each path is searched.
+func findNearestReadmes(paths []string, cfg *Config) []string {
+	given := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		given[canonicalPath(path)] = true
+	}
+
+	nearest := make(map[string]string) // Directory to its nearest README, "" if none
+	included := make(map[string]bool)
+	var readmes []string
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			continue
+		}
+		readme := nearestReadme(filepath.Dir(abs), cfg.AllowedFilePaths, nearest)
+		if readme == "" {
+			continue
+		}
+		key := canonicalPath(readme)
+		if given[key] || included[key] {
+			continue
+		}
+		included[key] = true
+		readmes = append(readmes, readme)
+	}
+	return readmes
+}
+
+// nearestReadme returns the README in dir or its nearest parent within the allowed roots,
+// remembering the result of every directory it visits in nearest
+func nearestReadme(dir string, allowedDirs []string, nearest map[string]string) string {
+	var visited []string
+	readme := ""
+	for {
+		if cached, ok := nearest[dir]; ok {
+			readme = cached
+			break
+		}
+		if len(allowedDirs) > 0 && !isPathAllowed(dir, allowedDirs) {
+			break
+		}
+		visited = append(visited, dir)
+		if readme = readmeIn(dir); readme != "" {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if len(allowedDirs) == 0 || parent == dir {
+			break
+		}
+		dir = parent
+	}
+	for _, d := range visited {
+		nearest[d] = readme
+	}
+	return readme
+}
+
+// readmeIn returns the path of the README file in dir, or "" if it has none
+func readmeIn(dir string) string {
+	for _, name := range readmeNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+	}
+	return ""
+}
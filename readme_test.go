@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindNearestReadmes(t *testing.T) {
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"README.md":            "# project",
+		"pkg/a/a.go":           "package a",
+		"pkg/a/b.go":           "package a",
+		"pkg/b/README.md":      "# b",
+		"pkg/b/b.go":           "package b",
+		"other/readme.md":      "# other",
+		"other/nested/main.go": "package main",
+	} {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := filepath.Join(t.TempDir(), "README.md")
+	if err := os.Symlink(filepath.Join(root, "README.md"), link); err != nil {
+		t.Fatal(err)
+	}
+	cfg := newTestConfig(t, map[string]string{"DEEPSEEK_ALLOWED_FILE_PATHS": root})
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{"parent README", []string{"pkg/a/a.go"}, []string{"README.md"}},
+		{"found once for files sharing it", []string{"pkg/a/a.go", "pkg/a/b.go"}, []string{"README.md"}},
+		{"nearest README wins", []string{"pkg/b/b.go", "pkg/a/a.go"}, []string{"pkg/b/README.md", "README.md"}},
+		{"lower-case name", []string{"other/nested/main.go"}, []string{"other/readme.md"}},
+		{"already listed", []string{"README.md", "pkg/a/a.go"}, nil},
+		{"already listed through a symlink", []string{link, "pkg/a/a.go"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var paths, want []string
+			for _, path := range tt.paths {
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(root, path)
+				}
+				paths = append(paths, path)
+			}
+			for _, path := range tt.want {
+				want = append(want, filepath.Join(root, path))
+			}
+			got := findNearestReadmes(paths, cfg)
+			if strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("findNearestReadmes() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestAskAutoIncludeReadme(t *testing.T) {
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"README.md": "# project readme",
+		"a.go":      "package a",
+		"b.go":      "package b",
+	} {
+		if err := os.WriteFile(filepath.Join(root, path), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		files     []string
+		limit     string
+		wantError string
+	}{
+		{name: "included", files: []string{"a.go"}},
+		{name: "included once when listed", files: []string{"README.md", "a.go"}},
+		{name: "counted against the limit", files: []string{"a.go", "b.go"}, limit: "2", wantError: "Too many files matched by file_paths: 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"DEEPSEEK_ALLOWED_FILE_PATHS": root}
+			if tt.limit != "" {
+				env["DEEPSEEK_MAX_FILE_PATHS"] = tt.limit
+			}
+			client := &mockDeepseekClient{}
+			s := newTestServer(t, client, env)
+			var files []any
+			for _, file := range tt.files {
+				files = append(files, filepath.Join(root, file))
+			}
+			result := callTool(t, s.handleAskDeepseek, map[string]any{"query": "q", "file_paths": files, "auto_include_readme": true})
+			if tt.wantError != "" {
+				if !result.IsError || !strings.Contains(resultText(result), tt.wantError) {
+					t.Errorf("result = %q, want error %q", resultText(result), tt.wantError)
+				}
+				return
+			}
+			if result.IsError {
+				t.Fatalf("request failed: %s", resultText(result))
+			}
+			messages := client.requests[0].Messages
+			prompt := messages[len(messages)-1].Content
+			if n := strings.Count(prompt, "# project readme"); n != 1 {
+				t.Errorf("README included %d times, want once:\n%s", n, prompt)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// reasoningReserveWarnRatio is the share of the reasoning token reserve above which a response
+// is reported as having likely needed a larger reserve
+const reasoningReserveWarnRatio = 0.9
+
+// isReasoningModel reports whether a model spends tokens on hidden reasoning before answering
+func (c *Config) isReasoningModel(modelID string) bool {
+	capabilities, _ := c.ModelCapabilities(modelID)
+	for _, capability := range capabilities {
+		if capability == CapabilityReasoning {
+			return true
+		}
+	}
+	return false
+}
+
+// projectedCompletionTokens returns the completion size assumed for cost estimates. Reasoning
+// models get the configured reasoning reserve on top, since their reasoning is billed as output.
+func (c *Config) projectedCompletionTokens(modelID string) int {
+	if c.isReasoningModel(modelID) {
+		return projectedOutputTokens + c.ReasoningTokenReserve
+	}
+	return projectedOutputTokens
+}
+
+// estimateReasoningTokens estimates the tokens spent on reasoning from the reasoning content of
+// the first choice. The deepseek-go library does not expose the reasoning token count reported
+// by the API, so this is an estimate.
+func estimateReasoningTokens(response *deepseek.ChatCompletionResponse) int {
+	if len(response.Choices) == 0 || response.Choices[0].Message.ReasoningContent == "" {
+		return 0
+	}
+	return deepseek.EstimateTokenCount(response.Choices[0].Message.ReasoningContent).EstimatedTokens
+}
+
+// formatUsageFooter formats the token usage footer appended to responses. Reasoning tokens are
+// shown separately from the answer for reasoning models.
+func formatUsageFooter(usage deepseek.Usage, reasoningTokens int, reasoning bool) string {
+	var sb strings.Builder
+	sb.WriteString("\n\n---\n**Usage:**\n")
+	sb.WriteString(fmt.Sprintf("- Prompt tokens: %d (%d from cache)\n", usage.PromptTokens, usage.PromptCacheHitTokens))
+	if reasoning {
+		reasoningTokens = min(reasoningTokens, usage.CompletionTokens)
+		sb.WriteString(fmt.Sprintf("- Completion tokens: %d (~%d reasoning, ~%d answer)\n",
+			usage.CompletionTokens, reasoningTokens, usage.CompletionTokens-reasoningTokens))
+	} else {
+		sb.WriteString(fmt.Sprintf("- Completion tokens: %d\n", usage.CompletionTokens))
+	}
+	sb.WriteString(fmt.Sprintf("- Total tokens: %d", usage.TotalTokens))
+	return sb.String()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestProjectedCompletionTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		reserve string
+		model   string
+		want    int
+	}{
+		{"chat model", "", "deepseek-chat", projectedOutputTokens},
+		{"reasoning model", "", "deepseek-reasoner", projectedOutputTokens + 8192},
+		{"custom reserve", "1000", "deepseek-reasoner", projectedOutputTokens + 1000},
+		{"unknown model", "", "other-model", projectedOutputTokens},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{}
+			if tt.reserve != "" {
+				env["DEEPSEEK_REASONING_TOKEN_RESERVE"] = tt.reserve
+			}
+			cfg := newTestConfig(t, env)
+			if got := cfg.projectedCompletionTokens(tt.model); got != tt.want {
+				t.Errorf("projectedCompletionTokens(%q) = %d, want %d", tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEstimateReasoningTokens(t *testing.T) {
+	withReasoning := textResponse("answer")
+	withReasoning.Choices[0].Message.ReasoningContent = strings.Repeat("thinking about it ", 20)
+	tests := []struct {
+		name     string
+		response *deepseek.ChatCompletionResponse
+		wantZero bool
+	}{
+		{"no choices", &deepseek.ChatCompletionResponse{}, true},
+		{"no reasoning", textResponse("answer"), true},
+		{"reasoning", withReasoning, false},
+	}
+	for _, tt := range tests {
+		if got := estimateReasoningTokens(tt.response); (got == 0) != tt.wantZero {
+			t.Errorf("estimateReasoningTokens(%s) = %d, want zero %v", tt.name, got, tt.wantZero)
+		}
+	}
+}
+
+func TestFormatUsageFooter(t *testing.T) {
+	usage := deepseek.Usage{PromptTokens: 100, PromptCacheHitTokens: 40, CompletionTokens: 50, TotalTokens: 150}
+	tests := []struct {
+		name            string
+		reasoningTokens int
+		reasoning       bool
+		want            string
+	}{
+		{
+			name: "chat model",
+			want: "\n\n---\n**Usage:**\n- Prompt tokens: 100 (40 from cache)\n- Completion tokens: 50\n- Total tokens: 150",
+		},
+		{
+			name:            "reasoning model",
+			reasoningTokens: 30,
+			reasoning:       true,
+			want:            "\n\n---\n**Usage:**\n- Prompt tokens: 100 (40 from cache)\n- Completion tokens: 50 (~30 reasoning, ~20 answer)\n- Total tokens: 150",
+		},
+		{
+			name:            "estimate above completion",
+			reasoningTokens: 80,
+			reasoning:       true,
+			want:            "\n\n---\n**Usage:**\n- Prompt tokens: 100 (40 from cache)\n- Completion tokens: 50 (~50 reasoning, ~0 answer)\n- Total tokens: 150",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatUsageFooter(usage, tt.reasoningTokens, tt.reasoning); got != tt.want {
+				t.Errorf("formatUsageFooter() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAskIncludeUsage(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       map[string]any
+		wantFooter bool
+	}{
+		{"omitted", map[string]any{"query": "q"}, false},
+		{"requested", map[string]any{"query": "q", "include_usage": true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+				response := textResponse("answer")
+				response.Usage = deepseek.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+				return response, nil
+			}}
+			s := newTestServer(t, client, nil)
+			text := resultText(callTool(t, s.handleAskDeepseek, tt.args))
+			footer := "- Completion tokens: 5 (~0 reasoning, ~5 answer)"
+			if strings.Contains(text, footer) != tt.wantFooter {
+				t.Errorf("result %q contains usage footer = %v, want %v", text, !tt.wantFooter, tt.wantFooter)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// parseModelRecommendations parses use-case recommendations in the form
+// "model=best for coding|cheapest;model2=best for reasoning"
+func parseModelRecommendations(value string) (map[string][]string, error) {
+	recommendations := make(map[string][]string)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		model, uses, ok := strings.Cut(entry, "=")
+		model = strings.TrimSpace(model)
+		if !ok || model == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected model=use case|use case", entry)
+		}
+
+		var list []string
+		for _, use := range strings.Split(uses, "|") {
+			if use = strings.TrimSpace(use); use != "" {
+				list = append(list, use)
+			}
+		}
+		if len(list) == 0 {
+			return nil, fmt.Errorf("no use cases given for model %s", model)
+		}
+		recommendations[model] = append(recommendations[model], list...)
+	}
+	return recommendations, nil
+}
+
+// formatModelRecommendations formats recommendations in the form accepted by parseModelRecommendations
+func formatModelRecommendations(recommendations map[string][]string) string {
+	models := make([]string, 0, len(recommendations))
+	for model := range recommendations {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+
+	entries := make([]string, 0, len(models))
+	for _, model := range models {
+		entries = append(entries, model+"="+strings.Join(recommendations[model], "|"))
+	}
+	return strings.Join(entries, ";")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// minRedactedSecretLen is the shortest secret that is redacted by value. Shorter values would
+// match too much unrelated text.
+const minRedactedSecretLen = 8
+
+var (
+	// bearerTokenRe matches bearer tokens, e.g. in an echoed Authorization header
+	bearerTokenRe = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/-]+=*`)
+	// apiKeyRe matches strings shaped like DeepSeek API keys
+	apiKeyRe = regexp.MustCompile(`\bsk-[A-Za-z0-9]{16,}`)
+)
+
+// secretRegistry holds the secret values redacted from logs and tool errors
+var secretRegistry struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// registerSecret adds a secret, such as the configured API key, to the values redacted by
+// redactSecrets
+func registerSecret(secret string) {
+	if len(secret) < minRedactedSecretLen {
+		return
+	}
+	secretRegistry.mu.Lock()
+	defer secretRegistry.mu.Unlock()
+	for _, known := range secretRegistry.secrets {
+		if known == secret {
+			return
+		}
+	}
+	secretRegistry.secrets = append(secretRegistry.secrets, secret)
+}
+
+// redactSecrets masks registered secrets, bearer tokens and strings resembling API keys in text
+func redactSecrets(text string) string {
+	secretRegistry.mu.RLock()
+	for _, secret := range secretRegistry.secrets {
+		text = strings.ReplaceAll(text, secret, maskSecret(secret))
+	}
+	secretRegistry.mu.RUnlock()
+	text = bearerTokenRe.ReplaceAllString(text, "${1}****")
+	return apiKeyRe.ReplaceAllStringFunc(text, maskSecret)
+}
+
+// redactToolErrors wraps a tool handler so that the text of error results is redacted.
+// Error results often quote errors from the API client, which may include request details.
+func redactToolErrors(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := handler(ctx, req)
+		if result != nil && result.IsError {
+			for i, content := range result.Content {
+				if text, ok := content.(mcp.TextContent); ok {
+					text.Text = redactSecrets(text.Text)
+					result.Content[i] = text
+				}
+			}
+			if structured, ok := result.StructuredContent.(map[string]any); ok {
+				if detail, ok := structured["error"].(toolErrorDetail); ok {
+					detail.Message = redactSecrets(detail.Message)
+					structured["error"] = detail
+				}
+			}
+		}
+		return result, err
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// refusalPhrases are openings of responses in which the model declines to answer. They make up
+// the default refusal patterns and are matched case-insensitively near the start of the response.
+var refusalPhrases = []string{
+	"i can't help",
+	"i cannot help",
+	"i can't assist",
+	"i cannot assist",
+	"i'm unable to",
+	"i am unable to",
+	"i'm not able to",
+	"i am not able to",
+	"i'm sorry, but i can't",
+	"i'm sorry, but i cannot",
+	"sorry, i can't",
+	"sorry, i cannot",
+	"as an ai",
+}
+
+// refusalWindowChars is how far into a response the default refusal phrases are looked for
+const refusalWindowChars = 200
+
+// refusalPattern is a regular expression that flags a response as a refusal or as disallowed
+type refusalPattern struct {
+	Source string // The pattern as configured, for logs and notes
+	re     *regexp.Regexp
+}
+
+// defaultRefusalPatterns returns the built-in refusal patterns. They are kept conservative, only
+// matching the phrases at the start of a response, so that answers quoting them are not flagged.
+func defaultRefusalPatterns() []refusalPattern {
+	patterns := make([]refusalPattern, 0, len(refusalPhrases))
+	for _, phrase := range refusalPhrases {
+		patterns = append(patterns, refusalPattern{
+			Source: phrase,
+			re:     regexp.MustCompile(fmt.Sprintf(`(?i)\A(?s:.{0,%d}?)%s`, refusalWindowChars, regexp.QuoteMeta(phrase))),
+		})
+	}
+	return patterns
+}
+
+// loadRefusalPatterns reads additional refusal patterns from a file with one regular expression
+// per line. Blank lines and lines starting with # are ignored. Patterns are case-insensitive
+// and match anywhere in the response.
+func loadRefusalPatterns(path string) ([]refusalPattern, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read refusal patterns file: %w", err)
+	}
+
+	var patterns []refusalPattern
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		source := strings.TrimSpace(scanner.Text())
+		if source == "" || strings.HasPrefix(source, "#") {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern on line %d of %s: %w", line, path, err)
+		}
+		patterns = append(patterns, refusalPattern{Source: source, re: re})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read refusal patterns file %s: %w", path, err)
+	}
+	return patterns, nil
+}
+
+// matchRefusal returns the first refusal pattern matching a response, or an empty string
+// if the response does not look like a refusal
+func (c *Config) matchRefusal(content string) string {
+	content = strings.ReplaceAll(strings.TrimSpace(content), "’", "'")
+	for _, pattern := range c.RefusalPatterns {
+		if pattern.re.MatchString(content) {
+			return pattern.Source
+		}
+	}
+	return ""
+}
+
+// formatRefusalFooter formats the note flagging a response that matched a refusal pattern
+func formatRefusalFooter(pattern string) string {
+	return fmt.Sprintf("\n\n---\n*Refused: the response matched the refusal pattern %q.*", pattern)
+}
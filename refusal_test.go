@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestMatchRefusal(t *testing.T) {
+	dir := t.TempDir()
+	patternsFile := filepath.Join(dir, "refusals.txt")
+	if err := os.WriteFile(patternsFile, []byte("# house rules\n\npolicy violation\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := newTestConfig(t, map[string]string{"DEEPSEEK_REFUSAL_PATTERNS_FILE": patternsFile})
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"default phrase", "I can't help with that request.", "i can't help"},
+		{"curly apostrophe", "  I’m unable to do this.", "i'm unable to"},
+		{"after a short opening", "Thanks for asking. Sorry, I cannot share that.", "sorry, i cannot"},
+		{"quoted later in an answer", strings.Repeat("x", refusalWindowChars+1) + " I can't help", ""},
+		{"custom pattern anywhere", strings.Repeat("x", 500) + " POLICY VIOLATION detected", "policy violation"},
+		{"answer", "Here is the refactored function.", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.matchRefusal(tt.content); got != tt.want {
+				t.Errorf("matchRefusal(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadRefusalPatterns(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr string
+	}{
+		{"patterns", "# comment\n\n  refuse\\w+  \nnot allowed\n", []string{`refuse\w+`, "not allowed"}, ""},
+		{"invalid pattern", "ok\n(unclosed\n", nil, "invalid pattern on line 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "patterns.txt")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			patterns, err := loadRefusalPatterns(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadRefusalPatterns() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, pattern := range patterns {
+				got = append(got, pattern.Source)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("loadRefusalPatterns() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := loadRefusalPatterns(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("loadRefusalPatterns() accepted a missing file")
+	}
+}
+
+func TestAskRefusalFooter(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   string
+		wantFooter string
+	}{
+		{"refusal", "I cannot assist with that.", `*Refused: the response matched the refusal pattern "i cannot assist".*`},
+		{"answer", "Sure, here it is.", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+				return textResponse(tt.response), nil
+			}}
+			s := newTestServer(t, client, nil)
+			text := resultText(callTool(t, s.handleAskDeepseek, map[string]any{"query": "q"}))
+			if tt.wantFooter != "" && !strings.Contains(text, tt.wantFooter) {
+				t.Errorf("result %q does not contain %q", text, tt.wantFooter)
+			}
+			if tt.wantFooter == "" && strings.Contains(text, "Refused") {
+				t.Errorf("result %q is flagged as a refusal", text)
+			}
+		})
+	}
+}
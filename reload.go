@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// errReloadInProgress is returned when a reload is requested while another one is running
+var errReloadInProgress = errors.New("a configuration reload is already in progress")
+
+// maxReloadValueChars bounds the old and new values shown for a changed setting
+const maxReloadValueChars = 80
+
+// restartOnlySettings are read once at startup, for example to build the API client or the
+// file cache. Changes to them are reported by a reload but only take effect after a restart,
+// so each entry restores the current value into the reloaded configuration.
+var restartOnlySettings = map[string]func(next, current *Config){
+	"DEEPSEEK_API_KEY":                 func(next, current *Config) { next.DeepseekAPIKey = current.DeepseekAPIKey },
+	"DEEPSEEK_CONNECT_TIMEOUT":         func(next, current *Config) { next.ConnectTimeout = current.ConnectTimeout },
+	"DEEPSEEK_MAX_CONCURRENT_REQUESTS": func(next, current *Config) { next.MaxConcurrentRequests = current.MaxConcurrentRequests },
+	"DEEPSEEK_QUEUE_DEPTH":             func(next, current *Config) { next.QueueDepth = current.QueueDepth },
+	"DEEPSEEK_MAX_QUEUE_WAIT":          func(next, current *Config) { next.MaxQueueWait = current.MaxQueueWait },
+	"DEEPSEEK_MAX_OPEN_FILES":          func(next, current *Config) { next.MaxOpenFiles = current.MaxOpenFiles },
+	"DEEPSEEK_FILE_CACHE_MAX_BYTES":    func(next, current *Config) { next.FileCacheMaxBytes = current.FileCacheMaxBytes },
+	"DEEPSEEK_FILE_TEMPLATE":           func(next, current *Config) { next.FileTemplate = current.FileTemplate },
+	"DEEPSEEK_USAGE_LEDGER":            func(next, current *Config) { next.UsageLedgerPath = current.UsageLedgerPath },
+	"DEEPSEEK_ORGANIZATION":            func(next, current *Config) { next.Organization = current.Organization },
+	"DEEPSEEK_PROJECT":                 func(next, current *Config) { next.Project = current.Project },
+	"DEEPSEEK_BREAKER_THRESHOLD":       func(next, current *Config) { next.BreakerThreshold = current.BreakerThreshold },
+	"DEEPSEEK_BREAKER_COOLDOWN":        func(next, current *Config) { next.BreakerCooldown = current.BreakerCooldown },
+	"DEEPSEEK_BALANCE_LOG_INTERVAL":    func(next, current *Config) { next.BalanceLogInterval = current.BalanceLogInterval },
+	"DEEPSEEK_ENABLED_TOOLS":           func(next, current *Config) { next.EnabledTools = current.EnabledTools },
+	"DEEPSEEK_DISABLED_TOOLS":          func(next, current *Config) { next.DisabledTools = current.DisabledTools },
+	"DEEPSEEK_ALLOW_RELOAD_TOOL":       func(next, current *Config) { next.AllowReloadTool = current.AllowReloadTool },
+	"DEEPSEEK_OFFLINE":                 func(next, current *Config) { next.OfflineMode = current.OfflineMode },
+	"DEEPSEEK_OFFLINE_FIXTURES_FILE": func(next, current *Config) {
+		next.OfflineFixturesPath, next.OfflineFixtures = current.OfflineFixturesPath, current.OfflineFixtures
+	},
+	"DEEPSEEK_LOG_LEVEL": func(next, current *Config) { next.LogLevel = current.LogLevel },
+}
+
+// reloadResult describes the outcome of a configuration reload
+type reloadResult struct {
+	Changed         []string // Applied changes, as "KEY: old -> new"
+	RestartRequired []string // Keys of changed settings that need a restart
+}
+
+// dotenvValues returns the settings of a configuration by key, with secrets masked
+func dotenvValues(c *Config) map[string]string {
+	values := make(map[string]string)
+	for _, entry := range c.dotenvEntries() {
+		values[entry.key] = entry.value
+	}
+	return values
+}
+
+// Reload loads the configuration again and applies the settings that can change while the
+// server is running. The API client and other state built at startup are left intact.
+// Concurrent reloads are rejected.
+func (s *DeepseekServer) Reload() (*reloadResult, error) {
+	if !s.reloadMu.TryLock() {
+		return nil, errReloadInProgress
+	}
+	defer s.reloadMu.Unlock()
+
+	load := s.loadConfig
+	if load == nil {
+		load = NewConfig
+	}
+	next, err := load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	current := s.config()
+	currentValues, nextValues := dotenvValues(current), dotenvValues(next)
+
+	result := &reloadResult{}
+	for key, restore := range restartOnlySettings {
+		// The API key is compared unmasked, since keys sharing their last characters mask alike
+		changed := currentValues[key] != nextValues[key] ||
+			(key == "DEEPSEEK_API_KEY" && next.DeepseekAPIKey != current.DeepseekAPIKey)
+		if changed {
+			result.RestartRequired = append(result.RestartRequired, key)
+			restore(next, current)
+		}
+	}
+	sort.Strings(result.RestartRequired)
+
+	if err := next.checkAllowedFilePaths(s.logger); err != nil {
+		return nil, fmt.Errorf("invalid reloaded configuration: %w", err)
+	}
+
+	model, err := s.resolveModel(next)
+	if err != nil {
+		return nil, fmt.Errorf("invalid model in reloaded configuration: %w", err)
+	}
+	next.DeepseekModel = model
+
+	for _, entry := range next.dotenvEntries() {
+		if old := currentValues[entry.key]; old != entry.value {
+			result.Changed = append(result.Changed, fmt.Sprintf("%s: %q -> %q", entry.key,
+				truncateLogField(old, maxReloadValueChars), truncateLogField(entry.value, maxReloadValueChars)))
+		}
+	}
+
+	s.configPtr.Store(next)
+
+	for _, change := range result.Changed {
+		s.logger.Info("Configuration reload changed %s", change)
+	}
+	for _, key := range result.RestartRequired {
+		s.logger.Warn("Configuration reload: %s changed but requires a restart to take effect", key)
+	}
+	s.logger.Info("Configuration reloaded: %d setting(s) changed, %d require a restart",
+		len(result.Changed), len(result.RestartRequired))
+	return result, nil
+}
+
+// reloadOnSignal reloads the configuration whenever the process receives SIGHUP,
+// until the context is done
+func (s *DeepseekServer) reloadOnSignal(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			s.logger.Info("Received SIGHUP, reloading configuration")
+			if _, err := s.Reload(); err != nil {
+				s.logger.Error("Configuration reload failed: %v", err)
+			}
+		}
+	}
+}
+
+// handleDeepseekReload handles requests to the deepseek_reload tool
+func (s *DeepseekServer) handleDeepseekReload(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.logger.Info("Reloading configuration on request")
+
+	result, err := s.Reload()
+	if err != nil {
+		s.logger.Error("Configuration reload failed: %v", err)
+		return toolError(ErrorCodeConfig, fmt.Sprintf("Configuration reload failed: %v. The previous configuration is still in use.", err)), nil
+	}
+
+	var formattedContent strings.Builder
+	formattedContent.WriteString("# Configuration Reloaded\n\n")
+	if len(result.Changed) == 0 {
+		formattedContent.WriteString("*No settings changed*\n")
+	} else {
+		formattedContent.WriteString("## Applied Changes\n\n")
+		for _, change := range result.Changed {
+			formattedContent.WriteString("- " + change + "\n")
+		}
+	}
+	if len(result.RestartRequired) > 0 {
+		formattedContent.WriteString("\n## Restart Required\n\n")
+		formattedContent.WriteString("These settings changed but are only read at startup, so the previous values are still in use:\n\n")
+		for _, key := range result.RestartRequired {
+			formattedContent.WriteString("- " + key + "\n")
+		}
+	}
+	return mcp.NewToolResultText(formattedContent.String()), nil
+}
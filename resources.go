@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/url"
+	"path/filepath"
+	"unicode/utf8"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// attachedFile is an included file returned to the client as a resource next to the answer
+type attachedFile struct {
+	Path    string
+	Content []byte
+	Inline  bool // Given through inline_files, so Path is only a name
+}
+
+// fileResourceURI returns the URI identifying an attached file: a file URI for files read from
+// disk and an inline URI for files given by content
+func fileResourceURI(file attachedFile) string {
+	if file.Inline {
+		return "inline:" + url.PathEscape(file.Path)
+	}
+	absPath, err := filepath.Abs(file.Path)
+	if err != nil {
+		absPath = file.Path
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(absPath)}).String()
+}
+
+// fileResource converts an attached file into an embedded resource. UTF-8 content is sent as
+// text and anything else as a base64 blob.
+func fileResource(file attachedFile) mcp.EmbeddedResource {
+	uri, mimeType := fileResourceURI(file), getMimeTypeFromPath(file.Path)
+	if utf8.Valid(file.Content) {
+		return mcp.NewEmbeddedResource(mcp.TextResourceContents{URI: uri, MIMEType: mimeType, Text: string(file.Content)})
+	}
+	return mcp.NewEmbeddedResource(mcp.BlobResourceContents{
+		URI:      uri,
+		MIMEType: mimeType,
+		Blob:     base64.StdEncoding.EncodeToString(file.Content),
+	})
+}
+
+// attachFileResources adds each file to the result as its own resource content block,
+// after the answer
+func attachFileResources(result *mcp.CallToolResult, files []attachedFile) *mcp.CallToolResult {
+	for _, file := range files {
+		result.Content = append(result.Content, fileResource(file))
+	}
+	return result
+}
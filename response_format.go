@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// Response formats supported by the deepseek_ask tool
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// jsonSchemaName is the name sent with json_schema response formats
+const jsonSchemaName = "response"
+
+// isValidResponseFormat reports whether format is a supported response format
+func isValidResponseFormat(format string) bool {
+	switch format {
+	case ResponseFormatText, ResponseFormatJSONObject, ResponseFormatJSONSchema:
+		return true
+	}
+	return false
+}
+
+// resolveResponseFormat determines the response format of a request from the response_format
+// parameter and the deprecated json_mode flag, which is an alias for json_object
+func resolveResponseFormat(format string, jsonMode bool) (string, error) {
+	if format == "" {
+		if jsonMode {
+			return ResponseFormatJSONObject, nil
+		}
+		return ResponseFormatText, nil
+	}
+	if !isValidResponseFormat(format) {
+		return "", fmt.Errorf("unsupported response_format %q, supported values are %q, %q and %q",
+			format, ResponseFormatText, ResponseFormatJSONObject, ResponseFormatJSONSchema)
+	}
+	if jsonMode && format != ResponseFormatJSONObject {
+		return "", fmt.Errorf("json_mode conflicts with response_format %q; use response_format alone", format)
+	}
+	return format, nil
+}
+
+// parseJSONSchema parses the schema of a json_schema response format, which must be a JSON object
+func parseJSONSchema(schema string) (map[string]any, error) {
+	if schema == "" {
+		return nil, fmt.Errorf("response_format %q requires a json_schema", ResponseFormatJSONSchema)
+	}
+	var parsed map[string]any
+	if err := json.Unmarshal([]byte(schema), &parsed); err != nil {
+		return nil, fmt.Errorf("json_schema must be a JSON object: %w", err)
+	}
+	return parsed, nil
+}
+
+// applyResponseFormat maps a response format onto the request. The deepseek-go library can only
+// send a format type, so the schema of a json_schema format is added to the request body through
+// the returned context.
+func applyResponseFormat(ctx context.Context, payload *deepseek.ChatCompletionRequest, format string, schema map[string]any) context.Context {
+	switch format {
+	case ResponseFormatJSONObject:
+		payload.ResponseFormat = &deepseek.ResponseFormat{Type: ResponseFormatJSONObject}
+	case ResponseFormatJSONSchema:
+		payload.ResponseFormat = &deepseek.ResponseFormat{Type: ResponseFormatJSONSchema}
+		ctx = withRequestBodyField(ctx, "response_format", map[string]any{
+			"type": ResponseFormatJSONSchema,
+			"json_schema": map[string]any{
+				"name":   jsonSchemaName,
+				"schema": schema,
+				"strict": true,
+			},
+		})
+	}
+	return ctx
+}
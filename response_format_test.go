@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestResolveResponseFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		jsonMode bool
+		want     string
+		wantErr  string
+	}{
+		{name: "default", want: ResponseFormatText},
+		{name: "json_mode alias", jsonMode: true, want: ResponseFormatJSONObject},
+		{name: "explicit text", format: "text", want: ResponseFormatText},
+		{name: "json_schema", format: "json_schema", want: ResponseFormatJSONSchema},
+		{name: "json_mode with json_object", format: "json_object", jsonMode: true, want: ResponseFormatJSONObject},
+		{name: "json_mode conflicts", format: "json_schema", jsonMode: true, wantErr: "conflicts"},
+		{name: "unsupported", format: "yaml", wantErr: `unsupported response_format "yaml"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveResponseFormat(tt.format, tt.jsonMode)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveResponseFormat() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("resolveResponseFormat() = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		schema  string
+		want    map[string]any
+		wantErr string
+	}{
+		{
+			name:   "object",
+			schema: `{"type":"object","required":["name"]}`,
+			want:   map[string]any{"type": "object", "required": []any{"name"}},
+		},
+		{name: "missing", wantErr: "requires a json_schema"},
+		{name: "array", schema: `[1,2]`, wantErr: "must be a JSON object"},
+		{name: "invalid", schema: `{"type":`, wantErr: "must be a JSON object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseJSONSchema(tt.schema)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseJSONSchema() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseJSONSchema() = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyResponseFormat(t *testing.T) {
+	schema := map[string]any{"type": "object"}
+	tests := []struct {
+		format         string
+		wantType       string
+		wantBodyFormat map[string]any
+	}{
+		{format: ResponseFormatText},
+		{format: ResponseFormatJSONObject, wantType: ResponseFormatJSONObject},
+		{
+			format:   ResponseFormatJSONSchema,
+			wantType: ResponseFormatJSONSchema,
+			wantBodyFormat: map[string]any{
+				"type": ResponseFormatJSONSchema,
+				"json_schema": map[string]any{
+					"name":   jsonSchemaName,
+					"schema": schema,
+					"strict": true,
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			payload := &deepseek.ChatCompletionRequest{}
+			ctx := applyResponseFormat(context.Background(), payload, tt.format, schema)
+
+			gotType := ""
+			if payload.ResponseFormat != nil {
+				gotType = payload.ResponseFormat.Type
+			}
+			if gotType != tt.wantType {
+				t.Errorf("ResponseFormat.Type = %q, want %q", gotType, tt.wantType)
+			}
+			bodyFormat, _ := requestBodyFieldsFromContext(ctx)["response_format"].(map[string]any)
+			if !reflect.DeepEqual(bodyFormat, tt.wantBodyFormat) {
+				t.Errorf("response_format body field = %v, want %v", bodyFormat, tt.wantBodyFormat)
+			}
+		})
+	}
+}
+
+func TestAskResponseFormatCapability(t *testing.T) {
+	tests := []struct {
+		name      string
+		args      map[string]any
+		wantError bool
+		wantCalls int
+	}{
+		{
+			name:      "json_object on a model supporting it",
+			args:      map[string]any{"query": "q", "response_format": "json_object"},
+			wantCalls: 1,
+		},
+		{
+			name:      "json_schema on a model without it",
+			args:      map[string]any{"query": "q", "response_format": "json_schema", "json_schema": `{"type":"object"}`},
+			wantError: true,
+		},
+		{
+			name:      "json_schema without a schema",
+			args:      map[string]any{"query": "q", "response_format": "json_schema"},
+			wantError: true,
+		},
+		{
+			name:      "unsupported format",
+			args:      map[string]any{"query": "q", "response_format": "xml"},
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+				return textResponse(`{"ok":true}`), nil
+			}}
+			s := newTestServer(t, client, nil)
+			result := callTool(t, s.handleAskDeepseek, tt.args)
+			if result.IsError != tt.wantError {
+				t.Errorf("IsError = %v, want %v (%s)", result.IsError, tt.wantError, resultText(result))
+			}
+			if client.calls() != tt.wantCalls {
+				t.Errorf("got %d requests, want %d", client.calls(), tt.wantCalls)
+			}
+		})
+	}
+}
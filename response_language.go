@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// responseLanguages maps the ISO 639-1 codes accepted by the response_language
+// parameter to the language name used in the system prompt instruction
+var responseLanguages = map[string]string{
+	"ar": "Arabic",
+	"bg": "Bulgarian",
+	"cs": "Czech",
+	"da": "Danish",
+	"de": "German",
+	"el": "Greek",
+	"en": "English",
+	"es": "Spanish",
+	"et": "Estonian",
+	"fa": "Persian",
+	"fi": "Finnish",
+	"fr": "French",
+	"he": "Hebrew",
+	"hi": "Hindi",
+	"hr": "Croatian",
+	"hu": "Hungarian",
+	"id": "Indonesian",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"lt": "Lithuanian",
+	"lv": "Latvian",
+	"ms": "Malay",
+	"nl": "Dutch",
+	"no": "Norwegian",
+	"pl": "Polish",
+	"pt": "Portuguese",
+	"ro": "Romanian",
+	"ru": "Russian",
+	"sk": "Slovak",
+	"sl": "Slovenian",
+	"sr": "Serbian",
+	"sv": "Swedish",
+	"th": "Thai",
+	"tr": "Turkish",
+	"uk": "Ukrainian",
+	"vi": "Vietnamese",
+	"zh": "Chinese",
+}
+
+// responseLanguageCodes returns the sorted language codes accepted by response_language
+func responseLanguageCodes() []string {
+	codes := make([]string, 0, len(responseLanguages))
+	for code := range responseLanguages {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// lookupResponseLanguage returns the language name for a response_language code
+func lookupResponseLanguage(code string) (string, error) {
+	name, ok := responseLanguages[strings.ToLower(strings.TrimSpace(code))]
+	if !ok {
+		return "", fmt.Errorf("unsupported response_language %q. Supported codes are: %s",
+			code, strings.Join(responseLanguageCodes(), ", "))
+	}
+	return name, nil
+}
+
+// withResponseLanguage appends an instruction to respond in the given language to the system prompt
+func withResponseLanguage(systemPrompt, language string) string {
+	instruction := fmt.Sprintf("Always respond in %s, regardless of the language of the question or the provided files. "+
+		"Keep code, identifiers and quoted file content unchanged.", language)
+	if strings.TrimSpace(systemPrompt) == "" {
+		return instruction
+	}
+	return strings.TrimRight(systemPrompt, "\n") + "\n\n" + instruction
+}
@@ -6,6 +6,8 @@ import (
 	"math/rand"
 	"strings"
 	"time"
+
+	"github.com/cohesion-org/deepseek-go"
 )
 
 // Operation represents a function that might fail and need to be retried
@@ -36,23 +38,36 @@ func IsNetworkError(err error) bool {
 		strings.Contains(errorMessage, "closed")
 }
 
+// IsRateLimitError checks if an error is a rate limit response, or carries a Retry-After wait
+func IsRateLimitError(err error) bool {
+	var retryAfter *retryAfterError
+	if errors.As(err, &retryAfter) {
+		return true
+	}
+	var apiErr *deepseek.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == 429
+}
+
 // IsRetryableError checks if an error should trigger a retry
 func IsRetryableError(err error) bool {
-	return IsTimeoutError(err) || IsNetworkError(err)
+	return IsTimeoutError(err) || IsNetworkError(err) || IsRateLimitError(err)
 }
 
-// RetryWithBackoff retries an operation with exponential backoff
+// RetryWithBackoff retries an operation with exponential backoff. When an error carries the
+// Retry-After wait of the response, that wait is used instead, capped at maxRetryWait.
 func RetryWithBackoff(
 	ctx context.Context,
 	maxRetries int,
 	initialBackoff time.Duration,
 	maxBackoff time.Duration,
+	maxRetryWait time.Duration,
 	operation Operation,
 	errorClassifier ErrorClassifier,
 	logger Logger,
 ) error {
 	var err error
 	backoff := initialBackoff
+	var delay time.Duration // Actual wait before the current attempt
 
 	// Initialize random with current time
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
@@ -61,7 +76,7 @@ func RetryWithBackoff(
 		// If this is not the first attempt, log the retry
 		if attempt > 0 {
 			logger.Info("Retrying operation (attempt %d/%d) after %v delay",
-				attempt, maxRetries, backoff)
+				attempt, maxRetries, delay)
 		}
 
 		// Attempt the operation
@@ -88,6 +103,18 @@ func RetryWithBackoff(
 			nextBackoff = maxBackoff
 		}
 
+		// Honor the wait the server asked for
+		var retryAfter *retryAfterError
+		if errors.As(err, &retryAfter) {
+			nextBackoff = retryAfter.wait
+			if maxRetryWait > 0 && nextBackoff > maxRetryWait {
+				logger.Warn("Server asked to retry after %v; waiting the maximum of %v instead", retryAfter.wait, maxRetryWait)
+				nextBackoff = maxRetryWait
+			} else {
+				logger.Info("Server asked to retry after %v", retryAfter.wait)
+			}
+		}
+
 		// Wait for backoff period or until context is cancelled
 		select {
 		case <-ctx.Done():
@@ -95,6 +122,7 @@ func RetryWithBackoff(
 		case <-time.After(nextBackoff):
 			// Continue to next attempt
 		}
+		delay = nextBackoff
 
 		// Increase backoff for next attempt (exponential)
 		backoff = time.Duration(float64(backoff) * 2.0)
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// retryAfterHintKey is the context key for the Retry-After hint of an API call
+const retryAfterHintKey contextKey = "retryAfterHint"
+
+// retryAfterHint records the Retry-After header of a rate-limited or unavailable response. The
+// deepseek-go library turns error responses into errors without their headers, so the HTTP
+// client stores the header here for the wrapper that made the call.
+type retryAfterHint struct {
+	mu   sync.Mutex
+	wait time.Duration
+	set  bool
+}
+
+// withRetryAfterHint returns a context whose API responses record their Retry-After header in
+// the returned hint
+func withRetryAfterHint(ctx context.Context) (context.Context, *retryAfterHint) {
+	hint := &retryAfterHint{}
+	return context.WithValue(ctx, retryAfterHintKey, hint), hint
+}
+
+// record stores the Retry-After header of a 429 or 503 response, if it has a valid one
+func (h *retryAfterHint) record(resp *http.Response, now time.Time) {
+	if h == nil || resp == nil {
+		return
+	}
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return
+	}
+	wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+	if !ok {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.wait, h.set = wait, true
+}
+
+// wrap attaches the recorded wait to err, so that retries honor it
+func (h *retryAfterHint) wrap(err error) error {
+	if err == nil || h == nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.set {
+		return err
+	}
+	return &retryAfterError{err: err, wait: h.wait}
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date.
+// Dates in the past result in no wait.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(date.Sub(now), 0), true
+}
+
+// retryAfterError is an API error whose response said how long to wait before retrying
+type retryAfterError struct {
+	err  error
+	wait time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return fmt.Sprintf("%v (retry after %v)", e.err, e.wait)
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{"", 0, false},
+		{"7", 7 * time.Second, true},
+		{" 0 ", 0, true},
+		{"-3", 0, false},
+		{"soon", 0, false},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseRetryAfter(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestRetryAfterHint(t *testing.T) {
+	apiErr := errors.New("rate limited")
+	tests := []struct {
+		name     string
+		status   int
+		header   string
+		err      error
+		wantWait time.Duration
+		wantHint bool
+	}{
+		{"rate limited", http.StatusTooManyRequests, "5", apiErr, 5 * time.Second, true},
+		{"unavailable", http.StatusServiceUnavailable, "2", apiErr, 2 * time.Second, true},
+		{"other status", http.StatusInternalServerError, "5", apiErr, 0, false},
+		{"invalid header", http.StatusTooManyRequests, "later", apiErr, 0, false},
+		{"no error", http.StatusTooManyRequests, "5", nil, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, hint := withRetryAfterHint(context.Background())
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{"Retry-After": {tt.header}}}
+			hint.record(resp, time.Now())
+			err := hint.wrap(tt.err)
+
+			var retryAfter *retryAfterError
+			if got := errors.As(err, &retryAfter); got != tt.wantHint {
+				t.Fatalf("wrap() = %v, want a Retry-After hint %v", err, tt.wantHint)
+			}
+			if tt.wantHint {
+				if retryAfter.wait != tt.wantWait || !errors.Is(err, apiErr) || !IsRateLimitError(err) {
+					t.Errorf("wrap() = %v, want a rate limit error wrapping %v with wait %v", err, apiErr, tt.wantWait)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryWithBackoffHonorsRetryAfter(t *testing.T) {
+	tests := []struct {
+		name         string
+		wait         time.Duration
+		maxRetryWait time.Duration
+		wantLog      string
+	}{
+		{"server wait", 20 * time.Millisecond, time.Second, "Server asked to retry after 20ms"},
+		{"capped wait", time.Hour, 20 * time.Millisecond, "waiting the maximum of 20ms instead"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+			attempts := 0
+			operation := func() error {
+				attempts++
+				if attempts == 1 {
+					return &retryAfterError{err: errors.New("rate limited"), wait: tt.wait}
+				}
+				return nil
+			}
+			start := time.Now()
+			err := RetryWithBackoff(context.Background(), 2, time.Hour, time.Hour, tt.maxRetryWait, operation, IsRetryableError, logger)
+			if err != nil || attempts != 2 {
+				t.Fatalf("RetryWithBackoff() = %v after %d attempts, want success after 2", err, attempts)
+			}
+			if elapsed := time.Since(start); elapsed > 10*time.Second {
+				t.Errorf("retry waited %v, want the Retry-After wait instead of the backoff", elapsed)
+			}
+			if !logger.contains(tt.wantLog) {
+				t.Errorf("log %q does not contain %q", logger.messages, tt.wantLog)
+			}
+		})
+	}
+}
+
+func TestRealClientReportsRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":{"message":"Rate limit reached"}}`))
+	}))
+	defer server.Close()
+
+	apiClient := deepseek.NewClient("test-key", server.URL+"/")
+	apiClient.HTTPClient = newAPIHTTPClient(time.Second, nil)
+	client := &realDeepseekClient{client: apiClient}
+	_, err := client.CreateChatCompletion(context.Background(), &deepseek.ChatCompletionRequest{
+		Model:    "deepseek-chat",
+		Messages: []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "hi"}},
+	})
+	var retryAfter *retryAfterError
+	if !errors.As(err, &retryAfter) || retryAfter.wait != 7*time.Second {
+		t.Fatalf("CreateChatCompletion() error = %v, want a Retry-After wait of 7s", err)
+	}
+	if !IsRetryableError(err) {
+		t.Errorf("IsRetryableError(%v) = false, want true", err)
+	}
+}
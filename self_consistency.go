@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// Ways of combining the answers sampled with self_consistency
+const (
+	SelfConsistencySynthesize = "synthesize"
+	SelfConsistencyAll        = "all"
+)
+
+// isValidSelfConsistencyMode reports whether mode is a supported way of combining samples
+func isValidSelfConsistencyMode(mode string) bool {
+	return mode == SelfConsistencySynthesize || mode == SelfConsistencyAll
+}
+
+// synthesisInstruction asks the model to combine the sampled answers into one
+const synthesisInstruction = "Above are %d independent answers to the same request. Write a single final answer to the request. " +
+	"Where the answers agree, keep what they agree on; where they disagree, follow the majority unless it is clearly wrong. " +
+	"Do not mention the individual answers or that there were several."
+
+// selfConsistencyResult is the outcome of sampling a request several times and combining the answers
+type selfConsistencyResult struct {
+	request   *deepseek.ChatCompletionRequest  // Request of the final response: the synthesis, or the sampled request
+	response  *deepseek.ChatCompletionResponse // Final response, with the usage of all requests combined
+	samples   int                              // Samples requested
+	answered  int                              // Samples that returned an answer
+	cost      float64                          // Total cost of all requests, when pricing is known
+	costKnown bool
+}
+
+// selfConsistency sends the same request samples times in parallel, within the concurrency
+// limit, and combines the answers: all of them under numbered headers, or synthesized into one
+// by a further request. Failed or empty samples are left out; the call fails only if no sample
+// answered or the synthesis failed.
+func (s *DeepseekServer) selfConsistency(ctx context.Context, request *deepseek.ChatCompletionRequest, samples int, mode string) (*selfConsistencyResult, error) {
+	cfg := s.configFrom(ctx)
+	if request.Temperature == 0 && !cfg.temperatureIgnored(request.Model) {
+		s.logger.Warn("Sampling %d answers at temperature 0; they will likely be identical", samples)
+	}
+	s.logger.Info("Sampling %d answers from %s for self-consistency", samples, request.Model)
+
+	responses := make([]*deepseek.ChatCompletionResponse, samples)
+	errs := make([]error, samples)
+	var wg sync.WaitGroup
+	for i := range samples {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i], errs[i] = s.createChatCompletion(ctx, request)
+		}(i)
+	}
+	wg.Wait()
+
+	result := &selfConsistencyResult{request: request, samples: samples, costKnown: true}
+	var usage deepseek.Usage
+	var answers []string
+	var first *deepseek.ChatCompletionResponse
+	var firstErr error
+	for i, response := range responses {
+		if errs[i] != nil {
+			s.logger.Warn("Self-consistency sample %d failed: %v", i+1, errs[i])
+			if firstErr == nil {
+				firstErr = errs[i]
+			}
+			continue
+		}
+		result.addUsage(cfg, request.Model, response.Usage, &usage)
+		if len(response.Choices) == 0 || response.Choices[0].Message.Content == "" {
+			s.logger.Warn("Self-consistency sample %d was empty", i+1)
+			continue
+		}
+		if first == nil {
+			first = response
+		}
+		answers = append(answers, response.Choices[0].Message.Content)
+	}
+	result.answered = len(answers)
+	if result.answered == 0 {
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return nil, fmt.Errorf("all %d samples were empty", samples)
+	}
+	s.logger.Info("%d of %d self-consistency samples answered", result.answered, samples)
+
+	if mode == SelfConsistencyAll || result.answered == 1 {
+		// A single answer is kept as it is, so that it can still be continued if truncated
+		combined := *first
+		if result.answered > 1 {
+			combined.Choices = []deepseek.Choice{{Message: deepseek.Message{
+				Role:    deepseek.ChatMessageRoleAssistant,
+				Content: formatSamples(answers),
+			}}}
+		}
+		combined.Usage = usage
+		result.response = &combined
+		return result, nil
+	}
+
+	synthesis := synthesisRequest(request, answers)
+	response, err := s.createChatCompletion(ctx, synthesis)
+	if err != nil {
+		return nil, fmt.Errorf("synthesizing %d answers: %w", result.answered, err)
+	}
+	result.addUsage(cfg, synthesis.Model, response.Usage, &usage)
+	synthesized := *response
+	synthesized.Usage = usage
+	result.request, result.response = synthesis, &synthesized
+	return result, nil
+}
+
+// addUsage adds the usage and cost of one request to the totals
+func (r *selfConsistencyResult) addUsage(cfg *Config, modelID string, usage deepseek.Usage, total *deepseek.Usage) {
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+	total.PromptCacheHitTokens += usage.PromptCacheHitTokens
+	total.PromptCacheMissTokens += usage.PromptCacheMissTokens
+	if cost, ok := cfg.responseCost(modelID, usage); ok {
+		r.cost += cost
+	} else {
+		r.costKnown = false
+	}
+}
+
+// synthesisRequest returns a copy of request whose last message is followed by the sampled
+// answers and an instruction to combine them into one
+func synthesisRequest(request *deepseek.ChatCompletionRequest, answers []string) *deepseek.ChatCompletionRequest {
+	synthesis := *request
+	synthesis.Messages = append([]deepseek.ChatCompletionMessage{}, request.Messages...)
+	last := &synthesis.Messages[len(synthesis.Messages)-1]
+	var sb strings.Builder
+	sb.WriteString(last.Content)
+	sb.WriteString("\n\n---\n\n# Candidate Answers\n\n")
+	sb.WriteString(formatSamples(answers))
+	sb.WriteString("\n\n---\n\n")
+	sb.WriteString(fmt.Sprintf(synthesisInstruction, len(answers)))
+	last.Content = sb.String()
+	return &synthesis
+}
+
+// formatSamples formats the sampled answers under numbered headers
+func formatSamples(answers []string) string {
+	var sb strings.Builder
+	for i, answer := range answers {
+		if i > 0 {
+			sb.WriteString("\n\n")
+		}
+		sb.WriteString(fmt.Sprintf("## Answer %d\n\n%s", i+1, answer))
+	}
+	return sb.String()
+}
+
+// formatSelfConsistencyFooter formats the note on how many samples answered and their total cost
+func formatSelfConsistencyFooter(result *selfConsistencyResult, mode string) string {
+	combined := "synthesized into one answer"
+	switch {
+	case result.answered == 1:
+		combined = "returned as is"
+	case mode == SelfConsistencyAll:
+		combined = "all returned"
+	}
+	cost := "unknown (no pricing for the model)"
+	if result.costKnown {
+		cost = fmt.Sprintf("$%.4f", result.cost)
+	}
+	return fmt.Sprintf("\n\n---\n*Self-consistency: %d of %d samples answered, %s. Total: %d tokens, cost %s.*",
+		result.answered, result.samples, combined, result.response.Usage.TotalTokens, cost)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestSelfConsistency(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         string
+		failing      int // Samples answering with an error
+		wantCalls    int
+		wantAnswered int
+		wantContent  string // Contained in the final answer
+		wantErr      string
+	}{
+		{name: "synthesized", mode: SelfConsistencySynthesize, wantCalls: 4, wantAnswered: 3, wantContent: "synthesized"},
+		{name: "all answers", mode: SelfConsistencyAll, wantCalls: 3, wantAnswered: 3, wantContent: "## Answer 3\n\nanswer"},
+		{name: "failed sample left out", mode: SelfConsistencySynthesize, failing: 1, wantCalls: 4, wantAnswered: 2, wantContent: "synthesized"},
+		{name: "single answer kept as is", mode: SelfConsistencySynthesize, failing: 2, wantCalls: 3, wantAnswered: 1, wantContent: "answer"},
+		{name: "all samples failed", mode: SelfConsistencyAll, failing: 3, wantCalls: 3, wantErr: "unavailable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sampled atomic.Int64
+			client := &mockDeepseekClient{respond: func(request *deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+				last := request.Messages[len(request.Messages)-1].Content
+				if strings.Contains(last, "# Candidate Answers") {
+					if n := strings.Count(last, "## Answer "); n != tt.wantAnswered {
+						t.Errorf("synthesis request holds %d answers, want %d", n, tt.wantAnswered)
+					}
+					response := textResponse("synthesized")
+					response.Usage = deepseek.Usage{PromptTokens: 30, CompletionTokens: 5, TotalTokens: 35}
+					return response, nil
+				}
+				n := sampled.Add(1)
+				if n <= int64(tt.failing) {
+					return nil, errors.New("service unavailable")
+				}
+				response := textResponse(fmt.Sprintf("answer %d", n))
+				response.Usage = deepseek.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+				return response, nil
+			}}
+			s := newTestServer(t, client, map[string]string{"DEEPSEEK_MAX_RETRIES": "0"})
+			request := &deepseek.ChatCompletionRequest{Model: "deepseek-chat", Temperature: 0.7,
+				Messages: []deepseek.ChatCompletionMessage{{Role: deepseek.ChatMessageRoleUser, Content: "Is 91 prime?"}}}
+
+			result, err := s.selfConsistency(context.Background(), request, 3, tt.mode)
+			if client.calls() != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", client.calls(), tt.wantCalls)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("selfConsistency() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selfConsistency() error = %v", err)
+			}
+			if result.answered != tt.wantAnswered {
+				t.Errorf("answered = %d, want %d", result.answered, tt.wantAnswered)
+			}
+			if content := result.response.Choices[0].Message.Content; !strings.Contains(content, tt.wantContent) {
+				t.Errorf("answer = %q, want one containing %q", content, tt.wantContent)
+			}
+			wantTokens := 15 * tt.wantAnswered
+			if tt.wantCalls > 3 {
+				wantTokens += 35
+			}
+			if got := result.response.Usage.TotalTokens; got != wantTokens {
+				t.Errorf("total tokens = %d, want %d across all requests", got, wantTokens)
+			}
+		})
+	}
+}
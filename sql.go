@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cohesion-org/deepseek-go"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// Supported SQL dialects for the deepseek_sql tool
+var sqlDialects = map[string]string{
+	"postgres": "PostgreSQL",
+	"mysql":    "MySQL",
+	"sqlite":   "SQLite",
+}
+
+// sqlSystemPrompt grounds the model in the task of translating questions into SQL
+const sqlSystemPrompt = "You are an expert database engineer who translates natural-language questions into correct, efficient SQL. " +
+	"Only reference tables and columns that exist in the provided schema. " +
+	"If the question cannot be answered with the schema, say so instead of inventing tables or columns. " +
+	"Always return the query in a single fenced code block tagged sql."
+
+var sqlBlockRe = regexp.MustCompile("(?s)```sql\\s*\\n(.*?)```")
+
+// handleDeepseekSQL handles requests to the deepseek_sql tool
+func (s *DeepseekServer) handleDeepseekSQL(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.logger.Info("Handling deepseek_sql request")
+
+	question, err := req.RequireString("question")
+	if err != nil {
+		s.logger.Error("Missing required 'question' parameter: %v", err)
+		return toolError(ErrorCodeInvalidArgument, "Missing required 'question' parameter: "+err.Error()), nil
+	}
+
+	dialect := strings.ToLower(req.GetString("dialect", "postgres"))
+	dialectName, ok := sqlDialects[dialect]
+	if !ok {
+		return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Unsupported dialect: %s. Supported dialects are postgres, mysql and sqlite", dialect)), nil
+	}
+
+	schema := req.GetString("schema", "")
+	if schemaFile := req.GetString("schema_file", ""); schemaFile != "" {
+		if err := ValidateFilePath(schemaFile, s.config()); err != nil {
+			s.logger.Warn("Schema file validation failed for %s: %v", schemaFile, err)
+			return toolError(fileErrorCode(err), fmt.Sprintf("Schema file validation failed: %v", err)), nil
+		}
+		contentBytes, err := readFile(schemaFile)
+		if err != nil {
+			s.logger.Error("Failed to read schema file %s: %v", schemaFile, err)
+			return toolError(ErrorCodeFileError, fmt.Sprintf("Error reading schema file: %v", err)), nil
+		}
+		if schema != "" {
+			schema += "\n\n"
+		}
+		schema += string(s.redactFileContent(s.config(), schemaFile, contentBytes))
+	}
+
+	explain := req.GetBool("explain", false)
+
+	var prompt strings.Builder
+	prompt.WriteString(fmt.Sprintf("Write a %s query that answers the following question.\n\n", dialectName))
+	prompt.WriteString(fmt.Sprintf("## Question\n\n%s\n\n", question))
+	if schema != "" {
+		prompt.WriteString(fmt.Sprintf("## Schema\n\n```sql\n%s\n```\n\n", strings.TrimSpace(schema)))
+	} else {
+		prompt.WriteString("No schema was provided, so use clear, conventional table and column names and state your assumptions.\n\n")
+	}
+	if explain {
+		prompt.WriteString("After the query, briefly explain how it works.")
+	} else {
+		prompt.WriteString("Return only the query, without any explanation.")
+	}
+
+	requestPayload := &deepseek.ChatCompletionRequest{
+		Model: s.config().DeepseekModel,
+		Messages: []deepseek.ChatCompletionMessage{
+			{Role: deepseek.ChatMessageRoleSystem, Content: sqlSystemPrompt},
+			{Role: deepseek.ChatMessageRoleUser, Content: prompt.String()},
+		},
+		Temperature: s.requestTemperature(s.config(), s.config().DeepseekModel),
+	}
+
+	response, err := s.createChatCompletion(ctx, requestPayload)
+	if err != nil {
+		s.logger.Error("DeepSeek API error: %v", err)
+		return toolError(apiErrorCode(err), fmt.Sprintf("Error from DeepSeek API: %v", err)), nil
+	}
+
+	var responseContent string
+	if len(response.Choices) > 0 {
+		responseContent = response.Choices[0].Message.Content
+	}
+	if strings.TrimSpace(responseContent) == "" {
+		s.logger.Warn("DeepSeek model returned an empty response for SQL generation")
+		return toolError(ErrorCodeInvalidResponse, "The DeepSeek model returned an empty response. Please try rephrasing your question or providing the schema."), nil
+	}
+
+	return mcp.NewToolResultText(formatSQLResponse(responseContent, dialectName, explain)), nil
+}
+
+// formatSQLResponse extracts the SQL query from the model output and formats it with a review warning
+func formatSQLResponse(content, dialectName string, explain bool) string {
+	query := strings.TrimSpace(content)
+	explanation := ""
+	if match := sqlBlockRe.FindStringSubmatchIndex(content); match != nil {
+		query = strings.TrimSpace(content[match[2]:match[3]])
+		explanation = strings.TrimSpace(content[:match[0]] + "\n" + content[match[1]:])
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# Generated %s Query\n\n", dialectName))
+	sb.WriteString(fmt.Sprintf("```sql\n%s\n```\n\n", query))
+	if explain && explanation != "" {
+		sb.WriteString(fmt.Sprintf("## Explanation\n\n%s\n\n", explanation))
+	}
+	sb.WriteString("> **Warning:** This query was generated by an AI model. Review it carefully and test it against a non-production database before running it.\n")
+	return sb.String()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/cohesion-org/deepseek-go"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// responseMetadata describes how a deepseek_ask response was produced
+type responseMetadata struct {
+	RequestID         string         `json:"request_id"`
+	Model             string         `json:"model"`
+	ServedModel       string         `json:"served_model,omitempty"`
+	FinishReason      string         `json:"finish_reason,omitempty"`
+	SystemFingerprint string         `json:"system_fingerprint,omitempty"`
+	Usage             deepseek.Usage `json:"usage"`
+}
+
+// newResponseMetadata collects the metadata of a response to a request
+func newResponseMetadata(requestID string, request *deepseek.ChatCompletionRequest, response *deepseek.ChatCompletionResponse) responseMetadata {
+	metadata := responseMetadata{
+		RequestID:   requestID,
+		Model:       request.Model,
+		ServedModel: response.Model,
+		Usage:       response.Usage,
+	}
+	if len(response.Choices) > 0 {
+		metadata.FinishReason = response.Choices[0].FinishReason
+	}
+	if response.SystemFingerprint != nil {
+		metadata.SystemFingerprint = *response.SystemFingerprint
+	}
+	return metadata
+}
+
+// modelListing is a model as returned in the structured content of deepseek_models
+type modelListing struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	Default      bool     `json:"default"`
+	Verified     bool     `json:"verified"`
+	Capabilities []string `json:"capabilities"` // null when unknown
+	Recommended  []string `json:"recommended,omitempty"`
+}
+
+// withStructuredContent adds data under key as the structured content of a result when
+// DEEPSEEK_STRUCTURED_RESULTS is set, along with a JSON text block for clients that only read
+// content blocks. Otherwise the result is returned unchanged, as text only.
+func (s *DeepseekServer) withStructuredContent(cfg *Config, result *mcp.CallToolResult, key string, data any) *mcp.CallToolResult {
+	if !cfg.StructuredResults {
+		return result
+	}
+	structured := map[string]any{key: data}
+	encoded, err := json.MarshalIndent(structured, "", "  ")
+	if err != nil {
+		s.logger.Error("Failed to marshal structured %s, returning text only: %v", key, err)
+		return result
+	}
+	result.StructuredContent = structured
+	result.Content = append(result.Content, mcp.NewTextContent(string(encoded)))
+	return result
+}
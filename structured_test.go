@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestStructuredResults(t *testing.T) {
+	fingerprint := "fp_test"
+	client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+		response := textResponse("The answer.")
+		response.SystemFingerprint = &fingerprint
+		response.Usage = deepseek.Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}
+		return response, nil
+	}}
+
+	tests := []struct {
+		name       string
+		structured string
+		models     bool   // Call deepseek_models instead of deepseek_ask
+		key        string // Key of the structured content, empty for text only
+	}{
+		{"ask, text only", "false", false, ""},
+		{"ask with metadata", "true", false, "metadata"},
+		{"models, text only", "false", true, ""},
+		{"models with listings", "true", true, "models"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, client, map[string]string{"DEEPSEEK_STRUCTURED_RESULTS": tt.structured, "DEEPSEEK_MODEL": "deepseek-chat"})
+			var result *mcp.CallToolResult
+			if tt.models {
+				result = callTool(t, s.handleDeepseekModels, nil)
+			} else {
+				result = callTool(t, s.handleAskDeepseek, map[string]any{"query": "q"})
+			}
+			if result.IsError {
+				t.Fatalf("request failed: %s", resultText(result))
+			}
+			if tt.key == "" {
+				if len(result.Content) != 1 || result.StructuredContent != nil {
+					t.Errorf("got %d content blocks and structured content %v, want a single text block", len(result.Content), result.StructuredContent)
+				}
+				return
+			}
+
+			if len(result.Content) != 2 {
+				t.Fatalf("got %d content blocks, want the text and a JSON block", len(result.Content))
+			}
+			if _, ok := result.Content[0].(mcp.TextContent); !ok {
+				t.Errorf("first block is %T, want the text", result.Content[0])
+			}
+			block, ok := result.Content[1].(mcp.TextContent)
+			if !ok {
+				t.Fatalf("second block is %T, want JSON text", result.Content[1])
+			}
+			var decoded map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(block.Text), &decoded); err != nil {
+				t.Fatalf("JSON block is not valid JSON: %v\n%s", err, block.Text)
+			}
+			structured, ok := result.StructuredContent.(map[string]any)
+			if !ok || structured[tt.key] == nil || decoded[tt.key] == nil {
+				t.Fatalf("structured content = %#v, want it under %q", result.StructuredContent, tt.key)
+			}
+
+			switch data := structured[tt.key].(type) {
+			case responseMetadata:
+				if data.Model != "deepseek-chat" || data.FinishReason != "stop" || data.SystemFingerprint != fingerprint ||
+					data.Usage.TotalTokens != 15 || data.RequestID == "" {
+					t.Errorf("metadata = %+v", data)
+				}
+			case []modelListing:
+				if len(data) == 0 || data[0].ID == "" {
+					t.Errorf("models = %+v", data)
+				}
+			default:
+				t.Errorf("structured %s is %T", tt.key, data)
+			}
+		})
+	}
+}
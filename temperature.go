@@ -0,0 +1,51 @@
+package main
+
+import "fmt"
+
+// Valid temperature range of the DeepSeek chat API. DeepSeek recommends 0.0 for coding and
+// math, 1.0 for data analysis, 1.3 for general conversation and translation and 1.5 for
+// creative writing.
+const (
+	minTemperature float32 = 0.0
+	maxTemperature float32 = 2.0
+)
+
+// ignoresTemperature reports whether a model ignores the temperature. Reasoning models sample
+// with fixed settings, so the capability table marks them through the reasoning capability.
+func (c *Config) ignoresTemperature(modelID string) bool {
+	return c.isReasoningModel(modelID)
+}
+
+// temperatureIgnored reports whether a configured temperature is ignored by a model
+func (c *Config) temperatureIgnored(modelID string) bool {
+	return c.TemperatureSet && c.ignoresTemperature(modelID)
+}
+
+// clampTemperature limits a temperature to the valid range of a model and reports whether it
+// had to be changed. Temperatures for models that ignore them are left as they are.
+func (c *Config) clampTemperature(modelID string, temperature float32) (float32, bool) {
+	if c.ignoresTemperature(modelID) {
+		return temperature, false
+	}
+	clamped := min(max(temperature, minTemperature), maxTemperature)
+	return clamped, clamped != temperature
+}
+
+// requestTemperature returns the configured temperature to send to a model, clamped to its
+// valid range. Out-of-range and ignored temperatures are logged rather than failing the request.
+func (s *DeepseekServer) requestTemperature(cfg *Config, modelID string) float32 {
+	if cfg.temperatureIgnored(modelID) {
+		s.logger.Info("Temperature %v is ignored by model %s", cfg.DeepseekTemperature, modelID)
+	}
+	temperature, clamped := cfg.clampTemperature(modelID, cfg.DeepseekTemperature)
+	if clamped {
+		s.logger.Warn("Temperature %v is outside the valid range %v-%v of model %s; using %v",
+			cfg.DeepseekTemperature, minTemperature, maxTemperature, modelID, temperature)
+	}
+	return temperature
+}
+
+// formatIgnoredTemperatureNote formats the note appended to a response whose model ignored the temperature
+func formatIgnoredTemperatureNote(temperature float32, modelID string) string {
+	return fmt.Sprintf("\n\n---\nNote: the configured temperature (%v) was ignored, since %s does not support it.", temperature, modelID)
+}
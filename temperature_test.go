@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClampTemperature(t *testing.T) {
+	cfg := newTestConfig(t, nil)
+	tests := []struct {
+		model       string
+		temperature float32
+		want        float32
+		wantClamped bool
+	}{
+		{"deepseek-chat", 0.7, 0.7, false},
+		{"deepseek-chat", 2.0, 2.0, false},
+		{"deepseek-chat", 2.5, 2.0, true},
+		{"deepseek-chat", -1, 0, true},
+		{"deepseek-reasoner", 2.5, 2.5, false},
+	}
+	for _, tt := range tests {
+		got, clamped := cfg.clampTemperature(tt.model, tt.temperature)
+		if got != tt.want || clamped != tt.wantClamped {
+			t.Errorf("clampTemperature(%s, %v) = %v, %v; want %v, %v", tt.model, tt.temperature, got, clamped, tt.want, tt.wantClamped)
+		}
+	}
+}
+
+func TestAskIgnoredTemperatureNote(t *testing.T) {
+	const note = "the configured temperature (1.5) was ignored, since deepseek-reasoner does not support it"
+	tests := []struct {
+		name        string
+		model       string
+		temperature string
+		noteEnabled string
+		wantNote    bool
+		wantSent    float32
+	}{
+		{"reasoning model", "deepseek-reasoner", "1.5", "true", true, 1.5},
+		{"note disabled", "deepseek-reasoner", "1.5", "false", false, 1.5},
+		{"temperature not configured", "deepseek-reasoner", "", "true", false, 0.4},
+		{"chat model", "deepseek-chat", "1.5", "true", false, 1.5},
+		{"chat model clamped", "deepseek-chat", "3", "true", false, 2.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{}
+			env := map[string]string{"DEEPSEEK_NOTE_IGNORED_TEMPERATURE": tt.noteEnabled}
+			if tt.temperature != "" {
+				env["DEEPSEEK_TEMPERATURE"] = tt.temperature
+			}
+			s := newTestServer(t, client, env)
+			text := resultText(callTool(t, s.handleAskDeepseek, map[string]any{"query": "q", "model": tt.model}))
+			if strings.Contains(text, "temperature (") != tt.wantNote {
+				t.Errorf("result %q contains the temperature note = %v, want %v", text, !tt.wantNote, tt.wantNote)
+			}
+			if tt.wantNote && !strings.Contains(text, note) {
+				t.Errorf("result %q does not contain %q", text, note)
+			}
+			if got := client.requests[0].Temperature; got != tt.wantSent {
+				t.Errorf("sent temperature %v, want %v", got, tt.wantSent)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timingPhase is the measured duration of one phase of a request
+type timingPhase struct {
+	Name     string
+	Duration time.Duration
+}
+
+// requestTimings records wall-clock durations of the phases of a deepseek_ask request
+type requestTimings struct {
+	start  time.Time
+	phases []timingPhase
+}
+
+// newRequestTimings starts timing a request
+func newRequestTimings() *requestTimings {
+	return &requestTimings{start: time.Now()}
+}
+
+// Start begins timing a phase; the returned function ends it and records its duration
+func (t *requestTimings) Start(name string) func() {
+	phaseStart := time.Now()
+	return func() {
+		t.phases = append(t.phases, timingPhase{Name: name, Duration: time.Since(phaseStart)})
+	}
+}
+
+// Total returns the time elapsed since the request started
+func (t *requestTimings) Total() time.Duration {
+	return time.Since(t.start)
+}
+
+// Log writes the timing breakdown at debug level
+func (t *requestTimings) Log(logger Logger) {
+	for _, phase := range t.phases {
+		logger.Debug("Timing: %s took %v", phase.Name, phase.Duration)
+	}
+	logger.Debug("Timing: request took %v in total", t.Total())
+}
+
+// formatTimingFooter formats the timing breakdown appended to responses when include_timing is set
+func formatTimingFooter(t *requestTimings) string {
+	var sb strings.Builder
+	sb.WriteString("\n\n---\n**Timing:**\n")
+	for _, phase := range t.phases {
+		sb.WriteString(fmt.Sprintf("- %s: %s\n", phase.Name, formatDuration(phase.Duration)))
+	}
+	sb.WriteString(fmt.Sprintf("- Total: %s", formatDuration(t.Total())))
+	return sb.String()
+}
+
+// formatDuration formats a duration in milliseconds with sub-millisecond precision
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.1f ms", float64(d)/float64(time.Millisecond))
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// toolNames lists the tools the server can register, whether or not the configuration enables them
+var toolNames = []string{
+	"deepseek_ask",
+	"deepseek_models",
+	"deepseek_balance",
+	"deepseek_cancel",
+	"deepseek_usage",
+	"deepseek_allowed_paths",
+	"deepseek_health",
+	"deepseek_reload",
+	"deepseek_token_estimate",
+	"deepseek_batch",
+	"deepseek_presets",
+	"deepseek_sql",
+}
+
+// toolNameKey is the context key for the name of the tool handling a request
+const toolNameKey contextKey = "toolName"
+
+// withToolName wraps a tool handler so that its context carries the tool name, which selects
+// the per-tool timeout of the API calls the handler makes
+func withToolName(name string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return handler(context.WithValue(ctx, toolNameKey, name), req)
+	}
+}
+
+// toolNameFromContext returns the name of the tool handling the request, or "" outside a tool call
+func toolNameFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(toolNameKey).(string)
+	return name
+}
+
+// parseToolTimeouts parses per-tool timeouts in the form "deepseek_ask=10m,deepseek_balance=10".
+// Timeouts are given in seconds or as Go durations, like the other timeouts. Unknown tool names
+// are rejected, so a misspelled name does not silently leave the tool on the default timeout.
+func parseToolTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, entry := range splitList(value) {
+		name, timeoutStr, ok := strings.Cut(entry, "=")
+		name, timeoutStr = strings.TrimSpace(name), strings.TrimSpace(timeoutStr)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid entry %q, expected tool=timeout", entry)
+		}
+		if !slices.Contains(toolNames, name) {
+			return nil, fmt.Errorf("unknown tool %q, expected one of %s", name, strings.Join(toolNames, ", "))
+		}
+		timeout, err := parseTimeout(timeoutStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout for %s: %w", name, err)
+		}
+		timeouts[name] = timeout
+	}
+	return timeouts, nil
+}
+
+// formatToolTimeouts formats timeouts in the form accepted by parseToolTimeouts
+func formatToolTimeouts(timeouts map[string]time.Duration) string {
+	names := make([]string, 0, len(timeouts))
+	for name := range timeouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entries := make([]string, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, name+"="+timeouts[name].String())
+	}
+	return strings.Join(entries, ",")
+}
+
+// toolTimeout returns the timeout configured for a tool, or fallback if it has none
+func (c *Config) toolTimeout(name string, fallback time.Duration) time.Duration {
+	if timeout, ok := c.ToolTimeouts[name]; ok {
+		return timeout
+	}
+	return fallback
+}
+
+// attemptTimeout returns the time allowed for each attempt of an API call made while handling
+// the tool call in ctx, falling back to the given default timeout
+func (s *DeepseekServer) attemptTimeout(ctx context.Context, fallback time.Duration) time.Duration {
+	return s.configFrom(ctx).toolTimeout(toolNameFromContext(ctx), fallback)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseToolTimeouts(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]time.Duration
+		wantErr string
+	}{
+		{"", map[string]time.Duration{}, ""},
+		{"deepseek_ask=10m", map[string]time.Duration{"deepseek_ask": 10 * time.Minute}, ""},
+		{"deepseek_ask=10m, deepseek_balance = 10", map[string]time.Duration{"deepseek_ask": 10 * time.Minute, "deepseek_balance": 10 * time.Second}, ""},
+		{"deepseek_ask", nil, "expected tool=timeout"},
+		{"=10s", nil, "expected tool=timeout"},
+		{"deepseek_ask=soon", nil, "invalid timeout for deepseek_ask"},
+		{"deepseek_aks=10m", nil, `unknown tool "deepseek_aks"`},
+		{"deepseek_ask=10m,startup_error=5s", nil, `unknown tool "startup_error"`},
+	}
+	for _, tt := range tests {
+		got, err := parseToolTimeouts(tt.value)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseToolTimeouts(%q) error = %v, want one containing %q", tt.value, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseToolTimeouts(%q) error = %v", tt.value, err)
+			continue
+		}
+		if formatToolTimeouts(got) != formatToolTimeouts(tt.want) {
+			t.Errorf("parseToolTimeouts(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestToolNamesMatchRegisteredTools(t *testing.T) {
+	source, err := os.ReadFile("main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var registered []string
+	for _, match := range regexp.MustCompile(`mcp\.NewTool\("(deepseek_\w+)"`).FindAllStringSubmatch(string(source), -1) {
+		registered = append(registered, match[1])
+	}
+	if !slices.Equal(registered, toolNames) {
+		t.Errorf("tools registered in main.go = %v, toolNames = %v", registered, toolNames)
+	}
+}
+
+func TestLoadConfigUnknownToolTimeout(t *testing.T) {
+	t.Setenv("DEEPSEEK_API_KEY", "test-key")
+	t.Setenv("DEEPSEEK_TOOL_TIMEOUTS", "deepseek_ask=10m,deepseek_blance=5s")
+	_, err := NewConfig()
+	if err == nil || !strings.Contains(err.Error(), `unknown tool "deepseek_blance"`) {
+		t.Errorf("NewConfig() error = %v, want an unknown tool error", err)
+	}
+}
+
+func TestAttemptTimeout(t *testing.T) {
+	s := newTestServer(t, &mockDeepseekClient{}, map[string]string{"DEEPSEEK_TOOL_TIMEOUTS": "deepseek_ask=10m,deepseek_balance=5s"})
+	fallback := 90 * time.Second
+	tests := []struct {
+		name string
+		tool string // Empty outside a tool call
+		want time.Duration
+	}{
+		{"configured tool", "deepseek_ask", 10 * time.Minute},
+		{"another configured tool", "deepseek_balance", 5 * time.Second},
+		{"tool without a timeout", "deepseek_models", fallback},
+		{"outside a tool call", "", fallback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.tool != "" {
+				ctx = context.WithValue(ctx, toolNameKey, tt.tool)
+			}
+			if got := s.attemptTimeout(ctx, fallback); got != tt.want {
+				t.Errorf("attemptTimeout() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+// maxFunctionTools is the maximum number of function definitions accepted in one request
+const maxFunctionTools = 128
+
+// functionNameRe matches the function names accepted by the API
+var functionNameRe = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// parseFunctionTools converts the tools argument, an array of {name, description, parameters}
+// objects, into function tools for the request
+func parseFunctionTools(raw any) ([]deepseek.Tool, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	items, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("tools must be an array of objects")
+	}
+	if len(items) > maxFunctionTools {
+		return nil, fmt.Errorf("too many tools: %d (maximum %d)", len(items), maxFunctionTools)
+	}
+
+	tools := make([]deepseek.Tool, 0, len(items))
+	seen := make(map[string]bool)
+	for i, item := range items {
+		fields, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("tools[%d] must be an object", i)
+		}
+		name, _ := fields["name"].(string)
+		if !functionNameRe.MatchString(name) {
+			return nil, fmt.Errorf("tools[%d] has an invalid name %q: use up to 64 letters, digits, underscores or dashes", i, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("tools[%d] duplicates the name %q", i, name)
+		}
+		seen[name] = true
+		description, _ := fields["description"].(string)
+
+		function := deepseek.Function{Name: name, Description: description}
+		if rawParams, ok := fields["parameters"]; ok && rawParams != nil {
+			params, err := parseFunctionParameters(rawParams)
+			if err != nil {
+				return nil, fmt.Errorf("tools[%d] (%s): %w", i, name, err)
+			}
+			function.Parameters = params
+		}
+		tools = append(tools, deepseek.Tool{Type: "function", Function: function})
+	}
+	return tools, nil
+}
+
+// parseFunctionParameters converts a JSON schema object into function parameters
+func parseFunctionParameters(raw any) (*deepseek.FunctionParameters, error) {
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+	var params deepseek.FunctionParameters
+	if err := json.Unmarshal(encoded, &params); err != nil {
+		return nil, fmt.Errorf("parameters must be a JSON schema object: %w", err)
+	}
+	if params.Type == "" {
+		params.Type = "object"
+	}
+	if params.Type != "object" {
+		return nil, fmt.Errorf("parameters must have type \"object\", got %q", params.Type)
+	}
+	return &params, nil
+}
+
+// toolCallResult is a function call requested by the model
+type toolCallResult struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// toolCallsResponse is returned instead of text when the model calls functions
+type toolCallsResponse struct {
+	Content      string           `json:"content,omitempty"`
+	ToolCalls    []toolCallResult `json:"tool_calls"`
+	FinishReason string           `json:"finish_reason,omitempty"`
+}
+
+// formatToolCalls formats the function calls of a choice, along with any content the model
+// returned next to them, as JSON. Arguments that are not valid JSON are passed as a string.
+func formatToolCalls(choice deepseek.Choice) (string, error) {
+	result := toolCallsResponse{
+		Content:      choice.Message.Content,
+		ToolCalls:    make([]toolCallResult, 0, len(choice.Message.ToolCalls)),
+		FinishReason: choice.FinishReason,
+	}
+	for _, call := range choice.Message.ToolCalls {
+		arguments := json.RawMessage(call.Function.Arguments)
+		if !json.Valid(arguments) {
+			encoded, err := json.Marshal(call.Function.Arguments)
+			if err != nil {
+				return "", err
+			}
+			arguments = encoded
+		}
+		result.ToolCalls = append(result.ToolCalls, toolCallResult{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: arguments,
+		})
+	}
+
+	encoded, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode tool calls: %w", err)
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cohesion-org/deepseek-go"
+)
+
+func TestParseFunctionTools(t *testing.T) {
+	weather := map[string]any{
+		"name":        "get_weather",
+		"description": "Current weather",
+		"parameters": map[string]any{
+			"properties": map[string]any{"city": map[string]any{"type": "string"}},
+			"required":   []any{"city"},
+		},
+	}
+	tooMany := make([]any, maxFunctionTools+1)
+	for i := range tooMany {
+		tooMany[i] = weather
+	}
+	tests := []struct {
+		name    string
+		raw     any
+		want    []deepseek.Tool
+		wantErr string
+	}{
+		{name: "absent", raw: nil},
+		{
+			name: "tools",
+			raw:  []any{weather, map[string]any{"name": "now"}},
+			want: []deepseek.Tool{
+				{Type: "function", Function: deepseek.Function{
+					Name:        "get_weather",
+					Description: "Current weather",
+					Parameters: &deepseek.FunctionParameters{
+						Type:       "object",
+						Properties: map[string]any{"city": map[string]any{"type": "string"}},
+						Required:   []string{"city"},
+					},
+				}},
+				{Type: "function", Function: deepseek.Function{Name: "now"}},
+			},
+		},
+		{name: "not an array", raw: weather, wantErr: "must be an array"},
+		{name: "too many", raw: tooMany, wantErr: "too many tools"},
+		{name: "not an object", raw: []any{"get_weather"}, wantErr: "tools[0] must be an object"},
+		{name: "invalid name", raw: []any{map[string]any{"name": "get weather"}}, wantErr: `invalid name "get weather"`},
+		{name: "duplicate name", raw: []any{weather, weather}, wantErr: `tools[1] duplicates the name "get_weather"`},
+		{
+			name:    "non-object parameters",
+			raw:     []any{map[string]any{"name": "f", "parameters": map[string]any{"type": "array"}}},
+			wantErr: `parameters must have type "object"`,
+		},
+		{
+			name:    "malformed parameters",
+			raw:     []any{map[string]any{"name": "f", "parameters": map[string]any{"required": "city"}}},
+			wantErr: "parameters must be a JSON schema object",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFunctionTools(tt.raw)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseFunctionTools() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFunctionTools() = %+v, %v; want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatToolCalls(t *testing.T) {
+	call := func(id, name, arguments string) deepseek.ToolCall {
+		return deepseek.ToolCall{ID: id, Type: "function", Function: deepseek.ToolCallFunction{Name: name, Arguments: arguments}}
+	}
+	tests := []struct {
+		name   string
+		choice deepseek.Choice
+		want   string
+	}{
+		{
+			name: "json arguments",
+			choice: deepseek.Choice{
+				Message:      deepseek.Message{ToolCalls: []deepseek.ToolCall{call("call_1", "get_weather", `{"city":"Oslo"}`)}},
+				FinishReason: "tool_calls",
+			},
+			want: `{"tool_calls":[{"id":"call_1","name":"get_weather","arguments":{"city":"Oslo"}}],"finish_reason":"tool_calls"}`,
+		},
+		{
+			name: "invalid arguments and content",
+			choice: deepseek.Choice{
+				Message: deepseek.Message{Content: "Checking", ToolCalls: []deepseek.ToolCall{call("call_2", "now", `{"tz":`)}},
+			},
+			want: `{"content":"Checking","tool_calls":[{"id":"call_2","name":"now","arguments":"{\"tz\":"}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatted, err := formatToolCalls(tt.choice)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got, want any
+			if err := json.Unmarshal([]byte(formatted), &got); err != nil {
+				t.Fatalf("formatToolCalls() returned invalid JSON %q: %v", formatted, err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("formatToolCalls() = %s, want %s", formatted, tt.want)
+			}
+		})
+	}
+}
+
+func TestAskFunctionTools(t *testing.T) {
+	tools := []any{map[string]any{"name": "get_weather"}}
+	tests := []struct {
+		name      string
+		model     string
+		wantError bool
+		wantCalls int
+		wantText  string
+	}{
+		{name: "model with function calling", model: "deepseek-chat", wantCalls: 1, wantText: `"name": "get_weather"`},
+		{name: "model without function calling", model: "deepseek-reasoner", wantError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{respond: func(*deepseek.ChatCompletionRequest) (*deepseek.ChatCompletionResponse, error) {
+				response := textResponse("")
+				response.Choices[0].Message.ToolCalls = []deepseek.ToolCall{{
+					ID: "call_1", Type: "function",
+					Function: deepseek.ToolCallFunction{Name: "get_weather", Arguments: `{}`},
+				}}
+				return response, nil
+			}}
+			s := newTestServer(t, client, nil)
+			result := callTool(t, s.handleAskDeepseek, map[string]any{"query": "weather?", "model": tt.model, "tools": tools})
+			if result.IsError != tt.wantError {
+				t.Fatalf("IsError = %v, want %v (%s)", result.IsError, tt.wantError, resultText(result))
+			}
+			if client.calls() != tt.wantCalls {
+				t.Fatalf("got %d requests, want %d", client.calls(), tt.wantCalls)
+			}
+			if tt.wantCalls == 0 {
+				return
+			}
+			if got := client.requests[0].Tools; len(got) != 1 || got[0].Function.Name != "get_weather" {
+				t.Errorf("request tools = %+v, want get_weather", got)
+			}
+			if text := resultText(result); !strings.Contains(text, tt.wantText) {
+				t.Errorf("result %q does not contain %q", text, tt.wantText)
+			}
+		})
+	}
+}
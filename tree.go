@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Limits for the directory tree included with the deepseek_ask "include_tree" parameter
+const (
+	defaultTreeDepth = 3
+	maxTreeDepth     = 10
+	maxTreeEntries   = 500
+)
+
+// gitignoreRule is a single pattern from a .gitignore file
+type gitignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// gitignore holds the rules of a .gitignore file in the root of a directory tree.
+// It supports the common subset of the format: comments, negation, directory-only
+// patterns, anchored patterns and "**" wildcards.
+type gitignore struct {
+	rules []gitignoreRule
+}
+
+// loadGitignore reads the .gitignore file in dir. A missing file yields no rules.
+func loadGitignore(dir string) *gitignore {
+	ignore := &gitignore{}
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return ignore
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var rule gitignoreRule
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		ignore.rules = append(ignore.rules, rule)
+	}
+	return ignore
+}
+
+// Ignored reports whether a slash-separated path relative to the tree root is ignored.
+// As in git, the last matching rule wins.
+func (g *gitignore) Ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		var matched bool
+		if rule.anchored {
+			matched = matchGlobSegments(strings.Split(rule.pattern, "/"), strings.Split(rel, "/"))
+		} else {
+			matched, _ = path.Match(rule.pattern, path.Base(rel))
+		}
+		if matched {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// directoryTree builds an indented listing of a directory
+type directoryTree struct {
+	root       string
+	maxDepth   int
+	maxEntries int
+	cfg        *Config
+	ignore     *gitignore
+	entries    int
+	truncated  bool
+	sb         strings.Builder
+}
+
+// buildDirectoryTree returns an indented file tree of root, descending at most maxDepth
+// levels and listing at most maxEntries entries. Entries ignored by the root .gitignore
+// and files whose type is not in AllowedFileTypes are omitted.
+func buildDirectoryTree(root string, maxDepth, maxEntries int, cfg *Config) (string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", fmt.Errorf("directory not found or not accessible: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("path is not a directory: %s", root)
+	}
+
+	tree := &directoryTree{
+		root:       root,
+		maxDepth:   maxDepth,
+		maxEntries: maxEntries,
+		cfg:        cfg,
+		ignore:     loadGitignore(root),
+	}
+	tree.sb.WriteString(filepath.Base(filepath.Clean(root)) + "/\n")
+	tree.walk(root, "", 1)
+
+	if tree.truncated {
+		tree.sb.WriteString(fmt.Sprintf("... (truncated after %d entries)\n", maxEntries))
+	}
+	return tree.sb.String(), nil
+}
+
+// walk appends the entries of dir to the tree; rel is dir's path relative to the root
+func (t *directoryTree) walk(dir, rel string, depth int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	// List directories before files, each group sorted by name
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].IsDir() && !entries[j].IsDir()
+	})
+
+	indent := strings.Repeat("  ", depth)
+	for _, entry := range entries {
+		if t.truncated {
+			return
+		}
+
+		name := entry.Name()
+		entryRel := path.Join(rel, name)
+		if name == ".git" || t.ignore.Ignored(entryRel, entry.IsDir()) {
+			continue
+		}
+		if !entry.IsDir() && t.cfg != nil && len(t.cfg.AllowedFileTypes) > 0 &&
+			!isMimeTypeAllowed(getMimeTypeFromPath(name), t.cfg.AllowedFileTypes) {
+			continue
+		}
+
+		if t.entries >= t.maxEntries {
+			t.truncated = true
+			return
+		}
+		t.entries++
+
+		if !entry.IsDir() {
+			t.sb.WriteString(indent + name + "\n")
+			continue
+		}
+		if depth >= t.maxDepth {
+			t.sb.WriteString(indent + name + "/ ...\n")
+			continue
+		}
+		t.sb.WriteString(indent + name + "/\n")
+		t.walk(filepath.Join(dir, name), entryRel, depth+1)
+	}
+}
+
+// formatDirectoryTree wraps a directory tree for inclusion in the query
+func formatDirectoryTree(tree string) string {
+	return "# Project Structure\n\n```\n" + tree + "```\n\n"
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGitignoreIgnored(t *testing.T) {
+	dir := t.TempDir()
+	rules := "# build output\n*.log\n!keep.log\nbin/\n/vendor\ndocs/**/*.tmp\n\n"
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ignore := loadGitignore(dir)
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"sub/app.log", false, true},
+		{"keep.log", false, false},
+		{"bin", true, true},
+		{"bin", false, false},
+		{"sub/bin", true, true},
+		{"vendor", true, true},
+		{"sub/vendor", true, false},
+		{"docs/a/b/x.tmp", false, true},
+		{"other/x.tmp", false, false},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := ignore.Ignored(tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%q, dir=%v) = %v, want %v", tt.rel, tt.isDir, got, tt.want)
+		}
+	}
+
+	if loadGitignore(t.TempDir()).Ignored("anything", false) {
+		t.Error("a directory without .gitignore ignores files")
+	}
+}
+
+func TestBuildDirectoryTree(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "project")
+	for _, file := range []string{"main.go", "README.md", "app.log", "image.png", "pkg/a.go", "pkg/deep/b.go", ".git/config"} {
+		path := filepath.Join(root, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.log\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		maxDepth   int
+		maxEntries int
+		cfg        *Config
+		want       string
+	}{
+		{
+			name:     "full tree",
+			maxDepth: 3, maxEntries: 100,
+			want: "project/\n  pkg/\n    deep/\n      b.go\n    a.go\n  .gitignore\n  README.md\n  image.png\n  main.go\n",
+		},
+		{
+			name:     "depth limit",
+			maxDepth: 1, maxEntries: 100,
+			want: "project/\n  pkg/ ...\n  .gitignore\n  README.md\n  image.png\n  main.go\n",
+		},
+		{
+			name:     "entry limit",
+			maxDepth: 3, maxEntries: 3,
+			want: "project/\n  pkg/\n    deep/\n      b.go\n... (truncated after 3 entries)\n",
+		},
+		{
+			name:     "allowed file types",
+			maxDepth: 3, maxEntries: 100,
+			cfg:  &Config{AllowedFileTypes: []string{"text/x-go"}},
+			want: "project/\n  pkg/\n    deep/\n      b.go\n    a.go\n  main.go\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildDirectoryTree(root, tt.maxDepth, tt.maxEntries, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("buildDirectoryTree() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := buildDirectoryTree(filepath.Join(root, "main.go"), 3, 100, nil); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Errorf("buildDirectoryTree(file) error = %v, want a not a directory error", err)
+	}
+	if _, err := buildDirectoryTree(filepath.Join(root, "missing"), 3, 100, nil); err == nil {
+		t.Error("buildDirectoryTree(missing) succeeded")
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cohesion-org/deepseek-go"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// usageDateLayout is the date format accepted by the deepseek_usage tool
+const usageDateLayout = "2006-01-02"
+
+// usageRecord is a single completion's token usage stored in the ledger
+type usageRecord struct {
+	Time             time.Time `json:"time"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	CacheHitTokens   int       `json:"cache_hit_tokens"`
+	Organization     string    `json:"organization,omitempty"`
+	Project          string    `json:"project,omitempty"`
+}
+
+// usageLedger records the token usage of every completion. Records are kept in memory
+// and, if a path is configured, appended to a JSON Lines file so they survive restarts.
+type usageLedger struct {
+	mu           sync.Mutex
+	path         string
+	organization string // Recorded with every new record for billing attribution
+	project      string
+	records      []usageRecord
+}
+
+// newUsageLedger creates a usage ledger, loading existing records from path if it is set.
+// New records are attributed to the given organization and project, which may be empty.
+func newUsageLedger(path, organization, project string) (*usageLedger, error) {
+	ledger := &usageLedger{path: path, organization: organization, project: project}
+	if path == "" {
+		return ledger, nil
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ledger, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var record usageRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			return nil, fmt.Errorf("failed to parse usage ledger %s: %w", path, err)
+		}
+		ledger.records = append(ledger.records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read usage ledger: %w", err)
+	}
+	return ledger, nil
+}
+
+// Record adds a completion's usage to the ledger
+func (l *usageLedger) Record(model string, usage deepseek.Usage) error {
+	record := usageRecord{
+		Time:             time.Now().UTC(),
+		Model:            model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		CacheHitTokens:   usage.PromptCacheHitTokens,
+		Organization:     l.organization,
+		Project:          l.project,
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.records = append(l.records, record)
+
+	if l.path == "" {
+		return nil
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage record: %w", err)
+	}
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open usage ledger: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write usage ledger: %w", err)
+	}
+	return nil
+}
+
+// usageRow aggregates usage for one day and model
+type usageRow struct {
+	Day              string
+	Model            string
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	CacheHitTokens   int
+}
+
+// Aggregate sums usage per day and model for records in [since, until)
+func (l *usageLedger) Aggregate(since, until time.Time) []usageRow {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rows := make(map[string]*usageRow)
+	for _, record := range l.records {
+		if record.Time.Before(since) || !record.Time.Before(until) {
+			continue
+		}
+		day := record.Time.Format(usageDateLayout)
+		key := day + "\x00" + record.Model
+		row, ok := rows[key]
+		if !ok {
+			row = &usageRow{Day: day, Model: record.Model}
+			rows[key] = row
+		}
+		row.Requests++
+		row.PromptTokens += record.PromptTokens
+		row.CompletionTokens += record.CompletionTokens
+		row.TotalTokens += record.TotalTokens
+		row.CacheHitTokens += record.CacheHitTokens
+	}
+
+	result := make([]usageRow, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, *row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Day != result[j].Day {
+			return result[i].Day < result[j].Day
+		}
+		return result[i].Model < result[j].Model
+	})
+	return result
+}
+
+// handleDeepseekUsage handles requests to the deepseek_usage tool
+func (s *DeepseekServer) handleDeepseekUsage(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.logger.Info("Reporting DeepSeek API usage")
+
+	now := time.Now().UTC()
+	since := now.AddDate(0, 0, -30).Truncate(24 * time.Hour)
+	if sinceStr := req.GetString("since", ""); sinceStr != "" {
+		parsed, err := time.Parse(usageDateLayout, sinceStr)
+		if err != nil {
+			return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid 'since' date %q: expected YYYY-MM-DD", sinceStr)), nil
+		}
+		since = parsed
+	}
+	until := now
+	if untilStr := req.GetString("until", ""); untilStr != "" {
+		parsed, err := time.Parse(usageDateLayout, untilStr)
+		if err != nil {
+			return toolError(ErrorCodeInvalidArgument, fmt.Sprintf("Invalid 'until' date %q: expected YYYY-MM-DD", untilStr)), nil
+		}
+		// The until date is inclusive
+		until = parsed.AddDate(0, 0, 1)
+	}
+	if !since.Before(until) {
+		return toolError(ErrorCodeInvalidArgument, "'since' must be before 'until'"), nil
+	}
+
+	rows := s.usage.Aggregate(since, until)
+
+	var formattedContent strings.Builder
+	formattedContent.WriteString("# DeepSeek API Usage\n\n")
+	formattedContent.WriteString(fmt.Sprintf("**Period:** %s to %s\n\n", since.Format(usageDateLayout), until.AddDate(0, 0, -1).Format(usageDateLayout)))
+
+	if len(rows) == 0 {
+		formattedContent.WriteString("*No usage recorded in this period*\n")
+	} else {
+		var total usageRow
+		formattedContent.WriteString("| Date | Model | Requests | Prompt Tokens | Completion Tokens | Total Tokens | Cache Hit Tokens |\n")
+		formattedContent.WriteString("|------|-------|----------|---------------|-------------------|--------------|------------------|\n")
+		for _, row := range rows {
+			formattedContent.WriteString(fmt.Sprintf("| %s | %s | %d | %d | %d | %d | %d |\n",
+				row.Day, row.Model, row.Requests, row.PromptTokens, row.CompletionTokens, row.TotalTokens, row.CacheHitTokens))
+			total.Requests += row.Requests
+			total.PromptTokens += row.PromptTokens
+			total.CompletionTokens += row.CompletionTokens
+			total.TotalTokens += row.TotalTokens
+			total.CacheHitTokens += row.CacheHitTokens
+		}
+		formattedContent.WriteString(fmt.Sprintf("| **Total** | | %d | %d | %d | %d | %d |\n",
+			total.Requests, total.PromptTokens, total.CompletionTokens, total.TotalTokens, total.CacheHitTokens))
+	}
+
+	formattedContent.WriteString("\n## Note\n\n")
+	if s.config().UsageLedgerPath == "" {
+		formattedContent.WriteString("*Usage is recorded locally by this server and only covers requests made since it started. ")
+		formattedContent.WriteString("Set DEEPSEEK_USAGE_LEDGER to persist usage across restarts.*\n")
+	} else {
+		formattedContent.WriteString("*Usage is recorded locally by this server and does not include requests made by other clients.*\n")
+	}
+
+	return mcp.NewToolResultText(formattedContent.String()), nil
+}
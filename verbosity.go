@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Answer lengths supported by the verbosity parameter
+const (
+	VerbosityConcise  = "concise"
+	VerbosityNormal   = "normal"
+	VerbosityDetailed = "detailed"
+)
+
+// conciseMaxTokens caps the completion of concise answers from models that do not reason.
+// Reasoning models are not capped, since their hidden reasoning counts towards the limit.
+const conciseMaxTokens = 1024
+
+// verbosityInstructions are the system prompt instructions of each verbosity. Normal adds none.
+var verbosityInstructions = map[string]string{
+	VerbosityConcise: "Answer concisely. Start with the direct answer, skip preamble, restating the question and closing summaries, " +
+		"and only add explanation that is needed to act on the answer.",
+	VerbosityDetailed: "Answer thoroughly. Explain your reasoning step by step, cover relevant edge cases, alternatives and trade-offs, " +
+		"and include examples where they help.",
+}
+
+// isValidVerbosity reports whether verbosity is a supported answer length
+func isValidVerbosity(verbosity string) bool {
+	switch verbosity {
+	case VerbosityConcise, VerbosityNormal, VerbosityDetailed:
+		return true
+	}
+	return false
+}
+
+// lookupVerbosity validates a verbosity parameter, defaulting to normal when empty
+func lookupVerbosity(verbosity string) (string, error) {
+	verbosity = strings.ToLower(strings.TrimSpace(verbosity))
+	if verbosity == "" {
+		return VerbosityNormal, nil
+	}
+	if !isValidVerbosity(verbosity) {
+		return "", fmt.Errorf("unsupported verbosity %q, supported values are %q, %q and %q",
+			verbosity, VerbosityConcise, VerbosityNormal, VerbosityDetailed)
+	}
+	return verbosity, nil
+}
+
+// withVerbosity appends the instruction for the given verbosity to the system prompt
+func withVerbosity(systemPrompt, verbosity string) string {
+	instruction, ok := verbosityInstructions[verbosity]
+	if !ok {
+		return systemPrompt
+	}
+	if strings.TrimSpace(systemPrompt) == "" {
+		return instruction
+	}
+	return strings.TrimRight(systemPrompt, "\n") + "\n\n" + instruction
+}
+
+// softMaxTokensTarget is the share of a soft token budget the model is asked to aim for, leaving
+// headroom so that an answer running slightly long still ends before the hard limit
+const softMaxTokensTarget = 0.8
+
+// withSoftMaxTokens appends an instruction to the system prompt asking the model to plan an
+// answer that concludes within a token budget, rather than being cut off by max_tokens
+func withSoftMaxTokens(systemPrompt string, budget int) string {
+	target := int(float64(budget) * softMaxTokensTarget)
+	instruction := fmt.Sprintf("Keep your answer under about %d tokens (roughly %d words). Plan its length up front "+
+		"so that it ends with a complete sentence and a brief conclusion instead of being cut off; leave out less "+
+		"important details rather than stopping mid-way.", target, target*3/4)
+	if strings.TrimSpace(systemPrompt) == "" {
+		return instruction
+	}
+	return strings.TrimRight(systemPrompt, "\n") + "\n\n" + instruction
+}
+
+// softMaxTokensLimit returns the max_tokens sent for a soft token budget. Reasoning models get
+// the reasoning reserve on top, since their hidden reasoning counts towards the limit.
+func (c *Config) softMaxTokensLimit(budget int, modelID string) int {
+	if c.isReasoningModel(modelID) {
+		return budget + c.ReasoningTokenReserve
+	}
+	return budget
+}
+
+// verbosityMaxTokens returns the completion token limit for a verbosity, or 0 for no limit
+func (c *Config) verbosityMaxTokens(verbosity, modelID string) int {
+	if verbosity == VerbosityConcise && !c.isReasoningModel(modelID) {
+		return conciseMaxTokens
+	}
+	return 0
+}
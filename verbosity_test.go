@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWithSoftMaxTokens(t *testing.T) {
+	tests := []struct {
+		name         string
+		systemPrompt string
+		budget       int
+		wantPrefix   string
+		wantTarget   string
+	}{
+		{"empty system prompt", "", 1000, "Keep your answer under about 800 tokens", "(roughly 600 words)"},
+		{"appended to system prompt", "You are a reviewer.\n", 500, "You are a reviewer.\n\nKeep your answer under about 400 tokens", "(roughly 300 words)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withSoftMaxTokens(tt.systemPrompt, tt.budget)
+			if !strings.HasPrefix(got, tt.wantPrefix) || !strings.Contains(got, tt.wantTarget) {
+				t.Errorf("withSoftMaxTokens() = %q, want prefix %q and %q", got, tt.wantPrefix, tt.wantTarget)
+			}
+		})
+	}
+}
+
+func TestAskSoftMaxTokens(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          map[string]any
+		wantMaxTokens int
+		wantPrompt    string
+		wantError     string
+	}{
+		{
+			name:          "chat model",
+			args:          map[string]any{"query": "q", "model": "deepseek-chat", "soft_max_tokens": float64(500)},
+			wantMaxTokens: 500,
+			wantPrompt:    "Keep your answer under about 400 tokens",
+		},
+		{
+			name:          "reasoning model gets the reserve",
+			args:          map[string]any{"query": "q", "model": "deepseek-reasoner", "soft_max_tokens": float64(500)},
+			wantMaxTokens: 500 + 8192,
+			wantPrompt:    "Keep your answer under about 400 tokens",
+		},
+		{
+			name:          "precedence over the concise cap",
+			args:          map[string]any{"query": "q", "model": "deepseek-chat", "verbosity": "concise", "soft_max_tokens": float64(3000)},
+			wantMaxTokens: 3000,
+			wantPrompt:    "Keep your answer under about 2400 tokens",
+		},
+		{
+			name:          "concise cap without a budget",
+			args:          map[string]any{"query": "q", "model": "deepseek-chat", "verbosity": "concise"},
+			wantMaxTokens: conciseMaxTokens,
+		},
+		{
+			name:      "negative",
+			args:      map[string]any{"query": "q", "soft_max_tokens": float64(-1)},
+			wantError: "Invalid soft_max_tokens: -1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockDeepseekClient{}
+			s := newTestServer(t, client, nil)
+			result := callTool(t, s.handleAskDeepseek, tt.args)
+			if tt.wantError != "" {
+				if !result.IsError || !strings.Contains(resultText(result), tt.wantError) {
+					t.Errorf("result = %q, want error %q", resultText(result), tt.wantError)
+				}
+				return
+			}
+			request := client.requests[0]
+			if request.MaxTokens != tt.wantMaxTokens {
+				t.Errorf("max_tokens = %d, want %d", request.MaxTokens, tt.wantMaxTokens)
+			}
+			if systemPrompt := request.Messages[0].Content; !strings.Contains(systemPrompt, tt.wantPrompt) {
+				t.Errorf("system prompt %q does not contain %q", systemPrompt, tt.wantPrompt)
+			}
+		})
+	}
+}
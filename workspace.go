@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/cohesion-org/deepseek-go"
+	mcp "github.com/mark3labs/mcp-go/mcp"
+)
+
+// workspaceDiffInstruction asks the model to answer with a multi-file diff for workspace_files
+const workspaceDiffInstruction = "Answer only with a unified diff in git format that implements the request, " +
+	"ready to be applied with `git apply`. For each changed file write `diff --git a/<path> b/<path>`, " +
+	"`--- a/<path>` and `+++ b/<path>` headers followed by hunks with correct line counts and three lines of context. " +
+	"Use exactly the paths given in the file headers and only change the files listed there. " +
+	"Do not add explanations or code fences around the diff."
+
+// workspaceFile is a file given in workspace_files, with its path relative to the workspace root
+type workspaceFile struct {
+	Path     string // Path relative to the workspace root, as used in the diff
+	Content  []byte
+	Language string
+}
+
+// readWorkspaceFiles validates and reads the files of a workspace_files request. Unlike
+// file_paths, any file that cannot be read fails the request, since a diff against a partial
+// workspace would be misleading. Paths are made relative to the deepest directory containing
+// all of them, which is returned as the workspace root.
+func readWorkspaceFiles(paths []string, cfg *Config, cache *fileCache) (string, []workspaceFile, error) {
+	paths, err := expandPaths(paths)
+	if err != nil {
+		return "", nil, err
+	}
+
+	absPaths := make([]string, 0, len(paths))
+	for _, path := range paths {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid path %s: %w", path, err)
+		}
+		absPaths = append(absPaths, absPath)
+	}
+	root := commonDir(absPaths)
+
+	files := make([]workspaceFile, 0, len(absPaths))
+	seen := make(map[string]bool)
+	for _, absPath := range absPaths {
+		result := readValidatedFile(absPath, cfg, cache)
+		if result.Err != nil {
+			return "", nil, result.Err
+		}
+		relPath, err := filepath.Rel(root, absPath)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid path %s: %w", absPath, err)
+		}
+		relPath = filepath.ToSlash(relPath)
+		if seen[relPath] {
+			continue
+		}
+		seen[relPath] = true
+
+		language := result.Language
+		if language == "" {
+			language = cfg.languageForFile(absPath, result.Content)
+		}
+		files = append(files, workspaceFile{Path: relPath, Content: result.Content, Language: language})
+	}
+	return root, files, nil
+}
+
+// commonDir returns the deepest directory containing all of the given absolute paths
+func commonDir(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	root := filepath.Dir(paths[0])
+	for _, path := range paths[1:] {
+		for !pathWithinRoot(root, path, runtime.GOOS == "windows") {
+			parent := filepath.Dir(root)
+			if parent == root {
+				break
+			}
+			root = parent
+		}
+	}
+	return root
+}
+
+// formatWorkspaceFiles formats the workspace files for the query under clear path headers.
+// With a boundary, each file is delimited as untrusted data like the files of file_paths.
+func formatWorkspaceFiles(files []workspaceFile, boundary string) string {
+	var sb strings.Builder
+	sb.WriteString("\n\n# Workspace Files\n")
+	for _, file := range files {
+		rendered := fmt.Sprintf("\n## File: %s\n\n```%s\n%s\n```\n", file.Path, file.Language, file.Content)
+		if boundary != "" {
+			rendered = wrapUntrusted(rendered, file.Path, boundary) + "\n"
+		}
+		sb.WriteString(rendered)
+	}
+	return sb.String()
+}
+
+// cleanDiff removes a code fence the model may have wrapped around a diff despite instructions
+func cleanDiff(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if !strings.HasPrefix(trimmed, "```") {
+		return content
+	}
+	lines := strings.Split(trimmed, "\n")
+	if len(lines) < 2 || strings.TrimSpace(lines[len(lines)-1]) != "```" {
+		return content
+	}
+	return strings.Join(lines[1:len(lines)-1], "\n") + "\n"
+}
+
+// diffPaths returns the file paths referenced by the headers of a unified diff, without
+// their a/ and b/ prefixes. /dev/null, used for created and deleted files, is left out.
+func diffPaths(diff string) []string {
+	seen := make(map[string]bool)
+	add := func(path string) {
+		path = strings.TrimSpace(path)
+		if tab := strings.IndexByte(path, '\t'); tab >= 0 {
+			path = path[:tab] // Timestamps may follow the path
+		}
+		if path == "" || path == "/dev/null" {
+			return
+		}
+		if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+			path = path[2:]
+		}
+		seen[path] = true
+	}
+
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			if a, b, ok := strings.Cut(strings.TrimPrefix(line, "diff --git "), " b/"); ok {
+				add(a)
+				add(b)
+			}
+		case strings.HasPrefix(line, "--- "):
+			add(strings.TrimPrefix(line, "--- "))
+		case strings.HasPrefix(line, "+++ "):
+			add(strings.TrimPrefix(line, "+++ "))
+		}
+	}
+
+	paths := make([]string, 0, len(seen))
+	for path := range seen {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// validateWorkspaceDiff checks that a diff only touches the given workspace files
+func validateWorkspaceDiff(diff string, files []workspaceFile) error {
+	allowed := make(map[string]bool, len(files))
+	for _, file := range files {
+		allowed[file.Path] = true
+	}
+
+	paths := diffPaths(diff)
+	if len(paths) == 0 {
+		return fmt.Errorf("the response does not contain a unified diff")
+	}
+	var unknown []string
+	for _, path := range paths {
+		if !allowed[path] {
+			unknown = append(unknown, path)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("the diff references files that were not provided: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// askWorkspaceDiff asks the model for a multi-file diff against workspace_files and returns the
+// diff verbatim, after checking that it only touches the provided files
+func (s *DeepseekServer) askWorkspaceDiff(ctx context.Context, query, systemPrompt, modelName string, paths []string) *mcp.CallToolResult {
+	cfg := s.configFrom(ctx)
+	root, files, err := readWorkspaceFiles(paths, cfg, s.fileCache)
+	if err != nil {
+		s.logger.Error("Failed to read workspace_files: %v", err)
+		return toolError(fileErrorCode(err), fmt.Sprintf("Failed to read workspace_files: %v", err))
+	}
+	for i := range files {
+		files[i].Content = s.redactFileContent(cfg, files[i].Path, files[i].Content)
+	}
+	s.logger.Info("Requesting a diff against %d workspace file(s) under %s", len(files), root)
+
+	// Delimit files as untrusted data, and tell the model not to follow instructions in them
+	var untrustedBoundary string
+	if cfg.MitigatePromptInjection {
+		if untrustedBoundary, err = newUntrustedBoundary(); err != nil {
+			s.logger.Error("%v", err)
+			return toolError(ErrorCodeInternal, fmt.Sprintf("Failed to prepare the request: %v", err))
+		}
+		systemPrompt = withUntrustedDataNote(systemPrompt, untrustedBoundary)
+		for _, file := range files {
+			if phrases := scanForInjection(file.Content); len(phrases) > 0 {
+				s.logger.Warn("Possible prompt injection in %s: %q", file.Path, phrases)
+			}
+		}
+	}
+
+	requestPayload := &deepseek.ChatCompletionRequest{
+		Model: modelName,
+		Messages: []deepseek.ChatCompletionMessage{
+			{Role: deepseek.ChatMessageRoleSystem, Content: strings.TrimRight(systemPrompt, "\n") + "\n\n" + workspaceDiffInstruction},
+			{Role: deepseek.ChatMessageRoleUser, Content: query + formatWorkspaceFiles(files, untrustedBoundary)},
+		},
+		Temperature: s.requestTemperature(cfg, modelName),
+	}
+
+	response, err := s.createChatCompletion(ctx, requestPayload)
+	if err != nil {
+		s.logger.Error("DeepSeek API error: %v", err)
+		return toolError(apiErrorCode(err), fmt.Sprintf("Error from DeepSeek API: %v", err))
+	}
+	if len(response.Choices) == 0 || strings.TrimSpace(response.Choices[0].Message.Content) == "" {
+		return toolError(ErrorCodeInvalidResponse, "The DeepSeek model returned an empty response instead of a diff.")
+	}
+
+	diff := cleanDiff(response.Choices[0].Message.Content)
+	if err := validateWorkspaceDiff(diff, files); err != nil {
+		s.logger.Warn("Rejecting workspace diff: %v", err)
+		return toolError(ErrorCodeInvalidResponse, fmt.Sprintf("The model did not return a usable diff: %v. Response:\n\n%s", err, diff))
+	}
+	return mcp.NewToolResultText(diff)
+}